@@ -9,33 +9,41 @@ import (
 	"github.com/mskKote/prospero_backend/internal/adapters/db/elastic/v8/articlesSearchRepository"
 	"github.com/mskKote/prospero_backend/internal/adapters/db/elastic/v8/publisherSearchRepository"
 	"github.com/mskKote/prospero_backend/internal/adapters/db/postgres/adminsRepository"
+	"github.com/mskKote/prospero_backend/internal/adapters/db/postgres/articlesRepository"
+	"github.com/mskKote/prospero_backend/internal/adapters/db/postgres/harvestRunsRepository"
 	"github.com/mskKote/prospero_backend/internal/adapters/db/postgres/publishersRepository"
+	"github.com/mskKote/prospero_backend/internal/adapters/db/postgres/retentionRunsRepository"
 	"github.com/mskKote/prospero_backend/internal/adapters/db/postgres/sourcesRepository"
 	internalMetrics "github.com/mskKote/prospero_backend/internal/adapters/metrics"
 	"github.com/mskKote/prospero_backend/internal/controller/http/v1/routes"
 	"github.com/mskKote/prospero_backend/internal/domain/entity/admin"
 	"github.com/mskKote/prospero_backend/internal/domain/service/adminService"
 	"github.com/mskKote/prospero_backend/internal/domain/service/articleService"
+	"github.com/mskKote/prospero_backend/internal/domain/service/catalogueService"
+	"github.com/mskKote/prospero_backend/internal/domain/service/harvestService"
+	"github.com/mskKote/prospero_backend/internal/domain/service/importService"
 	"github.com/mskKote/prospero_backend/internal/domain/service/publishersService"
+	"github.com/mskKote/prospero_backend/internal/domain/service/retentionService"
 	"github.com/mskKote/prospero_backend/internal/domain/service/sourcesService"
 	"github.com/mskKote/prospero_backend/internal/domain/usecase/RSS"
 	"github.com/mskKote/prospero_backend/internal/domain/usecase/adminka"
+	retentionUsecase "github.com/mskKote/prospero_backend/internal/domain/usecase/retention"
 	"github.com/mskKote/prospero_backend/internal/domain/usecase/search"
 	"github.com/mskKote/prospero_backend/internal/domain/usecase/service"
 	"github.com/mskKote/prospero_backend/pkg/client/elastic"
 	"github.com/mskKote/prospero_backend/pkg/client/postgres"
+	"github.com/mskKote/prospero_backend/pkg/client/rss"
 	"github.com/mskKote/prospero_backend/pkg/config"
 	"github.com/mskKote/prospero_backend/pkg/logging"
 	pkgMetrics "github.com/mskKote/prospero_backend/pkg/metrics"
+	"github.com/mskKote/prospero_backend/pkg/migrate"
 	"github.com/mskKote/prospero_backend/pkg/security"
 	"github.com/mskKote/prospero_backend/pkg/tracing"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 	"github.com/uptrace/opentelemetry-go-extra/otelzap"
 	"go.uber.org/zap"
-	"io"
 	"log"
-	"os"
 	"time"
 )
 
@@ -75,13 +83,22 @@ func startup(cfg *config.Config) {
 	}
 
 	sourcesREPO := sourcesRepository.New(pgClient)
+	articlesPgREPO := articlesRepository.New(pgClient)
 	articlesREPO := articlesSearchRepository.New(esClient)
 	publishersREPO := publishersRepository.New(pgClient)
 	publishersSearchREPO := publishersSearchRepository.New(esClient)
 
 	publishersSERVICE := publishersService.New(publishersREPO, publishersSearchREPO)
-	articlesSERVICE := articleService.New(sourcesREPO, articlesREPO)
-	sourcesSERVICE := sourcesService.New(sourcesREPO)
+	feeds := rss.NewClient()
+	articlesSERVICE := articleService.New(sourcesREPO, articlesPgREPO, articlesREPO, feeds)
+	defer articlesSERVICE.Close()
+	sourcesSERVICE := sourcesService.New(sourcesREPO, feeds)
+	importSERVICE := importService.New(pgClient)
+	harvestRunsREPO := harvestRunsRepository.New(pgClient)
+	harvestSERVICE := harvestService.New(sourcesSERVICE, articlesSERVICE, harvestRunsREPO)
+	catalogueSERVICE := catalogueService.New(pgClient, publishersSearchREPO, cfg.CataloguePath)
+	retentionRunsREPO := retentionRunsRepository.New(pgClient)
+	retentionSERVICE := retentionService.New(articlesPgREPO, articlesREPO, retentionRunsREPO)
 
 	if cfg.MigratePostgres {
 		migrationsPg(pgClient, ctx)
@@ -90,6 +107,27 @@ func startup(cfg *config.Config) {
 		migrationsEs(articlesREPO, publishersSearchREPO, ctx)
 	}
 
+	// Запуски, оборванные прошлым выключением, уже не завершатся
+	if aborted, err := harvestRunsREPO.AbortRunning(ctx); err != nil {
+		logger.Error("[POSTGRES] Не закрыли оборванные запуски парсинга", zap.Error(err))
+	} else if aborted > 0 {
+		logger.Warn(fmt.Sprintf("[POSTGRES] Закрыли оборванные запуски парсинга: %d", aborted))
+	}
+	if aborted, err := retentionRunsREPO.AbortRunning(ctx); err != nil {
+		logger.Error("[POSTGRES] Не закрыли оборванные очистки", zap.Error(err))
+	} else if aborted > 0 {
+		logger.Warn(fmt.Sprintf("[POSTGRES] Закрыли оборванные очистки: %d", aborted))
+	}
+
+	if cfg.CatalogueOnStartup {
+		if plan, err := catalogueSERVICE.Apply(ctx, cfg.CataloguePrune); err != nil {
+			logger.Error("[CATALOGUE] Каталог применён не полностью", zap.Error(err))
+		} else {
+			logger.Info(fmt.Sprintf("[CATALOGUE] Применили каталог: изменений %d, вне каталога %d",
+				len(plan.Changes), len(plan.Unmanaged)))
+		}
+	}
+
 	// --------------------------------------- GIN
 	r := gin.New()
 	if cfg.IsDebug == false {
@@ -148,15 +186,16 @@ func startup(cfg *config.Config) {
 	// --------------------------------------- ROUTES
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 	prosperoRoutes(r, &publishersSERVICE, &articlesSERVICE)
-	adminkaStartup(r, pgClient, &sourcesSERVICE, &publishersSERVICE, &articlesSERVICE)
+	adminkaStartup(r, pgClient, &sourcesSERVICE, &publishersSERVICE, &articlesSERVICE, &harvestSERVICE, &importSERVICE, &catalogueSERVICE, &retentionSERVICE)
 	serviceRoutes(r)
 
 	logger.Info(fmt.Sprintf("adminkaStartup: %t", cfg.MigratePostgres))
 
 	// --------------------------------------- IGNITION
 	if cfg.UseCronSourcesRSS {
-		go RSS.New(sourcesSERVICE, articlesSERVICE).Startup()
+		go RSS.New(sourcesSERVICE, articlesSERVICE, harvestSERVICE).Startup()
 	}
+	go retentionUsecase.New(retentionSERVICE).Startup()
 
 	if err := r.Run(":" + cfg.Port); err != nil {
 		logger.Fatal("ошибка, завершаем программу", zap.Error(err))
@@ -164,24 +203,11 @@ func startup(cfg *config.Config) {
 }
 
 func migrationsPg(client postgres.Client, ctx context.Context) {
-	migration, err := os.OpenFile("./resources/migration_20230517_1.sql", os.O_RDONLY, 0666)
-	if err != nil {
-		logger.Fatal("[MIGRATION] Невозможно прочитать файл", zap.Error(err))
-	}
-	defer func(migration *os.File) {
-		err := migration.Close()
-		if err != nil {
-			logger.Fatal("[MIGRATION] Невозможно закрыть файл миграции", zap.Error(err))
-		}
-	}(migration)
-
-	data, err := io.ReadAll(migration)
-	_, err = client.Exec(ctx, string(data))
+	versions, err := migrate.New(client, cfg.MigrationsPath).Up(ctx)
 	if err != nil {
 		logger.Fatal("[MIGRATION] Миграции POSTGRES провалились", zap.Error(err))
-	} else {
-		logger.Info("[MIGRATION] УСПЕШНО мигрировали POSTGRES")
 	}
+	logger.Info(fmt.Sprintf("[MIGRATION] УСПЕШНО мигрировали POSTGRES, применили версии %v", versions))
 }
 
 func migrationsEs(
@@ -199,13 +225,17 @@ func adminkaStartup(
 	client postgres.Client,
 	s *sourcesService.ISourceService,
 	p *publishersService.IPublishersService,
-	a *articleService.IArticleService) {
+	a *articleService.IArticleService,
+	h *harvestService.IHarvestService,
+	i *importService.IImportService,
+	cat *catalogueService.ICatalogueService,
+	ret *retentionService.IRetentionService) {
 
 	adminREPO := adminsRepository.New(client)
 	adminSERVICE := adminService.New(adminREPO)
-	adminkaUSECASE := adminka.New(s, p, a)
+	adminkaUSECASE := adminka.New(s, p, a, h, i, cat, ret)
 
-	// Админ
+	// Админ из .env - сид: создаётся или получает пароль из .env заново
 	if cfg.MigratePostgres {
 		adminMskKote := &admin.DTO{
 			Name:     cfg.Adminka.Username,
@@ -232,6 +262,7 @@ func adminkaStartup(
 		adminkaApiV1 := adminkaGroup.Group("api/v1")
 		routes.RegisterSourcesRoutes(adminkaApiV1, adminkaUSECASE)
 		routes.RegisterPublishersRoutes(adminkaApiV1, adminkaUSECASE)
+		routes.RegisterRetentionRoutes(adminkaApiV1, adminkaUSECASE)
 	}
 
 	r.NoRoute(auth.MiddlewareFunc(), security.NoRoute)
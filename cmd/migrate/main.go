@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/mskKote/prospero_backend/internal/adapters/db/elastic/v8/articlesSearchRepository"
+	"github.com/mskKote/prospero_backend/internal/adapters/db/elastic/v8/publisherSearchRepository"
+	"github.com/mskKote/prospero_backend/internal/adapters/db/postgres/adminsRepository"
+	"github.com/mskKote/prospero_backend/internal/adapters/db/postgres/articlesRepository"
+	"github.com/mskKote/prospero_backend/internal/adapters/db/postgres/reindexRunsRepository"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/admin"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/reindex"
+	"github.com/mskKote/prospero_backend/internal/domain/service/adminService"
+	"github.com/mskKote/prospero_backend/internal/domain/service/catalogueService"
+	"github.com/mskKote/prospero_backend/internal/domain/service/reindexService"
+	"github.com/mskKote/prospero_backend/pkg/client/elastic"
+	"github.com/mskKote/prospero_backend/pkg/client/postgres"
+	"github.com/mskKote/prospero_backend/pkg/config"
+	"github.com/mskKote/prospero_backend/pkg/migrate"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const usage = `Использование: migrate <команда>
+  status              версии схемы и применены ли они
+  up                  применить все новые версии
+  down [n]            откатить n последних версий, по умолчанию 1
+  seed                админ из .env и каталог издателей (catalogue_path)
+  reindex [restart]   собрать индексы статей, категорий и людей из postgres,
+                      продолжая прерванную переиндексацию; restart - заново
+  es-status           версии индексов Elasticsearch за алиасами и партиции статей
+  es-upgrade [alias]  перенести индексы (или один) в новую версию маппинга,
+                      статьи - в партиции по дате публикации
+  es-legacy-ids       заменить статьи со случайными ID ES (до StableID) на статьи
+                      со StableID по ссылке, копии уже записанных - удалить`
+
+var cfg = config.GetConfig()
+
+// Миграции схемы Postgres и обслуживание индексов ES отдельно от сервиса.
+// Запускается из каталога с app.yml и .env, как и сам сервис
+func main() {
+	if len(os.Args) < 2 {
+		fail(usage)
+	}
+
+	ctx := context.Background()
+	if strings.HasPrefix(os.Args[1], "es-") {
+		elasticIndices(ctx, os.Args[1], os.Args[2:])
+		return
+	}
+
+	pgClient, err := postgres.NewClient(ctx, 3)
+	if err != nil {
+		fail(err.Error())
+	}
+	defer pgClient.Close()
+	migrator := migrate.New(pgClient, cfg.MigrationsPath)
+
+	switch os.Args[1] {
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			fail(err.Error())
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "VERSION\tNAME\tSTATE\tAPPLIED AT")
+		for _, s := range statuses {
+			state, at := "pending", ""
+			if s.Applied {
+				state, at = "applied", s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if s.Changed {
+				state += ", file changed"
+			}
+			if s.Missing {
+				state += ", file missing"
+			}
+			_, _ = fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", s.Version, s.Name, state, at)
+		}
+		_ = w.Flush()
+
+	case "up":
+		versions, err := migrator.Up(ctx)
+		fmt.Println("Применили:", versions)
+		if err != nil {
+			fail(err.Error())
+		}
+
+	case "down":
+		steps := 1
+		if len(os.Args) > 2 {
+			if steps, err = strconv.Atoi(os.Args[2]); err != nil || steps < 1 {
+				fail(usage)
+			}
+		}
+		versions, err := migrator.Down(ctx, steps)
+		fmt.Println("Откатили:", versions)
+		if err != nil {
+			fail(err.Error())
+		}
+
+	case "seed":
+		seed(ctx, pgClient)
+
+	case "reindex":
+		reindexFromPostgres(ctx, pgClient, len(os.Args) > 2 && os.Args[2] == "restart")
+
+	default:
+		fail(usage)
+	}
+}
+
+// seed - данные, которые не относятся к схеме. Повторный запуск безопасен
+func seed(ctx context.Context, pgClient postgres.Client) {
+	err := adminService.New(adminsRepository.New(pgClient)).Create(ctx, &admin.DTO{
+		Name:     cfg.Adminka.Username,
+		Password: cfg.Adminka.Password,
+	})
+	if err != nil {
+		fail(err.Error())
+	}
+	fmt.Println("Админ:", cfg.Adminka.Username)
+
+	esClient, err := elastic.NewClient(ctx)
+	if err != nil {
+		fail(err.Error())
+	}
+	catalogue := catalogueService.New(pgClient, publishersSearchRepository.New(esClient), cfg.CataloguePath)
+	plan, err := catalogue.Apply(ctx, cfg.CataloguePrune)
+	if plan != nil {
+		for _, c := range plan.Changes {
+			fmt.Println(c.Store, c.Action, c.Kind, c.Publisher, c.RssURL)
+		}
+	}
+	if err != nil {
+		fail(err.Error())
+	}
+}
+
+// reindexFromPostgres пишет статьи из postgres в текущие индексы ES
+func reindexFromPostgres(ctx context.Context, pgClient postgres.Client, restart bool) {
+	esClient, err := elastic.NewClient(ctx)
+	if err != nil {
+		fail(err.Error())
+	}
+	reindexer := reindexService.New(
+		articlesRepository.New(pgClient),
+		reindexRunsRepository.New(pgClient),
+		articlesSearchRepository.New(esClient))
+
+	started := time.Now()
+	run, err := reindexer.Reindex(ctx, restart, func(run reindex.Run) {
+		percent := 100.0
+		if run.Total > 0 {
+			percent = float64(run.Done) * 100 / float64(run.Total)
+		}
+		fmt.Printf("%d/%d (%.1f%%), ошибок %d, %s\n",
+			run.Done, run.Total, percent, run.Failed, time.Since(started).Round(time.Second))
+	})
+	if run != nil {
+		fmt.Println("Переиндексация", run.ID+":", run.Status)
+	}
+	if err != nil {
+		fail(err.Error())
+	}
+}
+
+// elasticIndices - версии индексов ES: те же описания, что при старте сервиса
+func elasticIndices(ctx context.Context, command string, args []string) {
+	esClient, err := elastic.NewClient(ctx)
+	if err != nil {
+		fail(err.Error())
+	}
+	articles := articlesSearchRepository.New(esClient)
+	partitioned := articles.Partitioned()
+	versions := append(articles.Versions(), publishersSearchRepository.New(esClient).Version())
+	indices := elastic.NewIndices(esClient)
+
+	switch command {
+	case "es-status":
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "ALIAS\tREAD\tWRITE\tWANTED\tDOCS\tSTATE")
+		for _, v := range versions {
+			s, err := indices.State(ctx, v)
+			if err != nil {
+				fail(err.Error())
+			}
+			state := "ok"
+			switch {
+			case s.MappingChanged:
+				state = "mapping changed, bump version"
+			case s.Read == "":
+				state = "missing"
+			case s.Outdated():
+				state = "outdated"
+			}
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\n", s.Alias, s.Read, s.Write, s.Wanted, s.Docs, state)
+		}
+		_ = w.Flush()
+
+		// Статьи - партиции по времени за шаблоном
+		s, err := indices.PartitionedState(ctx, partitioned)
+		if err != nil {
+			fail(err.Error())
+		}
+		state := "ok"
+		switch {
+		case s.MappingChanged:
+			state = "mapping changed, bump version"
+		case !s.TemplateExists:
+			state = "missing"
+		case s.Outdated():
+			state = "outdated"
+		}
+		fmt.Printf("\n%s: template %s (%s), docs %d\n", s.Alias, s.Template, state, s.Docs)
+		if len(s.Legacy) > 0 {
+			fmt.Println("legacy:", strings.Join(s.Legacy, ", "))
+		}
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "PARTITION\tFROM\tTO\tDOCS\tSTATE")
+		for _, p := range s.Partitions {
+			state := "open"
+			if p.Closed {
+				state = "closed"
+			}
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n",
+				p.Index, p.Start.Format(time.DateOnly), p.End.Format(time.DateOnly), p.Docs, state)
+		}
+		_ = w.Flush()
+
+	case "es-upgrade":
+		for _, v := range versions {
+			if len(args) > 0 && args[0] != v.Alias {
+				continue
+			}
+			s, err := indices.Upgrade(ctx, v)
+			if err != nil {
+				fail(v.Alias + ": " + err.Error())
+			}
+			fmt.Println(s.Alias, "->", s.Read)
+		}
+		if len(args) == 0 || args[0] == partitioned.Alias {
+			s, err := indices.UpgradePartitioned(ctx, partitioned)
+			if err != nil {
+				fail(partitioned.Alias + ": " + err.Error())
+			}
+			fmt.Println(s.Alias, "->", s.Template, "партиций", len(s.Partitions))
+		}
+
+	case "es-legacy-ids":
+		stats, err := articles.ReplaceLegacyIDs(ctx)
+		if stats != nil {
+			fmt.Printf("Просмотрено %d, переписано %d, удалено %d, не заменено %d\n",
+				stats.Scanned, stats.Rekeyed, stats.Deleted, stats.Failed)
+		}
+		if err != nil {
+			fail(err.Error())
+		}
+
+	default:
+		fail(usage)
+	}
+}
+
+func fail(message string) {
+	_, _ = fmt.Fprintln(os.Stderr, message)
+	os.Exit(1)
+}
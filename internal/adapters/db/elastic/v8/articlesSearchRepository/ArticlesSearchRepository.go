@@ -3,39 +3,65 @@ package articlesSearchRepository
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/core/bulk"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/core/closepointintime"
 	"github.com/elastic/go-elasticsearch/v8/typedapi/core/search"
 	"github.com/elastic/go-elasticsearch/v8/typedapi/indices/create"
 	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
-	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/fieldsortnumerictype"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/conflicts"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/highlighterencoder"
 	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/operator"
 	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/optype"
 	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/result"
-	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/sortorder"
 	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/tokenchar"
+	customMetrics "github.com/mskKote/prospero_backend/internal/adapters/metrics"
 	"github.com/mskKote/prospero_backend/internal/controller/http/v1/dto"
 	"github.com/mskKote/prospero_backend/internal/domain/entity/article"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/catalogue"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/retention"
+	"github.com/mskKote/prospero_backend/pkg/client/elastic"
+	"github.com/mskKote/prospero_backend/pkg/config"
 	"github.com/mskKote/prospero_backend/pkg/lib"
 	"github.com/mskKote/prospero_backend/pkg/logging"
+	"github.com/mskKote/prospero_backend/pkg/metrics"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"hash/fnv"
-	"log"
 	"net/http"
 	"strings"
+	"time"
 )
 
+// Индексы - алиасы для чтения. Физические индексы версионированы
+// (category_v1, category_v2, ...), запись идёт через алиасы *_write.
+// Статьи лежат в партициях по времени (article_v2_2024.05), см. ArticleIndexFor
 const (
 	ArticleIndex  = "article"
 	CategoryIndex = "category"
 	PeopleIndex   = "people"
+
+	CategoryWriteIndex = CategoryIndex + "_write"
+	PeopleWriteIndex   = PeopleIndex + "_write"
+)
+
+// Версии маппингов: при изменении маппинга версию нужно поднять,
+// иначе Setup откажется запускаться
+const (
+	articleVersion  = 3
+	categoryVersion = 1
+	peopleVersion   = 1
 )
 
 var (
 	logger  = logging.GetLogger().With(zap.String("prefix", "[ES]"))
+	cfg     = config.GetConfig()
 	Indices = [...]string{ArticleIndex, CategoryIndex, PeopleIndex}
+
+	ErrNoArticleID = errors.New("у статьи нет ID")
 )
 
 type repository struct {
@@ -47,89 +73,70 @@ func New(client *elasticsearch.TypedClient) IRepository {
 }
 
 func (r *repository) Setup(ctx context.Context) {
-	// 1. Создать индексы
-	for _, index := range Indices {
-		if r.Exists(ctx, index) {
-			logger.Info(fmt.Sprintf("Индекс %s уже существует, удаляем", index))
-			r.Delete(ctx, index)
+	indices := elastic.NewIndices(r.client)
+	// Данные не удаляем: новая версия индекса заполняется из старой
+	if _, err := indices.EnsurePartitioned(ctx, r.Partitioned(), cfg.ElasticAutoUpgrade); err != nil {
+		logger.Fatal("Проблема с индексом "+ArticleIndex, zap.Error(err))
+	}
+	for _, v := range r.Versions() {
+		if _, err := indices.Ensure(ctx, v, cfg.ElasticAutoUpgrade); err != nil {
+			logger.Fatal("Проблема с индексом "+v.Alias, zap.Error(err))
 		}
 	}
+}
 
-	if err := r.Create(ctx); err != nil {
-		logger.Fatal(fmt.Sprintf("Проблема с индексами"), zap.Error(err))
-	}
-	// 2. Закинуть дефолтные значения
-	//articles := []article.EsArticleDBO{
-	//	{
-	//		Name:        "In Israel, Ron DeSantis Promotes His Foreign Policy Credentials",
-	//		Description: "The Florida governor, a likely contender for the Republican presidential nomination, stressed his strong interest in the country’s affairs, an issue that Donald J. Trump once made his own.",
-	//		URL:         "https://www.nytimes.com/2023/04/27/world/middleeast/israel-ron-desantis.html",
-	//		Address: article.AddressES{
-	//			Coords:  [2]float64{40.756133, -73.990322},
-	//			Country: "US",
-	//			City:    "Florida",
-	//		},
-	//		Publisher: article.PublisherES{
-	//			Name: "The New York Times",
-	//			Address: article.AddressES{
-	//				Coords:  [2]float64{40.756133, -73.990322},
-	//				Country: "US",
-	//				City:    "New York",
-	//			},
-	//		},
-	//		Categories: []string{"politics", "economics"},
-	//		People: []article.PersonES{
-	//			{FullName: "Ron DeSantis"},
-	//			{FullName: "Donald J. Trump"},
-	//		},
-	//		Links:         []string{},
-	//		DatePublished: lib.PointerFrom(time.Now()),
-	//	},
-	//}
-	//for _, a := range articles {
-	//	if ok := r.IndexArticle(ctx, &a); !ok {
-	//		logger.Fatal("Не записали данные в " + ArticleIndex)
-	//	}
-	//}
-
-	// 3. Тест поиска
-	//time.Sleep(2 * time.Second)
-	//f := dto.GrandFilterRequest{
-	//	FilterStrings: []dto.SearchString{
-	//		{Search: "Ron DeSantis", IsExact: true},
-	//	},
-	//	FilterPeople:     []dto.SearchPeople{},
-	//	FilterPublishers: []dto.SearchPublishers{{Name: "The New York Times"}},
-	//	FilterCountry:    []dto.SearchCountry{{Country: "US"}},
-	//	FilterTime:       dto.SearchTime{},
-	//}
-	//
-	//if p, err := r.FindArticles(ctx, f); err != nil {
-	//	logger.Fatal("Ошибка во время поиска...", zap.Error(err))
-	//} else if len(p) == 0 {
-	//	logger.Fatal("Не нашли тестовые данные...")
-	//} else {
-	//	logger.Info(fmt.Sprintf("Нашли %d статей", len(p)))
-	//}
+// articlePartitions - партиции статей без маппинга, хватает для имён
+func articlePartitions() *elastic.PartitionedIndex {
+	return &elastic.PartitionedIndex{
+		Alias:     ArticleIndex,
+		Version:   articleVersion,
+		Period:    cfg.ArticleRollover,
+		TimeField: "datePublished",
+	}
 }
 
-func (r *repository) Exists(ctx context.Context, index string) bool {
-	if resp, err := r.client.Indices.Exists(index).Perform(ctx); err != nil {
-		logger.Error("Не смогли узнать о существовании индекса", zap.Error(err))
-		return false
+// articleLocationScript - статьям до v3 координаты издателя из каталога.
+// coords прошлых версий не читаем: у издателей из старого сида это [долгота, широта],
+// у добавленных через админку - [широта, долгота]. Статьи издателей не из каталога
+// остаются без location до migrate reindex
+const articleLocationScript = `
+def name = ctx._source.publisher?.name;
+def loc = name == null ? null : params.locations[name.toLowerCase()];
+if (loc != null) {
+  for (def a : [ctx._source.address, ctx._source.publisher.address]) {
+    if (a != null) {
+      a.coords = [loc.lat, loc.lon];
+      a.location = ['lat': loc.lat, 'lon': loc.lon];
+    }
+  }
+}`
+
+// catalogueLocations - координаты издателей каталога по названию в нижнем регистре
+func catalogueLocations() json.RawMessage {
+	locations := map[string]article.GeoPoint{}
+	if cfg.CataloguePath == "" {
+		return json.RawMessage(`{}`)
+	}
+	if c, err := catalogue.Load(cfg.CataloguePath); err != nil {
+		logger.Warn("Каталог не прочитан, статьи прошлых версий останутся без location", zap.Error(err))
 	} else {
-		return resp.StatusCode == http.StatusOK
+		for _, p := range c.Publishers {
+			locations[strings.ToLower(p.Name)] = article.GeoPoint{Lat: p.Latitude, Lon: p.Longitude}
+		}
 	}
+	b, _ := json.Marshal(locations)
+	return b
 }
 
-func (r *repository) Delete(ctx context.Context, index string) {
-	if _, err := r.client.Indices.Delete(index).Do(ctx); err != nil {
-		logger.Error("Не удалили индекс "+index, zap.Error(err))
+// ArticleIndexFor - партиция для статьи с такой датой публикации
+func ArticleIndexFor(published *time.Time) string {
+	if published == nil {
+		return articlePartitions().Partition(time.Now())
 	}
+	return articlePartitions().Partition(*published)
 }
 
-func (r *repository) Create(ctx context.Context) error {
-	// ------------------------------------------------- Статьи
+func (r *repository) Partitioned() *elastic.PartitionedIndex {
 	articlesTokenizer := types.NGramTokenizer{
 		MinGram:    2,
 		MaxGram:    20,
@@ -137,77 +144,77 @@ func (r *repository) Create(ctx context.Context) error {
 		Type:       "ngram",
 	}
 
+	// coords - [широта, долгота] для фронта, geo_point прочитал бы их наоборот.
+	// Гео-фильтры работают по location
 	addressMapping := &types.ObjectProperty{
 		Properties: map[string]types.Property{
-			"country": types.NewKeywordProperty(),
-			"city":    types.NewKeywordProperty(),
-			"coords":  types.NewGeoPointProperty(),
+			"country":  types.NewKeywordProperty(),
+			"city":     types.NewKeywordProperty(),
+			"coords":   &types.DoubleNumberProperty{Index: lib.PointerFrom(false), Type: "double"},
+			"location": types.NewGeoPointProperty(),
 		},
 		Type: "object",
 	}
 
-	res, err := r.client.Indices.Create(ArticleIndex).
-		Request(&create.Request{
-			Settings: &types.IndexSettings{
-				Analysis: &types.IndexSettingsAnalysis{
-					Tokenizer: map[string]types.Tokenizer{
-						"article_tokenizer": articlesTokenizer,
-					},
-					Analyzer: map[string]types.Analyzer{
-						"article_analyzer": types.CustomAnalyzer{
-							Tokenizer: "article_tokenizer",
-							Filter:    []string{"lowercase"},
-						},
-						"article_search_analyzer": types.NewWhitespaceAnalyzer(),
+	articles := articlePartitions()
+	articles.Migrate = articleLocationScript
+	articles.MigrateParams = map[string]json.RawMessage{"locations": catalogueLocations()}
+	articles.Request = &create.Request{
+		Settings: &types.IndexSettings{
+			Analysis: &types.IndexSettingsAnalysis{
+				Tokenizer: map[string]types.Tokenizer{
+					"article_tokenizer": articlesTokenizer,
+				},
+				Analyzer: map[string]types.Analyzer{
+					"article_analyzer": types.CustomAnalyzer{
+						Tokenizer: "article_tokenizer",
+						Filter:    []string{"lowercase"},
 					},
+					"article_search_analyzer": types.NewWhitespaceAnalyzer(),
 				},
-				MaxNgramDiff: lib.PointerFrom(20),
 			},
-			Mappings: &types.TypeMapping{
-				Properties: map[string]types.Property{
-					"name": &types.TextProperty{
-						Analyzer:       lib.PointerFrom("article_analyzer"),
-						SearchAnalyzer: lib.PointerFrom("article_search_analyzer"),
-						Type:           "text",
-						Index:          lib.PointerFrom(true),
-					},
-					"description": &types.TextProperty{
-						Analyzer:       lib.PointerFrom("article_analyzer"),
-						SearchAnalyzer: lib.PointerFrom("article_search_analyzer"),
-						Type:           "text",
-						Index:          lib.PointerFrom(true),
-					},
-					"URL":        types.NewKeywordProperty(),
-					"categories": types.NewKeywordProperty(),
-					"address":    addressMapping,
-					"publisher": &types.ObjectProperty{
-						Properties: map[string]types.Property{
-							"name":    types.NewKeywordProperty(),
-							"address": addressMapping,
-						},
-						Type: "object",
+			MaxNgramDiff: lib.PointerFrom(20),
+		},
+		Mappings: &types.TypeMapping{
+			Properties: map[string]types.Property{
+				"name": &types.TextProperty{
+					Analyzer:       lib.PointerFrom("article_analyzer"),
+					SearchAnalyzer: lib.PointerFrom("article_search_analyzer"),
+					Type:           "text",
+					Index:          lib.PointerFrom(true),
+				},
+				"description": &types.TextProperty{
+					Analyzer:       lib.PointerFrom("article_analyzer"),
+					SearchAnalyzer: lib.PointerFrom("article_search_analyzer"),
+					Type:           "text",
+					Index:          lib.PointerFrom(true),
+				},
+				"URL":        types.NewKeywordProperty(),
+				"categories": types.NewKeywordProperty(),
+				"address":    addressMapping,
+				"publisher": &types.ObjectProperty{
+					Properties: map[string]types.Property{
+						"name":    types.NewKeywordProperty(),
+						"address": addressMapping,
 					},
-					"people": &types.ObjectProperty{
-						Properties: map[string]types.Property{
-							"fullName": types.NewKeywordProperty(),
-						},
-						Type: "object",
+					Type: "object",
+				},
+				"people": &types.ObjectProperty{
+					Properties: map[string]types.Property{
+						"fullName": types.NewKeywordProperty(),
 					},
-					"links":         types.NewKeywordProperty(),
-					"language":      types.NewKeywordProperty(),
-					"datePublished": types.NewDateProperty(),
+					Type: "object",
 				},
+				"links":         types.NewKeywordProperty(),
+				"language":      types.NewKeywordProperty(),
+				"datePublished": types.NewDateProperty(),
 			},
-		}).
-		Do(ctx)
-
-	if err != nil {
-		logger.Error("Не создали индекс " + ArticleIndex)
-		return err
-	} else {
-		log.Println(res)
+		},
 	}
+	return articles
+}
 
+func (r *repository) Versions() []*elastic.VersionedIndex {
 	// ------------------------------------------------- Категории
 	categoryTokenizer := types.NGramTokenizer{
 		MinGram: 2,
@@ -220,9 +227,10 @@ func (r *repository) Create(ctx context.Context) error {
 			tokenchar.Symbol},
 		Type: "ngram",
 	}
-
-	res, err = r.client.Indices.Create(CategoryIndex).
-		Request(&create.Request{
+	categories := &elastic.VersionedIndex{
+		Alias:   CategoryIndex,
+		Version: categoryVersion,
+		Request: &create.Request{
 			Settings: &types.IndexSettings{
 				Analysis: &types.IndexSettingsAnalysis{
 					Tokenizer: map[string]types.Tokenizer{
@@ -248,14 +256,7 @@ func (r *repository) Create(ctx context.Context) error {
 					},
 				},
 			},
-		}).
-		Do(ctx)
-
-	if err != nil {
-		logger.Error("Не создали индекс " + CategoryIndex)
-		return err
-	} else {
-		log.Println(res)
+		},
 	}
 
 	// ------------------------------------------------- Люди
@@ -270,9 +271,10 @@ func (r *repository) Create(ctx context.Context) error {
 			tokenchar.Symbol},
 		Type: "ngram",
 	}
-
-	res, err = r.client.Indices.Create(PeopleIndex).
-		Request(&create.Request{
+	people := &elastic.VersionedIndex{
+		Alias:   PeopleIndex,
+		Version: peopleVersion,
+		Request: &create.Request{
 			Settings: &types.IndexSettings{
 				Analysis: &types.IndexSettingsAnalysis{
 					Tokenizer: map[string]types.Tokenizer{
@@ -298,34 +300,35 @@ func (r *repository) Create(ctx context.Context) error {
 					},
 				},
 			},
-		}).
-		Do(ctx)
-
-	if err != nil {
-		logger.Error("Не создали индекс " + CategoryIndex)
-		return err
-	} else {
-		log.Println(res)
+		},
 	}
 
-	return nil
+	return []*elastic.VersionedIndex{categories, people}
 }
 
-func (r *repository) IndexArticle(ctx context.Context, a *article.EsArticleDBO) bool {
-	res, err := r.client.Index(ArticleIndex).
-		Request(a).
+// IndexArticle - upsert статьи по её стабильному ID.
+// Повторная запись той же статьи не создаёт дубликат:
+// ES вернёт updated, если статья изменилась, и noop, если нет
+func (r *repository) IndexArticle(ctx context.Context, a *article.EsArticleDBO) (result.Result, error) {
+	if a.ID == "" {
+		return result.Result{}, ErrNoArticleID
+	}
+
+	res, err := r.client.Update(ArticleIndexFor(a.DatePublished), a.ID).
+		Doc(a).
+		DocAsUpsert(true).
 		Do(ctx)
 
 	if err != nil {
 		logger.Error(fmt.Sprintf("Не записали данные в %s", ArticleIndex), zap.Error(err))
-		return false
+		return result.Result{}, err
 	}
 
 	logger.Info(
-		fmt.Sprintf("Добавили в ES[%s] статью [%s] с id=[%s] от [%s]",
-			ArticleIndex, a.Name, res.Id_, a.Publisher.Name))
+		fmt.Sprintf("Записали в ES[%s] статью [%s] с id=[%s] от [%s]: %s",
+			ArticleIndex, a.Name, res.Id_, a.Publisher.Name, res.Result))
 
-	return res.Result == result.Created
+	return res.Result, nil
 }
 
 func (r *repository) IndexCategory(ctx context.Context, a *article.CategoryES) bool {
@@ -336,7 +339,7 @@ func (r *repository) IndexCategory(ctx context.Context, a *article.CategoryES) b
 	}
 	hash := h.Sum32()
 
-	res, err := r.client.Index(CategoryIndex).
+	res, err := r.client.Index(CategoryWriteIndex).
 		Id(fmt.Sprintf("%d", hash)).
 		Request(a).
 		OpType(optype.Index).
@@ -359,7 +362,7 @@ func (r *repository) IndexPeople(ctx context.Context, a *article.PersonES) bool
 	}
 	hash := h.Sum32()
 
-	res, err := r.client.Index(PeopleIndex).
+	res, err := r.client.Index(PeopleWriteIndex).
 		Id(fmt.Sprintf("%d", hash)).
 		Request(a).
 		OpType(optype.Index).
@@ -374,9 +377,137 @@ func (r *repository) IndexPeople(ctx context.Context, a *article.PersonES) bool
 	return res.Result == result.Created
 }
 
-func (r *repository) FindArticles(ctx context.Context, f dto.GrandFilterRequest, size int) ([]*article.EsArticleDBO, int64, error) {
-	span := trace.SpanFromContext(ctx)
+func (r *repository) Bulk(ctx context.Context, docs []BulkDocument) ([]BulkItemResult, error) {
+	results := make([]BulkItemResult, len(docs))
+	todo := make([]int, len(docs))
+	for i := range docs {
+		todo[i] = i
+	}
+
+	delay := cfg.BulkRetryDelay
+	for attempt := 0; ; attempt++ {
+		canRetry := attempt < cfg.BulkMaxRetries
+
+		resp, err := r.bulkOnce(ctx, docs, todo)
+		if err != nil {
+			var esErr *types.ElasticsearchError
+			if canRetry && errors.As(err, &esErr) && esErr.Status == http.StatusTooManyRequests {
+				logger.Warn(fmt.Sprintf("ES перегружен, повторяем пачку из %d через %s", len(todo), delay))
+				metrics.AddCounterVecMetric(customMetrics.MetricEsBulkItemsName, float64(len(todo)), "retried")
+				if err := sleepCtx(ctx, delay); err != nil {
+					return nil, err
+				}
+				delay *= 2
+				continue
+			}
+			logger.Error("Не записали пачку в ES", zap.Error(err))
+			return nil, err
+		}
+
+		// Документы, отклонённые из-за перегрузки, отправляем ещё раз
+		var retry []int
+		for k, item := range resp.Items {
+			i := todo[k]
+			for _, res := range item {
+				if canRetry && res.Status == http.StatusTooManyRequests {
+					retry = append(retry, i)
+					continue
+				}
+				results[i] = bulkItemResult(res)
+			}
+		}
+		if len(retry) == 0 {
+			return results, nil
+		}
+
+		logger.Warn(fmt.Sprintf("ES отклонил %d документов, повторяем через %s", len(retry), delay))
+		metrics.AddCounterVecMetric(customMetrics.MetricEsBulkItemsName, float64(len(retry)), "retried")
+		if err := sleepCtx(ctx, delay); err != nil {
+			return nil, err
+		}
+		delay *= 2
+		todo = retry
+	}
+}
+
+func (r *repository) bulkOnce(ctx context.Context, docs []BulkDocument, todo []int) (*bulk.Response, error) {
+	req := r.client.Bulk()
+	for _, i := range todo {
+		d := docs[i]
+		var err error
+		switch {
+		case d.Delete:
+			err = req.DeleteOp(types.DeleteOperation{Index_: &d.Index, Id_: &d.ID})
+		case d.Upsert:
+			err = req.UpdateOp(
+				types.UpdateOperation{Index_: &d.Index, Id_: &d.ID},
+				d.Doc,
+				&types.UpdateAction{DocAsUpsert: lib.PointerFrom(true)})
+		default:
+			err = req.IndexOp(types.IndexOperation{Index_: &d.Index, Id_: &d.ID}, d.Doc)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return req.Do(ctx)
+}
+
+func bulkItemResult(res types.ResponseItem) BulkItemResult {
+	item := BulkItemResult{Status: res.Status}
+	if res.Result != nil {
+		_ = item.Result.UnmarshalText([]byte(*res.Result))
+	}
+	if res.Error != nil {
+		reason := res.Error.Type
+		if res.Error.Reason != nil {
+			reason += ": " + *res.Error.Reason
+		}
+		item.Err = errors.New(reason)
+	}
+	return item
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
+
+// filterQuery - условия grandFilter
+type filterQuery struct {
+	must []types.Query
+	// Фильтры по значениям фасетов: с фасетами уходят в post_filter
+	facets map[string]types.Query
+	// Без оценки: области на карте
+	filter   []types.Query
+	timeZone string
+}
+
+// query - условия запроса, withFacets - вместе с фильтрами фасетов
+func (q *filterQuery) query(withFacets bool) *types.Query {
+	must := append([]types.Query{}, q.must...)
+	if withFacets {
+		for _, name := range facetNames {
+			if f, ok := q.facets[name]; ok {
+				must = append(must, f)
+			}
+		}
+	}
+	if len(must) == 0 && len(q.filter) == 0 {
+		return nil
+	}
+	return &types.Query{Bool: &types.BoolQuery{Must: must, Filter: q.filter}}
+}
+
+// grandFilterQuery - условия из фильтров grandFilter, общие для выдачи и карты
+func grandFilterQuery(span trace.Span, f dto.GrandFilterRequest) (*filterQuery, error) {
 	var must []types.Query
+	// Фильтры по значениям фасетов: с фасетами уходят в post_filter
+	filters := make(map[string]types.Query)
 
 	// 1. Поисковые строки
 	for i, filterString := range f.FilterStrings {
@@ -429,7 +560,7 @@ func (r *repository) FindArticles(ctx context.Context, f dto.GrandFilterRequest,
 			fmt.Sprintf("Страна=[%s]", country)))
 	}
 	if len(f.FilterCountry) > 0 {
-		must = append(must, countryMust)
+		filters[facetCountry] = countryMust
 	}
 
 	// 3. Издание
@@ -444,7 +575,7 @@ func (r *repository) FindArticles(ctx context.Context, f dto.GrandFilterRequest,
 			fmt.Sprintf("Издание=[%s]", p.Name)))
 	}
 	if len(f.FilterPublishers) > 0 {
-		must = append(must, pubMust)
+		filters[facetPublisher] = pubMust
 	}
 
 	// 4. Люди
@@ -453,13 +584,13 @@ func (r *repository) FindArticles(ctx context.Context, f dto.GrandFilterRequest,
 		q := types.Query{Match: map[string]types.MatchQuery{
 			"people.fullName": {Query: person.FullName},
 		}}
-		peopleMust.Bool.Should = append(pubMust.Bool.Should, q)
+		peopleMust.Bool.Should = append(peopleMust.Bool.Should, q)
 		span.SetAttributes(attribute.String(
 			fmt.Sprintf("Ищем человека №%d", i),
 			fmt.Sprintf("Имя=[%s]", person.FullName)))
 	}
 	if len(f.FilterPeople) > 0 {
-		must = append(must, peopleMust)
+		filters[facetPerson] = peopleMust
 	}
 
 	// 5. Категории
@@ -474,7 +605,7 @@ func (r *repository) FindArticles(ctx context.Context, f dto.GrandFilterRequest,
 			fmt.Sprintf("Категория=[%s]", category.Name)))
 	}
 	if len(f.FilterCategories) > 0 {
-		must = append(must, categoriesMust)
+		filters[facetCategory] = categoriesMust
 	}
 
 	// 6. Языки
@@ -489,35 +620,135 @@ func (r *repository) FindArticles(ctx context.Context, f dto.GrandFilterRequest,
 			fmt.Sprintf("Язык=[%s]", language.Name)))
 	}
 	if len(f.FilterLanguages) > 0 {
-		must = append(must, languagesMust)
+		filters[facetLanguage] = languagesMust
+	}
+
+	// 7. Время
+	timeRange, err := f.FilterTime.Range()
+	if err != nil {
+		return nil, err
+	}
+	if timeRange != nil {
+		q := types.DateRangeQuery{}
+		if timeRange.Gte != "" {
+			q.Gte = &timeRange.Gte
+		}
+		if timeRange.Lte != "" {
+			q.Lte = &timeRange.Lte
+		}
+		if timeRange.TimeZone != "" {
+			q.TimeZone = &timeRange.TimeZone
+		}
+		must = append(must, types.Query{Range: map[string]types.RangeQuery{"datePublished": q}})
+		span.SetAttributes(attribute.String("Ищем за время",
+			fmt.Sprintf("С=[%s] По=[%s] Зона=[%s]", timeRange.Gte, timeRange.Lte, timeRange.TimeZone)))
+	}
+
+	// 8. Карта: любая из областей
+	var filter []types.Query
+	var areas []types.Query
+	for i, g := range f.FilterGeo {
+		q, err := geoQuery(g)
+		if err != nil {
+			return nil, err
+		}
+		areas = append(areas, q)
+		span.SetAttributes(attribute.String(
+			fmt.Sprintf("Ищем на карте №%d", i),
+			fmt.Sprintf("Поле=[%s]", g.Field())))
+	}
+	if len(areas) > 0 {
+		filter = append(filter, types.Query{Bool: &types.BoolQuery{Should: areas, MinimumShouldMatch: 1}})
 	}
 
-	// 7. Время f.FilterTime
+	q := &filterQuery{must: must, facets: filters, filter: filter}
+	if timeRange != nil {
+		q.timeZone = timeRange.TimeZone
+	}
+	return q, nil
+}
+
+func (r *repository) FindArticles(ctx context.Context, f dto.GrandFilterRequest, size int, after string, paginate, facets bool) (*article.SearchPage, error) {
+	span := trace.SpanFromContext(ctx)
+	fq, err := grandFilterQuery(span, f)
+	if err != nil {
+		return nil, err
+	}
+
+	// Порядок
+	mode, err := f.SortMode()
+	if err != nil {
+		return nil, err
+	}
+	span.SetAttributes(attribute.String("Порядок", mode))
+
+	// Страница: point in time держит выдачу неизменной между страницами,
+	// search_after продолжает с последней статьи. Открываем, только если клиент листает
+	fingerprint, err := filterHash(f)
+	if err != nil {
+		return nil, err
+	}
+	keepAlive := esDuration(cfg.SearchKeepAlive)
+	c := &cursor{Filter: fingerprint}
+	opened := false
+	switch {
+	case after != "":
+		if c, err = decodeCursor(after, f); err != nil {
+			return nil, err
+		}
+	case paginate:
+		pit, err := r.client.OpenPointInTime(ArticleIndex).KeepAlive(keepAlive).Do(ctx)
+		if err != nil {
+			return nil, err
+		}
+		c.Pit, opened = pit.Id, true
+	}
+	// Point in time закрываем на последней странице и на ошибке, если открыли его здесь.
+	// Point in time из курсора клиента переживает сбой: клиент может повторить запрос
+	continued, last := false, false
+	defer func() {
+		if c.Pit != "" && (last || opened && !continued) {
+			r.closePit(c.Pit)
+		}
+	}()
 
 	req := &search.Request{
 		Size: &size,
-		Sort: types.Sort{
-			map[string]types.FieldSort{
-				"datePublished": {
-					NumericType: lib.PointerFrom(fieldsortnumerictype.Date),
-					Order:       lib.PointerFrom(sortorder.Desc),
-				},
-			},
-		},
+		Sort: sortBy(mode, c.Pit != ""),
+	}
+	if c.Pit != "" {
+		req.Pit = &types.PointInTimeReference{Id: c.Pit, KeepAlive: keepAlive}
+		req.SearchAfter = c.After
 	}
 
-	if len(must) > 0 {
-		req.Query = &types.Query{
-			Bool: &types.BoolQuery{Must: must},
+	// При сортировке по дате оценку считаем отдельно. Подсвечиваем поисковые строки
+	if len(f.FilterStrings) > 0 {
+		req.TrackScores = lib.PointerFrom(mode == dto.SortDate)
+		req.Highlight = highlight()
+	}
+
+	if facets {
+		if len(fq.facets) > 0 {
+			req.PostFilter = facetFilter(fq.facets, "")
 		}
+		req.Aggregations = facetAggregations(fq.facets, fq.timeZone)
 	}
+	req.Query = rank(mode, fq.query(!facets))
 
-	resp, err := r.client.Search().
-		Index(ArticleIndex).
-		Request(req).
-		Do(ctx)
+	// С point in time индекс не указывается
+	searchReq := r.client.Search().Request(req)
+	if c.Pit == "" {
+		searchReq = searchReq.Index(ArticleIndex)
+	}
+	resp, err := searchReq.Do(ctx)
 	if err != nil {
-		return nil, 0, err
+		var esErr *types.ElasticsearchError
+		if after != "" && errors.As(err, &esErr) && esErr.Status == http.StatusNotFound {
+			// Закрывать уже нечего
+			c.Pit = ""
+			return nil, ErrCursorExpired
+		}
+		return nil, err
 	}
 
 	logger.Info(fmt.Sprintf("По запросу grandFilter нашли [%d]", resp.Hits.Total.Value))
@@ -525,16 +756,69 @@ func (r *repository) FindArticles(ctx context.Context, f dto.GrandFilterRequest,
 		fmt.Sprintf("Найдено"),
 		resp.Hits.Total.Value))
 
-	var p []*article.EsArticleDBO
+	page := &article.SearchPage{Hits: []*article.SearchHit{}, Total: resp.Hits.Total.Value}
 	for _, hit := range resp.Hits.Hits {
 		var res *article.EsArticleDBO
 		if err := json.Unmarshal(hit.Source_, &res); err != nil {
-			return nil, 0, err
+			return nil, err
 		}
-		p = append(p, res)
+		res.ID = hit.Id_
+		page.Hits = append(page.Hits, &article.SearchHit{
+			EsArticleDBO: res,
+			ID:           hit.Id_,
+			Score:        float64(hit.Score_),
+			Highlight:    hit.Highlight,
+		})
+	}
+	if facets {
+		if page.Facets, err = facetsFrom(resp.Aggregations); err != nil {
+			return nil, err
+		}
+	}
+
+	if resp.PitId != nil && c.Pit != "" {
+		c.Pit = *resp.PitId
+	}
+	// Неполная страница - последняя, point in time больше не нужен
+	hits := resp.Hits.Hits
+	if c.Pit == "" || len(hits) < size {
+		last = true
+		return page, nil
+	}
+	c.After = hits[len(hits)-1].Sort
+	if page.Cursor, err = c.encode(); err != nil {
+		return nil, err
 	}
+	continued = true
+	return page, nil
+}
 
-	return p, resp.Hits.Total.Value, nil
+// highlight - фрагменты name и description с совпадениями.
+// name целиком, description - лучшие фрагменты или начало, если совпало в name
+func highlight() *types.Highlight {
+	h := cfg.SearchHighlight
+	return &types.Highlight{
+		Encoder:  &highlighterencoder.Html,
+		PreTags:  []string{h.PreTag},
+		PostTags: []string{h.PostTag},
+		Fields: map[string]types.HighlightField{
+			"name": {NumberOfFragments: lib.PointerFrom(0)},
+			"description": {
+				FragmentSize:      lib.PointerFrom(h.FragmentSize),
+				NumberOfFragments: lib.PointerFrom(h.Fragments),
+				NoMatchSize:       lib.PointerFrom(h.FragmentSize),
+			},
+		},
+	}
+}
+
+// closePit освобождает point in time, не дожидаясь keep_alive
+func (r *repository) closePit(id string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := r.client.ClosePointInTime().Request(&closepointintime.Request{Id: id}).Do(ctx); err != nil {
+		logger.Warn("Не закрыли point in time", zap.Error(err))
+	}
 }
 
 func (r *repository) FindLanguages(ctx context.Context) ([]*article.LanguageES, error) {
@@ -650,3 +934,43 @@ func (r *repository) FindPeople(ctx context.Context, name string) ([]*article.Pe
 
 	return p, nil
 }
+
+func (r *repository) DeleteExpired(ctx context.Context, scope retention.Scope) (int64, error) {
+	filter := []types.Query{{Range: map[string]types.RangeQuery{
+		"datePublished": types.DateRangeQuery{Lt: lib.PointerFrom(scope.Before.Format(time.RFC3339))},
+	}}}
+	if scope.Publisher != "" {
+		filter = append(filter, types.Query{Term: map[string]types.TermQuery{"publisher.name": {Value: scope.Publisher}}})
+	}
+	if scope.Country != "" {
+		filter = append(filter, types.Query{Term: map[string]types.TermQuery{"address.country": {Value: scope.Country}}})
+	}
+	var mustNot []types.Query
+	for _, p := range scope.ExceptPublishers {
+		mustNot = append(mustNot, types.Query{Term: map[string]types.TermQuery{"publisher.name": {Value: p}}})
+	}
+	for _, c := range scope.ExceptCountries {
+		mustNot = append(mustNot, types.Query{Term: map[string]types.TermQuery{"address.country": {Value: c}}})
+	}
+
+	res, err := r.client.DeleteByQuery(ArticleIndex).
+		Query(&types.Query{Bool: &types.BoolQuery{Filter: filter, MustNot: mustNot}}).
+		// Статью могли обновить во время удаления - удалим в следующий раз
+		Conflicts(conflicts.Proceed).
+		Do(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var deleted int64
+	if res.Deleted != nil {
+		deleted = *res.Deleted
+	}
+	if len(res.Failures) > 0 {
+		return deleted, fmt.Errorf("не удалили документов: %d", len(res.Failures))
+	}
+	return deleted, nil
+}
+
+func (r *repository) DropPartitions(ctx context.Context, before time.Time, archive bool) ([]*elastic.Partition, error) {
+	return elastic.NewIndices(r.client).DropPartitions(ctx, articlePartitions(), before, archive)
+}
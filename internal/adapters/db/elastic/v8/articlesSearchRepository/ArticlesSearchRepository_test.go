@@ -0,0 +1,44 @@
+package articlesSearchRepository
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func withCatalogue(t *testing.T, content string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "catalogue.yml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	old := cfg.CataloguePath
+	t.Cleanup(func() { cfg.CataloguePath = old })
+	cfg.CataloguePath = path
+}
+
+// Координаты статей прошлых версий берутся из каталога по названию издателя
+func TestCatalogueLocations(t *testing.T) {
+	withCatalogue(t, `publishers:
+  - name: ТАСС
+    latitude: 55.75
+    longitude: 37.62
+    feeds:
+      - https://tass.ru/rss/v2.xml
+`)
+	if got := string(catalogueLocations()); got != `{"тасс":{"lat":55.75,"lon":37.62}}` {
+		t.Errorf("catalogueLocations() = %s", got)
+	}
+}
+
+// Без каталога статьи переносятся без location, а не с ошибкой
+func TestCatalogueLocationsWithoutCatalogue(t *testing.T) {
+	withCatalogue(t, "publishers:\n  - name: \"\"\n")
+	if got := string(catalogueLocations()); got != `{}` {
+		t.Errorf("catalogueLocations() с неверным каталогом = %s", got)
+	}
+	cfg.CataloguePath = ""
+	if got := string(catalogueLocations()); got != `{}` {
+		t.Errorf("catalogueLocations() без каталога = %s", got)
+	}
+}
@@ -2,20 +2,61 @@ package articlesSearchRepository
 
 import (
 	"context"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/result"
 	"github.com/mskKote/prospero_backend/internal/controller/http/v1/dto"
 	"github.com/mskKote/prospero_backend/internal/domain/entity/article"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/retention"
+	"github.com/mskKote/prospero_backend/pkg/client/elastic"
+	"time"
 )
 
 type IRepository interface {
+	// Setup создаёт индексы или переводит их на новую версию маппинга
 	Setup(ctx context.Context)
-	Exists(ctx context.Context, index string) bool
-	Delete(ctx context.Context, index string)
-	Create(ctx context.Context) error
-	IndexArticle(ctx context.Context, a *article.EsArticleDBO) bool
+	// Partitioned - партиции статей по времени
+	Partitioned() *elastic.PartitionedIndex
+	// Versions - описания версионированных индексов категорий и людей
+	Versions() []*elastic.VersionedIndex
+	IndexArticle(ctx context.Context, a *article.EsArticleDBO) (result.Result, error)
 	IndexCategory(ctx context.Context, a *article.CategoryES) bool
 	IndexPeople(ctx context.Context, a *article.PersonES) bool
-	FindArticles(ctx context.Context, f dto.GrandFilterRequest, size int) ([]*article.EsArticleDBO, int64, error)
+	// Bulk пишет пачку документов одним запросом _bulk.
+	// Результаты в том же порядке, что и docs
+	Bulk(ctx context.Context, docs []BulkDocument) ([]BulkItemResult, error)
+	// FindArticles - страница статей по фильтрам, свежие первыми.
+	// after - курсор предыдущей страницы, пустой - первая страница.
+	// paginate - первая страница с курсором на следующую.
+	// facets - посчитать фасеты по всей выдаче
+	FindArticles(ctx context.Context, f dto.GrandFilterRequest, size int, after string, paginate, facets bool) (*article.SearchPage, error)
+	// FindClusters - статьи в видимой части карты, сгруппированные geotile_grid
+	FindClusters(ctx context.Context, m dto.MapRequest) (*article.MapClusters, error)
 	FindLanguages(ctx context.Context) ([]*article.LanguageES, error)
 	FindCategory(ctx context.Context, cat string) ([]*article.CategoryES, error)
 	FindPeople(ctx context.Context, name string) ([]*article.PersonES, error)
+	// ReplaceLegacyIDs переписывает статьи, проиндексированные до StableID со случайным ID,
+	// под StableID по ссылке и удаляет старые документы. Копии статьи, уже записанной
+	// со StableID, просто удаляются. Повторный запуск безопасен
+	ReplaceLegacyIDs(ctx context.Context) (*LegacyStats, error)
+	// DeleteExpired удаляет статьи правила хранения, возвращает сколько удалено
+	DeleteExpired(ctx context.Context, scope retention.Scope) (int64, error)
+	// DropPartitions удаляет или с archive закрывает партиции статей, целиком лежащие до before
+	DropPartitions(ctx context.Context, before time.Time, archive bool) ([]*elastic.Partition, error)
+}
+
+// BulkDocument - документ для записи через _bulk
+type BulkDocument struct {
+	Index string
+	ID    string
+	Doc   any
+	// Upsert - частичное обновление с doc_as_upsert, иначе полная перезапись
+	Upsert bool
+	// Delete - удалить документ, Doc не нужен
+	Delete bool
+}
+
+// BulkItemResult - итог записи одного документа
+type BulkItemResult struct {
+	Result result.Result
+	Status int
+	Err    error
 }
@@ -0,0 +1,197 @@
+package articlesSearchRepository
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/result"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeES запоминает запросы к Elasticsearch, ответы даёт handle
+type fakeES struct {
+	mu       sync.Mutex
+	requests []string
+}
+
+func newTestRepository(t *testing.T, handle func(n int, body string) (int, string)) (*repository, *fakeES) {
+	t.Helper()
+	es := &fakeES{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var sb strings.Builder
+		sc := bufio.NewScanner(r.Body)
+		for sc.Scan() {
+			sb.WriteString(sc.Text() + "\n")
+		}
+		es.mu.Lock()
+		es.requests = append(es.requests, r.Method+" "+r.URL.Path+"\n"+sb.String())
+		n := len(es.requests)
+		es.mu.Unlock()
+
+		status, body := handle(n, sb.String())
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := elasticsearch.NewTypedClient(elasticsearch.Config{Addresses: []string{srv.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &repository{client}, es
+}
+
+func withBulkRetries(t *testing.T, retries int) {
+	t.Helper()
+	maxRetries, delay := cfg.BulkMaxRetries, cfg.BulkRetryDelay
+	t.Cleanup(func() { cfg.BulkMaxRetries, cfg.BulkRetryDelay = maxRetries, delay })
+	cfg.BulkMaxRetries, cfg.BulkRetryDelay = retries, time.Millisecond
+}
+
+// bulkItems - ответ _bulk: по статусу на каждый документ
+func bulkItems(ids []string, statuses []int) string {
+	items := make([]string, len(ids))
+	for i, id := range ids {
+		switch {
+		case statuses[i] == http.StatusCreated:
+			items[i] = fmt.Sprintf(`{"update":{"_index":"articles","_id":%q,"status":201,"result":"created"}}`, id)
+		case statuses[i] < 300:
+			items[i] = fmt.Sprintf(`{"update":{"_index":"articles","_id":%q,"status":200,"result":"noop"}}`, id)
+		default:
+			items[i] = fmt.Sprintf(`{"update":{"_index":"articles","_id":%q,"status":%d,`+
+				`"error":{"type":"es_rejected_execution_exception","reason":"queue full"}}}`, id, statuses[i])
+		}
+	}
+	return `{"took":1,"errors":true,"items":[` + strings.Join(items, ",") + `]}`
+}
+
+// bulkIDs - ID документов из тела запроса _bulk
+func bulkIDs(body string) []string {
+	var ids []string
+	for _, line := range strings.Split(strings.TrimSpace(body), "\n") {
+		var action map[string]struct {
+			ID string `json:"_id"`
+		}
+		if json.Unmarshal([]byte(line), &action) != nil {
+			continue
+		}
+		if op, ok := action["update"]; ok {
+			ids = append(ids, op.ID)
+		}
+	}
+	return ids
+}
+
+func upserts(ids ...string) []BulkDocument {
+	docs := make([]BulkDocument, len(ids))
+	for i, id := range ids {
+		docs[i] = BulkDocument{Index: ArticleIndex, ID: id, Doc: map[string]string{"name": id}, Upsert: true}
+	}
+	return docs
+}
+
+// Отклонённый из-за перегрузки документ уходит повторно один, остальные не дублируются
+func TestBulkRetriesRejectedItems(t *testing.T) {
+	withBulkRetries(t, 3)
+	r, es := newTestRepository(t, func(n int, body string) (int, string) {
+		ids := bulkIDs(body)
+		if n == 1 {
+			return http.StatusOK, bulkItems(ids, []int{201, 429, 200})
+		}
+		return http.StatusOK, bulkItems(ids, []int{201})
+	})
+
+	results, err := r.Bulk(context.Background(), upserts("a", "b", "c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []result.Result{result.Created, result.Created, result.Noop}
+	for i, res := range results {
+		if res.Err != nil || res.Result != want[i] {
+			t.Errorf("документ %d: %+v, want %s", i, res, want[i])
+		}
+	}
+	if len(es.requests) != 2 {
+		t.Fatalf("запросов %d, want 2", len(es.requests))
+	}
+	if ids := bulkIDs(es.requests[1]); len(ids) != 1 || ids[0] != "b" {
+		t.Errorf("повторно отправлены %v, want [b]", ids)
+	}
+}
+
+// Попытки кончились - документ возвращается с ошибкой, а не теряется
+func TestBulkRetriesExhausted(t *testing.T) {
+	withBulkRetries(t, 1)
+	r, es := newTestRepository(t, func(n int, body string) (int, string) {
+		ids := bulkIDs(body)
+		statuses := make([]int, len(ids))
+		for i := range statuses {
+			statuses[i] = http.StatusTooManyRequests
+		}
+		return http.StatusOK, bulkItems(ids, statuses)
+	})
+
+	results, err := r.Bulk(context.Background(), upserts("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Err == nil || results[0].Status != http.StatusTooManyRequests {
+		t.Errorf("results = %+v, want ошибку 429", results)
+	}
+	if len(es.requests) != 2 {
+		t.Errorf("запросов %d, want 2: первая попытка и один повтор", len(es.requests))
+	}
+}
+
+// Весь запрос отклонён с 429 - повторяем его целиком
+func TestBulkRetriesRejectedRequest(t *testing.T) {
+	withBulkRetries(t, 2)
+	r, _ := newTestRepository(t, func(n int, body string) (int, string) {
+		if n == 1 {
+			return http.StatusTooManyRequests,
+				`{"error":{"type":"es_rejected_execution_exception","reason":"queue full"},"status":429}`
+		}
+		return http.StatusOK, bulkItems(bulkIDs(body), []int{201, 201})
+	})
+
+	results, err := r.Bulk(context.Background(), upserts("a", "b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, res := range results {
+		if res.Err != nil || res.Result != result.Created {
+			t.Errorf("документ %d: %+v, want created", i, res)
+		}
+	}
+}
+
+// Удаление уходит строкой delete без тела документа
+func TestBulkDelete(t *testing.T) {
+	r, es := newTestRepository(t, func(n int, body string) (int, string) {
+		return http.StatusOK, `{"took":1,"errors":false,"items":[
+			{"update":{"_index":"article-2024.03","_id":"a","status":201,"result":"created"}},
+			{"delete":{"_index":"article-2023.03","_id":"a","status":200,"result":"deleted"}}]}`
+	})
+
+	docs := append(upserts("a"), BulkDocument{Index: "article-2023.03", ID: "a", Delete: true})
+	results, err := r.Bulk(context.Background(), docs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[1].Err != nil || results[1].Result != result.Deleted {
+		t.Errorf("удаление %+v, want deleted", results[1])
+	}
+	lines := strings.Split(strings.TrimSpace(es.requests[0]), "\n")
+	if len(lines) != 4 || lines[3] != `{"delete":{"_id":"a","_index":"article-2023.03"}}` {
+		t.Errorf("запрос %q", lines)
+	}
+}
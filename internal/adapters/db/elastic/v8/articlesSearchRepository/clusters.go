@@ -0,0 +1,119 @@
+package articlesSearchRepository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/core/search"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/sortorder"
+	"github.com/mskKote/prospero_backend/internal/controller/http/v1/dto"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/article"
+	"github.com/mskKote/prospero_backend/pkg/lib"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// Тайл кластера мельче тайла карты: 2 - 4x4 кластера на тайл
+	mapDetail = 2
+	// Сколько кластеров отдаём за раз
+	mapClusters = 2000
+)
+
+func (r *repository) FindClusters(ctx context.Context, m dto.MapRequest) (*article.MapClusters, error) {
+	span := trace.SpanFromContext(ctx)
+	fq, err := grandFilterQuery(span, m.GrandFilterRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	viewport := m.Viewport()
+	area, err := geoQuery(viewport)
+	if err != nil {
+		return nil, err
+	}
+	fq.filter = append(fq.filter, area)
+	field := viewport.Field()
+	precision := min(m.Zoom+mapDetail, dto.MaxMapZoom)
+	span.SetAttributes(attribute.String("Карта", fmt.Sprintf("Поле=[%s] Точность=[%d]", field, precision)))
+
+	req := &search.Request{
+		Size:  lib.PointerFrom(0),
+		Query: fq.query(true),
+		Aggregations: map[string]types.Aggregations{
+			"clusters": {
+				GeotileGrid: &types.GeoTileGridAggregation{
+					Field:     &field,
+					Precision: &precision,
+					Bounds: types.TopLeftBottomRightGeoBounds{
+						TopLeft:     location(m.Bounds.TopLeft),
+						BottomRight: location(m.Bounds.BottomRight),
+					},
+					Size: lib.PointerFrom(mapClusters),
+				},
+				Aggregations: map[string]types.Aggregations{
+					"centroid": {GeoCentroid: &types.GeoCentroidAggregation{Field: &field}},
+					"headlines": {TopHits: &types.TopHitsAggregation{
+						Size: lib.PointerFrom(m.HeadlineCount()),
+						Sort: []types.SortCombinations{map[string]types.FieldSort{
+							"datePublished": {Order: lib.PointerFrom(sortorder.Desc)},
+						}},
+						Source_: types.SourceFilter{Includes: []string{"name", "URL", "datePublished", "publisher.name"}},
+					}},
+				},
+			},
+		},
+	}
+
+	resp, err := r.client.Search().
+		Index(ArticleIndex).
+		Request(req).
+		Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	grid, ok := resp.Aggregations["clusters"].(*types.GeoTileGridAggregate)
+	if !ok {
+		return nil, fmt.Errorf("кластеры: неожиданный ответ %T", resp.Aggregations["clusters"])
+	}
+	buckets, _ := grid.Buckets.([]types.GeoTileGridBucket)
+
+	clusters := &article.MapClusters{Clusters: []*article.MapCluster{}, Total: resp.Hits.Total.Value}
+	for _, b := range buckets {
+		cluster := &article.MapCluster{Tile: b.Key, Count: b.DocCount, Headlines: []*article.Headline{}}
+
+		if centroid, ok := b.Aggregations["centroid"].(*types.GeoCentroidAggregate); ok {
+			// location приходит картой {lat, lon}
+			raw, err := json.Marshal(centroid.Location)
+			if err != nil {
+				return nil, err
+			}
+			if err := json.Unmarshal(raw, &cluster.Centroid); err != nil {
+				return nil, err
+			}
+		}
+
+		if top, ok := b.Aggregations["headlines"].(*types.TopHitsAggregate); ok {
+			for _, hit := range top.Hits.Hits {
+				var a article.EsArticleDBO
+				if err := json.Unmarshal(hit.Source_, &a); err != nil {
+					return nil, err
+				}
+				cluster.Headlines = append(cluster.Headlines, &article.Headline{
+					ID:            hit.Id_,
+					Name:          a.Name,
+					URL:           a.URL,
+					Publisher:     a.Publisher.Name,
+					DatePublished: a.DatePublished,
+				})
+			}
+		}
+		clusters.Clusters = append(clusters.Clusters, cluster)
+	}
+
+	logger.Info(fmt.Sprintf("На карте нашли [%d] в [%d] кластерах", clusters.Total, len(clusters.Clusters)))
+	span.SetAttributes(attribute.Int("Кластеров", len(clusters.Clusters)))
+	return clusters, nil
+}
@@ -0,0 +1,116 @@
+package articlesSearchRepository
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/mskKote/prospero_backend/internal/controller/http/v1/dto"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+var mapBounds = dto.GeoBoundingBox{TopLeft: dto.GeoPoint{Lat: 60, Lon: 30}, BottomRight: dto.GeoPoint{Lat: 50, Lon: 40}}
+
+func TestFindClusters(t *testing.T) {
+	r, es := newTestRepository(t, func(n int, body string) (int, string) {
+		return http.StatusOK, `{"took":1,"timed_out":false,"_shards":{"total":1,"successful":1,"failed":0},
+			"hits":{"total":{"value":4,"relation":"eq"},"hits":[]},
+			"aggregations":{"geotile_grid#clusters":{"buckets":[
+				{"key":"7/77/39","doc_count":3,
+					"geo_centroid#centroid":{"location":{"lat":55.75,"lon":37.62},"count":3},
+					"top_hits#headlines":{"hits":{"total":{"value":3,"relation":"eq"},"hits":[
+						{"_index":"article-2024","_id":"a1","_score":null,"_source":{"name":"Первая","URL":"https://a/1","datePublished":"2024-03-01T10:00:00Z","publisher":{"name":"ТАСС"}}},
+						{"_index":"article-2024","_id":"a2","_score":null,"_source":{"name":"Вторая","URL":"https://a/2","datePublished":"2024-02-01T10:00:00Z","publisher":{"name":"Ведомости"}}}
+					]}}},
+				{"key":"7/76/40","doc_count":1,
+					"geo_centroid#centroid":{"location":{"lat":51.5,"lon":31.3},"count":1},
+					"top_hits#headlines":{"hits":{"total":{"value":1,"relation":"eq"},"hits":[]}}}
+			]}}}`
+	})
+	m := dto.MapRequest{
+		GrandFilterRequest: dto.GrandFilterRequest{FilterStrings: []dto.SearchString{{Search: "выборы"}}},
+		Bounds:             mapBounds,
+		Zoom:               5,
+		Target:             dto.GeoTargetPublisher,
+	}
+	got, err := r.FindClusters(context.Background(), m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Total != 4 || len(got.Clusters) != 2 {
+		t.Fatalf("FindClusters() = %+v", got)
+	}
+	c := got.Clusters[0]
+	if c.Tile != "7/77/39" || c.Count != 3 || c.Centroid.Lat != 55.75 || c.Centroid.Lon != 37.62 {
+		t.Errorf("кластер %+v", c)
+	}
+	if len(c.Headlines) != 2 || c.Headlines[0].ID != "a1" || c.Headlines[0].Publisher != "ТАСС" || c.Headlines[1].Name != "Вторая" {
+		t.Errorf("заголовки %+v", c.Headlines)
+	}
+	if h := got.Clusters[1].Headlines; h == nil || len(h) != 0 {
+		t.Errorf("пустой кластер: заголовки %v, want []", h)
+	}
+
+	var req struct {
+		Size  int `json:"size"`
+		Query struct {
+			Bool struct {
+				Must   []json.RawMessage `json:"must"`
+				Filter []json.RawMessage `json:"filter"`
+			} `json:"bool"`
+		} `json:"query"`
+		Aggregations struct {
+			Clusters struct {
+				GeotileGrid struct {
+					Field     string `json:"field"`
+					Precision int    `json:"precision"`
+					Size      int    `json:"size"`
+				} `json:"geotile_grid"`
+				Aggregations struct {
+					Headlines struct {
+						TopHits struct {
+							Size int `json:"size"`
+						} `json:"top_hits"`
+					} `json:"headlines"`
+				} `json:"aggregations"`
+			} `json:"clusters"`
+		} `json:"aggregations"`
+	}
+	if !strings.HasPrefix(es.requests[0], "POST /"+ArticleIndex+"/_search") {
+		t.Errorf("запрос %s", es.requests[0])
+	}
+	body := es.requests[0][strings.Index(es.requests[0], "\n")+1:]
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		t.Fatal(err)
+	}
+	grid := req.Aggregations.Clusters.GeotileGrid
+	if req.Size != 0 || grid.Field != "publisher.address.location" || grid.Precision != 7 || grid.Size != mapClusters {
+		t.Errorf("запрос %s", body)
+	}
+	if req.Aggregations.Clusters.Aggregations.Headlines.TopHits.Size != dto.DefaultMapHeadlines {
+		t.Errorf("заголовков %d, want %d", req.Aggregations.Clusters.Aggregations.Headlines.TopHits.Size, dto.DefaultMapHeadlines)
+	}
+	// Видимая часть карты сужает выдачу без оценки
+	if len(req.Query.Bool.Must) != 1 || len(req.Query.Bool.Filter) != 1 || !strings.Contains(string(req.Query.Bool.Filter[0]), "geo_bounding_box") {
+		t.Errorf("условия %s", body)
+	}
+}
+
+// Точность кластеров не выходит за предел geotile_grid
+func TestFindClustersPrecision(t *testing.T) {
+	r, es := newTestRepository(t, func(n int, body string) (int, string) {
+		return http.StatusOK, `{"hits":{"total":{"value":0,"relation":"eq"},"hits":[]},
+			"aggregations":{"geotile_grid#clusters":{"buckets":[]}}}`
+	})
+	got, err := r.FindClusters(context.Background(), dto.MapRequest{Bounds: mapBounds, Zoom: dto.MaxMapZoom})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Clusters == nil || len(got.Clusters) != 0 {
+		t.Errorf("кластеры %v, want []", got.Clusters)
+	}
+	if !strings.Contains(es.requests[0], `"field":"address.location"`) || !strings.Contains(es.requests[0], `"precision":29`) {
+		t.Errorf("запрос %s", es.requests[0])
+	}
+}
@@ -0,0 +1,68 @@
+package articlesSearchRepository
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+	"github.com/mskKote/prospero_backend/internal/controller/http/v1/dto"
+)
+
+var (
+	ErrBadCursor     = errors.New("курсор повреждён")
+	ErrCursorFilter  = errors.New("курсор выдан для других фильтров")
+	ErrCursorExpired = errors.New("курсор устарел, начните поиск заново")
+)
+
+// cursor - продолжение поиска: point in time и sort последней статьи страницы.
+// Клиенту уходит непрозрачной строкой
+type cursor struct {
+	Pit    string             `json:"p"`
+	After  []types.FieldValue `json:"a"`
+	Filter string             `json:"f"`
+}
+
+func (c *cursor) encode() (string, error) {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// decodeCursor проверяет, что курсор выдан для тех же фильтров
+func decodeCursor(s string, f dto.GrandFilterRequest) (*cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrBadCursor
+	}
+	// Числа как есть: _shard_doc не влезает в float64
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	c := &cursor{}
+	if err := dec.Decode(c); err != nil || c.Pit == "" || len(c.After) == 0 {
+		return nil, ErrBadCursor
+	}
+
+	filter, err := filterHash(f)
+	if err != nil {
+		return nil, err
+	}
+	if c.Filter != filter {
+		return nil, ErrCursorFilter
+	}
+	return c, nil
+}
+
+// filterHash - отпечаток фильтров, под которые открыт курсор
+func filterHash(f dto.GrandFilterRequest) (string, error) {
+	b, err := json.Marshal(f)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:8]), nil
+}
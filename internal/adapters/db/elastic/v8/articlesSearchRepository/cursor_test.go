@@ -0,0 +1,255 @@
+package articlesSearchRepository
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+	"github.com/mskKote/prospero_backend/internal/controller/http/v1/dto"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func cursorFor(t *testing.T, f dto.GrandFilterRequest, after ...types.FieldValue) string {
+	t.Helper()
+	filter, err := filterHash(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := (&cursor{Pit: "pit-1", After: after, Filter: filter}).encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestDecodeCursor(t *testing.T) {
+	f := dto.GrandFilterRequest{
+		FilterStrings: []dto.SearchString{{Search: "выборы"}},
+		FilterTime:    dto.SearchTime{Start: "last 7 days"},
+	}
+	// _shard_doc больше 2^53: в float64 потерял бы младшие разряды
+	s := cursorFor(t, f, 1715940000000, json.Number("9223372036854775807"))
+
+	c, err := decodeCursor(s, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Pit != "pit-1" {
+		t.Errorf("Pit = %q, want pit-1", c.Pit)
+	}
+	want := []types.FieldValue{json.Number("1715940000000"), json.Number("9223372036854775807")}
+	if len(c.After) != len(want) {
+		t.Fatalf("After = %v, want %v", c.After, want)
+	}
+	for i := range want {
+		if c.After[i] != want[i] {
+			t.Errorf("After[%d] = %#v, want %#v", i, c.After[i], want[i])
+		}
+	}
+}
+
+func TestDecodeCursorErrors(t *testing.T) {
+	f := dto.GrandFilterRequest{FilterStrings: []dto.SearchString{{Search: "выборы"}}}
+	filter, err := filterHash(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := func(c cursor) string {
+		b, err := json.Marshal(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+
+	tests := []struct {
+		name   string
+		cursor string
+		filter dto.GrandFilterRequest
+		want   error
+	}{
+		{"не base64", "!!!", f, ErrBadCursor},
+		{"не JSON", base64.RawURLEncoding.EncodeToString([]byte("{p:")), f, ErrBadCursor},
+		{"без pit", raw(cursor{After: []types.FieldValue{1}, Filter: filter}), f, ErrBadCursor},
+		{"без sort", raw(cursor{Pit: "pit-1", Filter: filter}), f, ErrBadCursor},
+		{"другая строка", cursorFor(t, f, 1), dto.GrandFilterRequest{
+			FilterStrings: []dto.SearchString{{Search: "санкции"}},
+		}, ErrCursorFilter},
+		{"другой порядок", cursorFor(t, f, 1), dto.GrandFilterRequest{
+			FilterStrings: f.FilterStrings, Sort: dto.SortRelevance,
+		}, ErrCursorFilter},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeCursor(tt.cursor, tt.filter); !errors.Is(err, tt.want) {
+				t.Errorf("decodeCursor(%q) error = %v, want %v", tt.cursor, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterHash(t *testing.T) {
+	a := dto.GrandFilterRequest{FilterCountry: []dto.SearchCountry{{Country: "Russia"}}}
+	b := dto.GrandFilterRequest{FilterCountry: []dto.SearchCountry{{Country: "Russia"}}}
+	c := dto.GrandFilterRequest{FilterCountry: []dto.SearchCountry{{Country: "USA"}}}
+
+	ha, err := filterHash(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hb, _ := filterHash(b)
+	hc, _ := filterHash(c)
+	if ha != hb {
+		t.Errorf("filterHash одинаковых фильтров: %q и %q", ha, hb)
+	}
+	if ha == hc {
+		t.Errorf("filterHash разных фильтров совпал: %q", ha)
+	}
+	if len(ha) != 16 {
+		t.Errorf("len(filterHash) = %d, want 16", len(ha))
+	}
+}
+
+// searchHits - ответ _search: count статей из total, pit_id обновлён
+func searchHits(total, count int) string {
+	hits := make([]string, count)
+	for i := range hits {
+		hits[i] = fmt.Sprintf(`{"_index":"articles_v1","_id":"a%d","_source":{"name":"Статья %d"},"sort":[1715940000000,%d]}`, i, i, i)
+	}
+	return fmt.Sprintf(`{"took":1,"timed_out":false,"_shards":{"total":1,"successful":1,"skipped":0,"failed":0},`+
+		`"hits":{"total":{"value":%d,"relation":"eq"},"hits":[%s]},"pit_id":"pit-2"}`, total, strings.Join(hits, ","))
+}
+
+const searchFailed = `{"error":{"type":"search_phase_execution_exception","reason":"all shards failed"},"status":500}`
+
+// closedPits - сколько раз закрывали point in time
+func closedPits(es *fakeES) int {
+	n := 0
+	for _, r := range es.requests {
+		if strings.HasPrefix(r, "DELETE /_pit") {
+			n++
+		}
+	}
+	return n
+}
+
+// Первая страница открывает point in time, полная страница отдаёт курсор,
+// последняя страница закрывает point in time
+func TestFindArticlesPages(t *testing.T) {
+	f := dto.GrandFilterRequest{FilterStrings: []dto.SearchString{{Search: "выборы"}}}
+	r, es := newTestRepository(t, func(n int, body string) (int, string) {
+		switch n {
+		case 1:
+			return http.StatusOK, `{"id":"pit-1"}`
+		case 2:
+			return http.StatusOK, searchHits(3, 2)
+		case 3:
+			return http.StatusOK, searchHits(3, 1)
+		}
+		return http.StatusOK, `{"succeeded":true,"num_freed":1}`
+	})
+
+	first, err := r.FindArticles(context.Background(), f, 2, "", true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first.Hits) != 2 || first.Total != 3 || first.Cursor == "" {
+		t.Fatalf("первая страница %+v, want 2 из 3 с курсором", first)
+	}
+	if !strings.HasPrefix(es.requests[0], "POST /"+ArticleIndex+"/_pit") || !strings.Contains(es.requests[1], `"pit":{"id":"pit-1"`) {
+		t.Errorf("запросы %q", es.requests)
+	}
+	c, err := decodeCursor(first.Cursor, f)
+	if err != nil || c.Pit != "pit-2" {
+		t.Errorf("курсор %+v, %v, want pit-2 из ответа", c, err)
+	}
+	if closedPits(es) != 0 {
+		t.Error("point in time закрыт до последней страницы")
+	}
+
+	last, err := r.FindArticles(context.Background(), f, 2, first.Cursor, true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(last.Hits) != 1 || last.Cursor != "" {
+		t.Errorf("последняя страница %+v, want 1 без курсора", last)
+	}
+	if !strings.Contains(es.requests[2], `"search_after":[1715940000000,1]`) {
+		t.Errorf("второй запрос без search_after: %q", es.requests[2])
+	}
+	if closedPits(es) != 1 {
+		t.Errorf("point in time закрыт %d раз, want 1", closedPits(es))
+	}
+}
+
+// Без paginate выдача - одна страница из индекса без point in time и курсора
+func TestFindArticlesWithoutPagination(t *testing.T) {
+	f := dto.GrandFilterRequest{FilterStrings: []dto.SearchString{{Search: "выборы"}}}
+	r, es := newTestRepository(t, func(n int, body string) (int, string) {
+		return http.StatusOK, searchHits(3, 2)
+	})
+
+	page, err := r.FindArticles(context.Background(), f, 2, "", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Hits) != 2 || page.Cursor != "" {
+		t.Errorf("страница %+v, want 2 без курсора", page)
+	}
+	if len(es.requests) != 1 || !strings.HasPrefix(es.requests[0], "POST /"+ArticleIndex+"/_search") ||
+		strings.Contains(es.requests[0], `"pit"`) || strings.Contains(es.requests[0], "_shard_doc") {
+		t.Errorf("запросы %q", es.requests)
+	}
+}
+
+// Ошибка поиска закрывает только point in time, открытый этим вызовом;
+// point in time из курсора клиента остаётся - клиент может повторить запрос
+func TestFindArticlesPitOnError(t *testing.T) {
+	f := dto.GrandFilterRequest{FilterStrings: []dto.SearchString{{Search: "выборы"}}}
+
+	t.Run("первая страница", func(t *testing.T) {
+		r, es := newTestRepository(t, func(n int, body string) (int, string) {
+			if n == 1 {
+				return http.StatusOK, `{"id":"pit-1"}`
+			}
+			if n == 2 {
+				return http.StatusInternalServerError, searchFailed
+			}
+			return http.StatusOK, `{"succeeded":true,"num_freed":1}`
+		})
+		if _, err := r.FindArticles(context.Background(), f, 2, "", true, false); err == nil {
+			t.Fatal("FindArticles() без ошибки")
+		}
+		if closedPits(es) != 1 {
+			t.Errorf("point in time закрыт %d раз, want 1", closedPits(es))
+		}
+	})
+
+	t.Run("курсор клиента", func(t *testing.T) {
+		r, es := newTestRepository(t, func(n int, body string) (int, string) {
+			return http.StatusInternalServerError, searchFailed
+		})
+		if _, err := r.FindArticles(context.Background(), f, 2, cursorFor(t, f, 1, 2), true, false); err == nil {
+			t.Fatal("FindArticles() без ошибки")
+		}
+		if closedPits(es) != 0 {
+			t.Error("закрыт point in time клиента")
+		}
+	})
+
+	t.Run("курсор устарел", func(t *testing.T) {
+		r, es := newTestRepository(t, func(n int, body string) (int, string) {
+			return http.StatusNotFound, `{"error":{"type":"search_context_missing_exception","reason":"no search context"},"status":404}`
+		})
+		if _, err := r.FindArticles(context.Background(), f, 2, cursorFor(t, f, 1, 2), true, false); !errors.Is(err, ErrCursorExpired) {
+			t.Errorf("FindArticles() error = %v, want ErrCursorExpired", err)
+		}
+		if closedPits(es) != 0 {
+			t.Error("закрыт point in time клиента")
+		}
+	})
+}
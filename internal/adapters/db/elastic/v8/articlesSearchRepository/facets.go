@@ -0,0 +1,128 @@
+package articlesSearchRepository
+
+import (
+	"fmt"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/article"
+	"github.com/mskKote/prospero_backend/pkg/lib"
+	"time"
+)
+
+// Фасеты grandFilter: название агрегации и поле статьи
+const (
+	facetCountry   = "country"
+	facetPublisher = "publisher"
+	facetCategory  = "category"
+	facetLanguage  = "language"
+	facetPerson    = "person"
+	facetDates     = "dates"
+
+	// Сколько значений в фасете и столбцов в гистограмме
+	facetSize        = 20
+	facetDateBuckets = 30
+)
+
+var (
+	facetNames  = []string{facetCountry, facetPublisher, facetCategory, facetLanguage, facetPerson}
+	facetFields = map[string]string{
+		facetCountry:   "address.country",
+		facetPublisher: "publisher.name",
+		facetCategory:  "categories",
+		facetLanguage:  "language",
+		facetPerson:    "people.fullName",
+	}
+)
+
+// facetFilter - все выбранные фасеты, кроме except
+func facetFilter(filters map[string]types.Query, except string) *types.Query {
+	var filter []types.Query
+	for _, name := range facetNames {
+		if q, ok := filters[name]; ok && name != except {
+			filter = append(filter, q)
+		}
+	}
+	if len(filter) == 0 {
+		return &types.Query{MatchAll: &types.MatchAllQuery{}}
+	}
+	return &types.Query{Bool: &types.BoolQuery{Filter: filter}}
+}
+
+// facetAggregations - фасет считается с фильтрами остальных фасетов,
+// а выдачу сужает post_filter: выбор значения не схлопывает свой список
+func facetAggregations(filters map[string]types.Query, timeZone string) map[string]types.Aggregations {
+	aggs := make(map[string]types.Aggregations, len(facetNames)+1)
+	for _, name := range facetNames {
+		aggs[name] = types.Aggregations{
+			Filter: facetFilter(filters, name),
+			Aggregations: map[string]types.Aggregations{
+				"values": {Terms: &types.TermsAggregation{
+					Field: lib.PointerFrom(facetFields[name]),
+					Size:  lib.PointerFrom(facetSize),
+				}},
+			},
+		}
+	}
+
+	histogram := &types.AutoDateHistogramAggregation{
+		Field:   lib.PointerFrom("datePublished"),
+		Buckets: lib.PointerFrom(facetDateBuckets),
+	}
+	if timeZone != "" {
+		histogram.TimeZone = &timeZone
+	}
+	aggs[facetDates] = types.Aggregations{
+		Filter:       facetFilter(filters, ""),
+		Aggregations: map[string]types.Aggregations{"values": {AutoDateHistogram: histogram}},
+	}
+	return aggs
+}
+
+func facetsFrom(aggs map[string]types.Aggregate) (*article.Facets, error) {
+	f := &article.Facets{}
+	for name, into := range map[string]*[]article.FacetBucket{
+		facetCountry:   &f.Countries,
+		facetPublisher: &f.Publishers,
+		facetCategory:  &f.Categories,
+		facetLanguage:  &f.Languages,
+		facetPerson:    &f.People,
+	} {
+		values, err := facetValues(aggs, name)
+		if err != nil {
+			return nil, err
+		}
+		*into = []article.FacetBucket{}
+		switch terms := values.(type) {
+		case *types.StringTermsAggregate:
+			for _, b := range terms.Buckets.([]types.StringTermsBucket) {
+				*into = append(*into, article.FacetBucket{Value: fmt.Sprint(b.Key), Count: b.DocCount})
+			}
+		case *types.UnmappedTermsAggregate:
+			// В партициях ещё нет ни одной статьи
+		default:
+			return nil, fmt.Errorf("фасет %s: неожиданный ответ %T", name, values)
+		}
+	}
+
+	values, err := facetValues(aggs, facetDates)
+	if err != nil {
+		return nil, err
+	}
+	histogram, ok := values.(*types.AutoDateHistogramAggregate)
+	if !ok {
+		return nil, fmt.Errorf("фасет %s: неожиданный ответ %T", facetDates, values)
+	}
+	f.Dates = []article.DateBucket{}
+	f.DateInterval = histogram.Interval
+	for _, b := range histogram.Buckets.([]types.DateHistogramBucket) {
+		f.Dates = append(f.Dates, article.DateBucket{Date: time.UnixMilli(b.Key).UTC(), Count: b.DocCount})
+	}
+	return f, nil
+}
+
+func facetValues(aggs map[string]types.Aggregate, name string) (types.Aggregate, error) {
+	filter, ok := aggs[name].(*types.FilterAggregate)
+	if !ok {
+		return nil, fmt.Errorf("фасет %s: нет в ответе ES", name)
+	}
+	return filter.Aggregations["values"], nil
+}
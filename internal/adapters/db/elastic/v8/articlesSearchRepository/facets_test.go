@@ -0,0 +1,152 @@
+package articlesSearchRepository
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+	"github.com/mskKote/prospero_backend/internal/controller/http/v1/dto"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func termQuery(field, value string) types.Query {
+	return types.Query{Term: map[string]types.TermQuery{field: {Value: value}}}
+}
+
+func TestFacetFilter(t *testing.T) {
+	country, language := termQuery("address.country", "Russia"), termQuery("language", "ru")
+	filters := map[string]types.Query{facetCountry: country, facetLanguage: language}
+
+	tests := []struct {
+		name   string
+		except string
+		want   *types.Query
+	}{
+		{"все фильтры", "", &types.Query{Bool: &types.BoolQuery{Filter: []types.Query{country, language}}}},
+		{"без своего", facetCountry, &types.Query{Bool: &types.BoolQuery{Filter: []types.Query{language}}}},
+		{"фасет без выбора", facetPerson, &types.Query{Bool: &types.BoolQuery{Filter: []types.Query{country, language}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := facetFilter(filters, tt.except); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("facetFilter(%q) = %+v, want %+v", tt.except, got, tt.want)
+			}
+		})
+	}
+
+	if got := facetFilter(map[string]types.Query{facetCountry: country}, facetCountry); got.MatchAll == nil {
+		t.Errorf("единственный фильтр исключён: %+v, want match_all", got)
+	}
+}
+
+// Фасет страны не сужается выбранной страной, гистограмма дат - всеми фильтрами
+func TestFacetAggregations(t *testing.T) {
+	country := termQuery("address.country", "Russia")
+	aggs := facetAggregations(map[string]types.Query{facetCountry: country}, "Europe/Moscow")
+
+	if len(aggs) != len(facetNames)+1 {
+		t.Fatalf("агрегаций %d, want %d", len(aggs), len(facetNames)+1)
+	}
+	if aggs[facetCountry].Filter.MatchAll == nil {
+		t.Errorf("фасет страны с фильтром %+v, want match_all", aggs[facetCountry].Filter)
+	}
+	if f := aggs[facetPublisher].Filter; f.Bool == nil || !reflect.DeepEqual(f.Bool.Filter, []types.Query{country}) {
+		t.Errorf("фасет издателя с фильтром %+v, want страну", f)
+	}
+	if field := *aggs[facetPerson].Aggregations["values"].Terms.Field; field != "people.fullName" {
+		t.Errorf("фасет людей по полю %s", field)
+	}
+	dates := aggs[facetDates]
+	if dates.Filter.Bool == nil || *dates.Aggregations["values"].AutoDateHistogram.TimeZone != "Europe/Moscow" {
+		t.Errorf("гистограмма %+v", dates)
+	}
+}
+
+// facetsResponse - ответ _search с typed_keys: по одному значению в фасете,
+// языков в партициях ещё нет
+const facetsResponse = `{"took":1,"timed_out":false,"_shards":{"total":1,"successful":1,"skipped":0,"failed":0},
+"hits":{"total":{"value":0,"relation":"eq"},"hits":[]},"pit_id":"pit-1",
+"aggregations":{
+"filter#country":{"doc_count":7,"sterms#values":{"buckets":[{"key":"Russia","doc_count":5},{"key":"USA","doc_count":2}]}},
+"filter#publisher":{"doc_count":5,"sterms#values":{"buckets":[{"key":"ТАСС","doc_count":5}]}},
+"filter#category":{"doc_count":5,"sterms#values":{"buckets":[{"key":"Политика","doc_count":3}]}},
+"filter#language":{"doc_count":0,"umterms#values":{"buckets":[]}},
+"filter#person":{"doc_count":5,"sterms#values":{"buckets":[]}},
+"filter#dates":{"doc_count":5,"auto_date_histogram#values":{"interval":"1d","buckets":[{"key":1715904000000,"doc_count":5}]}}
+}}`
+
+func TestFindArticlesFacets(t *testing.T) {
+	f := dto.GrandFilterRequest{FilterCountry: []dto.SearchCountry{{Country: "Russia"}}}
+	r, es := newTestRepository(t, func(n int, body string) (int, string) {
+		if n == 1 {
+			return http.StatusOK, `{"id":"pit-1"}`
+		}
+		if n == 2 {
+			return http.StatusOK, facetsResponse
+		}
+		return http.StatusOK, `{"succeeded":true,"num_freed":1}`
+	})
+
+	page, err := r.FindArticles(context.Background(), f, 10, "", true, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Выбранная страна сужает выдачу через post_filter, а не query
+	var req struct {
+		Query        json.RawMessage            `json:"query"`
+		PostFilter   json.RawMessage            `json:"post_filter"`
+		Aggregations map[string]json.RawMessage `json:"aggregations"`
+	}
+	body := es.requests[1][strings.Index(es.requests[1], "\n")+1:]
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		t.Fatal(err)
+	}
+	if req.Query != nil || !strings.Contains(string(req.PostFilter), "Russia") || len(req.Aggregations) != 6 {
+		t.Errorf("запрос query=%s post_filter=%s агрегаций %d", req.Query, req.PostFilter, len(req.Aggregations))
+	}
+
+	facets := page.Facets
+	if facets == nil {
+		t.Fatal("нет фасетов")
+	}
+	if len(facets.Countries) != 2 || facets.Countries[0].Value != "Russia" || facets.Countries[0].Count != 5 {
+		t.Errorf("страны %+v", facets.Countries)
+	}
+	if facets.Languages == nil || len(facets.Languages) != 0 || facets.People == nil {
+		t.Errorf("пустые фасеты должны быть списками: языки %v, люди %v", facets.Languages, facets.People)
+	}
+	wantDate := time.Date(2024, 5, 17, 0, 0, 0, 0, time.UTC)
+	if facets.DateInterval != "1d" || len(facets.Dates) != 1 || !facets.Dates[0].Date.Equal(wantDate) {
+		t.Errorf("даты %+v с шагом %s", facets.Dates, facets.DateInterval)
+	}
+}
+
+// Без фасетов фильтры остаются в query, агрегаций нет
+func TestFindArticlesWithoutFacets(t *testing.T) {
+	f := dto.GrandFilterRequest{FilterCountry: []dto.SearchCountry{{Country: "Russia"}}}
+	r, es := newTestRepository(t, func(n int, body string) (int, string) {
+		if n == 1 {
+			return http.StatusOK, `{"id":"pit-1"}`
+		}
+		if n == 2 {
+			return http.StatusOK, searchHits(0, 0)
+		}
+		return http.StatusOK, `{"succeeded":true,"num_freed":1}`
+	})
+
+	page, err := r.FindArticles(context.Background(), f, 10, "", true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Facets != nil {
+		t.Errorf("фасеты %+v, want nil", page.Facets)
+	}
+	if body := es.requests[1]; strings.Contains(body, "post_filter") || strings.Contains(body, "aggregations") ||
+		!strings.Contains(body, "Russia") {
+		t.Errorf("запрос %s", body)
+	}
+}
@@ -0,0 +1,47 @@
+package articlesSearchRepository
+
+import (
+	"encoding/json"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+	"github.com/mskKote/prospero_backend/internal/controller/http/v1/dto"
+)
+
+// geoQuery - статьи внутри фигуры на карте. Фигура уже проверена dto.SearchGeo.Validate
+func geoQuery(g dto.SearchGeo) (types.Query, error) {
+	field := g.Field()
+	switch {
+	case g.Distance != nil:
+		return types.Query{GeoDistance: &types.GeoDistanceQuery{
+			Distance:         g.Distance.Distance,
+			GeoDistanceQuery: map[string]types.GeoLocation{field: location(g.Distance.Center)},
+		}}, nil
+
+	case g.BoundingBox != nil:
+		return types.Query{GeoBoundingBox: &types.GeoBoundingBoxQuery{
+			GeoBoundingBoxQuery: map[string]types.GeoBounds{field: types.TopLeftBottomRightGeoBounds{
+				TopLeft:     location(g.BoundingBox.TopLeft),
+				BottomRight: location(g.BoundingBox.BottomRight),
+			}},
+		}}, nil
+	}
+
+	// geo_polygon устарел, многоугольник - geo_shape в GeoJSON: [долгота, широта], кольцо замкнуто
+	ring := make([][2]float64, 0, len(g.Polygon)+1)
+	for _, p := range g.Polygon {
+		ring = append(ring, [2]float64{p.Lon, p.Lat})
+	}
+	if ring[0] != ring[len(ring)-1] {
+		ring = append(ring, ring[0])
+	}
+	shape, err := json.Marshal(map[string]any{"type": "polygon", "coordinates": [][][2]float64{ring}})
+	if err != nil {
+		return types.Query{}, err
+	}
+	return types.Query{GeoShape: &types.GeoShapeQuery{
+		GeoShapeQuery: map[string]types.GeoShapeFieldQuery{field: {Shape: shape}},
+	}}, nil
+}
+
+func location(p dto.GeoPoint) types.LatLonGeoLocation {
+	return types.LatLonGeoLocation{Lat: types.Float64(p.Lat), Lon: types.Float64(p.Lon)}
+}
@@ -0,0 +1,95 @@
+package articlesSearchRepository
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/mskKote/prospero_backend/internal/controller/http/v1/dto"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func geoJSON(t *testing.T, g dto.SearchGeo) string {
+	t.Helper()
+	q, err := geoQuery(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestGeoQuery(t *testing.T) {
+	moscow := dto.GeoPoint{Lat: 55.75, Lon: 37.62}
+	triangle := []dto.GeoPoint{{Lat: 50, Lon: 30}, {Lat: 60, Lon: 30}, {Lat: 55, Lon: 40}}
+
+	tests := []struct {
+		name string
+		geo  dto.SearchGeo
+		want string
+	}{
+		{"радиус", dto.SearchGeo{Distance: &dto.GeoDistance{Center: moscow, Distance: "300km"}},
+			`{"geo_distance":{"address.location":{"lat":55.75,"lon":37.62},"distance":"300km"}}`},
+		{"прямоугольник издания", dto.SearchGeo{Target: dto.GeoTargetPublisher, BoundingBox: &dto.GeoBoundingBox{
+			TopLeft: dto.GeoPoint{Lat: 60, Lon: 30}, BottomRight: dto.GeoPoint{Lat: 50, Lon: 40},
+		}}, `{"geo_bounding_box":{"publisher.address.location":{"bottom_right":{"lat":50,"lon":40},"top_left":{"lat":60,"lon":30}}}}`},
+		// GeoJSON: [долгота, широта], кольцо замыкается
+		{"многоугольник", dto.SearchGeo{Polygon: triangle},
+			`{"geo_shape":{"address.location":{"shape":{"coordinates":[[[30,50],[30,60],[40,55],[30,50]]],"type":"polygon"}}}}`},
+		{"замкнутый многоугольник", dto.SearchGeo{Polygon: append(triangle, triangle[0])},
+			`{"geo_shape":{"address.location":{"shape":{"coordinates":[[[30,50],[30,60],[40,55],[30,50]]],"type":"polygon"}}}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := geoJSON(t, tt.geo); got != tt.want {
+				t.Errorf("geoQuery() = %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// Области объединяются через should, не сужая поиск по строкам
+func TestFindArticlesGeo(t *testing.T) {
+	r, es := newTestRepository(t, func(n int, body string) (int, string) {
+		if n == 1 {
+			return http.StatusOK, `{"id":"pit-1"}`
+		}
+		if n == 2 {
+			return http.StatusOK, searchHits(0, 0)
+		}
+		return http.StatusOK, `{"succeeded":true,"num_freed":1}`
+	})
+	moscow := dto.GeoPoint{Lat: 55.75, Lon: 37.62}
+	f := dto.GrandFilterRequest{FilterGeo: []dto.SearchGeo{
+		{Distance: &dto.GeoDistance{Center: moscow, Distance: "300km"}},
+		{Target: dto.GeoTargetPublisher, Distance: &dto.GeoDistance{Center: moscow, Distance: "10km"}},
+	}}
+	if _, err := r.FindArticles(context.Background(), f, 10, "", true, false); err != nil {
+		t.Fatal(err)
+	}
+
+	var req struct {
+		Query struct {
+			Bool struct {
+				Must   []json.RawMessage `json:"must"`
+				Filter []struct {
+					Bool struct {
+						Should             []json.RawMessage `json:"should"`
+						MinimumShouldMatch json.RawMessage   `json:"minimum_should_match"`
+					} `json:"bool"`
+				} `json:"filter"`
+			} `json:"bool"`
+		} `json:"query"`
+	}
+	body := es.requests[1][strings.Index(es.requests[1], "\n")+1:]
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		t.Fatal(err)
+	}
+	b := req.Query.Bool
+	if len(b.Must) != 0 || len(b.Filter) != 1 || len(b.Filter[0].Bool.Should) != 2 || string(b.Filter[0].Bool.MinimumShouldMatch) != "1" {
+		t.Errorf("запрос %s", body)
+	}
+}
@@ -0,0 +1,95 @@
+package articlesSearchRepository
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/mskKote/prospero_backend/internal/controller/http/v1/dto"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const highlightResponse = `{"took":1,"timed_out":false,"_shards":{"total":1,"successful":1,"skipped":0,"failed":0},
+"hits":{"total":{"value":1,"relation":"eq"},"max_score":2.5,"hits":[{"_index":"article_v1_2024.05","_id":"a1","_score":2.5,
+"_source":{"name":"Выборы <в> Москве","description":"Итоги"},
+"highlight":{"name":["<mark>Выборы</mark> &lt;в&gt; Москве"]},"sort":[1715940000000,1]}]},"pit_id":"pit-1"}`
+
+func TestFindArticlesHighlight(t *testing.T) {
+	r, es := newTestRepository(t, func(n int, body string) (int, string) {
+		switch n {
+		case 1:
+			return http.StatusOK, `{"id":"pit-1"}`
+		case 2:
+			return http.StatusOK, highlightResponse
+		}
+		return http.StatusOK, `{"succeeded":true,"num_freed":1}`
+	})
+
+	f := dto.GrandFilterRequest{FilterStrings: []dto.SearchString{{Search: "выборы"}}}
+	page, err := r.FindArticles(context.Background(), f, 10, "", true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var req struct {
+		TrackScores bool `json:"track_scores"`
+		Highlight   struct {
+			Encoder  string                     `json:"encoder"`
+			PreTags  []string                   `json:"pre_tags"`
+			PostTags []string                   `json:"post_tags"`
+			Fields   map[string]json.RawMessage `json:"fields"`
+		} `json:"highlight"`
+	}
+	body := es.requests[1][strings.Index(es.requests[1], "\n")+1:]
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		t.Fatal(err)
+	}
+	h := req.Highlight
+	if !req.TrackScores || h.Encoder != "html" || len(h.Fields) != 2 ||
+		h.PreTags[0] != cfg.SearchHighlight.PreTag || h.PostTags[0] != cfg.SearchHighlight.PostTag {
+		t.Errorf("запрос track_scores=%t highlight=%+v", req.TrackScores, h)
+	}
+
+	if len(page.Hits) != 1 {
+		t.Fatalf("статей %d, want 1", len(page.Hits))
+	}
+	hit := page.Hits[0]
+	if hit.ID != "a1" || hit.EsArticleDBO.ID != "a1" || hit.Score != 2.5 || hit.Name != "Выборы <в> Москве" {
+		t.Errorf("статья %+v", hit)
+	}
+	if got := hit.Highlight["name"]; len(got) != 1 || got[0] != "<mark>Выборы</mark> &lt;в&gt; Москве" {
+		t.Errorf("подсветка %v", hit.Highlight)
+	}
+
+	// ID документа уходит клиенту рядом с полями статьи
+	b, err := json.Marshal(hit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out map[string]any
+	_ = json.Unmarshal(b, &out)
+	if out["id"] != "a1" || out["name"] != "Выборы <в> Москве" || out["highlight"] == nil {
+		t.Errorf("JSON статьи %s", b)
+	}
+}
+
+// Без поисковых строк подсвечивать нечего
+func TestFindArticlesWithoutHighlight(t *testing.T) {
+	r, es := newTestRepository(t, func(n int, body string) (int, string) {
+		if n == 1 {
+			return http.StatusOK, `{"id":"pit-1"}`
+		}
+		if n == 2 {
+			return http.StatusOK, searchHits(0, 0)
+		}
+		return http.StatusOK, `{"succeeded":true,"num_freed":1}`
+	})
+
+	f := dto.GrandFilterRequest{FilterCountry: []dto.SearchCountry{{Country: "Russia"}}}
+	if _, err := r.FindArticles(context.Background(), f, 10, "", true, false); err != nil {
+		t.Fatal(err)
+	}
+	if body := es.requests[1]; strings.Contains(body, "highlight") || strings.Contains(body, "track_scores") {
+		t.Errorf("запрос %s", body)
+	}
+}
@@ -0,0 +1,202 @@
+package articlesSearchRepository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/core/search"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/sortorder"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/article"
+	"github.com/mskKote/prospero_backend/pkg/lib"
+	"go.uber.org/zap"
+)
+
+const (
+	// legacyPage - статей за один запрос при замене старых ID
+	legacyPage = 500
+	// maxResultWindow - больше документов одним запросом ES не отдаёт
+	maxResultWindow = 10000
+	legacyKeepAlive = "5m"
+)
+
+// LegacyStats - итог ReplaceLegacyIDs
+type LegacyStats struct {
+	// Scanned - просмотрено статей
+	Scanned int64
+	// Rekeyed - статей переписано под StableID
+	Rekeyed int64
+	// Deleted - удалено документов со старыми ID
+	Deleted int64
+	// Failed - старых документов, которые не удалось заменить, они остались
+	Failed int64
+}
+
+// legacyArticle - статья со случайным ID и индекс, где она лежит
+type legacyArticle struct {
+	index string
+	id    string
+	*article.EsArticleDBO
+}
+
+func (r *repository) ReplaceLegacyIDs(ctx context.Context) (*LegacyStats, error) {
+	pit, err := r.client.OpenPointInTime(ArticleIndex).KeepAlive(legacyKeepAlive).Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pitID := pit.Id
+	defer func() { r.closePit(pitID) }()
+
+	stats := &LegacyStats{}
+	var after []types.FieldValue
+	for {
+		resp, err := r.client.Search().Request(&search.Request{
+			Size:        lib.PointerFrom(legacyPage),
+			Pit:         &types.PointInTimeReference{Id: pitID, KeepAlive: lib.PointerFrom(legacyKeepAlive)},
+			SearchAfter: after,
+			Sort:        types.Sort{map[string]types.FieldSort{"_shard_doc": {Order: lib.PointerFrom(sortorder.Asc)}}},
+		}).Do(ctx)
+		if err != nil {
+			return stats, err
+		}
+		if resp.PitId != nil {
+			pitID = *resp.PitId
+		}
+
+		hits := resp.Hits.Hits
+		var legacy []legacyArticle
+		for _, hit := range hits {
+			stats.Scanned++
+			if article.IsStableID(hit.Id_) {
+				continue
+			}
+			var a *article.EsArticleDBO
+			if err := json.Unmarshal(hit.Source_, &a); err != nil {
+				return stats, err
+			}
+			legacy = append(legacy, legacyArticle{index: hit.Index_, id: hit.Id_, EsArticleDBO: a})
+		}
+		if len(legacy) > 0 {
+			if err := r.replaceLegacy(ctx, legacy, stats); err != nil {
+				return stats, err
+			}
+			logger.Info(fmt.Sprintf("Старые ID: просмотрено %d, переписано %d, удалено %d",
+				stats.Scanned, stats.Rekeyed, stats.Deleted))
+		}
+
+		if len(hits) < legacyPage {
+			if stats.Failed > 0 {
+				return stats, fmt.Errorf("не заменили статей со старыми ID: %d", stats.Failed)
+			}
+			return stats, nil
+		}
+		after = hits[len(hits)-1].Sort
+	}
+}
+
+// replaceLegacy переписывает пачку старых статей под StableID и удаляет их.
+// Статья, уже записанная со StableID, не дублируется - старая просто удаляется.
+// GUID в документе не хранится, поэтому ID строится по ссылке
+func (r *repository) replaceLegacy(ctx context.Context, legacy []legacyArticle, stats *LegacyStats) error {
+	var urls []string
+	for _, l := range legacy {
+		if l.URL != "" {
+			urls = append(urls, l.URL)
+		}
+	}
+	known, err := r.stableURLs(ctx, urls)
+	if err != nil {
+		return err
+	}
+
+	// Сначала пишем новые документы: старый удаляем, только если замена записана.
+	// Копии одной статьи заменяет один документ
+	var writes []BulkDocument
+	writeOf, writeFor := make([]int, len(legacy)), map[string]int{}
+	for i, l := range legacy {
+		writeOf[i] = -1
+		id := article.StableID(l.Publisher.Name, "", l.URL)
+		// Без ссылки статью не опознать, парсинг такие больше не пишет
+		if id == "" || known[l.URL] {
+			continue
+		}
+		if w, ok := writeFor[id]; ok {
+			writeOf[i] = w
+			continue
+		}
+		l.ID = id
+		writeOf[i], writeFor[id] = len(writes), len(writes)
+		writes = append(writes, BulkDocument{Index: ArticleIndexFor(l.DatePublished), ID: id, Doc: l.EsArticleDBO, Upsert: true})
+	}
+	var written []BulkItemResult
+	if len(writes) > 0 {
+		if written, err = r.Bulk(ctx, writes); err != nil {
+			return err
+		}
+	}
+	for _, res := range written {
+		if res.Err == nil {
+			stats.Rekeyed++
+		}
+	}
+
+	var deletes []BulkDocument
+	for i, l := range legacy {
+		if w := writeOf[i]; w >= 0 && written[w].Err != nil {
+			logger.Error("Статья не переписана под StableID", zap.String("id", l.id), zap.Error(written[w].Err))
+			stats.Failed++
+			continue
+		}
+		deletes = append(deletes, BulkDocument{Index: l.index, ID: l.id, Delete: true})
+	}
+	if len(deletes) == 0 {
+		return nil
+	}
+	deleted, err := r.Bulk(ctx, deletes)
+	if err != nil {
+		return err
+	}
+	for i, res := range deleted {
+		if res.Err != nil {
+			logger.Error("Статья со старым ID не удалена", zap.String("id", deletes[i].ID), zap.Error(res.Err))
+			stats.Failed++
+			continue
+		}
+		stats.Deleted++
+	}
+	return nil
+}
+
+// stableURLs - ссылки, по которым уже есть статьи со StableID
+func (r *repository) stableURLs(ctx context.Context, urls []string) (map[string]bool, error) {
+	known := map[string]bool{}
+	if len(urls) == 0 {
+		return known, nil
+	}
+	values := make([]types.FieldValue, len(urls))
+	for i, u := range urls {
+		values[i] = u
+	}
+	resp, err := r.client.Search().Index(ArticleIndex).Request(&search.Request{
+		// Со старыми копиями по ссылке может найтись несколько документов
+		Size:    lib.PointerFrom(maxResultWindow),
+		Source_: false,
+		Query: &types.Query{Bool: &types.BoolQuery{Filter: []types.Query{{
+			Terms: &types.TermsQuery{TermsQuery: map[string]types.TermsQueryField{"URL": values}},
+		}}}},
+		DocvalueFields: []types.FieldAndFormat{{Field: "URL"}},
+	}).Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, hit := range resp.Hits.Hits {
+		if !article.IsStableID(hit.Id_) {
+			continue
+		}
+		var url []string
+		if err := json.Unmarshal(hit.Fields["URL"], &url); err == nil && len(url) > 0 {
+			known[url[0]] = true
+		}
+	}
+	return known, nil
+}
@@ -0,0 +1,125 @@
+package articlesSearchRepository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/article"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Выдача по всем статьям: одна уже со StableID, остальные - со случайными ID ES
+const legacyHits = `{"hits":{"total":{"value":5,"relation":"eq"},"hits":[
+	{"_index":"article_v3_2024.03","_id":"%s","_source":{"name":"Новая","URL":"https://a/1","publisher":{"name":"ТАСС"}},"sort":[1]},
+	{"_index":"articles_v1","_id":"copyOfNew0000000001","_source":{"name":"Копия новой","URL":"https://a/1","publisher":{"name":"ТАСС"}},"sort":[2]},
+	{"_index":"articles_v1","_id":"legacy0000000000001","_source":{"name":"Старая","URL":"https://a/2","datePublished":"2024-03-01T10:00:00Z","publisher":{"name":"ТАСС"}},"sort":[3]},
+	{"_index":"articles_v1","_id":"legacy0000000000002","_source":{"name":"Старая","URL":"https://a/2","datePublished":"2024-03-01T10:00:00Z","publisher":{"name":"ТАСС"}},"sort":[4]},
+	{"_index":"articles_v1","_id":"noURL00000000000001","_source":{"name":"Без ссылки","publisher":{"name":"ТАСС"}},"sort":[5]}
+]}}`
+
+var stableID = article.StableID("ТАСС", "guid-1", "https://a/1")
+
+// bulkOps - операции _bulk из тела запроса: "update id index" и "delete id index"
+func bulkOps(body string) []string {
+	var ops []string
+	for _, line := range strings.Split(strings.TrimSpace(body), "\n") {
+		var action map[string]struct {
+			Index string `json:"_index"`
+			ID    string `json:"_id"`
+		}
+		if json.Unmarshal([]byte(line), &action) != nil {
+			continue
+		}
+		for op, a := range action {
+			if op == "update" || op == "delete" {
+				ops = append(ops, op+" "+a.ID+" "+a.Index)
+			}
+		}
+	}
+	return ops
+}
+
+// legacyES отвечает на запросы ReplaceLegacyIDs по порядку.
+// failWrite - записи под StableID отказываются
+func legacyES(t *testing.T, failWrite bool) (*repository, *fakeES) {
+	withBulkRetries(t, 0)
+	return newTestRepository(t, func(n int, body string) (int, string) {
+		switch n {
+		case 1:
+			return http.StatusOK, `{"id":"pit-1"}`
+		case 2:
+			return http.StatusOK, fmt.Sprintf(legacyHits, stableID)
+		case 3:
+			// Ссылки, по которым уже есть статьи: StableID и старая копия
+			return http.StatusOK, fmt.Sprintf(`{"hits":{"total":{"value":2,"relation":"eq"},"hits":[
+				{"_index":"article_v3_2024.03","_id":"%s","fields":{"URL":["https://a/1"]}},
+				{"_index":"articles_v1","_id":"copyOfNew0000000001","fields":{"URL":["https://a/1"]}}]}}`, stableID)
+		case 4, 5:
+			var items []string
+			for _, op := range bulkOps(body) {
+				f := strings.Fields(op)
+				if f[0] == "update" && failWrite {
+					items = append(items, fmt.Sprintf(`{"update":{"_index":%q,"_id":%q,"status":400,`+
+						`"error":{"type":"mapper_parsing_exception","reason":"bad"}}}`, f[2], f[1]))
+					continue
+				}
+				items = append(items, fmt.Sprintf(`{%q:{"_index":%q,"_id":%q,"status":200,"result":"deleted"}}`, f[0], f[2], f[1]))
+			}
+			return http.StatusOK, `{"took":1,"errors":false,"items":[` + strings.Join(items, ",") + `]}`
+		}
+		return http.StatusOK, `{"succeeded":true,"num_freed":1}`
+	})
+}
+
+func TestReplaceLegacyIDs(t *testing.T) {
+	r, es := legacyES(t, false)
+
+	stats, err := r.ReplaceLegacyIDs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *stats != (LegacyStats{Scanned: 5, Rekeyed: 1, Deleted: 4}) {
+		t.Errorf("ReplaceLegacyIDs() = %+v", stats)
+	}
+
+	// Две копии старой статьи - один документ по ссылке в партиции её даты
+	published := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	rekeyed := article.StableID("ТАСС", "", "https://a/2")
+	if got := bulkOps(es.requests[3]); fmt.Sprint(got) != fmt.Sprint([]string{"update " + rekeyed + " " + ArticleIndexFor(&published)}) {
+		t.Errorf("запись %v", got)
+	}
+	// Копия статьи со StableID и статья без ссылки удаляются без замены
+	wantDeleted := []string{
+		"delete copyOfNew0000000001 articles_v1",
+		"delete legacy0000000000001 articles_v1",
+		"delete legacy0000000000002 articles_v1",
+		"delete noURL00000000000001 articles_v1",
+	}
+	if got := bulkOps(es.requests[4]); fmt.Sprint(got) != fmt.Sprint(wantDeleted) {
+		t.Errorf("удаление %v, want %v", got, wantDeleted)
+	}
+	if !strings.HasPrefix(es.requests[len(es.requests)-1], "DELETE /_pit") {
+		t.Errorf("point in time не закрыт: %s", es.requests[len(es.requests)-1])
+	}
+}
+
+// Старая статья остаётся, если замену не записали
+func TestReplaceLegacyIDsWriteFailed(t *testing.T) {
+	r, es := legacyES(t, true)
+
+	stats, err := r.ReplaceLegacyIDs(context.Background())
+	if err == nil {
+		t.Fatal("ReplaceLegacyIDs() без ошибки, want про незаменённые статьи")
+	}
+	if *stats != (LegacyStats{Scanned: 5, Deleted: 2, Failed: 2}) {
+		t.Errorf("ReplaceLegacyIDs() = %+v", stats)
+	}
+	for _, op := range bulkOps(es.requests[4]) {
+		if strings.Contains(op, "legacy") {
+			t.Errorf("удалена статья без замены: %s", op)
+		}
+	}
+}
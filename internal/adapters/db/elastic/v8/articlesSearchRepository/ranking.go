@@ -0,0 +1,81 @@
+package articlesSearchRepository
+
+import (
+	"fmt"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/fieldsortnumerictype"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/functionboostmode"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/functionscoremode"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/sortorder"
+	"github.com/mskKote/prospero_backend/internal/controller/http/v1/dto"
+	"github.com/mskKote/prospero_backend/pkg/lib"
+	"sort"
+	"time"
+)
+
+// sortBy - порядок выдачи. С point in time последний ключ - _shard_doc:
+// без него search_after пропускал бы статьи с одинаковыми ключами
+func sortBy(mode string, pit bool) types.Sort {
+	byDate := map[string]types.FieldSort{
+		"datePublished": {
+			NumericType: lib.PointerFrom(fieldsortnumerictype.Date),
+			Order:       lib.PointerFrom(sortorder.Desc),
+		},
+	}
+	order := types.Sort{byDate}
+	if mode != dto.SortDate {
+		byScore := map[string]types.FieldSort{"_score": {Order: lib.PointerFrom(sortorder.Desc)}}
+		order = types.Sort{byScore, byDate}
+	}
+	if pit {
+		order = append(order, map[string]types.FieldSort{"_shard_doc": {Order: lib.PointerFrom(sortorder.Asc)}})
+	}
+	return order
+}
+
+// rank - для hybrid оценка совпадения умножается на затухание по дате публикации
+// и на вес издания из search_ranking. Издания без веса - 1
+func rank(mode string, query *types.Query) *types.Query {
+	if mode != dto.SortHybrid {
+		return query
+	}
+	ranking := cfg.SearchRanking
+
+	functions := []types.FunctionScore{{
+		Gauss: types.DateDecayFunction{
+			DateDecayFunction: map[string]types.DecayPlacementDateMathDuration{
+				"datePublished": {
+					Origin: lib.PointerFrom("now"),
+					Scale:  esDuration(ranking.RecencyScale),
+					Offset: esDuration(ranking.RecencyOffset),
+					Decay:  lib.PointerFrom(types.Float64(ranking.RecencyDecay)),
+				},
+			},
+		},
+	}}
+
+	// Порядок изданий постоянный - запрос одинаковый от раза к разу
+	publishers := make([]string, 0, len(ranking.PublisherWeights))
+	for name := range ranking.PublisherWeights {
+		publishers = append(publishers, name)
+	}
+	sort.Strings(publishers)
+	for _, name := range publishers {
+		functions = append(functions, types.FunctionScore{
+			Filter: &types.Query{Term: map[string]types.TermQuery{"publisher.name": {Value: name}}},
+			Weight: lib.PointerFrom(types.Float64(ranking.PublisherWeights[name])),
+		})
+	}
+
+	return &types.Query{FunctionScore: &types.FunctionScoreQuery{
+		Query:     query,
+		Functions: functions,
+		ScoreMode: &functionscoremode.Multiply,
+		BoostMode: &functionboostmode.Multiply,
+	}}
+}
+
+// esDuration - длительность в единицах ES: 5m0s он не понимает
+func esDuration(d time.Duration) string {
+	return fmt.Sprintf("%ds", int(d.Seconds()))
+}
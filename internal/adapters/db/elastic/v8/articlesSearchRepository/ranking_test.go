@@ -0,0 +1,145 @@
+package articlesSearchRepository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+	"github.com/mskKote/prospero_backend/internal/controller/http/v1/dto"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func withRanking(t *testing.T, weights map[string]float64) {
+	t.Helper()
+	old := cfg.SearchRanking
+	t.Cleanup(func() { cfg.SearchRanking = old })
+	cfg.SearchRanking.RecencyScale = 7 * 24 * time.Hour
+	cfg.SearchRanking.RecencyOffset = 24 * time.Hour
+	cfg.SearchRanking.RecencyDecay = 0.5
+	cfg.SearchRanking.PublisherWeights = weights
+}
+
+// sortKeys - поля сортировки по порядку
+func sortKeys(s types.Sort) []string {
+	var keys []string
+	for _, option := range s {
+		for field := range option.(map[string]types.FieldSort) {
+			keys = append(keys, field)
+		}
+	}
+	return keys
+}
+
+func TestSortBy(t *testing.T) {
+	tests := []struct {
+		mode string
+		pit  bool
+		want string
+	}{
+		{dto.SortDate, true, "[datePublished _shard_doc]"},
+		{dto.SortRelevance, true, "[_score datePublished _shard_doc]"},
+		{dto.SortHybrid, true, "[_score datePublished _shard_doc]"},
+		// _shard_doc есть только у point in time
+		{dto.SortDate, false, "[datePublished]"},
+		{dto.SortHybrid, false, "[_score datePublished]"},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s pit=%t", tt.mode, tt.pit), func(t *testing.T) {
+			if got := fmt.Sprint(sortKeys(sortBy(tt.mode, tt.pit))); got != tt.want {
+				t.Errorf("sortBy(%s, %t) = %s, want %s", tt.mode, tt.pit, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRank(t *testing.T) {
+	withRanking(t, map[string]float64{"ТАСС": 1.5, "Ведомости": 0.8})
+	query := &types.Query{MatchAll: &types.MatchAllQuery{}}
+
+	for _, mode := range []string{dto.SortDate, dto.SortRelevance} {
+		if got := rank(mode, query); got != query {
+			t.Errorf("rank(%s) изменил запрос: %+v", mode, got)
+		}
+	}
+
+	fs := rank(dto.SortHybrid, query).FunctionScore
+	if fs == nil || fs.Query != query || len(fs.Functions) != 3 {
+		t.Fatalf("rank(hybrid) = %+v", fs)
+	}
+	if fs.ScoreMode.Name != "multiply" || fs.BoostMode.Name != "multiply" {
+		t.Errorf("score_mode %s, boost_mode %s, want multiply", fs.ScoreMode, fs.BoostMode)
+	}
+	decay := fs.Functions[0].Gauss.(types.DateDecayFunction).DateDecayFunction["datePublished"]
+	if *decay.Origin != "now" || decay.Scale != "604800s" || decay.Offset != "86400s" || *decay.Decay != 0.5 {
+		t.Errorf("затухание %+v", decay)
+	}
+	// Издания по алфавиту - запрос не меняется от раза к разу
+	var weights []string
+	for _, f := range fs.Functions[1:] {
+		for _, term := range f.Filter.Term {
+			weights = append(weights, fmt.Sprintf("%v=%v", term.Value, *f.Weight))
+		}
+	}
+	if got := fmt.Sprint(weights); got != "[Ведомости=0.8 ТАСС=1.5]" {
+		t.Errorf("веса изданий %s", got)
+	}
+}
+
+func TestEsDuration(t *testing.T) {
+	if got := esDuration(5 * time.Minute); got != "300s" {
+		t.Errorf("esDuration(5m) = %s, want 300s", got)
+	}
+}
+
+// relevance сортирует по оценке и не просит track_scores, hybrid оборачивает запрос в function_score
+func TestFindArticlesSort(t *testing.T) {
+	withRanking(t, nil)
+	tests := []struct {
+		mode        string
+		trackScores bool
+		function    bool
+	}{
+		{dto.SortDate, true, false},
+		{dto.SortRelevance, false, false},
+		{dto.SortHybrid, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			r, es := newTestRepository(t, func(n int, body string) (int, string) {
+				if n == 1 {
+					return http.StatusOK, `{"id":"pit-1"}`
+				}
+				if n == 2 {
+					return http.StatusOK, searchHits(0, 0)
+				}
+				return http.StatusOK, `{"succeeded":true,"num_freed":1}`
+			})
+			f := dto.GrandFilterRequest{FilterStrings: []dto.SearchString{{Search: "выборы"}}, Sort: tt.mode}
+			if _, err := r.FindArticles(context.Background(), f, 10, "", true, false); err != nil {
+				t.Fatal(err)
+			}
+
+			var req struct {
+				TrackScores bool `json:"track_scores"`
+				Query       struct {
+					FunctionScore json.RawMessage `json:"function_score"`
+				} `json:"query"`
+			}
+			body := es.requests[1][strings.Index(es.requests[1], "\n")+1:]
+			if err := json.Unmarshal([]byte(body), &req); err != nil {
+				t.Fatal(err)
+			}
+			if req.TrackScores != tt.trackScores || (req.Query.FunctionScore != nil) != tt.function {
+				t.Errorf("track_scores=%t function_score=%s", req.TrackScores, req.Query.FunctionScore)
+			}
+		})
+	}
+
+	r, _ := newTestRepository(t, func(n int, body string) (int, string) { return http.StatusOK, `{"id":"pit-1"}` })
+	if _, err := r.FindArticles(context.Background(), dto.GrandFilterRequest{Sort: "score"}, 10, "", true, false); err == nil {
+		t.Error("неизвестный порядок без ошибки")
+	}
+}
@@ -3,13 +3,16 @@ package publishersSearchRepository
 import (
 	"context"
 	"github.com/mskKote/prospero_backend/internal/domain/entity/publisher"
+	"github.com/mskKote/prospero_backend/pkg/client/elastic"
 )
 
 type IRepository interface {
+	// Setup создаёт индекс или переводит его на новую версию маппинга
 	Setup(ctx context.Context)
-	Exists(ctx context.Context) bool
-	Delete(ctx context.Context)
-	Create(ctx context.Context) error
+	Version() *elastic.VersionedIndex
 	FindPublishersByNameViaES(ctx context.Context, name string) ([]*publisher.EsDBO, error)
+	// IndexPublisher создаёт документ или перезаписывает его, если задан p.PublisherID
 	IndexPublisher(ctx context.Context, p *publisher.EsDBO) bool
+	FindAll(ctx context.Context) ([]*publisher.EsDBO, error)
+	DeletePublisher(ctx context.Context, id string) error
 }
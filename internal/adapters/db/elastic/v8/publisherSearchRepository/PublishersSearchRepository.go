@@ -11,18 +11,28 @@ import (
 	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/result"
 	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/tokenchar"
 	"github.com/mskKote/prospero_backend/internal/domain/entity/publisher"
+	"github.com/mskKote/prospero_backend/pkg/client/elastic"
+	"github.com/mskKote/prospero_backend/pkg/config"
 	"github.com/mskKote/prospero_backend/pkg/lib"
 	"github.com/mskKote/prospero_backend/pkg/logging"
 	"go.uber.org/zap"
-	"log"
-	"net/http"
 	"strings"
 	"time"
 )
 
-const Index = "publisher"
+// Index - алиас для чтения, WriteIndex - для записи.
+// Физический индекс: publisher_v<version>
+const (
+	Index      = "publisher"
+	WriteIndex = Index + "_write"
+	// Поднять при изменении маппинга
+	version = 1
+)
 
-var logger = logging.GetLogger().With(zap.String("prefix", "[ES]"))
+var (
+	logger = logging.GetLogger().With(zap.String("prefix", "[ES]"))
+	cfg    = config.GetConfig()
+)
 
 type repository struct {
 	client *elasticsearch.TypedClient
@@ -38,63 +48,13 @@ type DTO struct {
 }
 
 func (r *repository) Setup(ctx context.Context) {
-	// 1. Создать индекс публициста
-	if r.Exists(ctx) {
-		logger.Info(fmt.Sprintf("Индекс %s уже существует, удаляем", Index))
-		r.Delete(ctx)
-	}
-
-	if err := r.Create(ctx); err != nil {
+	// Издателей в индекс кладёт сверка с каталогом (catalogueService)
+	if _, err := elastic.NewIndices(r.client).Ensure(ctx, r.Version(), cfg.ElasticAutoUpgrade); err != nil {
 		logger.Fatal("Проблема с индексом публициста", zap.Error(err))
 	}
-
-	// 2. Закинуть дефолтные значения
-	publishers := []publisher.EsDBO{
-		{Name: "The New York Times"},
-		{Name: "The Guardian"},
-		{Name: "Vedomosti"},
-		{Name: "ООН"},
-		{Name: "Hindustan Times"},
-		{Name: "Rambler"},
-		{Name: "lenta.ru"},
-		{Name: "Wall Street Journal"},
-		{Name: "France 24"},
-		{Name: "CNN"},
-		{Name: "Meduza"},
-	}
-	for _, p := range publishers {
-		if ok := r.IndexPublisher(ctx, &p); !ok {
-			logger.Fatal("Не записали данные в " + Index)
-		}
-	}
-
-	// 3. Неточный поиск
-	time.Sleep(2 * time.Second)
-	if p, err := r.FindPublishersByNameViaES(ctx, "the new"); err != nil {
-		logger.Fatal("Не нашли...", zap.Error(err))
-	} else {
-		for _, dbo := range p {
-			logger.Info(fmt.Sprintf("Нашли %s с id=[%s] добавленный %s", dbo.Name, dbo.PublisherID, dbo.AddDate))
-		}
-	}
 }
 
-func (r *repository) Exists(ctx context.Context) bool {
-	if resp, err := r.client.Indices.Exists(Index).Perform(ctx); err != nil {
-		logger.Error("Не смогли узнать о существовании индекса", zap.Error(err))
-		return false
-	} else {
-		return resp.StatusCode == http.StatusOK
-	}
-}
-
-func (r *repository) Delete(ctx context.Context) {
-	if _, err := r.client.Indices.Delete(Index).Do(ctx); err != nil {
-		logger.Error("Не удалили индекс "+Index, zap.Error(err))
-	}
-}
-
-func (r *repository) Create(ctx context.Context) error {
+func (r *repository) Version() *elastic.VersionedIndex {
 	// Разбивает предложения по 2 буквы, включая пробелы
 	// Для поиска названий
 	MyTokenizer := types.NGramTokenizer{
@@ -103,9 +63,10 @@ func (r *repository) Create(ctx context.Context) error {
 		TokenChars: []tokenchar.TokenChar{tokenchar.Letter, tokenchar.Digit, tokenchar.Whitespace},
 		Type:       "ngram",
 	}
-	//logger.Info(types.NewLowercaseTokenFilter().Type) // -> "lowercase"
-	res, err := r.client.Indices.Create(Index).
-		Request(&create.Request{
+	return &elastic.VersionedIndex{
+		Alias:   Index,
+		Version: version,
+		Request: &create.Request{
 			Settings: &types.IndexSettings{
 				Analysis: &types.IndexSettingsAnalysis{
 					Tokenizer: map[string]types.Tokenizer{
@@ -135,22 +96,18 @@ func (r *repository) Create(ctx context.Context) error {
 					},
 				},
 			},
-		}).
-		Do(ctx)
-
-	if err != nil {
-		logger.Error("Не создали индекс " + Index)
-	} else {
-		log.Println(res)
+		},
 	}
-
-	return err
 }
 
 func (r *repository) IndexPublisher(ctx context.Context, p *publisher.EsDBO) bool {
-	res, err := r.client.Index(Index).
-		Request(DTO{AddDate: time.Now(), Name: p.Name}).
-		Do(ctx)
+	req := r.client.Index(WriteIndex).
+		Request(DTO{AddDate: time.Now(), Name: p.Name})
+	// С ID документ перезаписывается, без него - создаётся новый
+	if p.PublisherID != "" {
+		req.Id(p.PublisherID)
+	}
+	res, err := req.Do(ctx)
 
 	if err != nil {
 		logger.Error("Не записали данные в "+Index, zap.Error(err))
@@ -159,7 +116,32 @@ func (r *repository) IndexPublisher(ctx context.Context, p *publisher.EsDBO) boo
 		p.AddDate = time.Now()
 		logger.Info(fmt.Sprintf("Добавили %s в ES[%s] с id=[%s]", p.Name, Index, res.Id_))
 	}
-	return res.Result == result.Created
+	return err == nil && (res.Result == result.Created || res.Result == result.Updated)
+}
+
+// maxPublishers - сколько издателей отдаёт FindAll, индекс небольшой
+const maxPublishers = 10000
+
+func (r *repository) FindAll(ctx context.Context) ([]*publisher.EsDBO, error) {
+	resp, err := r.client.Search().
+		Index(Index).
+		Request(&search.Request{
+			Size:  lib.PointerFrom(maxPublishers),
+			Query: &types.Query{MatchAll: &types.MatchAllQuery{}},
+		}).
+		Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return hitsToPublishers(resp.Hits.Hits)
+}
+
+func (r *repository) DeletePublisher(ctx context.Context, id string) error {
+	_, err := r.client.Delete(WriteIndex, id).Do(ctx)
+	if err != nil {
+		logger.Error("Не удалили издателя "+id+" из "+Index, zap.Error(err))
+	}
+	return err
 }
 
 func (r *repository) FindPublishersByNameViaES(ctx context.Context, name string) ([]*publisher.EsDBO, error) {
@@ -189,8 +171,12 @@ func (r *repository) FindPublishersByNameViaES(ctx context.Context, name string)
 	}
 
 	logger.Info(fmt.Sprintf("По запросу [%s] нашли [%d]", name, resp.Hits.Total.Value))
+	return hitsToPublishers(resp.Hits.Hits)
+}
+
+func hitsToPublishers(hits []types.Hit) ([]*publisher.EsDBO, error) {
 	var p []*publisher.EsDBO
-	for _, hit := range resp.Hits.Hits {
+	for _, hit := range hits {
 		var res DTO
 		if err := json.Unmarshal(hit.Source_, &res); err != nil {
 			return nil, err
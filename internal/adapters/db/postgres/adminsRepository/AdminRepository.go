@@ -41,6 +41,7 @@ func (r *repository) Create(ctx context.Context, a *admin.Admin) error {
 	q := lib.FormatQuery(`
 		INSERT INTO admins(name, password) 
 		VALUES ($1, $2)
+		ON CONFLICT (name) DO UPDATE SET password = EXCLUDED.password
 		RETURNING user_id
 	`)
 
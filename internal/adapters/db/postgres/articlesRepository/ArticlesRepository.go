@@ -0,0 +1,234 @@
+package articlesRepository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/article"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/retention"
+	"github.com/mskKote/prospero_backend/pkg/client/postgres"
+	"github.com/mskKote/prospero_backend/pkg/lib"
+	"github.com/mskKote/prospero_backend/pkg/logging"
+	"go.uber.org/zap"
+	"sort"
+	"time"
+)
+
+var logger = logging.GetLogger().With(zap.String("prefix", "[POSTGRES]"))
+
+type repository struct {
+	client postgres.Client
+}
+
+func New(client postgres.Client) IRepository {
+	return &repository{client}
+}
+
+func (r *repository) SaveAll(
+	ctx context.Context,
+	rssID, publisherID string,
+	articles []*article.EsArticleDBO) (published map[string]*time.Time, err error) {
+
+	if len(articles) == 0 {
+		return nil, nil
+	}
+
+	tx, err := r.client.Begin(ctx)
+	if err != nil {
+		return nil, lib.HandlePgErr(err)
+	}
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(ctx); rbErr != nil && !errors.Is(rbErr, pgx.ErrTxClosed) {
+				logger.Error("Не откатили запись статей", zap.Error(rbErr))
+			}
+		}
+	}()
+
+	// Словари и статьи пишем в порядке сортировки:
+	// параллельные ленты берут блокировки в одном порядке и не ловят deadlock.
+	// Категории и люди уже нормализованы, см. EsArticleDBO.Normalize
+	var categories, people []string
+	for _, a := range articles {
+		categories = append(categories, a.Categories...)
+		people = append(people, fullNames(a.People)...)
+	}
+	sort.Strings(categories)
+	sort.Strings(people)
+	if _, err = tx.Exec(ctx, `
+		INSERT INTO categories(name) SELECT unnest($1::text[]) ON CONFLICT DO NOTHING
+	`, categories); err != nil {
+		return nil, lib.HandlePgErr(err)
+	}
+	if _, err = tx.Exec(ctx, `
+		INSERT INTO people(full_name) SELECT unnest($1::text[]) ON CONFLICT DO NOTHING
+	`, people); err != nil {
+		return nil, lib.HandlePgErr(err)
+	}
+
+	sorted := append([]*article.EsArticleDBO(nil), articles...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+	published = map[string]*time.Time{}
+	for _, a := range sorted {
+		existed, prev, err := saveArticle(ctx, tx, rssID, publisherID, a)
+		if err != nil {
+			return nil, fmt.Errorf("статья %s: %w", a.ID, lib.HandlePgErr(err))
+		}
+		if existed {
+			published[a.ID] = prev
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, lib.HandlePgErr(err)
+	}
+	logger.Info(fmt.Sprintf("Сохранили статьи: %d", len(articles)))
+	return published, nil
+}
+
+// saveArticle возвращает, была ли статья сохранена раньше, и её прежнюю дату публикации
+func saveArticle(
+	ctx context.Context,
+	tx pgx.Tx,
+	rssID, publisherID string,
+	a *article.EsArticleDBO) (existed bool, published *time.Time, err error) {
+
+	// Координаты в ES - [широта, долгота], point - (долгота, широта), как у издателей
+	point := pgtype.Point{P: pgtype.Vec2{X: a.Address.Coords[1], Y: a.Address.Coords[0]}, Valid: true}
+	err = tx.QueryRow(ctx, `
+		WITH old AS (SELECT date_published FROM articles WHERE article_id = $1 FOR UPDATE)
+		INSERT INTO articles(
+			article_id, rss_id, publisher_id, publisher_name, name, description, url,
+			country, city, point, date_published, language)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		ON CONFLICT (article_id) DO UPDATE
+		SET rss_id = EXCLUDED.rss_id, publisher_id = EXCLUDED.publisher_id,
+			publisher_name = EXCLUDED.publisher_name, name = EXCLUDED.name,
+			description = EXCLUDED.description, url = EXCLUDED.url,
+			country = EXCLUDED.country, city = EXCLUDED.city, point = EXCLUDED.point,
+			date_published = EXCLUDED.date_published, language = EXCLUDED.language,
+			updated_at = current_timestamp
+		RETURNING EXISTS(SELECT FROM old), (SELECT date_published FROM old)`,
+		a.ID, lib.StringToUUID(rssID), lib.StringToUUID(publisherID), a.Publisher.Name,
+		a.Name, a.Description, a.URL, a.Address.Country, a.Address.City, point,
+		a.DatePublished, a.Language).Scan(&existed, &published)
+	if err != nil {
+		return false, nil, err
+	}
+
+	// Категории, люди и ссылки статьи заменяются целиком
+	_, err = tx.Exec(ctx, `
+		WITH c AS (DELETE FROM article_categories WHERE article_id = $1),
+			 p AS (DELETE FROM article_people WHERE article_id = $1)
+		DELETE FROM article_links WHERE article_id = $1`, a.ID)
+	if err != nil {
+		return false, nil, err
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO article_categories(article_id, name, position)
+		SELECT $1, name, position FROM unnest($2::text[]) WITH ORDINALITY AS c(name, position)
+		ON CONFLICT DO NOTHING`, a.ID, a.Categories)
+	if err != nil {
+		return false, nil, err
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO article_people(article_id, full_name, position)
+		SELECT $1, full_name, position FROM unnest($2::text[]) WITH ORDINALITY AS p(full_name, position)
+		ON CONFLICT DO NOTHING`, a.ID, fullNames(a.People))
+	if err != nil {
+		return false, nil, err
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO article_links(article_id, position, url)
+		SELECT $1, position, url FROM unnest($2::text[]) WITH ORDINALITY AS l(url, position)`,
+		a.ID, a.Links)
+	return existed, published, err
+}
+
+func fullNames(people []article.PersonES) []string {
+	res := make([]string, 0, len(people))
+	for _, p := range people {
+		res = append(res, p.FullName)
+	}
+	return res
+}
+
+// FindAfter берёт координаты у издателя, если он остался:
+// сверка каталога исправляет точки издателей из старого сида
+func (r *repository) FindAfter(ctx context.Context, afterID string, limit int) (articles []*article.EsArticleDBO, err error) {
+	q := lib.FormatQuery(`
+		SELECT 	a.article_id, a.name, a.description, a.url, a.publisher_name,
+				a.country, a.city, COALESCE(pub.point, a.point), a.date_published, a.language,
+				ARRAY(SELECT c.name FROM article_categories c
+					  WHERE c.article_id = a.article_id ORDER BY c.position),
+				ARRAY(SELECT p.full_name FROM article_people p
+					  WHERE p.article_id = a.article_id ORDER BY p.position),
+				ARRAY(SELECT l.url FROM article_links l
+					  WHERE l.article_id = a.article_id ORDER BY l.position)
+		FROM articles a
+			LEFT JOIN publishers pub ON pub.publisher_id = a.publisher_id
+		WHERE a.article_id > $1
+		ORDER BY a.article_id
+		LIMIT $2
+	`)
+
+	rows, err := r.client.Query(ctx, q, afterID, limit)
+	if err != nil {
+		return nil, lib.HandlePgErr(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		a := &article.EsArticleDBO{}
+		var point pgtype.Point
+		var people []string
+		err := rows.Scan(
+			&a.ID, &a.Name, &a.Description, &a.URL, &a.Publisher.Name,
+			&a.Address.Country, &a.Address.City, &point, &a.DatePublished, &a.Language,
+			&a.Categories, &people, &a.Links)
+		if err != nil {
+			return nil, lib.HandlePgErr(err)
+		}
+		a.Address = article.NewAddressES(a.Address.Country, a.Address.City, point.P.Y, point.P.X)
+		a.Publisher.Address = a.Address
+		for _, p := range people {
+			a.People = append(a.People, article.PersonES{FullName: p})
+		}
+		articles = append(articles, a)
+	}
+	return articles, lib.HandlePgErr(rows.Err())
+}
+
+func (r *repository) CountAfter(ctx context.Context, afterID string) (count int64, err error) {
+	q := lib.FormatQuery(`
+		SELECT count(*) FROM articles WHERE article_id > $1
+	`)
+
+	err = r.client.QueryRow(ctx, q, afterID).Scan(&count)
+	logger.Info(q)
+
+	return count, lib.HandlePgErr(err)
+}
+
+func (r *repository) DeleteExpired(ctx context.Context, scope retention.Scope) (int64, error) {
+	q := lib.FormatQuery(`
+		DELETE FROM articles
+		WHERE date_published < $1
+			AND ($2 = '' OR publisher_name = $2)
+			AND ($3 = '' OR country = $3)
+			AND NOT publisher_name = ANY($4::text[])
+			AND NOT country = ANY($5::text[])
+	`)
+
+	// nil ушёл бы как NULL, и NOT ... = ANY(NULL) отбросил бы все статьи
+	tag, err := r.client.Exec(ctx, q,
+		scope.Before, scope.Publisher, scope.Country,
+		append([]string{}, scope.ExceptPublishers...), append([]string{}, scope.ExceptCountries...))
+	logger.Info(q)
+	if err != nil {
+		return 0, lib.HandlePgErr(err)
+	}
+	return tag.RowsAffected(), nil
+}
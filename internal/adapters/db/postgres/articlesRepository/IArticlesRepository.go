@@ -0,0 +1,21 @@
+package articlesRepository
+
+import (
+	"context"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/article"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/retention"
+	"time"
+)
+
+type IRepository interface {
+	// SaveAll в одной транзакции создаёт или обновляет статьи ленты
+	// вместе с категориями, людьми и ссылками. Возвращает прежние даты публикации
+	// уже сохранённых статей: по дате выбирается партиция ES
+	SaveAll(ctx context.Context, rssID, publisherID string, articles []*article.EsArticleDBO) (map[string]*time.Time, error)
+	// FindAfter - статьи с article_id больше afterID по возрастанию article_id
+	FindAfter(ctx context.Context, afterID string, limit int) ([]*article.EsArticleDBO, error)
+	// CountAfter - сколько статей с article_id больше afterID
+	CountAfter(ctx context.Context, afterID string) (int64, error)
+	// DeleteExpired удаляет статьи правила хранения, возвращает сколько удалено
+	DeleteExpired(ctx context.Context, scope retention.Scope) (int64, error)
+}
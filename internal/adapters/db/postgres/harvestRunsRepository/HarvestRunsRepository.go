@@ -0,0 +1,221 @@
+package harvestRunsRepository
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/harvest"
+	"github.com/mskKote/prospero_backend/pkg/client/postgres"
+	"github.com/mskKote/prospero_backend/pkg/lib"
+	"github.com/mskKote/prospero_backend/pkg/logging"
+	"go.uber.org/zap"
+)
+
+var (
+	logger = logging.GetLogger().With(zap.String("prefix", "[POSTGRES]"))
+
+	ErrNotFound = errors.New("запуск парсинга не найден")
+)
+
+type repository struct {
+	client postgres.Client
+}
+
+func New(client postgres.Client) IRepository {
+	return &repository{client}
+}
+
+func (r *repository) CreateRun(ctx context.Context, run *harvest.Job) error {
+	q := lib.FormatQuery(`
+		INSERT INTO harvest_runs(trigger, full_harvest, status, started_at, sources_total)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING run_id
+	`)
+
+	var id pgtype.UUID
+	err := r.client.
+		QueryRow(ctx, q, run.Trigger, run.Full, run.Status, run.StartedAt, run.SourcesTotal).
+		Scan(&id)
+	logger.Info(q)
+	if err != nil {
+		return lib.HandlePgErr(err)
+	}
+
+	run.ID = lib.UuidToString(id)
+	return nil
+}
+
+func (r *repository) FinishRun(ctx context.Context, run *harvest.Job) error {
+	q := lib.FormatQuery(`
+		UPDATE harvest_runs
+		SET status = $1, finished_at = $2, error = NULLIF($3, ''),
+			sources_done = $4, sources_failed = $5, sources_skipped = $6,
+			articles_created = $7, articles_updated = $8, articles_unchanged = $9,
+			articles_skipped = $10, articles_failed = $11
+		WHERE run_id = $12
+	`)
+
+	a := run.Articles
+	_, err := r.client.Exec(ctx, q,
+		run.Status, run.FinishedAt, run.Error,
+		run.SourcesDone, run.SourcesFailed, run.SourcesSkipped,
+		a.Created, a.Updated, a.Unchanged, a.Skipped, a.Failed,
+		run.ID)
+	logger.Info(q)
+
+	return lib.HandlePgErr(err)
+}
+
+func (r *repository) AbortRunning(ctx context.Context) (int64, error) {
+	q := lib.FormatQuery(`
+		UPDATE harvest_runs
+		SET status = $1, finished_at = current_timestamp, error = $2
+		WHERE status = $3
+	`)
+
+	tag, err := r.client.Exec(ctx, q, harvest.JobFailed, "прерван перезапуском", harvest.JobRunning)
+	logger.Info(q)
+	if err != nil {
+		return 0, lib.HandlePgErr(err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+func (r *repository) AddSource(ctx context.Context, runID string, s *harvest.SourceResult) error {
+	q := lib.FormatQuery(`
+		INSERT INTO harvest_run_sources(
+			run_id, rss_id, rss_url, publisher_name, status, http_status, error, items,
+			articles_created, articles_updated, articles_unchanged,
+			articles_skipped, articles_failed, started_at, finished_at)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, 0), NULLIF($7, ''), $8,
+				$9, $10, $11, $12, $13, $14, $15)
+	`)
+
+	a := s.Articles
+	_, err := r.client.Exec(ctx, q,
+		runID, lib.StringToUUID(s.RssID), s.RssURL, s.Publisher, s.Status, s.HTTPStatus, s.Error, s.Items,
+		a.Created, a.Updated, a.Unchanged,
+		a.Skipped, a.Failed, s.StartedAt, s.FinishedAt)
+	logger.Info(q)
+
+	return lib.HandlePgErr(err)
+}
+
+const runColumns = `
+		run_id, trigger, full_harvest, status, started_at, finished_at, COALESCE(error, ''),
+		sources_total, sources_done, sources_failed, sources_skipped,
+		articles_created, articles_updated, articles_unchanged,
+		articles_skipped, articles_failed`
+
+func scanRun(row pgx.Row) (*harvest.Job, error) {
+	run := &harvest.Job{}
+	var id pgtype.UUID
+	var finishedAt pgtype.Timestamptz
+	a := &run.Articles
+	err := row.Scan(
+		&id, &run.Trigger, &run.Full, &run.Status, &run.StartedAt, &finishedAt, &run.Error,
+		&run.SourcesTotal, &run.SourcesDone, &run.SourcesFailed, &run.SourcesSkipped,
+		&a.Created, &a.Updated, &a.Unchanged,
+		&a.Skipped, &a.Failed)
+	if err != nil {
+		return nil, err
+	}
+	run.ID = lib.UuidToString(id)
+	if finishedAt.Valid {
+		run.FinishedAt = &finishedAt.Time
+	}
+	return run, nil
+}
+
+func (r *repository) FindRuns(ctx context.Context, offset, limit int) (runs []*harvest.Job, err error) {
+	q := lib.FormatQuery(`
+		SELECT ` + runColumns + `
+		FROM harvest_runs
+		ORDER BY started_at DESC
+		OFFSET $1 LIMIT $2
+	`)
+
+	rows, err := r.client.Query(ctx, q, offset, limit)
+	if err != nil {
+		return nil, lib.HandlePgErr(err)
+	}
+	defer rows.Close()
+
+	logger.Info(q)
+
+	for rows.Next() {
+		run, err := scanRun(rows)
+		if err != nil {
+			return nil, lib.HandlePgErr(err)
+		}
+		runs = append(runs, run)
+	}
+	return runs, lib.HandlePgErr(rows.Err())
+}
+
+func (r *repository) FindRun(ctx context.Context, id string) (*harvest.Job, error) {
+	q := lib.FormatQuery(`
+		SELECT ` + runColumns + `
+		FROM harvest_runs
+		WHERE run_id = $1
+	`)
+
+	run, err := scanRun(r.client.QueryRow(ctx, q, id))
+	logger.Info(q)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return run, lib.HandlePgErr(err)
+}
+
+func (r *repository) FindRunSources(ctx context.Context, id, publisher string) (res []*harvest.SourceResult, err error) {
+	q := lib.FormatQuery(`
+		SELECT 	rss_id, rss_url, COALESCE(publisher_name, ''), status,
+				COALESCE(http_status, 0), COALESCE(error, ''), items,
+				articles_created, articles_updated, articles_unchanged,
+				articles_skipped, articles_failed, started_at, finished_at
+		FROM harvest_run_sources
+		WHERE run_id = $1 AND LOWER(COALESCE(publisher_name, '')) LIKE LOWER('%'||$2||'%')
+		ORDER BY publisher_name, rss_url
+	`)
+
+	rows, err := r.client.Query(ctx, q, id, publisher)
+	if err != nil {
+		return nil, lib.HandlePgErr(err)
+	}
+	defer rows.Close()
+
+	logger.Info(q)
+
+	for rows.Next() {
+		s := &harvest.SourceResult{}
+		var rssID pgtype.UUID
+		a := &s.Articles
+		err := rows.Scan(
+			&rssID, &s.RssURL, &s.Publisher, &s.Status,
+			&s.HTTPStatus, &s.Error, &s.Items,
+			&a.Created, &a.Updated, &a.Unchanged,
+			&a.Skipped, &a.Failed, &s.StartedAt, &s.FinishedAt)
+		if err != nil {
+			return nil, lib.HandlePgErr(err)
+		}
+		// Источник могли удалить после запуска
+		if rssID.Valid {
+			s.RssID = lib.UuidToString(rssID)
+		}
+		res = append(res, s)
+	}
+	return res, lib.HandlePgErr(rows.Err())
+}
+
+func (r *repository) Count(ctx context.Context) (count int64, err error) {
+	q := lib.FormatQuery(`
+		SELECT count(*) FROM harvest_runs
+	`)
+
+	err = r.client.QueryRow(ctx, q).Scan(&count)
+	logger.Info(q)
+
+	return count, lib.HandlePgErr(err)
+}
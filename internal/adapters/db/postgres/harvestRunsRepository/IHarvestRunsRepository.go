@@ -0,0 +1,21 @@
+package harvestRunsRepository
+
+import (
+	"context"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/harvest"
+)
+
+type IRepository interface {
+	// CreateRun записывает начало парсинга и проставляет run.ID
+	CreateRun(ctx context.Context, run *harvest.Job) error
+	// FinishRun записывает итог парсинга
+	FinishRun(ctx context.Context, run *harvest.Job) error
+	// AbortRunning закрывает запуски, оборванные перезапуском приложения
+	AbortRunning(ctx context.Context) (int64, error)
+	AddSource(ctx context.Context, runID string, r *harvest.SourceResult) error
+	FindRuns(ctx context.Context, offset, limit int) ([]*harvest.Job, error)
+	FindRun(ctx context.Context, id string) (*harvest.Job, error)
+	// FindRunSources - итоги источников запуска, publisher фильтрует по названию
+	FindRunSources(ctx context.Context, id, publisher string) ([]*harvest.SourceResult, error)
+	Count(ctx context.Context) (int64, error)
+}
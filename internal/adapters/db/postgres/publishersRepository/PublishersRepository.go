@@ -3,6 +3,7 @@ package publishersRepository
 import (
 	"context"
 	"errors"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/mskKote/prospero_backend/internal/domain/entity/publisher"
@@ -12,7 +13,11 @@ import (
 	"go.uber.org/zap"
 )
 
-var logger = logging.GetLogger().With(zap.String("prefix", "[POSTGRES]"))
+var (
+	logger = logging.GetLogger().With(zap.String("prefix", "[POSTGRES]"))
+
+	ErrNotFound = errors.New("издатель не найден")
+)
 
 type repository struct {
 	client postgres.Client
@@ -90,6 +95,24 @@ func (r *repository) FindPublishersByName(ctx context.Context, name string) (p [
 	return p, nil
 }
 
+// FindByName ищет издателя по точному названию без учёта регистра
+func (r *repository) FindByName(ctx context.Context, name string) (*publisher.PgDBO, error) {
+	q := lib.FormatQuery(`
+		SELECT 	p.name, p.publisher_id, p.add_date, p.country, p.city, p.point
+		FROM publishers p
+		WHERE LOWER(p.name) = LOWER($1)
+	`)
+
+	p := &publisher.PgDBO{}
+	err := r.client.QueryRow(ctx, q, name).
+		Scan(&p.Name, &p.PublisherID, &p.AddDate, &p.Country, &p.City, &p.Point)
+	logger.Info(q)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return p, lib.HandlePgErr(err)
+}
+
 func (r *repository) FindPublishersByIDs(ctx context.Context, ids []pgtype.UUID) (p []*publisher.PgDBO, err error) {
 	q := lib.FormatQuery(`
 		SELECT 	p.name, p.publisher_id, p.add_date, p.country, p.city, p.point
@@ -127,7 +150,7 @@ func (r *repository) Update(ctx context.Context, p *publisher.PgDBO) error {
 		WHERE publisher_id = $5
 	`)
 
-	_, err := r.client.Query(ctx, q, p.Name, p.Country, p.City, p.Point, p.PublisherID)
+	_, err := r.client.Exec(ctx, q, p.Name, p.Country, p.City, p.Point, p.PublisherID)
 	logger.Info(q)
 
 	return lib.HandlePgErr(err)
@@ -0,0 +1,15 @@
+package reindexRunsRepository
+
+import (
+	"context"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/reindex"
+)
+
+type IRepository interface {
+	// CreateRun записывает начало переиндексации и проставляет run.ID
+	CreateRun(ctx context.Context, run *reindex.Run) error
+	// SaveProgress записывает прогресс и статус переиндексации
+	SaveProgress(ctx context.Context, run *reindex.Run) error
+	// FindUnfinished - последняя переиндексация, если она не завершена, иначе nil
+	FindUnfinished(ctx context.Context) (*reindex.Run, error)
+}
@@ -0,0 +1,87 @@
+package reindexRunsRepository
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/reindex"
+	"github.com/mskKote/prospero_backend/pkg/client/postgres"
+	"github.com/mskKote/prospero_backend/pkg/lib"
+	"github.com/mskKote/prospero_backend/pkg/logging"
+	"go.uber.org/zap"
+)
+
+var logger = logging.GetLogger().With(zap.String("prefix", "[POSTGRES]"))
+
+type repository struct {
+	client postgres.Client
+}
+
+func New(client postgres.Client) IRepository {
+	return &repository{client}
+}
+
+func (r *repository) CreateRun(ctx context.Context, run *reindex.Run) error {
+	q := lib.FormatQuery(`
+		INSERT INTO reindex_runs(status, started_at, articles_total)
+		VALUES ($1, $2, $3)
+		RETURNING run_id
+	`)
+
+	var id pgtype.UUID
+	err := r.client.QueryRow(ctx, q, run.Status, run.StartedAt, run.Total).Scan(&id)
+	logger.Info(q)
+	if err != nil {
+		return lib.HandlePgErr(err)
+	}
+
+	run.ID = lib.UuidToString(id)
+	return nil
+}
+
+func (r *repository) SaveProgress(ctx context.Context, run *reindex.Run) error {
+	q := lib.FormatQuery(`
+		UPDATE reindex_runs
+		SET status = $1, updated_at = current_timestamp, finished_at = $2, error = NULLIF($3, ''),
+			articles_total = $4, articles_done = $5, articles_failed = $6, last_article_id = $7
+		WHERE run_id = $8
+	`)
+
+	_, err := r.client.Exec(ctx, q,
+		run.Status, run.FinishedAt, run.Error,
+		run.Total, run.Done, run.Failed, run.LastArticleID,
+		run.ID)
+
+	return lib.HandlePgErr(err)
+}
+
+func (r *repository) FindUnfinished(ctx context.Context) (*reindex.Run, error) {
+	q := lib.FormatQuery(`
+		SELECT 	run_id, status, started_at, COALESCE(error, ''),
+				articles_total, articles_done, articles_failed, last_article_id
+		FROM reindex_runs
+		ORDER BY started_at DESC
+		LIMIT 1
+	`)
+
+	run := &reindex.Run{}
+	var id pgtype.UUID
+	err := r.client.QueryRow(ctx, q).Scan(
+		&id, &run.Status, &run.StartedAt, &run.Error,
+		&run.Total, &run.Done, &run.Failed, &run.LastArticleID)
+	logger.Info(q)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, lib.HandlePgErr(err)
+	}
+	// Продолжать можно только последнюю: после неё индексы уже собраны заново
+	if run.Finished() {
+		return nil, nil
+	}
+
+	run.ID = lib.UuidToString(id)
+	return run, nil
+}
@@ -0,0 +1,17 @@
+package retentionRunsRepository
+
+import (
+	"context"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/retention"
+)
+
+type IRepository interface {
+	// CreateRun записывает начало очистки и проставляет run.ID
+	CreateRun(ctx context.Context, run *retention.Run) error
+	// FinishRun записывает итог очистки
+	FinishRun(ctx context.Context, run *retention.Run) error
+	// AbortRunning закрывает очистки, оборванные перезапуском приложения
+	AbortRunning(ctx context.Context) (int64, error)
+	FindRuns(ctx context.Context, offset, limit int) ([]*retention.Run, error)
+	Count(ctx context.Context) (int64, error)
+}
@@ -0,0 +1,118 @@
+package retentionRunsRepository
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/retention"
+	"github.com/mskKote/prospero_backend/pkg/client/postgres"
+	"github.com/mskKote/prospero_backend/pkg/lib"
+	"github.com/mskKote/prospero_backend/pkg/logging"
+	"go.uber.org/zap"
+)
+
+var logger = logging.GetLogger().With(zap.String("prefix", "[POSTGRES]"))
+
+type repository struct {
+	client postgres.Client
+}
+
+func New(client postgres.Client) IRepository {
+	return &repository{client}
+}
+
+func (r *repository) CreateRun(ctx context.Context, run *retention.Run) error {
+	q := lib.FormatQuery(`
+		INSERT INTO retention_runs(trigger, status, action, started_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING run_id
+	`)
+
+	var id pgtype.UUID
+	err := r.client.QueryRow(ctx, q, run.Trigger, run.Status, run.Action, run.StartedAt).Scan(&id)
+	logger.Info(q)
+	if err != nil {
+		return lib.HandlePgErr(err)
+	}
+
+	run.ID = lib.UuidToString(id)
+	return nil
+}
+
+func (r *repository) FinishRun(ctx context.Context, run *retention.Run) error {
+	q := lib.FormatQuery(`
+		UPDATE retention_runs
+		SET status = $1, finished_at = $2, error = NULLIF($3, ''), scopes = $4,
+			indices = $5, index_docs = $6, documents = $7, articles = $8
+		WHERE run_id = $9
+	`)
+
+	_, err := r.client.Exec(ctx, q,
+		run.Status, run.FinishedAt, run.Error, run.Scopes,
+		append([]string{}, run.Indices...), run.IndexDocs, run.Documents, run.Articles,
+		run.ID)
+	logger.Info(q)
+
+	return lib.HandlePgErr(err)
+}
+
+func (r *repository) AbortRunning(ctx context.Context) (int64, error) {
+	q := lib.FormatQuery(`
+		UPDATE retention_runs
+		SET status = $1, finished_at = current_timestamp, error = $2
+		WHERE status = $3
+	`)
+
+	tag, err := r.client.Exec(ctx, q, retention.RunFailed, "прервана перезапуском", retention.RunRunning)
+	logger.Info(q)
+	if err != nil {
+		return 0, lib.HandlePgErr(err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+func (r *repository) FindRuns(ctx context.Context, offset, limit int) (runs []*retention.Run, err error) {
+	q := lib.FormatQuery(`
+		SELECT 	run_id, trigger, status, action, started_at, finished_at, COALESCE(error, ''),
+				scopes, indices, index_docs, documents, articles
+		FROM retention_runs
+		ORDER BY started_at DESC
+		OFFSET $1 LIMIT $2
+	`)
+
+	rows, err := r.client.Query(ctx, q, offset, limit)
+	if err != nil {
+		return nil, lib.HandlePgErr(err)
+	}
+	defer rows.Close()
+
+	logger.Info(q)
+
+	for rows.Next() {
+		run := &retention.Run{}
+		var id pgtype.UUID
+		var finishedAt pgtype.Timestamptz
+		err := rows.Scan(
+			&id, &run.Trigger, &run.Status, &run.Action, &run.StartedAt, &finishedAt, &run.Error,
+			&run.Scopes, &run.Indices, &run.IndexDocs, &run.Documents, &run.Articles)
+		if err != nil {
+			return nil, lib.HandlePgErr(err)
+		}
+		run.ID = lib.UuidToString(id)
+		if finishedAt.Valid {
+			run.FinishedAt = &finishedAt.Time
+		}
+		runs = append(runs, run)
+	}
+	return runs, lib.HandlePgErr(rows.Err())
+}
+
+func (r *repository) Count(ctx context.Context) (count int64, err error) {
+	q := lib.FormatQuery(`
+		SELECT count(*) FROM retention_runs
+	`)
+
+	err = r.client.QueryRow(ctx, q).Scan(&count)
+	logger.Info(q)
+
+	return count, lib.HandlePgErr(err)
+}
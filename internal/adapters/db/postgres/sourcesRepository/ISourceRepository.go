@@ -2,15 +2,25 @@ package sourcesRepository
 
 import (
 	"context"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/mskKote/prospero_backend/internal/domain/entity/source"
 )
 
 type IRepository interface {
 	Create(ctx context.Context, source *source.RSS) (*source.RSS, error)
+	// Страницы FindAll, FindAllWithPublishers и FindByPublisherName - свежие первыми.
+	// rss_id разбивает равные add_date: источники одного импорта не теряются между страницами
 	FindAll(ctx context.Context, offset, limit int) ([]*source.RSS, error)
 	FindAllWithPublishers(ctx context.Context, offset, limit int) ([]*source.RSS, error)
+	FindByURL(ctx context.Context, url string) (*source.RSS, error)
+	FindByIDWithPublisher(ctx context.Context, id string) (*source.RSS, error)
 	FindByPublisherName(ctx context.Context, name string, offset, limit int) ([]*source.RSS, error)
 	Update(ctx context.Context, source source.RSS) error
+	UpdateWatermark(ctx context.Context, id pgtype.UUID, w source.Watermark) error
+	UpdateHealth(ctx context.Context, id pgtype.UUID, h source.Health) error
+	UpdateFeedMeta(ctx context.Context, id pgtype.UUID, m source.FeedMeta) error
+	UpdateURL(ctx context.Context, id pgtype.UUID, url string) error
+	ResetHealth(ctx context.Context, id string) error
 	Delete(ctx context.Context, id string) error
 	Count(ctx context.Context) (int64, error)
 }
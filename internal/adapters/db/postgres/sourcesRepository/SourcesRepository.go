@@ -2,6 +2,9 @@ package sourcesRepository
 
 import (
 	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/mskKote/prospero_backend/internal/domain/entity/source"
 	"github.com/mskKote/prospero_backend/pkg/client/postgres"
 	"github.com/mskKote/prospero_backend/pkg/lib"
@@ -9,7 +12,11 @@ import (
 	"go.uber.org/zap"
 )
 
-var logger = logging.GetLogger().With(zap.String("prefix", "[POSTGRES]"))
+var (
+	logger = logging.GetLogger().With(zap.String("prefix", "[POSTGRES]"))
+
+	ErrNotFound = errors.New("источник не найден")
+)
 
 type repository struct {
 	client postgres.Client
@@ -17,24 +24,46 @@ type repository struct {
 
 func (r *repository) Create(ctx context.Context, s *source.RSS) (*source.RSS, error) {
 	q := lib.FormatQuery(`
-		INSERT INTO sources_rss(rss_url, publisher_id) 
-		VALUES ($1, $2) 
+		INSERT INTO sources_rss(rss_url, publisher_id,
+			feed_title, feed_language, feed_item_count, feed_update_minutes, feed_checked_at) 
+		VALUES ($1, $2, $3, $4, $5, $6, $7) 
 		RETURNING rss_id
 	`)
 
 	err := r.client.
-		QueryRow(ctx, q, s.RssURL, s.Publisher.PublisherID).
+		QueryRow(ctx, q, s.RssURL, s.Publisher.PublisherID,
+			s.FeedTitle, s.FeedLanguage, s.FeedItemCount, s.FeedUpdateMinutes, s.FeedCheckedAt).
 		Scan(&s.RssID)
 	logger.Info(q)
 
 	return s, lib.HandlePgErr(err)
 }
 
+// stateColumns - состояние парсинга источника, порядок как в stateDest
+const stateColumns = `
+				s.last_harvest_at, s.last_item_date, s.last_item_guid,
+				s.etag, s.last_modified,
+				s.last_status, s.consecutive_failures, s.last_error,
+				s.last_http_status, s.last_success_at, s.quarantined_until,
+				s.feed_title, s.feed_language, s.feed_item_count,
+				s.feed_update_minutes, s.feed_checked_at`
+
+func stateDest(src *source.RSS) []any {
+	return []any{
+		&src.LastHarvestAt, &src.LastItemDate, &src.LastItemGUID,
+		&src.ETag, &src.LastModified,
+		&src.LastStatus, &src.ConsecutiveFailures, &src.LastError,
+		&src.LastHTTPStatus, &src.LastSuccessAt, &src.QuarantinedUntil,
+		&src.FeedTitle, &src.FeedLanguage, &src.FeedItemCount,
+		&src.FeedUpdateMinutes, &src.FeedCheckedAt,
+	}
+}
+
 func (r *repository) FindAll(ctx context.Context, offset, limit int) (s []*source.RSS, err error) {
 	q := lib.FormatQuery(`
-		SELECT s.rss_id, s.rss_url, s.publisher_id, s.add_date
+		SELECT s.rss_id, s.rss_url, s.publisher_id, s.add_date,` + stateColumns + `
 		FROM sources_rss s
-		ORDER BY s.add_date DESC
+		ORDER BY s.add_date DESC, s.rss_id
 		OFFSET $1 LIMIT $2
 	`)
 
@@ -47,7 +76,8 @@ func (r *repository) FindAll(ctx context.Context, offset, limit int) (s []*sourc
 
 	for rows.Next() {
 		src := &source.RSS{}
-		if err = rows.Scan(&src.RssID, &src.RssURL, &src.Publisher.PublisherID, &src.AddDate); err != nil {
+		dest := append([]any{&src.RssID, &src.RssURL, &src.Publisher.PublisherID, &src.AddDate}, stateDest(src)...)
+		if err = rows.Scan(dest...); err != nil {
 			return nil, lib.HandlePgErr(err)
 		}
 		s = append(s, src)
@@ -58,11 +88,11 @@ func (r *repository) FindAll(ctx context.Context, offset, limit int) (s []*sourc
 
 func (r *repository) FindAllWithPublishers(ctx context.Context, offset, limit int) (s []*source.RSS, err error) {
 	q := lib.FormatQuery(`
-		SELECT 	s.rss_id, s.rss_url, s.add_date,
+		SELECT 	s.rss_id, s.rss_url, s.add_date,` + stateColumns + `,
 				p.name, p.publisher_id, p.add_date, p.country, p.city, p.point
 		FROM sources_rss s
 			JOIN publishers p on p.publisher_id = s.publisher_id
-		ORDER BY s.add_date DESC
+		ORDER BY s.add_date DESC, s.rss_id
 		OFFSET $1 LIMIT $2
 	`)
 
@@ -73,29 +103,60 @@ func (r *repository) FindAllWithPublishers(ctx context.Context, offset, limit in
 
 	logger.Info(q)
 
-	for rows.Next() {
-		src := &source.RSS{}
-		err = rows.Scan(
-			&src.RssID, &src.RssURL, &src.AddDate,
-			&src.Publisher.Name, &src.Publisher.PublisherID,
-			&src.Publisher.AddDate, &src.Publisher.Country,
-			&src.Publisher.City, &src.Publisher.Point)
-		if err != nil {
-			return nil, lib.HandlePgErr(err)
-		}
-		s = append(s, src)
+	return scanWithPublishers(rows)
+}
+
+func (r *repository) FindByIDWithPublisher(ctx context.Context, id string) (*source.RSS, error) {
+	q := lib.FormatQuery(`
+		SELECT 	s.rss_id, s.rss_url, s.add_date,` + stateColumns + `,
+				p.name, p.publisher_id, p.add_date, p.country, p.city, p.point
+		FROM sources_rss s
+			JOIN publishers p on p.publisher_id = s.publisher_id
+		WHERE s.rss_id = $1
+	`)
+
+	rows, err := r.client.Query(ctx, q, id)
+	if err != nil {
+		return nil, lib.HandlePgErr(err)
 	}
-	return s, nil
+
+	logger.Info(q)
+
+	s, err := scanWithPublishers(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(s) == 0 {
+		return nil, ErrNotFound
+	}
+	return s[0], nil
+}
+
+func (r *repository) FindByURL(ctx context.Context, url string) (*source.RSS, error) {
+	q := lib.FormatQuery(`
+		SELECT s.rss_id, s.rss_url, s.publisher_id, s.add_date
+		FROM sources_rss s
+		WHERE s.rss_url = $1
+	`)
+
+	src := &source.RSS{}
+	err := r.client.QueryRow(ctx, q, url).
+		Scan(&src.RssID, &src.RssURL, &src.Publisher.PublisherID, &src.AddDate)
+	logger.Info(q)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return src, lib.HandlePgErr(err)
 }
 
 func (r *repository) FindByPublisherName(ctx context.Context, name string, offset, limit int) (s []*source.RSS, err error) {
 	q := lib.FormatQuery(`
-		SELECT 	s.rss_id, s.rss_url, s.add_date,
+		SELECT 	s.rss_id, s.rss_url, s.add_date,` + stateColumns + `,
 				p.name, p.publisher_id, p.add_date, p.country, p.city, p.point
 		FROM sources_rss s
 			JOIN publishers p on p.publisher_id = s.publisher_id
 		WHERE LOWER(p.name) LIKE LOWER('%'||$1||'%')
-		ORDER BY s.add_date DESC
+		ORDER BY s.add_date DESC, s.rss_id
 		OFFSET $2 LIMIT $3
 	`)
 
@@ -106,19 +167,24 @@ func (r *repository) FindByPublisherName(ctx context.Context, name string, offse
 
 	logger.Info(q)
 
+	return scanWithPublishers(rows)
+}
+
+func scanWithPublishers(rows pgx.Rows) (s []*source.RSS, err error) {
+	defer rows.Close()
 	for rows.Next() {
 		src := &source.RSS{}
-		err = rows.Scan(
-			&src.RssID, &src.RssURL, &src.AddDate,
+		dest := append([]any{&src.RssID, &src.RssURL, &src.AddDate}, stateDest(src)...)
+		dest = append(dest,
 			&src.Publisher.Name, &src.Publisher.PublisherID,
 			&src.Publisher.AddDate, &src.Publisher.Country,
 			&src.Publisher.City, &src.Publisher.Point)
-		if err != nil {
+		if err = rows.Scan(dest...); err != nil {
 			return nil, lib.HandlePgErr(err)
 		}
 		s = append(s, src)
 	}
-	return s, nil
+	return s, lib.HandlePgErr(rows.Err())
 }
 
 func (r *repository) Update(ctx context.Context, s source.RSS) error {
@@ -128,19 +194,111 @@ func (r *repository) Update(ctx context.Context, s source.RSS) error {
 		WHERE rss_id = $3
 	`)
 
-	_, err := r.client.Query(ctx, q, s.RssURL, s.Publisher.PublisherID, s.RssID)
+	_, err := r.client.Exec(ctx, q, s.RssURL, s.Publisher.PublisherID, s.RssID)
+	logger.Info(q)
+
+	return lib.HandlePgErr(err)
+}
+
+func (r *repository) UpdateWatermark(ctx context.Context, id pgtype.UUID, w source.Watermark) error {
+	q := lib.FormatQuery(`
+		UPDATE sources_rss
+		SET last_harvest_at = $1,
+			last_item_date = COALESCE($2, last_item_date),
+			last_item_guid = COALESCE(NULLIF($3, ''), last_item_guid),
+			etag = NULLIF($4, ''),
+			last_modified = NULLIF($5, '')
+		WHERE rss_id = $6
+	`)
+
+	_, err := r.client.Exec(ctx, q,
+		w.LastHarvestAt, w.LastItemDate, w.LastItemGUID,
+		w.ETag, w.LastModified, id)
 	logger.Info(q)
 
 	return lib.HandlePgErr(err)
 }
 
+func (r *repository) UpdateHealth(ctx context.Context, id pgtype.UUID, h source.Health) error {
+	q := lib.FormatQuery(`
+		UPDATE sources_rss
+		SET last_status = $1,
+			last_http_status = NULLIF($2, 0),
+			last_error = NULLIF($3, ''),
+			consecutive_failures = $4,
+			last_success_at = COALESCE($5, last_success_at),
+			quarantined_until = $6
+		WHERE rss_id = $7
+	`)
+
+	_, err := r.client.Exec(ctx, q,
+		h.Status, h.HTTPStatus, h.Error,
+		h.ConsecutiveFailures, h.LastSuccessAt, h.QuarantinedUntil, id)
+	logger.Info(q)
+
+	return lib.HandlePgErr(err)
+}
+
+func (r *repository) UpdateFeedMeta(ctx context.Context, id pgtype.UUID, m source.FeedMeta) error {
+	q := lib.FormatQuery(`
+		UPDATE sources_rss
+		SET feed_title = NULLIF($1, ''),
+			feed_language = NULLIF($2, ''),
+			feed_item_count = $3,
+			feed_update_minutes = NULLIF($4, 0),
+			feed_checked_at = $5
+		WHERE rss_id = $6
+	`)
+
+	_, err := r.client.Exec(ctx, q,
+		m.Title, m.Language, m.ItemCount,
+		int(m.UpdatePeriod.Minutes()), m.CheckedAt, id)
+	logger.Info(q)
+
+	return lib.HandlePgErr(err)
+}
+
+func (r *repository) UpdateURL(ctx context.Context, id pgtype.UUID, url string) error {
+	q := lib.FormatQuery(`
+		UPDATE sources_rss
+		SET rss_url = $1
+		WHERE rss_id = $2
+	`)
+
+	_, err := r.client.Exec(ctx, q, url, id)
+	logger.Info(q)
+
+	return lib.HandlePgErr(err)
+}
+
+func (r *repository) ResetHealth(ctx context.Context, id string) error {
+	q := lib.FormatQuery(`
+		UPDATE sources_rss
+		SET last_status = NULL,
+			last_error = NULL,
+			consecutive_failures = 0,
+			quarantined_until = NULL
+		WHERE rss_id = $1
+	`)
+
+	tag, err := r.client.Exec(ctx, q, id)
+	logger.Info(q)
+	if err != nil {
+		return lib.HandlePgErr(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
 func (r *repository) Delete(ctx context.Context, id string) error {
 	q := lib.FormatQuery(`
 		DELETE FROM sources_rss
 		WHERE rss_id = $1 
 	`)
 
-	_, err := r.client.Query(ctx, q, id)
+	_, err := r.client.Exec(ctx, q, id)
 	logger.Info(q)
 
 	return lib.HandlePgErr(err)
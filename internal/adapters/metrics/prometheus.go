@@ -9,6 +9,12 @@ const (
 	MetricCounterTestName = "test_metric"
 	MetricSummaryTestName = "test_metric_2"
 	MetricRssObtainName   = "metric_rss_harvest"
+	MetricRssArticlesName = "metric_rss_articles"
+	MetricRssFetchName    = "metric_rss_fetch"
+	MetricEsBulkSizeName  = "metric_es_bulk_size"
+	MetricEsBulkItemsName = "metric_es_bulk_items"
+
+	MetricRetentionPurgedName = "metric_retention_purged"
 )
 
 func RegisterMetrics(p *ginPrometheus.Prometheus) {
@@ -33,4 +39,48 @@ func RegisterMetrics(p *ginPrometheus.Prometheus) {
 		Type:        "summary",                         // type associated with prometheus collector
 	}
 	metrics.RegisterCustomMetric(p, metricRssHarvestSummary)
+
+	// Итог индексации статей: created/updated/unchanged/skipped/failed
+	metricRssArticlesCounter := &ginPrometheus.Metric{
+		Name:        MetricRssArticlesName,
+		Description: "Статьи, обработанные при проходе по RSS источникам",
+		Type:        "counter_vec",
+		Args:        []string{"result"},
+	}
+	metrics.RegisterCustomMetric(p, metricRssArticlesCounter)
+
+	// Запросы лент: fetched/not_modified/failed
+	metricRssFetchCounter := &ginPrometheus.Metric{
+		Name:        MetricRssFetchName,
+		Description: "Запросы RSS лент по результату",
+		Type:        "counter_vec",
+		Args:        []string{"result"},
+	}
+	metrics.RegisterCustomMetric(p, metricRssFetchCounter)
+
+	// Размер пачек _bulk
+	metricEsBulkSizeSummary := &ginPrometheus.Metric{
+		Name:        MetricEsBulkSizeName,
+		Description: "Количество документов в пачке _bulk",
+		Type:        "summary",
+	}
+	metrics.RegisterCustomMetric(p, metricEsBulkSizeSummary)
+
+	// Документы _bulk: created/updated/noop/failed/retried
+	metricEsBulkItemsCounter := &ginPrometheus.Metric{
+		Name:        MetricEsBulkItemsName,
+		Description: "Документы, записанные через _bulk, по результату",
+		Type:        "counter_vec",
+		Args:        []string{"result"},
+	}
+	metrics.RegisterCustomMetric(p, metricEsBulkItemsCounter)
+
+	// Очистка по срокам хранения: documents/articles/indices
+	metricRetentionPurgedCounter := &ginPrometheus.Metric{
+		Name:        MetricRetentionPurgedName,
+		Description: "Удалённые по срокам хранения документы ES, статьи postgres и партиции",
+		Type:        "counter_vec",
+		Args:        []string{"kind"},
+	}
+	metrics.RegisterCustomMetric(p, metricRetentionPurgedCounter)
 }
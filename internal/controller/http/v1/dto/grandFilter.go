@@ -1,5 +1,17 @@
 package dto
 
+import "fmt"
+
+// Порядок выдачи grandFilter
+const (
+	// Свежие первыми
+	SortDate = "date"
+	// Лучшие совпадения с поисковыми строками первыми
+	SortRelevance = "relevance"
+	// Совпадение с поправкой на свежесть и вес издания, см. search_ranking
+	SortHybrid = "hybrid"
+)
+
 //------------------------------ REQUEST
 
 type SearchString struct {
@@ -23,10 +35,12 @@ type SearchCountry struct {
 }
 
 type SearchTime struct {
-	// Начало временного диапазона
+	// Начало временного диапазона: 2024-01-31, 2024-01-31T10:00:00+03:00, now-24h, last 7 days
 	Start string `json:"start"`
-	// Окончание временного диапазона
+	// Окончание временного диапазона, пустое - до сих пор
 	End string `json:"end"`
+	// Зона для дат без смещения и округления now/d: Europe/Moscow или +03:00, по умолчанию UTC
+	TimeZone string `json:"timeZone"`
 }
 
 type SearchLanguage struct {
@@ -46,4 +60,35 @@ type GrandFilterRequest struct {
 	FilterCategories []SearchCategory   `json:"filterCategories"`
 	FilterLanguages  []SearchLanguage   `json:"filterLanguages"`
 	FilterTime       SearchTime         `json:"filterTime"`
+	// Области на карте, оператор объединения ||
+	FilterGeo []SearchGeo `json:"filterGeo"`
+	// Порядок выдачи: date (по умолчанию), relevance или hybrid
+	Sort string `json:"sort"`
+}
+
+// SortMode - порядок выдачи, пустой - по дате
+func (f GrandFilterRequest) SortMode() (string, error) {
+	switch f.Sort {
+	case "":
+		return SortDate, nil
+	case SortDate, SortRelevance, SortHybrid:
+		return f.Sort, nil
+	}
+	return "", fmt.Errorf("sort %q: ожидается %s, %s или %s", f.Sort, SortDate, SortRelevance, SortHybrid)
+}
+
+// Validate проверяет то, что ES не разобрал бы сам: время, порядок и гео-фильтры
+func (f GrandFilterRequest) Validate() error {
+	if _, err := f.FilterTime.Range(); err != nil {
+		return err
+	}
+	if _, err := f.SortMode(); err != nil {
+		return err
+	}
+	for i, g := range f.FilterGeo {
+		if err := g.Validate(); err != nil {
+			return fmt.Errorf("filterGeo[%d]: %w", i, err)
+		}
+	}
+	return nil
 }
@@ -0,0 +1,54 @@
+package dto
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSortMode(t *testing.T) {
+	tests := []struct {
+		sort    string
+		want    string
+		wantErr bool
+	}{
+		{"", SortDate, false},
+		{SortDate, SortDate, false},
+		{SortRelevance, SortRelevance, false},
+		{SortHybrid, SortHybrid, false},
+		{"Relevance", "", true},
+		{"score", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sort, func(t *testing.T) {
+			got, err := GrandFilterRequest{Sort: tt.sort}.SortMode()
+			if got != tt.want || (err != nil) != tt.wantErr {
+				t.Errorf("SortMode(%q) = %q, %v, want %q, ошибка %t", tt.sort, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGrandFilterValidate(t *testing.T) {
+	box := &GeoBoundingBox{TopLeft: GeoPoint{Lat: 60, Lon: 30}, BottomRight: GeoPoint{Lat: 50, Lon: 40}}
+	tests := []struct {
+		name    string
+		f       GrandFilterRequest
+		wantErr string
+	}{
+		{"пустой", GrandFilterRequest{}, ""},
+		{"всё верно", GrandFilterRequest{
+			FilterTime: SearchTime{Start: "last 7 days"}, Sort: SortHybrid, FilterGeo: []SearchGeo{{BoundingBox: box}},
+		}, ""},
+		{"время", GrandFilterRequest{FilterTime: SearchTime{Start: "вчера"}}, "filterTime.start"},
+		{"порядок", GrandFilterRequest{Sort: "score"}, "sort"},
+		{"вторая область", GrandFilterRequest{FilterGeo: []SearchGeo{{BoundingBox: box}, {}}}, "filterGeo[1]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.f.Validate()
+			if (err == nil) != (tt.wantErr == "") || (err != nil && !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Validate() = %v, want ошибку про %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package dto
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// По чьим координатам фильтруем
+const (
+	GeoTargetArticle   = "article"
+	GeoTargetPublisher = "publisher"
+)
+
+var (
+	ErrGeoShape = errors.New("нужна ровно одна фигура: distance, boundingBox или polygon")
+
+	// 300km, 50mi, 1500m
+	geoDistance = regexp.MustCompile(`^\d+(\.\d+)?(km|m|mi|yd|ft|nmi|NM)$`)
+)
+
+type GeoPoint struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+type GeoDistance struct {
+	Center GeoPoint `json:"center"`
+	// С единицами: 300km
+	Distance string `json:"distance"`
+}
+
+type GeoBoundingBox struct {
+	TopLeft     GeoPoint `json:"topLeft"`
+	BottomRight GeoPoint `json:"bottomRight"`
+}
+
+type SearchGeo struct {
+	// article (по умолчанию) - место статьи, publisher - место издания
+	Target      string          `json:"target"`
+	Distance    *GeoDistance    `json:"distance,omitempty"`
+	BoundingBox *GeoBoundingBox `json:"boundingBox,omitempty"`
+	// Вершины многоугольника, замыкать не нужно
+	Polygon []GeoPoint `json:"polygon,omitempty"`
+}
+
+// Field - поле geo_point, по которому фильтруем
+func (g SearchGeo) Field() string {
+	if g.Target == GeoTargetPublisher {
+		return "publisher.address.location"
+	}
+	return "address.location"
+}
+
+func (g SearchGeo) Validate() error {
+	if g.Target != "" && g.Target != GeoTargetArticle && g.Target != GeoTargetPublisher {
+		return fmt.Errorf("target %q: ожидается %s или %s", g.Target, GeoTargetArticle, GeoTargetPublisher)
+	}
+
+	shapes := 0
+	var points []GeoPoint
+	if g.Distance != nil {
+		shapes++
+		if !geoDistance.MatchString(g.Distance.Distance) {
+			return fmt.Errorf("distance %q: ожидается число с единицами, например 300km", g.Distance.Distance)
+		}
+		points = append(points, g.Distance.Center)
+	}
+	if g.BoundingBox != nil {
+		shapes++
+		if g.BoundingBox.TopLeft.Lat < g.BoundingBox.BottomRight.Lat {
+			return errors.New("boundingBox: topLeft южнее bottomRight")
+		}
+		points = append(points, g.BoundingBox.TopLeft, g.BoundingBox.BottomRight)
+	}
+	if g.Polygon != nil {
+		shapes++
+		if len(g.Polygon) < 3 {
+			return errors.New("polygon: нужно хотя бы 3 вершины")
+		}
+		points = append(points, g.Polygon...)
+	}
+	if shapes != 1 {
+		return ErrGeoShape
+	}
+
+	for _, p := range points {
+		if p.Lat < -90 || p.Lat > 90 || p.Lon < -180 || p.Lon > 180 {
+			return fmt.Errorf("точка %v,%v: широта от -90 до 90, долгота от -180 до 180", p.Lat, p.Lon)
+		}
+	}
+	return nil
+}
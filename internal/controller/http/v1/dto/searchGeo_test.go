@@ -0,0 +1,80 @@
+package dto
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSearchGeoValidate(t *testing.T) {
+	moscow := GeoPoint{Lat: 55.75, Lon: 37.62}
+	box := &GeoBoundingBox{TopLeft: GeoPoint{Lat: 60, Lon: 30}, BottomRight: GeoPoint{Lat: 50, Lon: 40}}
+	triangle := []GeoPoint{{Lat: 50, Lon: 30}, {Lat: 60, Lon: 30}, {Lat: 55, Lon: 40}}
+
+	tests := []struct {
+		name    string
+		geo     SearchGeo
+		wantErr bool
+	}{
+		{"радиус", SearchGeo{Distance: &GeoDistance{Center: moscow, Distance: "300km"}}, false},
+		{"радиус дробный в милях", SearchGeo{Distance: &GeoDistance{Center: moscow, Distance: "12.5mi"}}, false},
+		{"прямоугольник издания", SearchGeo{Target: GeoTargetPublisher, BoundingBox: box}, false},
+		{"многоугольник", SearchGeo{Target: GeoTargetArticle, Polygon: triangle}, false},
+		{"неизвестная цель", SearchGeo{Target: "author", BoundingBox: box}, true},
+		{"радиус без единиц", SearchGeo{Distance: &GeoDistance{Center: moscow, Distance: "300"}}, true},
+		{"радиус с пробелом", SearchGeo{Distance: &GeoDistance{Center: moscow, Distance: "300 km"}}, true},
+		{"верх южнее низа", SearchGeo{BoundingBox: &GeoBoundingBox{
+			TopLeft: GeoPoint{Lat: 50, Lon: 30}, BottomRight: GeoPoint{Lat: 60, Lon: 40},
+		}}, true},
+		{"две вершины", SearchGeo{Polygon: triangle[:2]}, true},
+		{"широта вне диапазона", SearchGeo{Distance: &GeoDistance{Center: GeoPoint{Lat: 91, Lon: 0}, Distance: "1km"}}, true},
+		{"долгота вне диапазона", SearchGeo{Polygon: []GeoPoint{{Lat: 0, Lon: 0}, {Lat: 1, Lon: 181}, {Lat: 1, Lon: 0}}}, true},
+		{"перепутаны координаты", SearchGeo{Distance: &GeoDistance{Center: GeoPoint{Lat: 120, Lon: 55}, Distance: "1km"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.geo.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate(%+v) = %v, wantErr %t", tt.geo, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSearchGeoValidateShapes(t *testing.T) {
+	point := GeoPoint{Lat: 55.75, Lon: 37.62}
+	tests := []struct {
+		name string
+		geo  SearchGeo
+	}{
+		{"без фигуры", SearchGeo{}},
+		{"радиус и многоугольник", SearchGeo{
+			Distance: &GeoDistance{Center: point, Distance: "1km"}, Polygon: []GeoPoint{point, point, point},
+		}},
+		{"радиус и прямоугольник", SearchGeo{
+			Distance:    &GeoDistance{Center: point, Distance: "1km"},
+			BoundingBox: &GeoBoundingBox{TopLeft: point, BottomRight: point},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.geo.Validate(); !errors.Is(err, ErrGeoShape) {
+				t.Errorf("Validate(%+v) = %v, want %v", tt.geo, err, ErrGeoShape)
+			}
+		})
+	}
+}
+
+func TestSearchGeoField(t *testing.T) {
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{"", "address.location"},
+		{GeoTargetArticle, "address.location"},
+		{GeoTargetPublisher, "publisher.address.location"},
+	}
+	for _, tt := range tests {
+		if got := (SearchGeo{Target: tt.target}).Field(); got != tt.want {
+			t.Errorf("Field(%q) = %q, want %q", tt.target, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package dto
+
+import "fmt"
+
+const (
+	// Предел точности geotile_grid
+	MaxMapZoom = 29
+	// Заголовков в кластере по умолчанию и максимум
+	DefaultMapHeadlines = 3
+	MaxMapHeadlines     = 10
+)
+
+// MapRequest - фильтры grandFilter и видимая часть карты
+type MapRequest struct {
+	GrandFilterRequest
+	Bounds GeoBoundingBox `json:"bounds"`
+	// Масштаб карты, как у тайлов: 0 - весь мир
+	Zoom int `json:"zoom"`
+	// article (по умолчанию) - по месту статьи, publisher - по месту издания
+	Target string `json:"target"`
+	// Свежих заголовков в кластере, 0 - по умолчанию
+	Headlines int `json:"headlines"`
+}
+
+// Viewport - видимая часть карты как гео-фильтр
+func (m MapRequest) Viewport() SearchGeo {
+	return SearchGeo{Target: m.Target, BoundingBox: &m.Bounds}
+}
+
+// HeadlineCount - сколько заголовков отдавать в кластере
+func (m MapRequest) HeadlineCount() int {
+	if m.Headlines == 0 {
+		return DefaultMapHeadlines
+	}
+	return m.Headlines
+}
+
+func (m MapRequest) Validate() error {
+	if err := m.GrandFilterRequest.Validate(); err != nil {
+		return err
+	}
+	if err := m.Viewport().Validate(); err != nil {
+		return fmt.Errorf("bounds: %w", err)
+	}
+	if m.Zoom < 0 || m.Zoom > MaxMapZoom {
+		return fmt.Errorf("zoom: от 0 до %d", MaxMapZoom)
+	}
+	if m.Headlines < 0 || m.Headlines > MaxMapHeadlines {
+		return fmt.Errorf("headlines: от 0 до %d", MaxMapHeadlines)
+	}
+	return nil
+}
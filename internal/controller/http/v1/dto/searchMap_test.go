@@ -0,0 +1,38 @@
+package dto
+
+import "testing"
+
+func TestMapRequestValidate(t *testing.T) {
+	box := GeoBoundingBox{TopLeft: GeoPoint{Lat: 60, Lon: 30}, BottomRight: GeoPoint{Lat: 50, Lon: 40}}
+
+	tests := []struct {
+		name    string
+		m       MapRequest
+		wantErr bool
+	}{
+		{"область", MapRequest{Bounds: box}, false},
+		{"издания крупно", MapRequest{Bounds: box, Zoom: MaxMapZoom, Target: GeoTargetPublisher, Headlines: MaxMapHeadlines}, false},
+		{"верх южнее низа", MapRequest{Bounds: GeoBoundingBox{TopLeft: box.BottomRight, BottomRight: box.TopLeft}}, true},
+		{"отрицательный масштаб", MapRequest{Bounds: box, Zoom: -1}, true},
+		{"масштаб мельче предела", MapRequest{Bounds: box, Zoom: MaxMapZoom + 1}, true},
+		{"много заголовков", MapRequest{Bounds: box, Headlines: MaxMapHeadlines + 1}, true},
+		{"неизвестная цель", MapRequest{Bounds: box, Target: "author"}, true},
+		{"неверный фильтр", MapRequest{GrandFilterRequest: GrandFilterRequest{Sort: "popular"}, Bounds: box}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.m.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate(%+v) = %v, wantErr %t", tt.m, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHeadlineCount(t *testing.T) {
+	if got := (MapRequest{}).HeadlineCount(); got != DefaultMapHeadlines {
+		t.Errorf("HeadlineCount() = %d, want %d", got, DefaultMapHeadlines)
+	}
+	if got := (MapRequest{Headlines: 7}).HeadlineCount(); got != 7 {
+		t.Errorf("HeadlineCount() = %d, want 7", got)
+	}
+}
@@ -0,0 +1,126 @@
+package dto
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	// Зоны по названию: в alpine нет /usr/share/zoneinfo
+	_ "time/tzdata"
+)
+
+var (
+	ErrTimeFormat   = errors.New("ожидается дата ISO-8601, now-24h или last 7 days")
+	ErrTimeZone     = errors.New("ожидается зона вида Europe/Moscow или +03:00")
+	ErrTimeInverted = errors.New("начало диапазона позже окончания")
+
+	// Date math ES: now, сдвиги и округление
+	dateMath = regexp.MustCompile(`^now([+-]\d+[yMwdhHms])*(/[yMwdhHms])?$`)
+	// last 7 days, last hour
+	lastPeriod = regexp.MustCompile(`^last\s+(\d+\s+)?(minute|hour|day|week|month|year)s?$`)
+	offset     = regexp.MustCompile(`^[+-]\d{2}:\d{2}$`)
+
+	periodUnits = map[string]string{
+		"minute": "m",
+		"hour":   "h",
+		"day":    "d",
+		"week":   "w",
+		"month":  "M",
+		"year":   "y",
+	}
+	// Дата со временем, но без зоны: зону добавит TimeZone
+	localLayouts = []string{"2006-01-02T15:04:05.999999999", "2006-01-02T15:04:05", "2006-01-02T15:04"}
+)
+
+// TimeRange - диапазон для range query по datePublished.
+// Пустая граница - диапазон открыт с этой стороны
+type TimeRange struct {
+	Gte      string
+	Lte      string
+	TimeZone string
+}
+
+// Range разбирает filterTime, nil - фильтра нет
+func (t SearchTime) Range() (*TimeRange, error) {
+	start, end := strings.TrimSpace(t.Start), strings.TrimSpace(t.End)
+	zone := strings.TrimSpace(t.TimeZone)
+	if start == "" && end == "" {
+		return nil, nil
+	}
+
+	loc := time.UTC
+	if zone != "" {
+		var err error
+		if loc, err = location(zone); err != nil {
+			return nil, fmt.Errorf("filterTime.timeZone %q: %w", zone, err)
+		}
+	}
+
+	gte, from, err := timeBound(start, loc, false)
+	if err != nil {
+		return nil, fmt.Errorf("filterTime.start %q: %w", start, err)
+	}
+	lte, to, err := timeBound(end, loc, true)
+	if err != nil {
+		return nil, fmt.Errorf("filterTime.end %q: %w", end, err)
+	}
+	if from != nil && to != nil && from.After(*to) {
+		return nil, ErrTimeInverted
+	}
+	return &TimeRange{Gte: gte, Lte: lte, TimeZone: zone}, nil
+}
+
+func location(zone string) (*time.Location, error) {
+	if offset.MatchString(zone) {
+		t, err := time.Parse("-07:00", zone)
+		if err != nil {
+			return nil, ErrTimeZone
+		}
+		return t.Location(), nil
+	}
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return nil, ErrTimeZone
+	}
+	return loc, nil
+}
+
+// timeBound - граница в синтаксисе ES и, для абсолютной даты, сам момент.
+// Дата без времени - весь день: ||/d округляет gte к началу дня, lte - к концу
+func timeBound(s string, loc *time.Location, end bool) (string, *time.Time, error) {
+	switch lower := strings.ToLower(s); {
+	case s == "":
+		return "", nil, nil
+	case lower == "today":
+		return "now/d", nil, nil
+	case lower == "yesterday":
+		return "now-1d/d", nil, nil
+	case dateMath.MatchString(s):
+		return s, nil, nil
+	case lastPeriod.MatchString(lower):
+		m := lastPeriod.FindStringSubmatch(lower)
+		n := 1
+		if m[1] != "" {
+			n, _ = strconv.Atoi(strings.TrimSpace(m[1]))
+		}
+		return fmt.Sprintf("now-%d%s", n, periodUnits[m[2]]), nil, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return s, &t, nil
+	}
+	for _, layout := range localLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return s, &t, nil
+		}
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, s, loc); err == nil {
+		if end {
+			t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+		return s + "||/d", &t, nil
+	}
+	return "", nil, ErrTimeFormat
+}
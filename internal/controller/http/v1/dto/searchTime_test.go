@@ -0,0 +1,80 @@
+package dto
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSearchTimeRange(t *testing.T) {
+	tests := []struct {
+		name string
+		in   SearchTime
+		want TimeRange
+	}{
+		{"сегодня", SearchTime{Start: "today"}, TimeRange{Gte: "now/d"}},
+		{"вчера до сегодня", SearchTime{Start: "Yesterday", End: "today"}, TimeRange{Gte: "now-1d/d", Lte: "now/d"}},
+		{"date math", SearchTime{Start: "now-24h", End: "now/h"}, TimeRange{Gte: "now-24h", Lte: "now/h"}},
+		{"последние 7 дней", SearchTime{Start: "last 7 days"}, TimeRange{Gte: "now-7d"}},
+		{"последний час", SearchTime{Start: "last hour"}, TimeRange{Gte: "now-1h"}},
+		{"последние месяцы", SearchTime{Start: "Last 2 Months"}, TimeRange{Gte: "now-2M"}},
+		{"RFC3339", SearchTime{Start: "2024-05-01T00:00:00Z", End: "2024-05-02T10:00:00+03:00"},
+			TimeRange{Gte: "2024-05-01T00:00:00Z", Lte: "2024-05-02T10:00:00+03:00"}},
+		{"время без зоны", SearchTime{Start: "2024-05-01T10:00", TimeZone: "Europe/Moscow"},
+			TimeRange{Gte: "2024-05-01T10:00", TimeZone: "Europe/Moscow"}},
+		{"дата без времени - весь день", SearchTime{Start: "2024-05-01", End: "2024-05-01", TimeZone: "+03:00"},
+			TimeRange{Gte: "2024-05-01||/d", Lte: "2024-05-01||/d", TimeZone: "+03:00"}},
+		{"только конец", SearchTime{End: "2024-05-01"}, TimeRange{Lte: "2024-05-01||/d"}},
+		{"пробелы", SearchTime{Start: "  today ", TimeZone: " UTC "}, TimeRange{Gte: "now/d", TimeZone: "UTC"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.in.Range()
+			if err != nil {
+				t.Fatalf("Range(%+v) error: %v", tt.in, err)
+			}
+			if got == nil || *got != tt.want {
+				t.Errorf("Range(%+v) = %+v, want %+v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchTimeRangeEmpty(t *testing.T) {
+	for _, in := range []SearchTime{{}, {Start: " ", End: "", TimeZone: "Europe/Moscow"}} {
+		if got, err := in.Range(); got != nil || err != nil {
+			t.Errorf("Range(%+v) = %+v, %v, want nil, nil", in, got, err)
+		}
+	}
+}
+
+func TestSearchTimeRangeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		in   SearchTime
+		want error
+	}{
+		{"неизвестная зона", SearchTime{Start: "today", TimeZone: "Mars/Olympus"}, ErrTimeZone},
+		{"зона без минут", SearchTime{Start: "today", TimeZone: "+03"}, ErrTimeZone},
+		{"мусор в начале", SearchTime{Start: "вчера"}, ErrTimeFormat},
+		{"мусор в конце", SearchTime{Start: "today", End: "2024-13-01"}, ErrTimeFormat},
+		{"last без периода", SearchTime{Start: "last 7"}, ErrTimeFormat},
+		{"начало позже конца", SearchTime{Start: "2024-05-02", End: "2024-05-01"}, ErrTimeInverted},
+		// Конец дня в Москве раньше 2024-05-01T23:00Z
+		{"с учётом зоны", SearchTime{Start: "2024-05-01T23:00:00Z", End: "2024-05-01", TimeZone: "Europe/Moscow"}, ErrTimeInverted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.in.Range(); !errors.Is(err, tt.want) {
+				t.Errorf("Range(%+v) error = %v, want %v", tt.in, err, tt.want)
+			}
+		})
+	}
+}
+
+// Один день в начале и в конце - не перевёрнутый диапазон
+func TestSearchTimeRangeSameDay(t *testing.T) {
+	in := SearchTime{Start: "2024-05-01", End: "2024-05-01T12:00:00Z"}
+	if _, err := in.Range(); err != nil {
+		t.Errorf("Range(%+v) error: %v", in, err)
+	}
+}
@@ -7,6 +7,8 @@ const (
 	readPublishersURL  = "/getPublishers"
 	updatePublisherURL = "/updatePublisher"
 	deletePublisherURL = "/removePublisher"
+	planCatalogueURL   = "/catalogue/plan"
+	applyCatalogueURL  = "/catalogue/apply"
 )
 
 type IPublishersUseCase interface {
@@ -14,6 +16,8 @@ type IPublishersUseCase interface {
 	ReadPublishers(c *gin.Context)
 	UpdatePublisher(c *gin.Context)
 	DeletePublisher(c *gin.Context)
+	PlanCatalogue(c *gin.Context)
+	ApplyCatalogue(c *gin.Context)
 }
 
 func RegisterPublishersRoutes(g *gin.RouterGroup, p IPublishersUseCase) {
@@ -21,4 +25,6 @@ func RegisterPublishersRoutes(g *gin.RouterGroup, p IPublishersUseCase) {
 	g.GET(readPublishersURL, p.ReadPublishers)
 	g.PUT(updatePublisherURL, p.UpdatePublisher)
 	g.DELETE(deletePublisherURL, p.DeletePublisher)
+	g.GET(planCatalogueURL, p.PlanCatalogue)
+	g.POST(applyCatalogueURL, p.ApplyCatalogue)
 }
@@ -0,0 +1,21 @@
+package routes
+
+import "github.com/gin-gonic/gin"
+
+const (
+	retentionPolicyURL = "/retention/policy"
+	retentionRunURL    = "/retention/run"
+	retentionRunsURL   = "/retention/runs"
+)
+
+type IRetentionUseCase interface {
+	ReadRetentionPolicy(c *gin.Context)
+	RunRetention(c *gin.Context)
+	ReadRetentionRuns(c *gin.Context)
+}
+
+func RegisterRetentionRoutes(g *gin.RouterGroup, retention IRetentionUseCase) {
+	g.GET(retentionPolicyURL, retention.ReadRetentionPolicy)
+	g.POST(retentionRunURL, retention.RunRetention)
+	g.GET(retentionRunsURL, retention.ReadRetentionRuns)
+}
@@ -6,6 +6,7 @@ import (
 
 const (
 	searchURL                    = "/grandFilter"
+	searchMapURL                 = "/map"
 	searchPublisherURL           = "/searchPublisherWithHints/:search"
 	searchDefaultPublisherURL    = "/searchPublisherWithHints/"
 	searchLanguagesURL           = "/searchLanguages"
@@ -15,6 +16,7 @@ const (
 
 type ISearchUseCase interface {
 	GrandFilter(g *gin.Context)
+	MapClusters(c *gin.Context)
 	SearchPublisherWithHints(c *gin.Context)
 	SearchDefaultPublisherWithHints(c *gin.Context)
 	SearchLanguages(c *gin.Context)
@@ -24,6 +26,7 @@ type ISearchUseCase interface {
 
 func RegisterSearchRoutes(g *gin.RouterGroup, s ISearchUseCase) {
 	g.POST(searchURL, s.GrandFilter)
+	g.POST(searchMapURL, s.MapClusters)
 	g.POST(searchPublisherURL, s.SearchPublisherWithHints)
 	g.POST(searchDefaultPublisherURL, s.SearchDefaultPublisherWithHints)
 	g.POST(searchLanguagesURL, s.SearchLanguages)
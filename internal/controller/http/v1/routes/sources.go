@@ -4,30 +4,69 @@ import "github.com/gin-gonic/gin"
 
 const (
 	createSourceURL        = "/RSS/addSource"
+	createSourcesURL       = "/RSS/addSources"
+	discoverSourcesURL     = "/RSS/discoverSources"
 	readSourcesURL         = "/RSS/getSources"
 	readEnrichedSourcesURL = "/RSS/getEnrichedSources"
 	updateSourceURL        = "/RSS/updateSource"
 	deleteSourceURL        = "/RSS/removeSource"
+	resetSourceURL         = "/RSS/resetSource"
 	harvest                = "/RSS/harvest"
+	harvestJobURL          = "/RSS/harvest/:id"
+	harvestJobStreamURL    = "/RSS/harvest/:id/stream"
+	harvestJobCancelURL    = "/RSS/harvest/:id/cancel"
+	harvestSourceURL       = "/RSS/harvestSource"
+	previewSourceURL       = "/RSS/previewSource"
+	harvestRunsURL         = "/RSS/runs"
+	harvestRunURL          = "/RSS/runs/:id"
 	addSourceAndPublisher  = "/addSourceAndPublisher"
+	importOPMLURL          = "/RSS/importOPML"
+	importSourcesURL       = "/RSS/importSources"
+	exportOPMLURL          = "/RSS/exportOPML"
 )
 
 type ISourcesUseCase interface {
 	CreateSourceRSS(c *gin.Context)
+	CreateSourcesRSS(c *gin.Context)
+	DiscoverSourcesRSS(c *gin.Context)
 	ReadSourcesRSS(c *gin.Context)
 	ReadSourcesRSSWithPublishers(c *gin.Context)
 	UpdateSourceRSS(c *gin.Context)
 	DeleteSourceRSS(c *gin.Context)
+	ResetSourceRSS(c *gin.Context)
 	Harvest(c *gin.Context)
+	ReadHarvestJob(c *gin.Context)
+	StreamHarvestJob(c *gin.Context)
+	CancelHarvestJob(c *gin.Context)
+	HarvestSource(c *gin.Context)
+	PreviewSource(c *gin.Context)
+	ReadHarvestRuns(c *gin.Context)
+	ReadHarvestRun(c *gin.Context)
 	AddSourceAndPublisher(c *gin.Context)
+	ImportOPML(c *gin.Context)
+	ImportSourcesRSS(c *gin.Context)
+	ExportOPML(c *gin.Context)
 }
 
 func RegisterSourcesRoutes(g *gin.RouterGroup, sources ISourcesUseCase) {
 	g.POST(addSourceAndPublisher, sources.AddSourceAndPublisher)
 	g.POST(createSourceURL, sources.CreateSourceRSS)
+	g.POST(createSourcesURL, sources.CreateSourcesRSS)
+	g.POST(discoverSourcesURL, sources.DiscoverSourcesRSS)
 	g.POST(harvest, sources.Harvest)
+	g.GET(harvestJobURL, sources.ReadHarvestJob)
+	g.GET(harvestJobStreamURL, sources.StreamHarvestJob)
+	g.POST(harvestJobCancelURL, sources.CancelHarvestJob)
+	g.POST(harvestSourceURL, sources.HarvestSource)
+	g.POST(previewSourceURL, sources.PreviewSource)
+	g.GET(harvestRunsURL, sources.ReadHarvestRuns)
+	g.GET(harvestRunURL, sources.ReadHarvestRun)
 	g.GET(readSourcesURL, sources.ReadSourcesRSS)
 	g.GET(readEnrichedSourcesURL, sources.ReadSourcesRSSWithPublishers)
 	g.PUT(updateSourceURL, sources.UpdateSourceRSS)
 	g.DELETE(deleteSourceURL, sources.DeleteSourceRSS)
+	g.POST(resetSourceURL, sources.ResetSourceRSS)
+	g.POST(importOPMLURL, sources.ImportOPML)
+	g.POST(importSourcesURL, sources.ImportSourcesRSS)
+	g.GET(exportOPMLURL, sources.ExportOPML)
 }
@@ -1,8 +1,13 @@
 package article
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 type EsArticleDBO struct {
+	// ID документа в ES, см. StableID
+	ID            string      `json:"-"`
 	Name          string      `json:"name"`
 	Description   string      `json:"description"`
 	URL           string      `json:"URL"`
@@ -22,6 +27,41 @@ type EsArticleDBO struct {
 	//emotionalDescription? string,
 }
 
+// Normalize убирает пробелы по краям и пустые значения категорий, людей и ссылок,
+// повторы категорий и людей. Статья пишется в postgres и ES уже такой
+func (a *EsArticleDBO) Normalize() {
+	a.Categories = uniqueNames(a.Categories)
+	var people []PersonES
+	seen := map[string]bool{}
+	for _, p := range a.People {
+		if name := strings.TrimSpace(p.FullName); name != "" && !seen[name] {
+			seen[name] = true
+			people = append(people, PersonES{FullName: name})
+		}
+	}
+	a.People = people
+
+	var links []string
+	for _, l := range a.Links {
+		if l = strings.TrimSpace(l); l != "" {
+			links = append(links, l)
+		}
+	}
+	a.Links = links
+}
+
+func uniqueNames(values []string) []string {
+	var res []string
+	seen := map[string]bool{}
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" && !seen[v] {
+			seen[v] = true
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
 type PublisherES struct {
 	Name    string    `json:"name"`
 	Address AddressES `json:"address"`
@@ -34,9 +74,26 @@ type PersonES struct {
 }
 
 type AddressES struct {
-	Coords  [2]float64 `json:"coords"`
-	Country string     `json:"country"`
-	City    string     `json:"city"`
+	// [широта, долгота]
+	Coords [2]float64 `json:"coords"`
+	// Та же точка для гео-фильтров: массив ES читает как [долгота, широта]
+	Location *GeoPoint `json:"location,omitempty"`
+	Country  string    `json:"country"`
+	City     string    `json:"city"`
+}
+
+type GeoPoint struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// NewAddressES - адрес с точкой, 0,0 - координаты неизвестны
+func NewAddressES(country, city string, lat, lon float64) AddressES {
+	a := AddressES{Coords: [2]float64{lat, lon}, Country: country, City: city}
+	if lat != 0 || lon != 0 {
+		a.Location = &GeoPoint{Lat: lat, Lon: lon}
+	}
+	return a
 }
 
 type CategoryES struct {
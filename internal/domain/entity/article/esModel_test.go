@@ -0,0 +1,45 @@
+package article
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestNewAddressES(t *testing.T) {
+	a := NewAddressES("Russia", "Moscow", 55.75, 37.62)
+	if a.Location == nil || a.Location.Lat != 55.75 || a.Location.Lon != 37.62 || a.Coords != [2]float64{55.75, 37.62} {
+		t.Errorf("NewAddressES() = %+v", a)
+	}
+	// location - объектом: массив ES прочитал бы как [долгота, широта]
+	b, err := json.Marshal(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"coords":[55.75,37.62],"location":{"lat":55.75,"lon":37.62},"country":"Russia","city":"Moscow"}`
+	if string(b) != want {
+		t.Errorf("JSON %s\nwant %s", b, want)
+	}
+
+	// Точка на экваторе или меридиане - это координаты, 0,0 - их нет
+	if NewAddressES("", "", 0, 37.62).Location == nil {
+		t.Error("точка на экваторе потеряна")
+	}
+	if a := NewAddressES("Russia", "", 0, 0); a.Location != nil {
+		t.Errorf("без координат location = %+v, want nil", a.Location)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	a := &EsArticleDBO{
+		Categories: []string{" Политика ", "", "Политика", "Экономика"},
+		People:     []PersonES{{FullName: "Иван Петров "}, {FullName: " "}, {FullName: "Иван Петров"}},
+		Links:      []string{" https://a/1", "", "https://a/1"},
+	}
+	a.Normalize()
+	// Повторы ссылок остаются: у них есть позиция в статье
+	got, _ := json.Marshal([]any{a.Categories, a.People, a.Links})
+	want := `[["Политика","Экономика"],[{"fullName":"Иван Петров"}],["https://a/1","https://a/1"]]`
+	if string(got) != want {
+		t.Errorf("Normalize() = %s\nwant %s", got, want)
+	}
+}
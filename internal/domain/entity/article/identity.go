@@ -0,0 +1,123 @@
+package article
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"hash/fnv"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Параметры ссылок, которые не влияют на содержимое статьи
+var trackingParams = map[string]bool{
+	"fbclid": true,
+	"gclid":  true,
+	"yclid":  true,
+	"ref":    true,
+	"rss":    true,
+	"cmpid":  true,
+}
+
+// StableID - идентификатор статьи в ES, не меняющийся между парсингами.
+// Основа - GUID из ленты, если его нет - канонизированная ссылка.
+// Пустая строка, если идентифицировать статью не по чему
+func StableID(publisherName, guid, link string) string {
+	identity := strings.TrimSpace(guid)
+	if identity == "" {
+		identity = CanonicalURL(link)
+	}
+	if identity == "" {
+		return ""
+	}
+
+	h := sha1.New()
+	h.Write([]byte(strings.ToLower(strings.TrimSpace(publisherName))))
+	h.Write([]byte{0})
+	h.Write([]byte(identity))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// IsStableID - похож ли ID на StableID. Документы, записанные до StableID,
+// получили случайный ID от ES - 20 символов base64
+func IsStableID(id string) bool {
+	if len(id) != sha1.Size*2 {
+		return false
+	}
+	for _, c := range id {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// CategoryID - ID категории в ES
+func CategoryID(name string) string {
+	return nameHash(name)
+}
+
+// PersonID - ID человека в ES
+func PersonID(fullName string) string {
+	return nameHash(fullName)
+}
+
+func nameHash(name string) string {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return strconv.FormatUint(uint64(h.Sum32()), 10)
+}
+
+// CanonicalURL приводит ссылку к виду, одинаковому для одной и той же статьи:
+// без схемы, www, фрагмента, utm-меток и завершающего слэша,
+// с отсортированными параметрами запроса
+func CanonicalURL(link string) string {
+	link = strings.TrimSpace(link)
+	if link == "" {
+		return ""
+	}
+
+	u, err := url.Parse(link)
+	if err != nil || u.Host == "" {
+		return link
+	}
+
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	if port := u.Port(); port != "" && port != "80" && port != "443" {
+		host += ":" + port
+	}
+
+	query := u.Query()
+	for key := range query {
+		lower := strings.ToLower(key)
+		if strings.HasPrefix(lower, "utm_") || trackingParams[lower] {
+			query.Del(key)
+		}
+	}
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	sb.WriteString(host)
+	sb.WriteString(strings.TrimRight(u.EscapedPath(), "/"))
+	for i, key := range keys {
+		if i == 0 {
+			sb.WriteString("?")
+		} else {
+			sb.WriteString("&")
+		}
+		values := query[key]
+		sort.Strings(values)
+		for j, v := range values {
+			if j > 0 {
+				sb.WriteString("&")
+			}
+			sb.WriteString(url.QueryEscape(key) + "=" + url.QueryEscape(v))
+		}
+	}
+	return sb.String()
+}
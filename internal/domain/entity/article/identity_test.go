@@ -0,0 +1,102 @@
+package article
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCanonicalURL(t *testing.T) {
+	tests := []struct {
+		name string
+		link string
+		want string
+	}{
+		{"пустая", "  ", ""},
+		{"схема, www и регистр хоста", "https://WWW.Example.com/news/1", "example.com/news/1"},
+		{"завершающий слэш и фрагмент", "http://example.com/news/1/#comments", "example.com/news/1"},
+		{"стандартный порт", "https://example.com:443/a", "example.com/a"},
+		{"нестандартный порт", "http://example.com:8080/a", "example.com:8080/a"},
+		{"utm и трекинг", "https://example.com/a?utm_source=rss&fbclid=1&REF=x&id=7", "example.com/a?id=7"},
+		{"порядок параметров", "https://example.com/a?b=2&a=1&a=0", "example.com/a?a=0&a=1&b=2"},
+		{"без хоста - как есть", "/relative/path", "/relative/path"},
+		{"корень сайта", "https://example.com/", "example.com"},
+		{"регистр пути не трогаем", "https://Example.com/News/A", "example.com/News/A"},
+		{"только метки", "https://example.com/a?utm_medium=x&gclid=2", "example.com/a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CanonicalURL(tt.link); got != tt.want {
+				t.Errorf("CanonicalURL(%q) = %q, want %q", tt.link, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStableID(t *testing.T) {
+	base := StableID("Vedomosti", "guid-1", "https://vedomosti.ru/a")
+
+	tests := []struct {
+		name       string
+		publisher  string
+		guid, link string
+		same       bool
+	}{
+		{"тот же GUID, другая ссылка", "Vedomosti", "guid-1", "https://vedomosti.ru/b", true},
+		{"пробелы и регистр издателя", " vedomosti ", " guid-1 ", "", true},
+		{"другой GUID", "Vedomosti", "guid-2", "https://vedomosti.ru/a", false},
+		{"другой издатель", "Kommersant", "guid-1", "https://vedomosti.ru/a", false},
+		// GUID - не обязательно ссылка, берём как есть
+		{"GUID не канонизируем", "Vedomosti", "GUID-1", "https://vedomosti.ru/a", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := StableID(tt.publisher, tt.guid, tt.link)
+			if (got == base) != tt.same {
+				t.Errorf("StableID(%q, %q, %q) = %q, base %q, same want %t",
+					tt.publisher, tt.guid, tt.link, got, base, tt.same)
+			}
+		})
+	}
+}
+
+func TestStableIDWithoutGUID(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		same bool
+	}{
+		{"ссылки с метками", "https://www.example.com/a?utm_source=rss", "http://example.com/a/", true},
+		{"разные статьи", "https://example.com/a", "https://example.com/b", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := StableID("Example", "", tt.a), StableID("Example", "", tt.b)
+			if (a == b) != tt.same {
+				t.Errorf("StableID по %q = %q, по %q = %q, same want %t", tt.a, a, tt.b, b, tt.same)
+			}
+		})
+	}
+
+	if id := StableID("Example", "", ""); id != "" {
+		t.Errorf("StableID без GUID и ссылки = %q, want пусто", id)
+	}
+}
+
+func TestIsStableID(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{StableID("Example", "guid-1", ""), true},
+		{StableID("Example", "", "https://example.com/a"), true},
+		// случайный ID от ES
+		{"8mXvBY8BvI7hTi0bYc_Q", false},
+		{strings.ToUpper(StableID("Example", "guid-1", "")), false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := IsStableID(tt.id); got != tt.want {
+			t.Errorf("IsStableID(%q) = %t, want %t", tt.id, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package article
+
+import "time"
+
+// MapClusters - статьи на карте, сгруппированные по тайлам
+type MapClusters struct {
+	Clusters []*MapCluster `json:"clusters"`
+	// Статей в видимой части карты
+	Total int64 `json:"total"`
+}
+
+type MapCluster struct {
+	// Тайл z/x/y
+	Tile  string `json:"tile"`
+	Count int64  `json:"count"`
+	// Центр статей кластера, а не тайла
+	Centroid  GeoPoint    `json:"centroid"`
+	Headlines []*Headline `json:"headlines"`
+}
+
+// Headline - свежая статья кластера без текста
+type Headline struct {
+	ID            string     `json:"id"`
+	Name          string     `json:"name"`
+	URL           string     `json:"URL"`
+	Publisher     string     `json:"publisher"`
+	DatePublished *time.Time `json:"datePublished"`
+}
@@ -0,0 +1,25 @@
+package article
+
+// Почему новость ленты не попадает в ES
+const (
+	SkipNoDate          = "no_date"
+	SkipBeforeWatermark = "before_watermark"
+	SkipNoID            = "no_id"
+	SkipExpired         = "expired"
+)
+
+// Preview - лента глазами парсера, в ES ничего не записано
+type Preview struct {
+	RssURL   string            `json:"rssURL"`
+	Title    string            `json:"title"`
+	Language string            `json:"language"`
+	Items    int               `json:"items"`
+	Articles []*PreviewArticle `json:"articles"`
+}
+
+// PreviewArticle - документ, который ушёл бы в ES
+type PreviewArticle struct {
+	ID         string `json:"id"`
+	SkipReason string `json:"skipReason,omitempty"`
+	*EsArticleDBO
+}
@@ -0,0 +1,45 @@
+package article
+
+import "time"
+
+// SearchPage - страница результатов grandFilter.
+// Cursor передаётся за следующей страницей, пустой - страниц больше нет
+type SearchPage struct {
+	Hits   []*SearchHit `json:"hits"`
+	Total  int64        `json:"total"`
+	Cursor string       `json:"cursor,omitempty"`
+	Facets *Facets      `json:"facets,omitempty"`
+}
+
+// SearchHit - статья выдачи с ID документа, оценкой и подсветкой
+type SearchHit struct {
+	*EsArticleDBO
+	ID    string  `json:"id"`
+	Score float64 `json:"score"`
+	// Фрагменты name и description с совпадениями в тегах search_highlight.
+	// Текст статьи в них экранирован как HTML
+	Highlight map[string][]string `json:"highlight,omitempty"`
+}
+
+// Facets - сколько статей по каждому значению при текущих фильтрах.
+// Список фасета не учитывает выбор в нём самом, только в остальных
+type Facets struct {
+	Countries  []FacetBucket `json:"countries"`
+	Publishers []FacetBucket `json:"publishers"`
+	Categories []FacetBucket `json:"categories"`
+	Languages  []FacetBucket `json:"languages"`
+	People     []FacetBucket `json:"people"`
+	Dates      []DateBucket  `json:"dates"`
+	// Шаг гистограммы, подобранный ES: 1d, 7d, 1M, ...
+	DateInterval string `json:"dateInterval,omitempty"`
+}
+
+type FacetBucket struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+type DateBucket struct {
+	Date  time.Time `json:"date"`
+	Count int64     `json:"count"`
+}
@@ -0,0 +1,28 @@
+package article
+
+// IndexStats - итог индексации статей за проход
+type IndexStats struct {
+	// Новые статьи
+	Created int `json:"created"`
+	// Статьи, изменившиеся с прошлого раза
+	Updated int `json:"updated"`
+	// Статьи, которые уже лежат в ES без изменений
+	Unchanged int `json:"unchanged"`
+	// Отброшенные по дате или без идентификатора
+	Skipped int `json:"skipped"`
+	// Ошибки записи
+	Failed int `json:"failed"`
+}
+
+func (s *IndexStats) Add(o IndexStats) {
+	s.Created += o.Created
+	s.Updated += o.Updated
+	s.Unchanged += o.Unchanged
+	s.Skipped += o.Skipped
+	s.Failed += o.Failed
+}
+
+// Indexed - сколько статей дошло до ES
+func (s *IndexStats) Indexed() int {
+	return s.Created + s.Updated + s.Unchanged
+}
@@ -0,0 +1,18 @@
+package article
+
+import "testing"
+
+func TestIndexStats(t *testing.T) {
+	stats := IndexStats{}
+	stats.Add(IndexStats{Created: 2, Skipped: 1})
+	stats.Add(IndexStats{Updated: 1, Unchanged: 3, Failed: 1})
+
+	want := IndexStats{Created: 2, Updated: 1, Unchanged: 3, Skipped: 1, Failed: 1}
+	if stats != want {
+		t.Errorf("Add() = %+v, want %+v", stats, want)
+	}
+	// Пропущенные и ошибки до ES не дошли
+	if got := stats.Indexed(); got != 6 {
+		t.Errorf("Indexed() = %d, want 6", got)
+	}
+}
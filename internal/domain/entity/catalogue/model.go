@@ -0,0 +1,140 @@
+package catalogue
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Catalogue - издатели и их ленты, описанные в файле каталога (catalogue_path).
+// Каталог - желаемое состояние: сверка приводит к нему Postgres и индекс издателей в ES
+type Catalogue struct {
+	Publishers []*Publisher `yaml:"publishers" json:"publishers"`
+}
+
+type Publisher struct {
+	Name      string   `yaml:"name" json:"name"`
+	Country   string   `yaml:"country" json:"country"`
+	City      string   `yaml:"city" json:"city"`
+	Latitude  float64  `yaml:"latitude" json:"latitude"`
+	Longitude float64  `yaml:"longitude" json:"longitude"`
+	Feeds     []string `yaml:"feeds" json:"feeds"`
+}
+
+// Load читает и проверяет файл каталога: .json или yaml
+func Load(path string) (*Catalogue, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Catalogue{}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, c)
+	} else {
+		err = yaml.Unmarshal(data, c)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("каталог %s: %w", path, err)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("каталог %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Validate проверяет каталог целиком и возвращает все ошибки сразу.
+// Издатели сравниваются без учёта регистра, как в publishersRepository.FindByName
+func (c *Catalogue) Validate() error {
+	var errs []error
+	names := map[string]bool{}
+	feeds := map[string]string{}
+	for i, p := range c.Publishers {
+		p.Name = strings.TrimSpace(p.Name)
+		where := fmt.Sprintf("издатель #%d %q", i+1, p.Name)
+		if p.Name == "" {
+			errs = append(errs, fmt.Errorf("%s: нет названия", where))
+		}
+		if key := strings.ToLower(p.Name); names[key] {
+			errs = append(errs, fmt.Errorf("%s: название повторяется", where))
+		} else {
+			names[key] = true
+		}
+		if p.Latitude < -90 || p.Latitude > 90 {
+			errs = append(errs, fmt.Errorf("%s: latitude вне [-90, 90]: %v", where, p.Latitude))
+		}
+		if p.Longitude < -180 || p.Longitude > 180 {
+			errs = append(errs, fmt.Errorf("%s: longitude вне [-180, 180]: %v", where, p.Longitude))
+		}
+
+		for j, feed := range p.Feeds {
+			feed = strings.TrimSpace(feed)
+			p.Feeds[j] = feed
+			if u, err := url.Parse(feed); err != nil ||
+				(u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				errs = append(errs, fmt.Errorf("%s: лента должна быть http(s) адресом: %q", where, feed))
+			}
+			if other, ok := feeds[feed]; ok {
+				errs = append(errs, fmt.Errorf("%s: лента %s уже есть у %q", where, feed, other))
+			}
+			feeds[feed] = p.Name
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Где применяется изменение
+const (
+	StorePostgres = "postgres"
+	StoreElastic  = "elastic"
+)
+
+// Что меняется
+const (
+	KindPublisher = "publisher"
+	KindSource    = "source"
+)
+
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+)
+
+// Change - одно изменение плана сверки
+type Change struct {
+	Store     string `json:"store"`
+	Kind      string `json:"kind"`
+	Action    string `json:"action"`
+	Publisher string `json:"publisher"`
+	RssURL    string `json:"rss_url,omitempty"`
+	// Что именно изменится при update
+	Fields []FieldChange `json:"fields,omitempty"`
+}
+
+type FieldChange struct {
+	Field string `json:"field"`
+	From  any    `json:"from"`
+	To    any    `json:"to"`
+}
+
+// Plan - разница между каталогом и базами.
+// Applied - изменения применены, а не только показаны
+type Plan struct {
+	Path    string    `json:"path"`
+	Prune   bool      `json:"prune"`
+	Applied bool      `json:"applied"`
+	Changes []*Change `json:"changes"`
+	// Записи вне каталога, которые остались, потому что prune выключен
+	Unmanaged []*Change `json:"unmanaged,omitempty"`
+}
+
+// Empty - базы уже совпадают с каталогом
+func (p *Plan) Empty() bool {
+	return len(p.Changes) == 0
+}
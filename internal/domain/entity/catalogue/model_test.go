@@ -0,0 +1,47 @@
+package catalogue
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	c := &Catalogue{Publishers: []*Publisher{
+		{Name: " Медуза ", Feeds: []string{" https://meduza.io/rss/all "}},
+		{Name: "медуза", Feeds: []string{"https://meduza.io/rss/news"}},
+		{Name: "ТАСС", Latitude: 91, Longitude: -181, Feeds: []string{"tass.ru/rss", "https://meduza.io/rss/all"}},
+		{Name: ""},
+	}}
+
+	err := c.Validate()
+	if err == nil {
+		t.Fatal("Validate() без ошибок")
+	}
+	// Все ошибки сразу, а не первая
+	for _, want := range []string{
+		`#2 "медуза": название повторяется`,
+		"latitude вне",
+		"longitude вне",
+		`http(s) адресом: "tass.ru/rss"`,
+		`лента https://meduza.io/rss/all уже есть у "Медуза"`,
+		"#4 \"\": нет названия",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate() = %v\nwant %s", err, want)
+		}
+	}
+	// Пробелы вокруг названия и адреса не считаются частью значения
+	if c.Publishers[0].Name != "Медуза" || c.Publishers[0].Feeds[0] != "https://meduza.io/rss/all" {
+		t.Errorf("издатель = %+v, want без пробелов", c.Publishers[0])
+	}
+}
+
+func TestValidateOK(t *testing.T) {
+	c := &Catalogue{Publishers: []*Publisher{
+		{Name: "Медуза", Latitude: 56.95, Longitude: 24.1, Feeds: []string{"https://meduza.io/rss/all", "https://meduza.io/rss/news"}},
+		{Name: "Без лент"},
+	}}
+	if err := c.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+}
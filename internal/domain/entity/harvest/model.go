@@ -0,0 +1,80 @@
+package harvest
+
+import (
+	"github.com/mskKote/prospero_backend/internal/domain/entity/article"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/source"
+	"time"
+)
+
+// Статусы задачи парсинга
+const (
+	JobRunning   = "running"
+	JobDone      = "done"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+// Кто запустил парсинг
+const (
+	TriggerCron   = "cron"
+	TriggerManual = "manual"
+)
+
+// Источник пропущен: в карантине, его уже парсят по запросу
+// или задачу отменили, пока читали ленту. Остальные статусы источника - source.Status*
+const (
+	SourceQuarantined = "quarantined"
+	SourceBusy        = "busy"
+	SourceCancelled   = "cancelled"
+)
+
+// Job - состояние задачи парсинга всех источников,
+// она же запись в harvest_runs
+type Job struct {
+	ID         string     `json:"id"`
+	Status     string     `json:"status"`
+	Trigger    string     `json:"trigger"`
+	Full       bool       `json:"full"`
+	StartedAt  time.Time  `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	// Сколько источников было на старте
+	SourcesTotal int64 `json:"sourcesTotal"`
+	// Сколько обработано, из них с ошибкой и пропущено
+	SourcesDone    int                `json:"sourcesDone"`
+	SourcesFailed  int                `json:"sourcesFailed"`
+	SourcesSkipped int                `json:"sourcesSkipped"`
+	Articles       article.IndexStats `json:"articles"`
+	Error          string             `json:"error,omitempty"`
+}
+
+// Finished - задача больше не изменится
+func (j *Job) Finished() bool {
+	return j.Status != JobRunning
+}
+
+// SourceResult - итог парсинга одного источника
+type SourceResult struct {
+	RssID     string `json:"rssID"`
+	RssURL    string `json:"rssURL"`
+	Publisher string `json:"publisher"`
+	Status    string `json:"status"`
+	// Сколько новостей было в ленте
+	Items      int                `json:"items"`
+	HTTPStatus int                `json:"httpStatus,omitempty"`
+	Error      string             `json:"error,omitempty"`
+	Articles   article.IndexStats `json:"articles"`
+	StartedAt  time.Time          `json:"startedAt"`
+	FinishedAt time.Time          `json:"finishedAt"`
+}
+
+// Add учитывает итог источника в задаче
+func (j *Job) Add(r SourceResult) {
+	j.SourcesDone++
+	switch r.Status {
+	case source.StatusError:
+		j.SourcesFailed++
+	case SourceQuarantined, SourceBusy, SourceCancelled, source.StatusRateLimited, source.StatusDuplicate:
+		j.SourcesSkipped++
+	}
+	j.Articles.Add(r.Articles)
+}
@@ -0,0 +1,41 @@
+package harvest
+
+import (
+	"github.com/mskKote/prospero_backend/internal/domain/entity/article"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/source"
+	"testing"
+)
+
+func TestJobAdd(t *testing.T) {
+	var j Job
+	for _, r := range []SourceResult{
+		{Status: source.StatusOK, Articles: article.IndexStats{Created: 2, Updated: 1}},
+		{Status: source.StatusNotModified},
+		{Status: source.StatusError, Articles: article.IndexStats{Failed: 1}},
+		{Status: SourceQuarantined},
+		{Status: source.StatusRateLimited},
+		{Status: SourceCancelled},
+		{Status: SourceBusy},
+		{Status: source.StatusDuplicate},
+	} {
+		j.Add(r)
+	}
+
+	if j.SourcesDone != 8 || j.SourcesFailed != 1 || j.SourcesSkipped != 5 {
+		t.Errorf("источники: done %d, failed %d, skipped %d, want 8, 1, 5", j.SourcesDone, j.SourcesFailed, j.SourcesSkipped)
+	}
+	want := article.IndexStats{Created: 2, Updated: 1, Failed: 1}
+	if j.Articles != want {
+		t.Errorf("Articles = %+v, want %+v", j.Articles, want)
+	}
+}
+
+func TestJobFinished(t *testing.T) {
+	for status, want := range map[string]bool{
+		JobRunning: false, JobDone: true, JobFailed: true, JobCancelled: true,
+	} {
+		if got := (&Job{Status: status}).Finished(); got != want {
+			t.Errorf("Finished(%s) = %t, want %t", status, got, want)
+		}
+	}
+}
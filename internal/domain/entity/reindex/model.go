@@ -0,0 +1,31 @@
+package reindex
+
+import "time"
+
+// Статусы переиндексации
+const (
+	RunRunning = "running"
+	RunDone    = "done"
+	RunFailed  = "failed"
+)
+
+// Run - переиндексация статей, категорий и людей из postgres в ES,
+// она же запись в reindex_runs. Незавершённую можно продолжить
+// с LastArticleID: статьи обходятся по возрастанию article_id
+type Run struct {
+	ID         string     `json:"id"`
+	Status     string     `json:"status"`
+	StartedAt  time.Time  `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	// Сколько статей в postgres, сколько записано и сколько с ошибкой
+	Total         int64  `json:"total"`
+	Done          int64  `json:"done"`
+	Failed        int64  `json:"failed"`
+	LastArticleID string `json:"lastArticleID"`
+	Error         string `json:"error,omitempty"`
+}
+
+// Finished - переиндексацию не нужно продолжать
+func (r *Run) Finished() bool {
+	return r.Status == RunDone
+}
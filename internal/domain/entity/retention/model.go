@@ -0,0 +1,171 @@
+package retention
+
+import (
+	"fmt"
+	"github.com/mskKote/prospero_backend/pkg/config"
+	"time"
+)
+
+// Что делать с устаревшими статьями
+const (
+	ActionDelete  = "delete"
+	ActionArchive = "archive"
+)
+
+// Статусы очистки
+const (
+	RunRunning = "running"
+	RunDone    = "done"
+	RunFailed  = "failed"
+)
+
+// Кто запустил очистку
+const (
+	TriggerCron   = "cron"
+	TriggerManual = "manual"
+)
+
+// Rule - сколько дней хранить статьи страны или издателя, 0 - всегда
+type Rule struct {
+	Country   string `json:"country,omitempty"`
+	Publisher string `json:"publisher,omitempty"`
+	Days      int    `json:"days"`
+}
+
+// Policy - сроки хранения статей. Правило издателя главнее правила страны,
+// правило страны - срока по умолчанию. Названия сравниваются как есть
+type Policy struct {
+	Action      string `json:"action"`
+	DefaultDays int    `json:"defaultDays"`
+	Rules       []Rule `json:"rules"`
+}
+
+// FromConfig - политика из раздела retention в app.yml
+func FromConfig(cfg *config.Config) *Policy {
+	p := &Policy{Action: cfg.Retention.Action, DefaultDays: cfg.Retention.DefaultDays}
+	for _, r := range cfg.Retention.Rules {
+		p.Rules = append(p.Rules, Rule{Country: r.Country, Publisher: r.Publisher, Days: r.Days})
+	}
+	return p
+}
+
+func (p *Policy) Validate() error {
+	if p.Action != ActionDelete && p.Action != ActionArchive {
+		return fmt.Errorf("retention.action: %s или %s", ActionDelete, ActionArchive)
+	}
+	for _, r := range p.Rules {
+		if (r.Country == "") == (r.Publisher == "") {
+			return fmt.Errorf("правило хранения: нужна страна или издатель")
+		}
+		if r.Days < 0 {
+			return fmt.Errorf("правило хранения %s%s: days < 0", r.Country, r.Publisher)
+		}
+	}
+	return nil
+}
+
+// Days - сколько дней хранить статью издателя из страны, 0 - всегда
+func (p *Policy) Days(country, publisher string) int {
+	days, found := p.DefaultDays, false
+	for _, r := range p.Rules {
+		switch {
+		case r.Publisher != "" && r.Publisher == publisher:
+			return r.Days
+		case !found && r.Country != "" && r.Country == country:
+			days, found = r.Days, true
+		}
+	}
+	return days
+}
+
+// Expired - статья старше своего срока хранения
+func (p *Policy) Expired(country, publisher string, published *time.Time, now time.Time) bool {
+	days := p.Days(country, publisher)
+	return days > 0 && published != nil && published.Before(now.AddDate(0, 0, -days))
+}
+
+// MaxDays - дольше этого не хранится ни одна статья, 0 - какие-то хранятся всегда
+func (p *Policy) MaxDays() int {
+	days := p.DefaultDays
+	for _, r := range p.Rules {
+		if days == 0 || r.Days == 0 {
+			return 0
+		}
+		days = max(days, r.Days)
+	}
+	return days
+}
+
+// Scope - статьи одного правила, которые пора удалить
+type Scope struct {
+	// publisher:<название>, country:<страна> или default
+	Name      string
+	Country   string
+	Publisher string
+	// Правила точнее - статьи под ними удаляет своё правило
+	ExceptCountries  []string
+	ExceptPublishers []string
+	Before           time.Time
+}
+
+// Scopes - что удалить на момент now, правила без срока пропускаются
+func (p *Policy) Scopes(now time.Time) []Scope {
+	var countries, publishers []string
+	for _, r := range p.Rules {
+		if r.Publisher != "" {
+			publishers = append(publishers, r.Publisher)
+		} else {
+			countries = append(countries, r.Country)
+		}
+	}
+
+	var scopes []Scope
+	for _, r := range p.Rules {
+		if r.Days == 0 {
+			continue
+		}
+		before := now.AddDate(0, 0, -r.Days)
+		if r.Publisher != "" {
+			scopes = append(scopes, Scope{Name: "publisher:" + r.Publisher, Publisher: r.Publisher, Before: before})
+		} else {
+			scopes = append(scopes, Scope{
+				Name: "country:" + r.Country, Country: r.Country, ExceptPublishers: publishers, Before: before,
+			})
+		}
+	}
+	if p.DefaultDays > 0 {
+		scopes = append(scopes, Scope{
+			Name:             "default",
+			ExceptCountries:  countries,
+			ExceptPublishers: publishers,
+			Before:           now.AddDate(0, 0, -p.DefaultDays),
+		})
+	}
+	return scopes
+}
+
+// Purged - сколько удалено по одному правилу
+type Purged struct {
+	Scope  string    `json:"scope"`
+	Before time.Time `json:"before"`
+	// Документы ES и статьи postgres
+	Documents int64 `json:"documents"`
+	Articles  int64 `json:"articles"`
+}
+
+// Run - одна очистка, она же запись в retention_runs
+type Run struct {
+	ID         string     `json:"id"`
+	Trigger    string     `json:"trigger"`
+	Status     string     `json:"status"`
+	Action     string     `json:"action"`
+	StartedAt  time.Time  `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	Scopes     []Purged   `json:"scopes"`
+	// Удалённые или закрытые партиции и документы в них
+	Indices   []string `json:"indices"`
+	IndexDocs int64    `json:"indexDocs"`
+	Documents int64    `json:"documents"`
+	Articles  int64    `json:"articles"`
+	Error     string   `json:"error,omitempty"`
+}
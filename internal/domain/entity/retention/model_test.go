@@ -0,0 +1,146 @@
+package retention
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPolicyDays(t *testing.T) {
+	p := &Policy{
+		Action:      ActionDelete,
+		DefaultDays: 365,
+		Rules: []Rule{
+			{Country: "Russia", Days: 730},
+			{Publisher: "Vedomosti", Days: 30},
+			{Country: "USA", Days: 0},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		country   string
+		publisher string
+		want      int
+	}{
+		{"по умолчанию", "UK", "The Guardian", 365},
+		{"правило страны", "Russia", "Kommersant", 730},
+		{"издатель главнее страны", "Russia", "Vedomosti", 30},
+		{"страна без срока", "USA", "The New York Times", 0},
+		{"названия как есть", "russia", "vedomosti", 365},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.Days(tt.country, tt.publisher); got != tt.want {
+				t.Errorf("Days(%q, %q) = %d, want %d", tt.country, tt.publisher, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyExpired(t *testing.T) {
+	p := &Policy{Action: ActionDelete, DefaultDays: 10, Rules: []Rule{{Country: "USA", Days: 0}}}
+	now := time.Date(2024, 5, 17, 12, 0, 0, 0, time.UTC)
+	old := now.AddDate(0, 0, -11)
+	fresh := now.AddDate(0, 0, -9)
+
+	tests := []struct {
+		name      string
+		country   string
+		published *time.Time
+		want      bool
+	}{
+		{"старше срока", "UK", &old, true},
+		{"моложе срока", "UK", &fresh, false},
+		{"без даты", "UK", nil, false},
+		{"хранится всегда", "USA", &old, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.Expired(tt.country, "", tt.published, now); got != tt.want {
+				t.Errorf("Expired(%q) = %t, want %t", tt.country, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyMaxDays(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy Policy
+		want   int
+	}{
+		{"выключена", Policy{}, 0},
+		{"только срок по умолчанию", Policy{DefaultDays: 365}, 365},
+		{"правило дольше", Policy{DefaultDays: 365, Rules: []Rule{{Country: "Russia", Days: 730}}}, 730},
+		{"правило короче", Policy{DefaultDays: 365, Rules: []Rule{{Publisher: "Vedomosti", Days: 30}}}, 365},
+		{"правило без срока", Policy{DefaultDays: 365, Rules: []Rule{{Country: "USA", Days: 0}}}, 0},
+		{"без срока по умолчанию", Policy{Rules: []Rule{{Country: "Russia", Days: 730}}}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.MaxDays(); got != tt.want {
+				t.Errorf("MaxDays() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyScopes(t *testing.T) {
+	now := time.Date(2024, 5, 17, 12, 0, 0, 0, time.UTC)
+	p := &Policy{
+		Action:      ActionDelete,
+		DefaultDays: 365,
+		Rules: []Rule{
+			{Country: "Russia", Days: 730},
+			{Publisher: "Vedomosti", Days: 30},
+			{Country: "USA", Days: 0},
+		},
+	}
+
+	want := []Scope{
+		{
+			Name: "country:Russia", Country: "Russia",
+			ExceptPublishers: []string{"Vedomosti"},
+			Before:           now.AddDate(0, 0, -730),
+		},
+		{Name: "publisher:Vedomosti", Publisher: "Vedomosti", Before: now.AddDate(0, 0, -30)},
+		{
+			Name:             "default",
+			ExceptCountries:  []string{"Russia", "USA"},
+			ExceptPublishers: []string{"Vedomosti"},
+			Before:           now.AddDate(0, 0, -365),
+		},
+	}
+	if got := p.Scopes(now); !reflect.DeepEqual(got, want) {
+		t.Errorf("Scopes() = %+v\nwant %+v", got, want)
+	}
+
+	if got := (&Policy{Action: ActionDelete}).Scopes(now); len(got) != 0 {
+		t.Errorf("выключенная политика: Scopes() = %+v, want пусто", got)
+	}
+}
+
+func TestPolicyValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  Policy
+		wantErr bool
+	}{
+		{"удаление", Policy{Action: ActionDelete, DefaultDays: 365}, false},
+		{"архив", Policy{Action: ActionArchive}, false},
+		{"неизвестное действие", Policy{Action: "drop"}, true},
+		{"правило без страны и издателя", Policy{Action: ActionDelete, Rules: []Rule{{Days: 10}}}, true},
+		{"правило со страной и издателем", Policy{
+			Action: ActionDelete, Rules: []Rule{{Country: "Russia", Publisher: "Vedomosti", Days: 10}},
+		}, true},
+		{"отрицательный срок", Policy{Action: ActionDelete, Rules: []Rule{{Country: "Russia", Days: -1}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.policy.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, wantErr %t", err, tt.wantErr)
+			}
+		})
+	}
+}
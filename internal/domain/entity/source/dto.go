@@ -25,11 +25,103 @@ type DeleteSourceDTO struct {
 	RssID string `json:"rss_id"`
 }
 
+type ResetSourceDTO struct {
+	RssID string `json:"rss_id"`
+}
+
+type HarvestSourceDTO struct {
+	RssID string `json:"rss_id"`
+	// Игнорировать watermark источника
+	Full bool `json:"full"`
+}
+
+type PreviewSourceDTO struct {
+	RssURL string `json:"rss_url"`
+}
+
+type DiscoverSourcesDTO struct {
+	SiteURL string `json:"site_url"`
+}
+
+// DiscoveredFeedDTO - найденная на сайте лента, уже скачанная и разобранная
+type DiscoveredFeedDTO struct {
+	RssURL              string `json:"rss_url"`
+	Via                 string `json:"via"`
+	Title               string `json:"title,omitempty"`
+	Language            string `json:"language,omitempty"`
+	ItemCount           int    `json:"item_count"`
+	UpdatePeriodMinutes int    `json:"update_period_minutes,omitempty"`
+}
+
+type AddSourcesDTO struct {
+	PublisherID string   `json:"publisher_id"`
+	RssURLs     []string `json:"rss_urls"`
+}
+
+// AddSourceResultDTO - итог добавления одного адреса из AddSourcesDTO
+type AddSourceResultDTO struct {
+	RssURL string     `json:"rss_url"`
+	Source *DTO       `json:"source,omitempty"`
+	Error  string     `json:"error,omitempty"`
+	Feed   *FeedError `json:"feed,omitempty"`
+}
+
+// Итог импорта одной ленты
+const (
+	ImportCreated = "created"
+	// Уже есть или не применена из-за отката, см. Reason
+	ImportSkipped = "skipped"
+	ImportFailed  = "failed"
+)
+
+// Режимы импорта
+const (
+	// Любая ошибка откатывает весь импорт
+	ImportAllOrNothing = "all_or_nothing"
+	// Ошибочные записи пропускаются, остальные сохраняются
+	ImportBestEffort = "best_effort"
+)
+
+// ImportResultDTO - итог импорта одной записи, Entry - её номер в файле с 1
+type ImportResultDTO struct {
+	Entry            int    `json:"entry"`
+	RssURL           string `json:"rss_url"`
+	Publisher        string `json:"publisher"`
+	Status           string `json:"status"`
+	Reason           string `json:"reason,omitempty"`
+	RssID            string `json:"rss_id,omitempty"`
+	PublisherCreated bool   `json:"publisher_created"`
+	Error            string `json:"error,omitempty"`
+}
+
+// HealthDTO - здоровье ленты для админки
+type HealthDTO struct {
+	LastStatus          string     `json:"last_status,omitempty"`
+	ConsecutiveFailures int32      `json:"consecutive_failures"`
+	LastError           string     `json:"last_error,omitempty"`
+	LastHTTPStatus      int32      `json:"last_http_status,omitempty"`
+	LastSuccessAt       *time.Time `json:"last_success_at,omitempty"`
+	QuarantinedUntil    *time.Time `json:"quarantined_until,omitempty"`
+}
+
+// FeedDTO - метаданные ленты для админки
+type FeedDTO struct {
+	Title               string    `json:"title,omitempty"`
+	Language            string    `json:"language,omitempty"`
+	ItemCount           int32     `json:"item_count"`
+	UpdatePeriodMinutes int32     `json:"update_period_minutes,omitempty"`
+	CheckedAt           time.Time `json:"checked_at"`
+}
+
 type DTO struct {
-	RssID       string    `json:"rss_id"`
-	RssURL      string    `json:"rss_url"`
-	PublisherID string    `json:"publisher_id"`
-	AddDate     time.Time `json:"add_date"`
+	RssID         string     `json:"rss_id"`
+	RssURL        string     `json:"rss_url"`
+	PublisherID   string     `json:"publisher_id"`
+	AddDate       time.Time  `json:"add_date"`
+	LastHarvestAt *time.Time `json:"last_harvest_at,omitempty"`
+	LastItemDate  *time.Time `json:"last_item_date,omitempty"`
+	Health        *HealthDTO `json:"health,omitempty"`
+	Feed          *FeedDTO   `json:"feed,omitempty"`
 }
 
 func (dto *DTO) ToDomain() RSS {
@@ -52,12 +144,43 @@ func (dto *DTO) ToDomain() RSS {
 //}
 
 func (r *RSS) ToDTO() *DTO {
-	return &DTO{
+	dto := &DTO{
 		RssID:       lib.UuidToString(r.RssID),
 		RssURL:      r.RssURL,
 		PublisherID: lib.UuidToString(r.Publisher.PublisherID),
 		AddDate:     r.AddDate.Time,
 	}
+	if r.LastHarvestAt.Valid {
+		dto.LastHarvestAt = lib.PointerFrom(r.LastHarvestAt.Time)
+	}
+	if r.LastItemDate.Valid {
+		dto.LastItemDate = lib.PointerFrom(r.LastItemDate.Time)
+	}
+
+	health := &HealthDTO{
+		LastStatus:          r.LastStatus.String,
+		ConsecutiveFailures: r.ConsecutiveFailures,
+		LastError:           r.LastError.String,
+		LastHTTPStatus:      r.LastHTTPStatus.Int32,
+	}
+	if r.LastSuccessAt.Valid {
+		health.LastSuccessAt = lib.PointerFrom(r.LastSuccessAt.Time)
+	}
+	if r.QuarantinedUntil.Valid {
+		health.QuarantinedUntil = lib.PointerFrom(r.QuarantinedUntil.Time)
+	}
+	dto.Health = health
+
+	if r.FeedCheckedAt.Valid {
+		dto.Feed = &FeedDTO{
+			Title:               r.FeedTitle.String,
+			Language:            r.FeedLanguage.String,
+			ItemCount:           r.FeedItemCount.Int32,
+			UpdatePeriodMinutes: r.FeedUpdateMinutes.Int32,
+			CheckedAt:           r.FeedCheckedAt.Time,
+		}
+	}
+	return dto
 }
 
 func ToDTOs(r []*RSS) (d []*DTO) {
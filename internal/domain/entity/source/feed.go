@@ -0,0 +1,50 @@
+package source
+
+import (
+	"fmt"
+	"github.com/jackc/pgx/v5/pgtype"
+	"time"
+)
+
+// FeedMeta - что лента сообщает о себе, заполняется при проверке адреса
+type FeedMeta struct {
+	// Адрес ленты после постоянных редиректов
+	URL       string
+	Title     string
+	Language  string
+	ItemCount int
+	// Заявленная частота обновления (sy:updatePeriod), 0 - не заявлена
+	UpdatePeriod time.Duration
+	CheckedAt    time.Time
+}
+
+// SetFeedMeta переносит метаданные ленты в источник
+func (r *RSS) SetFeedMeta(m FeedMeta) {
+	r.FeedTitle = pgtype.Text{String: m.Title, Valid: m.Title != ""}
+	r.FeedLanguage = pgtype.Text{String: m.Language, Valid: m.Language != ""}
+	r.FeedItemCount = pgtype.Int4{Int32: int32(m.ItemCount), Valid: true}
+	minutes := int32(m.UpdatePeriod.Minutes())
+	r.FeedUpdateMinutes = pgtype.Int4{Int32: minutes, Valid: minutes > 0}
+	r.FeedCheckedAt = pgtype.Timestamptz{Time: m.CheckedAt, Valid: true}
+}
+
+// Почему адрес не приняли как ленту
+const (
+	FeedInvalidURL  = "invalid_url"
+	FeedUnreachable = "unreachable"
+	FeedHTTPError   = "http_status"
+	FeedRateLimited = "rate_limited"
+	FeedNotAFeed    = "not_a_feed"
+)
+
+// FeedError - адрес источника не отдаёт RSS/Atom ленту
+type FeedError struct {
+	URL        string `json:"url"`
+	Reason     string `json:"reason"`
+	HTTPStatus int    `json:"http_status,omitempty"`
+	Detail     string `json:"detail"`
+}
+
+func (e *FeedError) Error() string {
+	return fmt.Sprintf("%s не лента (%s): %s", e.URL, e.Reason, e.Detail)
+}
@@ -3,11 +3,67 @@ package source
 import (
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/mskKote/prospero_backend/internal/domain/entity/publisher"
+	"time"
 )
 
 type RSS struct {
-	RssID     pgtype.UUID      `json:"rss_id"`
-	RssURL    string           `json:"rss_url"`
-	Publisher publisher.PgDBO  `json:"publisher_id"`
-	AddDate   pgtype.Timestamp `json:"add_date"`
+	RssID         pgtype.UUID        `json:"rss_id"`
+	RssURL        string             `json:"rss_url"`
+	Publisher     publisher.PgDBO    `json:"publisher_id"`
+	AddDate       pgtype.Timestamp   `json:"add_date"`
+	LastHarvestAt pgtype.Timestamptz `json:"last_harvest_at"`
+	LastItemDate  pgtype.Timestamptz `json:"last_item_date"`
+	LastItemGUID  pgtype.Text        `json:"last_item_guid"`
+	ETag          pgtype.Text        `json:"etag"`
+	LastModified  pgtype.Text        `json:"last_modified"`
+	// Здоровье ленты
+	LastStatus          pgtype.Text        `json:"last_status"`
+	ConsecutiveFailures int32              `json:"consecutive_failures"`
+	LastError           pgtype.Text        `json:"last_error"`
+	LastHTTPStatus      pgtype.Int4        `json:"last_http_status"`
+	LastSuccessAt       pgtype.Timestamptz `json:"last_success_at"`
+	QuarantinedUntil    pgtype.Timestamptz `json:"quarantined_until"`
+	// Что лента сообщила о себе при проверке адреса
+	FeedTitle         pgtype.Text        `json:"feed_title"`
+	FeedLanguage      pgtype.Text        `json:"feed_language"`
+	FeedItemCount     pgtype.Int4        `json:"feed_item_count"`
+	FeedUpdateMinutes pgtype.Int4        `json:"feed_update_minutes"`
+	FeedCheckedAt     pgtype.Timestamptz `json:"feed_checked_at"`
+}
+
+// IsQuarantined - источник временно не парсится из-за ошибок
+func (r *RSS) IsQuarantined(now time.Time) bool {
+	return r.QuarantinedUntil.Valid && r.QuarantinedUntil.Time.After(now)
+}
+
+// Watermark - докуда источник прочитан последним успешным парсингом
+type Watermark struct {
+	LastHarvestAt time.Time
+	LastItemDate  *time.Time
+	LastItemGUID  string
+	// ETag и Last-Modified прочитанной версии ленты
+	ETag         string
+	LastModified string
+}
+
+// Статусы последнего запроса ленты
+const (
+	StatusOK          = "ok"
+	StatusNotModified = "not_modified"
+	StatusRateLimited = "rate_limited"
+	// Лента переехала на адрес другого источника
+	StatusDuplicate = "duplicate"
+	StatusError     = "error"
+)
+
+// Health - состояние источника после очередного запроса ленты
+type Health struct {
+	Status              string
+	HTTPStatus          int
+	Error               string
+	ConsecutiveFailures int
+	// nil - не меняется
+	LastSuccessAt *time.Time
+	// nil - не в карантине
+	QuarantinedUntil *time.Time
 }
@@ -3,15 +3,23 @@ package articleService
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/result"
 	"github.com/mmcdole/gofeed"
 	"github.com/mskKote/prospero_backend/internal/adapters/db/elastic/v8/articlesSearchRepository"
+	"github.com/mskKote/prospero_backend/internal/adapters/db/postgres/articlesRepository"
 	"github.com/mskKote/prospero_backend/internal/adapters/db/postgres/sourcesRepository"
 	customMetrics "github.com/mskKote/prospero_backend/internal/adapters/metrics"
 	"github.com/mskKote/prospero_backend/internal/controller/http/v1/dto"
 	"github.com/mskKote/prospero_backend/internal/domain/entity/article"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/harvest"
 	"github.com/mskKote/prospero_backend/internal/domain/entity/publisher"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/retention"
 	"github.com/mskKote/prospero_backend/internal/domain/entity/source"
+	"github.com/mskKote/prospero_backend/pkg/client/rss"
+	"github.com/mskKote/prospero_backend/pkg/config"
+	"github.com/mskKote/prospero_backend/pkg/lib"
 	"github.com/mskKote/prospero_backend/pkg/logging"
 	"github.com/mskKote/prospero_backend/pkg/metrics"
 	"github.com/mskKote/prospero_backend/pkg/tracing"
@@ -19,76 +27,376 @@ import (
 	"go.uber.org/zap"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 )
 
 var (
 	logger = logging.GetLogger()
-	parser = gofeed.NewParser()
+	cfg    = config.GetConfig()
+
+	ErrSourceBusy = errors.New("источник уже парсится")
+	// ErrDuplicateSource - лента переехала на адрес, который уже читает другой источник
+	ErrDuplicateSource = errors.New("лента уже есть у другого источника")
 )
 
 type service struct {
-	sources sourcesRepository.IRepository
-	elastic articlesSearchRepository.IRepository
+	sources  sourcesRepository.IRepository
+	articles articlesRepository.IRepository
+	elastic  articlesSearchRepository.IRepository
+	feeds    rss.Client
+	indexer  *bulkIndexer
+	// Устаревшие статьи не сохраняем
+	retention *retention.Policy
+
+	// Источники, которые сейчас парсятся: ручной запуск не пересекается с общим
+	busyMu sync.Mutex
+	busy   map[string]bool
+}
+
+// lockSource занимает источник, false - его уже парсят
+func (s *service) lockSource(rssID string) bool {
+	s.busyMu.Lock()
+	defer s.busyMu.Unlock()
+	if s.busy[rssID] {
+		return false
+	}
+	s.busy[rssID] = true
+	return true
+}
+
+func (s *service) unlockSource(rssID string) {
+	s.busyMu.Lock()
+	defer s.busyMu.Unlock()
+	delete(s.busy, rssID)
 }
 
 // ------------------------------------------------------------------- RSS parsing
 
-func (s *service) ParseAllOnce(ctx context.Context, full bool) error {
+func (s *service) ParseAllOnce(ctx context.Context, full bool) (article.IndexStats, error) {
+	return s.ParseAll(ctx, full, nil)
+}
+
+func (s *service) ParseAll(ctx context.Context, full bool, onSource func(harvest.SourceResult)) (article.IndexStats, error) {
 	start := time.Now()
-	count, err := s.sources.Count(ctx)
-	if err != nil {
-		logger.ErrorContext(ctx, "[POSTGRES] Не посчитали источники", zap.Error(err))
-		return err
-	}
-	const batch = 8
-	parts := int(count / batch)
+	stats := article.IndexStats{}
 
-	potential := 0
+	// Пул из harvest_concurrency воркеров разбирает источники по одному
+	jobs := make(chan *source.RSS)
+	results := make(chan harvest.SourceResult)
+	var wg sync.WaitGroup
+	for w := 0; w < max(cfg.HarvestConcurrency, 1); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for src := range jobs {
+				if now := time.Now(); src.IsQuarantined(now) {
+					logger.Info(fmt.Sprintf("Источник в карантине до %s: %s",
+						src.QuarantinedUntil.Time.Format(time.RFC3339), src.RssURL))
+					results <- harvest.SourceResult{
+						RssID:      lib.UuidToString(src.RssID),
+						RssURL:     src.RssURL,
+						Publisher:  src.Publisher.Name,
+						Status:     harvest.SourceQuarantined,
+						StartedAt:  now,
+						FinishedAt: now,
+					}
+					continue
+				}
+				rssID := lib.UuidToString(src.RssID)
+				if !s.lockSource(rssID) {
+					logger.Info("Источник парсится по запросу, пропускаем: " + src.RssURL)
+					now := time.Now()
+					results <- harvest.SourceResult{
+						RssID:      rssID,
+						RssURL:     src.RssURL,
+						Publisher:  src.Publisher.Name,
+						Status:     harvest.SourceBusy,
+						StartedAt:  now,
+						FinishedAt: now,
+					}
+					continue
+				}
+				logger.Info("Парсим источник: " + src.RssURL)
+				r := s.harvestSource(ctx, src, full)
+				s.unlockSource(rssID)
+				results <- r
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
 
-	for i := 0; i <= parts; i++ {
-		sources, err := s.sources.FindAllWithPublishers(ctx, i*batch, batch)
-		if err != nil {
-			logger.ErrorContext(ctx,
-				fmt.Sprintf("Не прочитали партию источников {%d/%d}", i+1, parts+1),
-				zap.Error(err))
-			return err
-		}
-		logger.InfoContext(ctx,
-			fmt.Sprintf("Прочитали партию источников {%d/%d}", i+1, parts+1))
-
-		// Читаем партию источников
-		partPotential := make(chan int)
-		for srcI, src := range sources {
-			go func(_srcI int, _src *source.RSS) {
-				logger.Info(fmt.Sprintf("Парсим источник #%d: %s", i*batch+_srcI+1, _src.RssURL))
-
-				feed, feedErr := s.ParseRSS(ctx, _src.RssURL)
-				if feedErr != nil {
-					logger.ErrorContext(ctx, fmt.Sprintf("Не парсили источник #%d: %s", i*batch+_srcI+1, _src.RssURL))
-					partPotential <- 0
+	// Читаем источники страницами и раздаём воркерам
+	var readErr error
+	go func() {
+		defer close(jobs)
+		const page = 50
+		for offset := 0; ; offset += page {
+			sources, err := s.sources.FindAllWithPublishers(ctx, offset, page)
+			if err != nil {
+				logger.ErrorContext(ctx,
+					fmt.Sprintf("Не прочитали источники с %d", offset),
+					zap.Error(err))
+				readErr = err
+				return
+			}
+			logger.InfoContext(ctx,
+				fmt.Sprintf("Прочитали источники %d-%d", offset+1, offset+len(sources)))
+			for _, src := range sources {
+				select {
+				case jobs <- src:
+				case <-ctx.Done():
+					readErr = ctx.Err()
 					return
 				}
-				//u.logFeed(feed)
-				//feedPotential := s.analyseFeed(feed)
-				// сохранить новости в ES
-				s.indexFeed(ctx, _src.Publisher.ToDTO(), feed, full)
-				partPotential <- 0
-			}(srcI, src)
+			}
+			if len(sources) < page {
+				return
+			}
 		}
-		// Ждём чтение партии
-		for range sources {
-			potential += <-partPotential
+	}()
+
+	for res := range results {
+		stats.Add(res.Articles)
+		if onSource != nil {
+			onSource(res)
 		}
 	}
+	if readErr != nil {
+		return stats, readErr
+	}
 
-	logger.InfoContext(ctx, fmt.Sprintf("Потенциальные топонимы/имена %d", potential))
+	logger.InfoContext(ctx, fmt.Sprintf(
+		"Статьи: новых %d, обновлено %d, без изменений %d, пропущено %d, ошибок %d",
+		stats.Created, stats.Updated, stats.Unchanged, stats.Skipped, stats.Failed))
+	observeIndexStats(stats)
 
 	elapsed := time.Since(start)
 	metrics.ObserveSummaryMetric(customMetrics.MetricRssObtainName, elapsed.Seconds())
+	return stats, nil
+}
+
+// harvestSource читает ленту источника, сохраняет новые статьи
+// и записывает watermark и здоровье источника
+func (s *service) harvestSource(ctx context.Context, src *source.RSS, full bool) (r harvest.SourceResult) {
+	harvestStart := time.Now()
+	r = harvest.SourceResult{
+		RssID:     lib.UuidToString(src.RssID),
+		RssURL:    src.RssURL,
+		Publisher: src.Publisher.Name,
+		StartedAt: harvestStart,
+	}
+	defer func() { r.FinishedAt = time.Now() }()
+
+	validators := rss.Validators{
+		ETag:         src.ETag.String,
+		LastModified: src.LastModified.String,
+	}
+	fetchCtx, cancel := context.WithTimeout(ctx, cfg.HarvestSourceTimeout)
+	res, feedErr := s.feeds.Fetch(fetchCtx, src.RssURL, validators)
+	cancel()
+	// Отменили задачу, а не лента сломалась: здоровье и watermark не трогаем
+	if feedErr != nil && ctx.Err() != nil {
+		logger.Info("Парсинг отменён, пропускаем источник: " + src.RssURL)
+		r.Status = harvest.SourceCancelled
+		return r
+	}
+	if res != nil {
+		r.HTTPStatus = res.StatusCode
+	}
+	if feedErr != nil && !errors.Is(feedErr, rss.ErrNotModified) {
+		r.Error = feedErr.Error()
+	}
+
+	// Лента переехала навсегда - запоминаем новый адрес.
+	// Если его уже читает другой источник, этот - дубликат: не парсим и отмечаем в здоровье
+	if res != nil && res.PermanentURL != "" {
+		if err := s.moveSource(ctx, src, res.PermanentURL); err != nil {
+			logger.Warn("Источник не парсим: "+src.RssURL, zap.Error(err))
+			metrics.AddCounterVecMetric(customMetrics.MetricRssFetchName, 1, "duplicate")
+			r.Error = err.Error()
+			r.Status = s.recordHealth(ctx, src, res, err, harvestStart)
+			return r
+		}
+	}
+
+	if errors.Is(feedErr, rss.ErrNotModified) {
+		logger.Info("Источник не изменился: " + src.RssURL)
+		metrics.AddCounterVecMetric(customMetrics.MetricRssFetchName, 1, "not_modified")
+		w := source.Watermark{
+			LastHarvestAt: harvestStart,
+			ETag:          validators.ETag,
+			LastModified:  validators.LastModified,
+		}
+		if err := s.sources.UpdateWatermark(ctx, src.RssID, w); err != nil {
+			logger.ErrorContext(ctx, "Не сохранили watermark источника "+src.RssURL, zap.Error(err))
+		}
+		r.Status = s.recordHealth(ctx, src, res, feedErr, harvestStart)
+		return r
+	}
+	var rateLimited *rss.RateLimitedError
+	if errors.As(feedErr, &rateLimited) {
+		logger.Warn("Хост просит подождать, пропускаем источник: "+src.RssURL, zap.Error(feedErr))
+		metrics.AddCounterVecMetric(customMetrics.MetricRssFetchName, 1, "rate_limited")
+		r.Status = s.recordHealth(ctx, src, res, feedErr, harvestStart)
+		return r
+	}
+	if feedErr != nil {
+		logger.ErrorContext(ctx, "Не парсили источник "+src.RssURL, zap.Error(feedErr))
+		metrics.AddCounterVecMetric(customMetrics.MetricRssFetchName, 1, "failed")
+		r.Status = s.recordHealth(ctx, src, res, feedErr, harvestStart)
+		return r
+	}
+	metrics.AddCounterVecMetric(customMetrics.MetricRssFetchName, 1, "fetched")
+	r.Status = s.recordHealth(ctx, src, res, nil, harvestStart)
+
+	feed := res.Feed
+	r.Items = len(feed.Items)
+	//u.logFeed(feed)
+	//feedPotential := s.analyseFeed(feed)
+	// сохранить новости в ES
+	var from *time.Time
+	if !full {
+		from = lib.PointerFrom(harvestFrom(src, harvestStart))
+	}
+	r.Articles = s.indexFeed(ctx, r.RssID, src.Publisher.ToDTO(), feed, from, src.LastItemGUID.String)
+
+	// Сдвигаем watermark, только если всё записали
+	if r.Articles.Failed == 0 {
+		w := watermark(src, feed, harvestStart)
+		w.ETag = res.Validators.ETag
+		w.LastModified = res.Validators.LastModified
+		if err := s.sources.UpdateWatermark(ctx, src.RssID, w); err != nil {
+			logger.ErrorContext(ctx, "Не сохранили watermark источника "+src.RssURL, zap.Error(err))
+		}
+	}
+	return r
+}
+
+// moveSource сохраняет новый адрес ленты. rss_url уникален:
+// если адрес уже у другого источника, возвращает ErrDuplicateSource
+func (s *service) moveSource(ctx context.Context, src *source.RSS, url string) error {
+	logger.Info(fmt.Sprintf("Источник переехал: %s -> %s", src.RssURL, url))
+	other, err := s.sources.FindByURL(ctx, url)
+	switch {
+	case err == nil && other.RssID != src.RssID:
+		return fmt.Errorf("%w: %s у %s", ErrDuplicateSource, url, lib.UuidToString(other.RssID))
+	case err != nil && !errors.Is(err, sourcesRepository.ErrNotFound):
+		logger.ErrorContext(ctx, "Не проверили новый адрес источника "+src.RssURL, zap.Error(err))
+		return nil
+	}
+	if err := s.sources.UpdateURL(ctx, src.RssID, url); err != nil {
+		logger.ErrorContext(ctx, "Не обновили адрес источника "+src.RssURL, zap.Error(err))
+	}
 	return nil
 }
 
+// recordHealth записывает итог запроса ленты.
+// После harvest_quarantine_after ошибок подряд источник уходит в карантин,
+// каждая следующая ошибка удваивает срок. Возвращает записанный статус
+func (s *service) recordHealth(ctx context.Context, src *source.RSS, res *rss.Response, feedErr error, at time.Time) string {
+	h := source.Health{}
+	if res != nil {
+		h.HTTPStatus = res.StatusCode
+	}
+
+	switch {
+	case feedErr == nil:
+		h.Status = source.StatusOK
+		h.LastSuccessAt = &at
+	case errors.Is(feedErr, rss.ErrNotModified):
+		h.Status = source.StatusNotModified
+		h.LastSuccessAt = &at
+	case errors.As(feedErr, new(*rss.RateLimitedError)), errors.Is(feedErr, ErrDuplicateSource):
+		// Лента не сломана: хост просит подождать или её читает другой источник -
+		// счётчик ошибок не трогаем
+		h.Status = source.StatusRateLimited
+		if errors.Is(feedErr, ErrDuplicateSource) {
+			h.Status = source.StatusDuplicate
+		}
+		h.Error = feedErr.Error()
+		h.ConsecutiveFailures = int(src.ConsecutiveFailures)
+		if src.QuarantinedUntil.Valid {
+			h.QuarantinedUntil = &src.QuarantinedUntil.Time
+		}
+	default:
+		h.Status = source.StatusError
+		h.Error = feedErr.Error()
+		h.ConsecutiveFailures = int(src.ConsecutiveFailures) + 1
+		h.QuarantinedUntil = quarantineUntil(h.ConsecutiveFailures, at,
+			cfg.HarvestQuarantineAfter, cfg.HarvestQuarantineBackoff, cfg.HarvestQuarantineMaxBackoff)
+		if h.QuarantinedUntil != nil {
+			logger.Warn(fmt.Sprintf("Источник %s в карантине до %s после %d ошибок подряд",
+				src.RssURL, h.QuarantinedUntil.Format(time.RFC3339), h.ConsecutiveFailures))
+		}
+	}
+
+	if err := s.sources.UpdateHealth(ctx, src.RssID, h); err != nil {
+		logger.ErrorContext(ctx, "Не сохранили здоровье источника "+src.RssURL, zap.Error(err))
+	}
+	return h.Status
+}
+
+// quarantineUntil - конец карантина после failures ошибок подряд или nil.
+// Карантин начинается с after ошибок со срока backoff и удваивается до maxBackoff, after <= 0 - без карантина
+func quarantineUntil(failures int, now time.Time, after int, backoff, maxBackoff time.Duration) *time.Time {
+	if after <= 0 || failures < after {
+		return nil
+	}
+	for i := after; i < failures && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	return lib.PointerFrom(now.Add(backoff))
+}
+
+// harvestFrom - с какого момента брать новости источника:
+// с последней уже прочитанной новости, но не глубже harvest_catch_up
+func harvestFrom(src *source.RSS, now time.Time) time.Time {
+	from := now.Add(-cfg.HarvestCatchUp)
+	if src.LastItemDate.Valid && src.LastItemDate.Time.After(from) {
+		from = src.LastItemDate.Time
+	}
+	return from
+}
+
+// watermark - новое состояние источника после успешного парсинга
+func watermark(src *source.RSS, feed *gofeed.Feed, harvestStart time.Time) source.Watermark {
+	w := source.Watermark{LastHarvestAt: harvestStart}
+	if src.LastItemDate.Valid {
+		w.LastItemDate = &src.LastItemDate.Time
+	}
+	for _, item := range feed.Items {
+		if item.PublishedParsed == nil {
+			continue
+		}
+		// Дата из будущего не уводит watermark вперёд:
+		// иначе статьи, вышедшие до неё, считались бы уже прочитанными
+		published := *item.PublishedParsed
+		if published.After(harvestStart) {
+			published = harvestStart
+		}
+		if w.LastItemDate == nil || published.After(*w.LastItemDate) {
+			w.LastItemDate = &published
+			w.LastItemGUID = item.GUID
+		}
+	}
+	return w
+}
+
+func observeIndexStats(stats article.IndexStats) {
+	metrics.AddCounterVecMetric(customMetrics.MetricRssArticlesName, float64(stats.Created), "created")
+	metrics.AddCounterVecMetric(customMetrics.MetricRssArticlesName, float64(stats.Updated), "updated")
+	metrics.AddCounterVecMetric(customMetrics.MetricRssArticlesName, float64(stats.Unchanged), "unchanged")
+	metrics.AddCounterVecMetric(customMetrics.MetricRssArticlesName, float64(stats.Skipped), "skipped")
+	metrics.AddCounterVecMetric(customMetrics.MetricRssArticlesName, float64(stats.Failed), "failed")
+}
+
 func (s *service) logFeed(feed *gofeed.Feed) {
 	jsonFeed, err := json.MarshalIndent(feed, "", "  ")
 	if err != nil {
@@ -126,93 +434,230 @@ func (s *service) analyseFeed(feed *gofeed.Feed) int {
 	return potential
 }
 
-func (s *service) indexFeed(ctx context.Context, p *publisher.DTO, feed *gofeed.Feed, full bool) {
-	itemsChan := make(chan bool)
+// indexFeed сохраняет новости ленты в postgres, затем в ES через bulkIndexer.
+// from - новости не старше этого момента, nil - все новости ленты;
+// новость ровно на from с lastGUID уже сохранена прошлым парсингом
+func (s *service) indexFeed(
+	ctx context.Context,
+	rssID string,
+	p *publisher.DTO,
+	feed *gofeed.Feed,
+	from *time.Time,
+	lastGUID string) article.IndexStats {
 
+	stats := article.IndexStats{}
+	var articles []*article.EsArticleDBO
 	for _, item := range feed.Items {
-		go func(_item *gofeed.Item) {
-			// Do not save news without time
-			if _item.PublishedParsed == nil {
-				itemsChan <- false
-				return
-			}
+		articleDBO := toArticle(p, feed, item)
+		if s.skipReason(item, articleDBO, from, lastGUID) != "" {
+			stats.Skipped++
+			continue
+		}
+		articles = append(articles, articleDBO)
+	}
 
-			// Сохраняю новости только за последние N времени
-			// TODO: remove hardcode 20 minutes
-			if full == false && time.Since(*_item.PublishedParsed) > 20*time.Minute {
-				itemsChan <- false
-				return
-			}
+	// postgres - источник истины: не сохранили там - не пишем и в ES,
+	// watermark не сдвинется и лента перечитается
+	published, err := s.articles.SaveAll(ctx, rssID, p.PublisherID, articles)
+	if err != nil {
+		logger.ErrorContext(ctx, "Не сохранили статьи в postgres", zap.Error(err))
+		stats.Failed += len(articles)
+		return stats
+	}
 
-			var people []article.PersonES
-			for _, author := range _item.Authors {
-				people = append(people, article.PersonES{FullName: author.Name})
-			}
-			language := strings.ToLower(strings.Split(feed.Language, "-")[0])
-
-			articleDBO := &article.EsArticleDBO{
-				Name:        _item.Title,
-				Description: _item.Description,
-				URL:         _item.Link,
-				Address: article.AddressES{
-					Coords:  [2]float64{p.Latitude, p.Longitude},
-					Country: p.Country,
-					City:    p.City,
-				},
-				Publisher: article.PublisherES{
-					Name: p.Name,
-					Address: article.AddressES{
-						Coords:  [2]float64{p.Latitude, p.Longitude},
-						Country: p.Country,
-						City:    p.City,
-					},
-				},
-				Categories:    _item.Categories,
-				People:        people,
-				Links:         _item.Links,
-				DatePublished: _item.PublishedParsed,
-				Language:      language,
-			}
-			if ok := s.elastic.IndexArticle(ctx, articleDBO); !ok {
-				logger.ErrorContext(ctx, "Ошибка добавления статьи")
-				itemsChan <- false
-				return
+	var pending []<-chan articlesSearchRepository.BulkItemResult
+	moved := map[int]bool{}
+	for i, articleDBO := range articles {
+		index := articlesSearchRepository.ArticleIndexFor(articleDBO.DatePublished)
+		pending = append(pending, s.indexer.Add(ctx, articlesSearchRepository.BulkDocument{
+			Index:  index,
+			ID:     articleDBO.ID,
+			Doc:    articleDBO,
+			Upsert: true,
+		}))
+
+		// Дату публикации поправили через границу партиции - старая копия не нужна
+		if prev, ok := published[articleDBO.ID]; ok {
+			if old := articlesSearchRepository.ArticleIndexFor(prev); old != index {
+				moved[i] = true
+				s.indexer.Add(ctx, articlesSearchRepository.BulkDocument{Index: old, ID: articleDBO.ID, Delete: true})
 			}
+		}
 
-			for _, category := range _item.Categories {
-				categoryDBO := &article.CategoryES{Name: category}
+		// Подсказки для поиска, их итог не ждём
+		for _, category := range articleDBO.Categories {
+			s.indexer.Add(ctx, articlesSearchRepository.BulkDocument{
+				Index: articlesSearchRepository.CategoryWriteIndex,
+				ID:    article.CategoryID(category),
+				Doc:   &article.CategoryES{Name: category},
+			})
+		}
+		for _, person := range articleDBO.People {
+			s.indexer.Add(ctx, articlesSearchRepository.BulkDocument{
+				Index: articlesSearchRepository.PeopleWriteIndex,
+				ID:    article.PersonID(person.FullName),
+				Doc:   &article.PersonES{FullName: person.FullName},
+			})
+		}
+	}
 
-				s.elastic.IndexCategory(ctx, categoryDBO)
-			}
-			for _, person := range people {
-				s.elastic.IndexPeople(ctx, &person)
-			}
-			itemsChan <- true
-		}(item)
+	for i, done := range pending {
+		res := <-done
+		switch {
+		case res.Err != nil:
+			stats.Failed++
+		case res.Result == result.Created && moved[i]:
+			stats.Updated++
+		case res.Result == result.Created:
+			stats.Created++
+		case res.Result == result.Updated:
+			stats.Updated++
+		default:
+			stats.Unchanged++
+		}
 	}
-	for range feed.Items {
-		<-itemsChan
+	return stats
+}
+
+// skipReason - почему новость не сохраняем, пустая строка - сохраняем
+func (s *service) skipReason(item *gofeed.Item, articleDBO *article.EsArticleDBO, from *time.Time, lastGUID string) string {
+	// Do not save news without time
+	if item.PublishedParsed == nil {
+		return article.SkipNoDate
 	}
+
+	// Сохраняю только новости после watermark источника
+	if from != nil {
+		published := *item.PublishedParsed
+		if published.Before(*from) || (published.Equal(*from) && item.GUID == lastGUID) {
+			return article.SkipBeforeWatermark
+		}
+	}
+
+	// Без идентификатора статью не отличить от уже сохранённой
+	if articleDBO.ID == "" {
+		return article.SkipNoID
+	}
+
+	// Очистка такую уже удалила бы, а её партиция может быть закрыта
+	if s.retention.Expired(articleDBO.Address.Country, articleDBO.Publisher.Name, item.PublishedParsed, time.Now()) {
+		return article.SkipExpired
+	}
+	return ""
+}
+
+// toArticle - новость ленты в виде документа ES
+func toArticle(p *publisher.DTO, feed *gofeed.Feed, item *gofeed.Item) *article.EsArticleDBO {
+	var people []article.PersonES
+	for _, author := range item.Authors {
+		people = append(people, article.PersonES{FullName: author.Name})
+	}
+	language := strings.ToLower(strings.Split(feed.Language, "-")[0])
+
+	address := article.NewAddressES(p.Country, p.City, p.Latitude, p.Longitude)
+
+	a := &article.EsArticleDBO{
+		ID:          article.StableID(p.Name, item.GUID, item.Link),
+		Name:        item.Title,
+		Description: item.Description,
+		URL:         item.Link,
+		Address:     address,
+		Publisher: article.PublisherES{
+			Name:    p.Name,
+			Address: address,
+		},
+		Categories:    item.Categories,
+		People:        people,
+		Links:         item.Links,
+		DatePublished: item.PublishedParsed,
+		Language:      language,
+	}
+	a.Normalize()
+	return a
+}
+
+func (s *service) HarvestOne(ctx context.Context, rssID string, full bool) (harvest.SourceResult, error) {
+	if !lib.StringToUUID(rssID).Valid {
+		return harvest.SourceResult{}, sourcesRepository.ErrNotFound
+	}
+	src, err := s.sources.FindByIDWithPublisher(ctx, rssID)
+	if err != nil {
+		return harvest.SourceResult{}, err
+	}
+	// Тот же источник из общего парсинга сдвинул бы watermark под нами
+	id := lib.UuidToString(src.RssID)
+	if !s.lockSource(id) {
+		return harvest.SourceResult{}, ErrSourceBusy
+	}
+	defer s.unlockSource(id)
+
+	// Админ просит явно - карантин не помеха
+	logger.Info("Парсим источник по запросу: " + src.RssURL)
+	r := s.harvestSource(ctx, src, full)
+	observeIndexStats(r.Articles)
+	return r, nil
+}
+
+func (s *service) Preview(ctx context.Context, url string) (*article.Preview, error) {
+	fetchCtx, cancel := context.WithTimeout(ctx, cfg.HarvestSourceTimeout)
+	defer cancel()
+	res, err := s.feeds.Fetch(fetchCtx, url, rss.Validators{})
+	if err != nil {
+		return nil, err
+	}
+
+	feed := res.Feed
+	preview := &article.Preview{
+		RssURL:   url,
+		Title:    feed.Title,
+		Language: feed.Language,
+		Items:    len(feed.Items),
+		Articles: make([]*article.PreviewArticle, 0, len(feed.Items)),
+	}
+	if res.PermanentURL != "" {
+		preview.RssURL = res.PermanentURL
+	}
+
+	// Издателя ещё нет, документы без него
+	p := &publisher.DTO{}
+	for _, item := range feed.Items {
+		articleDBO := toArticle(p, feed, item)
+		preview.Articles = append(preview.Articles, &article.PreviewArticle{
+			ID:           articleDBO.ID,
+			SkipReason:   s.skipReason(item, articleDBO, nil, ""),
+			EsArticleDBO: articleDBO,
+		})
+	}
+	return preview, nil
 }
 
 func (s *service) ParseRSS(ctx context.Context, src string) (*gofeed.Feed, error) {
-	feed, err := parser.ParseURLWithContext(src, ctx)
+	res, err := s.feeds.Fetch(ctx, src, rss.Validators{})
 	if err != nil {
 		logger.Error("Ошибка парса", zap.Error(err))
 		return nil, err
 	}
-	return feed, nil
+	return res.Feed, nil
 }
 
 // ------------------------------------------------------------------- Search hints
 
-func (s *service) FindWithGrandFilter(ctx context.Context, p dto.GrandFilterRequest, size int) ([]*article.EsArticleDBO, int64, error) {
+func (s *service) FindWithGrandFilter(ctx context.Context, p dto.GrandFilterRequest, size int, cursor string, paginate, facets bool) (*article.SearchPage, error) {
 	tracer := tracing.TracerFromContext(ctx)
 	ctxWithSpan, span := tracer.Start(ctx, "ElasticSearch")
 	span.SetAttributes(attribute.String("[articleSERVICE]", "Идём в ElasticSearch"))
 	defer span.End()
 
-	return s.elastic.FindArticles(ctxWithSpan, p, size)
+	return s.elastic.FindArticles(ctxWithSpan, p, size, cursor, paginate, facets)
+}
+
+func (s *service) FindClusters(ctx context.Context, m dto.MapRequest) (*article.MapClusters, error) {
+	tracer := tracing.TracerFromContext(ctx)
+	ctxWithSpan, span := tracer.Start(ctx, "ElasticSearch")
+	span.SetAttributes(attribute.String("[articleSERVICE]", "Идём в ElasticSearch"))
+	defer span.End()
+
+	return s.elastic.FindClusters(ctxWithSpan, m)
 }
 
 func (s *service) FindAllLanguages(ctx context.Context) ([]*article.LanguageES, error) {
@@ -237,8 +682,23 @@ func (s *service) FindPeople(ctx context.Context, name string) ([]*article.Perso
 	return s.elastic.FindPeople(ctx, name)
 }
 
+func (s *service) Close() {
+	s.indexer.Close()
+}
+
 func New(
 	sources sourcesRepository.IRepository,
-	elastic articlesSearchRepository.IRepository) IArticleService {
-	return &service{sources, elastic}
+	articles articlesRepository.IRepository,
+	elastic articlesSearchRepository.IRepository,
+	feeds rss.Client) IArticleService {
+	indexer := newBulkIndexer(context.Background(), elastic, cfg.BulkSize, cfg.BulkFlushInterval)
+	return &service{
+		sources:   sources,
+		articles:  articles,
+		elastic:   elastic,
+		feeds:     feeds,
+		indexer:   indexer,
+		retention: retention.FromConfig(cfg),
+		busy:      map[string]bool{},
+	}
 }
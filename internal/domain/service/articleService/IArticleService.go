@@ -5,13 +5,26 @@ import (
 	"github.com/mmcdole/gofeed"
 	"github.com/mskKote/prospero_backend/internal/controller/http/v1/dto"
 	"github.com/mskKote/prospero_backend/internal/domain/entity/article"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/harvest"
 )
 
 type IArticleService interface {
-	FindWithGrandFilter(ctx context.Context, p dto.GrandFilterRequest, size int) ([]*article.EsArticleDBO, int64, error)
+	FindWithGrandFilter(ctx context.Context, p dto.GrandFilterRequest, size int, cursor string, paginate, facets bool) (*article.SearchPage, error)
+	// FindClusters - кластеры статей на карте со свежими заголовками
+	FindClusters(ctx context.Context, m dto.MapRequest) (*article.MapClusters, error)
 
-	// ParseAllOnce проходит по всем источникам
-	ParseAllOnce(ctx context.Context, full bool) error
+	// ParseAllOnce проходит по всем источникам,
+	// возвращает сколько статей создано, обновлено и осталось без изменений
+	ParseAllOnce(ctx context.Context, full bool) (article.IndexStats, error)
+
+	// ParseAll - ParseAllOnce, сообщающий onSource итог каждого источника
+	ParseAll(ctx context.Context, full bool, onSource func(harvest.SourceResult)) (article.IndexStats, error)
+
+	// HarvestOne парсит один источник, даже если он в карантине
+	HarvestOne(ctx context.Context, rssID string, full bool) (harvest.SourceResult, error)
+
+	// Preview парсит ленту как при сохранении, но ничего не записывает
+	Preview(ctx context.Context, url string) (*article.Preview, error)
 
 	// ParseRSS достаёт контент по источнику
 	ParseRSS(ctx context.Context, src string) (*gofeed.Feed, error)
@@ -21,4 +34,7 @@ type IArticleService interface {
 	FindCategory(ctx context.Context, cat string) ([]*article.CategoryES, error)
 
 	FindPeople(ctx context.Context, name string) ([]*article.PersonES, error)
+
+	// Close дописывает в ES накопленные статьи и останавливает фоновую запись
+	Close()
 }
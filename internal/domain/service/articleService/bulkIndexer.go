@@ -0,0 +1,167 @@
+package articleService
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/result"
+	"github.com/mskKote/prospero_backend/internal/adapters/db/elastic/v8/articlesSearchRepository"
+	customMetrics "github.com/mskKote/prospero_backend/internal/adapters/metrics"
+	"github.com/mskKote/prospero_backend/pkg/metrics"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+// ErrIndexerClosed - документ пришёл после Close, записывать его некому
+var ErrIndexerClosed = errors.New("запись в ES остановлена")
+
+// bulkIndexer копит документы и пишет их в ES пачками _bulk:
+// как только набралось bulk_size документов или прошло bulk_flush_interval.
+// Пачки пишутся по одной, поэтому при медленном ES Add блокируется
+type bulkIndexer struct {
+	elastic articlesSearchRepository.IRepository
+	size    int
+
+	mu      sync.Mutex
+	pending []pendingDoc
+	// после Close документы не принимаются
+	closing bool
+
+	// одна пачка в полёте
+	flushMu sync.Mutex
+
+	closeOnce sync.Once
+	closed    chan struct{}
+	stopped   chan struct{}
+}
+
+type pendingDoc struct {
+	doc  articlesSearchRepository.BulkDocument
+	done chan articlesSearchRepository.BulkItemResult
+}
+
+// newBulkIndexer запускает запись по таймеру, она идёт до отмены ctx или Close
+func newBulkIndexer(
+	ctx context.Context,
+	elastic articlesSearchRepository.IRepository,
+	size int,
+	interval time.Duration) *bulkIndexer {
+
+	b := &bulkIndexer{
+		elastic: elastic,
+		size:    size,
+		closed:  make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go func() {
+		defer close(b.stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				b.flush(context.WithoutCancel(ctx))
+			case <-ctx.Done():
+				return
+			case <-b.closed:
+				return
+			}
+		}
+	}()
+	return b
+}
+
+// Close останавливает таймер и дописывает накопленные документы
+func (b *bulkIndexer) Close() {
+	b.mu.Lock()
+	b.closing = true
+	b.mu.Unlock()
+	b.closeOnce.Do(func() { close(b.closed) })
+	<-b.stopped
+	b.flush(context.Background())
+}
+
+// Add ставит документ в очередь, итог записи придёт в канал.
+// После Close итог сразу с ErrIndexerClosed
+func (b *bulkIndexer) Add(ctx context.Context, doc articlesSearchRepository.BulkDocument) <-chan articlesSearchRepository.BulkItemResult {
+	done := make(chan articlesSearchRepository.BulkItemResult, 1)
+
+	b.mu.Lock()
+	if b.closing {
+		b.mu.Unlock()
+		done <- articlesSearchRepository.BulkItemResult{Err: ErrIndexerClosed}
+		return done
+	}
+	b.pending = append(b.pending, pendingDoc{doc, done})
+	full := len(b.pending) >= b.size
+	b.mu.Unlock()
+
+	if full {
+		// Пачка общая для всех источников - не рвём её по отмене одного запроса
+		b.flush(context.WithoutCancel(ctx))
+	}
+	return done
+}
+
+func (b *bulkIndexer) flush(ctx context.Context) {
+	b.flushMu.Lock()
+	defer b.flushMu.Unlock()
+
+	b.mu.Lock()
+	batch := b.pending
+	b.pending = nil
+	b.mu.Unlock()
+
+	if len(batch) == 0 {
+		return
+	}
+
+	docs := make([]articlesSearchRepository.BulkDocument, len(batch))
+	for i, p := range batch {
+		docs[i] = p.doc
+	}
+	metrics.ObserveSummaryMetric(customMetrics.MetricEsBulkSizeName, float64(len(docs)))
+
+	results, err := b.elastic.Bulk(ctx, docs)
+	if err != nil {
+		logger.ErrorContext(ctx, "Пачка _bulk не записана", zap.Int("size", len(docs)), zap.Error(err))
+		metrics.AddCounterVecMetric(customMetrics.MetricEsBulkItemsName, float64(len(docs)), "failed")
+		for _, p := range batch {
+			p.done <- articlesSearchRepository.BulkItemResult{Err: err}
+		}
+		return
+	}
+
+	// Итогов меньше, чем документов, - у оставшихся ошибка, а не вечное ожидание
+	if len(results) != len(batch) {
+		logger.ErrorContext(ctx, "Ответ _bulk не сходится с пачкой",
+			zap.Int("size", len(docs)), zap.Int("results", len(results)))
+	}
+	counts := map[string]int{}
+	for i, p := range batch {
+		res := articlesSearchRepository.BulkItemResult{
+			Err: fmt.Errorf("нет итога документа %d из %d в ответе _bulk", i+1, len(batch)),
+		}
+		if i < len(results) {
+			res = results[i]
+		}
+		switch {
+		case res.Err != nil:
+			counts["failed"]++
+			logger.ErrorContext(ctx, "Документ не записан в "+p.doc.Index,
+				zap.String("id", p.doc.ID), zap.Error(res.Err))
+		case res.Result == result.Created:
+			counts["created"]++
+		case res.Result == result.Updated:
+			counts["updated"]++
+		default:
+			counts["noop"]++
+		}
+		p.done <- res
+	}
+	for label, count := range counts {
+		metrics.AddCounterVecMetric(customMetrics.MetricEsBulkItemsName, float64(count), label)
+	}
+	logger.Info("Записали пачку _bulk", zap.Int("size", len(docs)), zap.Int("failed", counts["failed"]))
+}
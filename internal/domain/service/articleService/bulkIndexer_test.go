@@ -0,0 +1,174 @@
+package articleService
+
+import (
+	"context"
+	"errors"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/result"
+	"github.com/mskKote/prospero_backend/internal/adapters/db/elastic/v8/articlesSearchRepository"
+	"testing"
+	"time"
+)
+
+func doc(id string) articlesSearchRepository.BulkDocument {
+	return articlesSearchRepository.BulkDocument{Index: "articles", ID: id, Doc: map[string]string{"id": id}}
+}
+
+func wait(t *testing.T, done <-chan articlesSearchRepository.BulkItemResult) articlesSearchRepository.BulkItemResult {
+	t.Helper()
+	select {
+	case res := <-done:
+		return res
+	case <-time.After(time.Second):
+		t.Fatal("итог записи не пришёл")
+		return articlesSearchRepository.BulkItemResult{}
+	}
+}
+
+// Набралось bulk_size - пачка уходит сразу, итоги приходят каждому своему документу
+func TestBulkIndexerFlushBySize(t *testing.T) {
+	es := &fakeElastic{bulk: func(docs []articlesSearchRepository.BulkDocument) ([]articlesSearchRepository.BulkItemResult, error) {
+		return []articlesSearchRepository.BulkItemResult{
+			{Result: result.Created, Status: 201},
+			{Result: result.Updated, Status: 200},
+			{Status: 400, Err: errors.New("mapper_parsing_exception")},
+		}, nil
+	}}
+	b := newBulkIndexer(context.Background(), es, 3, time.Hour)
+
+	first := b.Add(context.Background(), doc("a"))
+	second := b.Add(context.Background(), doc("b"))
+	if len(es.sent()) != 0 {
+		t.Fatalf("пачка ушла до bulk_size: %v", es.sent())
+	}
+	third := b.Add(context.Background(), doc("c"))
+
+	batches := es.sent()
+	if len(batches) != 1 || len(batches[0]) != 3 {
+		t.Fatalf("пачки = %v, want одна из 3 документов", batches)
+	}
+	for i, id := range []string{"a", "b", "c"} {
+		if batches[0][i].ID != id {
+			t.Errorf("документ %d = %s, want %s", i, batches[0][i].ID, id)
+		}
+	}
+	if res := wait(t, first); res.Result != result.Created {
+		t.Errorf("a: %+v, want created", res)
+	}
+	if res := wait(t, second); res.Result != result.Updated {
+		t.Errorf("b: %+v, want updated", res)
+	}
+	if res := wait(t, third); res.Err == nil {
+		t.Errorf("c: %+v, want ошибку документа", res)
+	}
+}
+
+// Неполная пачка уходит по таймеру
+func TestBulkIndexerFlushByInterval(t *testing.T) {
+	es := &fakeElastic{}
+	b := newBulkIndexer(context.Background(), es, 100, 10*time.Millisecond)
+
+	if res := wait(t, b.Add(context.Background(), doc("a"))); res.Err != nil || res.Result != result.Created {
+		t.Errorf("итог = %+v, want created", res)
+	}
+	if batches := es.sent(); len(batches) != 1 || len(batches[0]) != 1 {
+		t.Errorf("пачки = %v, want одна из 1 документа", batches)
+	}
+}
+
+// Пачка не записана целиком - ошибка у каждого документа
+func TestBulkIndexerRequestFailed(t *testing.T) {
+	failure := errors.New("connection refused")
+	es := &fakeElastic{bulk: func([]articlesSearchRepository.BulkDocument) ([]articlesSearchRepository.BulkItemResult, error) {
+		return nil, failure
+	}}
+	b := newBulkIndexer(context.Background(), es, 2, time.Hour)
+
+	first := b.Add(context.Background(), doc("a"))
+	second := b.Add(context.Background(), doc("b"))
+	for _, done := range []<-chan articlesSearchRepository.BulkItemResult{first, second} {
+		if res := wait(t, done); !errors.Is(res.Err, failure) {
+			t.Errorf("итог = %+v, want %v", res, failure)
+		}
+	}
+}
+
+// Отмена запроса одного источника не рвёт общую пачку
+func TestBulkIndexerIgnoresCallerCancel(t *testing.T) {
+	es := &fakeElastic{}
+	b := newBulkIndexer(context.Background(), es, 1, time.Hour)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if res := wait(t, b.Add(ctx, doc("a"))); res.Err != nil {
+		t.Errorf("итог = %+v, want без ошибки", res)
+	}
+	if len(es.ctxErrs) != 1 || es.ctxErrs[0] != nil {
+		t.Errorf("ctx пачки = %v, want не отменён", es.ctxErrs)
+	}
+}
+
+// Close дописывает неполную пачку, повторный Close ничего не ломает
+func TestBulkIndexerClose(t *testing.T) {
+	es := &fakeElastic{}
+	b := newBulkIndexer(context.Background(), es, 100, time.Hour)
+
+	done := b.Add(context.Background(), doc("a"))
+	b.Close()
+	if res := wait(t, done); res.Err != nil || res.Result != result.Created {
+		t.Errorf("итог = %+v, want created", res)
+	}
+	b.Close()
+	if batches := es.sent(); len(batches) != 1 {
+		t.Errorf("пачки = %v, want одна", batches)
+	}
+}
+
+// Отмена ctx останавливает запись по таймеру
+func TestBulkIndexerStopsOnCancel(t *testing.T) {
+	es := &fakeElastic{}
+	ctx, cancel := context.WithCancel(context.Background())
+	b := newBulkIndexer(ctx, es, 100, time.Millisecond)
+	cancel()
+	select {
+	case <-b.stopped:
+	case <-time.After(time.Second):
+		t.Fatal("таймер не остановлен")
+	}
+
+	b.Add(context.Background(), doc("a"))
+	time.Sleep(10 * time.Millisecond)
+	if batches := es.sent(); len(batches) != 0 {
+		t.Errorf("после отмены ушли пачки %v", batches)
+	}
+}
+
+// После Close документ не теряется молча: итог сразу с ошибкой
+func TestBulkIndexerAddAfterClose(t *testing.T) {
+	es := &fakeElastic{}
+	b := newBulkIndexer(context.Background(), es, 1, time.Hour)
+	b.Close()
+
+	if res := wait(t, b.Add(context.Background(), doc("a"))); !errors.Is(res.Err, ErrIndexerClosed) {
+		t.Errorf("итог = %+v, want %v", res, ErrIndexerClosed)
+	}
+	if batches := es.sent(); len(batches) != 0 {
+		t.Errorf("после Close ушли пачки %v", batches)
+	}
+}
+
+// ES вернул итогов меньше, чем документов, - у оставшихся ошибка
+func TestBulkIndexerShortResults(t *testing.T) {
+	es := &fakeElastic{bulk: func([]articlesSearchRepository.BulkDocument) ([]articlesSearchRepository.BulkItemResult, error) {
+		return []articlesSearchRepository.BulkItemResult{{Result: result.Created, Status: 201}}, nil
+	}}
+	b := newBulkIndexer(context.Background(), es, 2, time.Hour)
+
+	first := b.Add(context.Background(), doc("a"))
+	second := b.Add(context.Background(), doc("b"))
+	if res := wait(t, first); res.Err != nil || res.Result != result.Created {
+		t.Errorf("a: %+v, want created", res)
+	}
+	if res := wait(t, second); res.Err == nil {
+		t.Errorf("b: %+v, want ошибку", res)
+	}
+}
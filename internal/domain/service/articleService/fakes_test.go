@@ -0,0 +1,169 @@
+package articleService
+
+import (
+	"context"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/result"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/mskKote/prospero_backend/internal/adapters/db/elastic/v8/articlesSearchRepository"
+	"github.com/mskKote/prospero_backend/internal/adapters/db/postgres/articlesRepository"
+	"github.com/mskKote/prospero_backend/internal/adapters/db/postgres/sourcesRepository"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/article"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/publisher"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/retention"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/source"
+	"github.com/mskKote/prospero_backend/pkg/client/rss"
+	"github.com/mskKote/prospero_backend/pkg/lib"
+	"sync"
+	"time"
+)
+
+// keepAll - политика хранения без сроков
+var keepAll = &retention.Policy{Action: retention.ActionDelete}
+
+// testSource - источник с издателем, как его отдаёт FindAllWithPublishers
+func testSource(url string) *source.RSS {
+	return &source.RSS{
+		RssID:  lib.StringToUUID("7b0e3c8e-4d5a-4f7e-9c39-2b1f0d6a1e01"),
+		RssURL: url,
+		Publisher: publisher.PgDBO{
+			PublisherID: lib.StringToUUID("5d3c1a2b-8e9f-4a6b-b7c8-d9e0f1a2b3c4"),
+			Name:        "Example",
+		},
+	}
+}
+
+// fakeSources запоминает, что сервис записал об источниках.
+// Остальные методы репозитория тестам не нужны и паникуют
+type fakeSources struct {
+	sourcesRepository.IRepository
+	list []*source.RSS
+
+	mu         sync.Mutex
+	health     []source.Health
+	watermarks []source.Watermark
+	urls       []string
+}
+
+func (f *fakeSources) FindAllWithPublishers(_ context.Context, offset, limit int) ([]*source.RSS, error) {
+	if offset >= len(f.list) {
+		return nil, nil
+	}
+	return f.list[offset:min(offset+limit, len(f.list))], nil
+}
+
+func (f *fakeSources) FindByIDWithPublisher(_ context.Context, id string) (*source.RSS, error) {
+	for _, src := range f.list {
+		if lib.UuidToString(src.RssID) == id {
+			return src, nil
+		}
+	}
+	return nil, sourcesRepository.ErrNotFound
+}
+
+func (f *fakeSources) FindByURL(_ context.Context, url string) (*source.RSS, error) {
+	for _, src := range f.list {
+		if src.RssURL == url {
+			return src, nil
+		}
+	}
+	return nil, sourcesRepository.ErrNotFound
+}
+
+func (f *fakeSources) UpdateHealth(_ context.Context, _ pgtype.UUID, h source.Health) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.health = append(f.health, h)
+	return nil
+}
+
+func (f *fakeSources) UpdateWatermark(_ context.Context, _ pgtype.UUID, w source.Watermark) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.watermarks = append(f.watermarks, w)
+	return nil
+}
+
+func (f *fakeSources) UpdateURL(_ context.Context, _ pgtype.UUID, url string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.urls = append(f.urls, url)
+	return nil
+}
+
+// fakeArticles запоминает статьи, сохранённые в postgres,
+// и как postgres возвращает прежние даты публикации уже сохранённых
+type fakeArticles struct {
+	articlesRepository.IRepository
+	err error
+
+	mu    sync.Mutex
+	saved map[string][]*article.EsArticleDBO
+	dates map[string]*time.Time
+}
+
+func (f *fakeArticles) SaveAll(_ context.Context, rssID, _ string, articles []*article.EsArticleDBO) (map[string]*time.Time, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.saved == nil {
+		f.saved, f.dates = map[string][]*article.EsArticleDBO{}, map[string]*time.Time{}
+	}
+	f.saved[rssID] = append(f.saved[rssID], articles...)
+	published := map[string]*time.Time{}
+	for _, a := range articles {
+		if prev, ok := f.dates[a.ID]; ok {
+			published[a.ID] = prev
+		}
+		f.dates[a.ID] = a.DatePublished
+	}
+	return published, nil
+}
+
+// fakeFeeds на любой адрес отвечает одним и тем же, с fetch - тем, что он вернёт
+type fakeFeeds struct {
+	rss.Client
+	res   *rss.Response
+	err   error
+	fetch func(ctx context.Context, url string) (*rss.Response, error)
+}
+
+func (f *fakeFeeds) Fetch(ctx context.Context, url string, _ rss.Validators) (*rss.Response, error) {
+	if f.fetch != nil {
+		return f.fetch(ctx, url)
+	}
+	return f.res, f.err
+}
+
+// fakeElastic запоминает пачки _bulk. Без bulk все документы создаются
+type fakeElastic struct {
+	articlesSearchRepository.IRepository
+	bulk func(docs []articlesSearchRepository.BulkDocument) ([]articlesSearchRepository.BulkItemResult, error)
+
+	mu      sync.Mutex
+	batches [][]articlesSearchRepository.BulkDocument
+	// ctx.Err() каждой пачки
+	ctxErrs []error
+}
+
+func (f *fakeElastic) Bulk(ctx context.Context, docs []articlesSearchRepository.BulkDocument) ([]articlesSearchRepository.BulkItemResult, error) {
+	f.mu.Lock()
+	f.batches = append(f.batches, docs)
+	f.ctxErrs = append(f.ctxErrs, ctx.Err())
+	f.mu.Unlock()
+	if f.bulk != nil {
+		return f.bulk(docs)
+	}
+	results := make([]articlesSearchRepository.BulkItemResult, len(docs))
+	for i := range results {
+		results[i] = articlesSearchRepository.BulkItemResult{Result: result.Created, Status: 201}
+	}
+	return results, nil
+}
+
+func (f *fakeElastic) sent() [][]articlesSearchRepository.BulkDocument {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.batches
+}
@@ -0,0 +1,221 @@
+package articleService
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/mmcdole/gofeed"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/harvest"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/source"
+	"github.com/mskKote/prospero_backend/pkg/client/rss"
+	"github.com/mskKote/prospero_backend/pkg/lib"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func withQuarantine(t *testing.T, after int, backoff, maxBackoff time.Duration) {
+	t.Helper()
+	a, b, m := cfg.HarvestQuarantineAfter, cfg.HarvestQuarantineBackoff, cfg.HarvestQuarantineMaxBackoff
+	t.Cleanup(func() {
+		cfg.HarvestQuarantineAfter, cfg.HarvestQuarantineBackoff, cfg.HarvestQuarantineMaxBackoff = a, b, m
+	})
+	cfg.HarvestQuarantineAfter, cfg.HarvestQuarantineBackoff, cfg.HarvestQuarantineMaxBackoff = after, backoff, maxBackoff
+}
+
+// Ошибка ленты считается подряд идущей и на пороге уводит источник в карантин
+func TestHarvestSourceFailure(t *testing.T) {
+	withQuarantine(t, 5, 30*time.Minute, 24*time.Hour)
+	sources := &fakeSources{}
+	s := &service{retention: keepAll, sources: sources, articles: &fakeArticles{}, feeds: &fakeFeeds{
+		res: &rss.Response{StatusCode: http.StatusInternalServerError},
+		err: gofeed.HTTPError{StatusCode: http.StatusInternalServerError, Status: "500 Internal Server Error"},
+	}}
+
+	tests := []struct {
+		name       string
+		failures   int32
+		quarantine bool
+	}{
+		{"первая ошибка", 0, false},
+		{"до порога", 3, false},
+		{"порог", 4, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sources.health = nil
+			start := time.Now()
+			src := testSource("https://example.com/rss")
+			src.ConsecutiveFailures = tt.failures
+			s.harvestSource(context.Background(), src, false)
+
+			if len(sources.health) != 1 {
+				t.Fatalf("здоровье записано %d раз, want 1", len(sources.health))
+			}
+			h := sources.health[0]
+			if h.Status != source.StatusError || h.HTTPStatus != http.StatusInternalServerError || h.Error == "" {
+				t.Errorf("здоровье = %+v, want error 500 с текстом ошибки", h)
+			}
+			if h.ConsecutiveFailures != int(tt.failures)+1 {
+				t.Errorf("ConsecutiveFailures = %d, want %d", h.ConsecutiveFailures, tt.failures+1)
+			}
+			if (h.QuarantinedUntil != nil) != tt.quarantine {
+				t.Fatalf("QuarantinedUntil = %v, want карантин %t", h.QuarantinedUntil, tt.quarantine)
+			}
+			if tt.quarantine && h.QuarantinedUntil.Before(start.Add(30*time.Minute)) {
+				t.Errorf("QuarantinedUntil = %s, want не раньше чем через 30m", h.QuarantinedUntil)
+			}
+			// Сломанная лента не сдвигает watermark
+			if len(sources.watermarks) != 0 {
+				t.Errorf("watermark сдвинут после ошибки: %+v", sources.watermarks)
+			}
+		})
+	}
+}
+
+// Успешный запрос сбрасывает счётчик ошибок и карантин
+func TestHarvestSourceRecovered(t *testing.T) {
+	sources := &fakeSources{}
+	s := &service{retention: keepAll, sources: sources, articles: &fakeArticles{}, feeds: &fakeFeeds{res: &rss.Response{
+		Feed:       &gofeed.Feed{},
+		StatusCode: http.StatusOK,
+		Validators: rss.Validators{ETag: `"v2"`},
+	}}}
+	src := testSource("https://example.com/rss")
+	src.ConsecutiveFailures = 7
+	src.QuarantinedUntil = pgtype.Timestamptz{Time: time.Now().Add(-time.Minute), Valid: true}
+
+	s.harvestSource(context.Background(), src, false)
+
+	if len(sources.health) != 1 {
+		t.Fatalf("здоровье записано %d раз, want 1", len(sources.health))
+	}
+	h := sources.health[0]
+	if h.Status != source.StatusOK || h.ConsecutiveFailures != 0 || h.QuarantinedUntil != nil || h.LastSuccessAt == nil {
+		t.Errorf("здоровье = %+v, want ok без ошибок и карантина", h)
+	}
+	if len(sources.watermarks) != 1 || sources.watermarks[0].ETag != `"v2"` {
+		t.Errorf("watermarks = %+v, want один с новым ETag", sources.watermarks)
+	}
+}
+
+// 304 - успех без новых статей: статус not_modified, ошибки и карантин сбрасываются,
+// watermark сдвигается с прежними валидаторами
+func TestHarvestSourceNotModified(t *testing.T) {
+	sources := &fakeSources{}
+	s := &service{retention: keepAll, sources: sources, articles: &fakeArticles{}, feeds: &fakeFeeds{
+		res: &rss.Response{StatusCode: http.StatusNotModified},
+		err: rss.ErrNotModified,
+	}}
+	src := testSource("https://example.com/rss")
+	src.ConsecutiveFailures = 2
+	src.ETag = pgtype.Text{String: `"v1"`, Valid: true}
+
+	r := s.harvestSource(context.Background(), src, false)
+
+	if r.Status != source.StatusNotModified || r.Error != "" {
+		t.Errorf("итог = %+v, want not_modified без ошибки", r)
+	}
+	if len(sources.health) != 1 {
+		t.Fatalf("здоровье записано %d раз, want 1", len(sources.health))
+	}
+	h := sources.health[0]
+	if h.Status != source.StatusNotModified || h.HTTPStatus != http.StatusNotModified ||
+		h.ConsecutiveFailures != 0 || h.Error != "" || h.LastSuccessAt == nil {
+		t.Errorf("здоровье = %+v, want not_modified 304 без ошибок", h)
+	}
+	if len(sources.watermarks) != 1 || sources.watermarks[0].ETag != `"v1"` {
+		t.Errorf("watermarks = %+v, want один с прежним ETag", sources.watermarks)
+	}
+}
+
+// Лента переехала навсегда - новый адрес сохраняется
+func TestHarvestSourceMoved(t *testing.T) {
+	sources := &fakeSources{}
+	s := &service{retention: keepAll, sources: sources, articles: &fakeArticles{}, feeds: &fakeFeeds{res: &rss.Response{
+		Feed:         &gofeed.Feed{},
+		StatusCode:   http.StatusOK,
+		PermanentURL: "https://example.com/feed.xml",
+	}}}
+
+	s.harvestSource(context.Background(), testSource("https://example.com/rss"), false)
+
+	if len(sources.urls) != 1 || sources.urls[0] != "https://example.com/feed.xml" {
+		t.Errorf("UpdateURL = %v, want [https://example.com/feed.xml]", sources.urls)
+	}
+}
+
+// Новый адрес уже читает другой источник: адрес не меняем, ленту не парсим,
+// источник отмечен дубликатом без счёта ошибок
+func TestHarvestSourceMovedToDuplicate(t *testing.T) {
+	other := testSource("https://example.com/feed.xml")
+	other.RssID = lib.StringToUUID("00000000-0000-0000-0000-000000000002")
+	sources := &fakeSources{list: []*source.RSS{other}}
+	es := &fakeElastic{}
+	s := &service{retention: keepAll, sources: sources, articles: &fakeArticles{}, elastic: es,
+		indexer: newBulkIndexer(context.Background(), es, 1, time.Hour), feeds: &fakeFeeds{res: &rss.Response{
+			Feed:         testFeed(time.Now()),
+			StatusCode:   http.StatusOK,
+			PermanentURL: other.RssURL,
+		}}}
+	src := testSource("https://example.com/rss")
+	src.ConsecutiveFailures = 1
+
+	r := s.harvestSource(context.Background(), src, false)
+
+	if r.Status != source.StatusDuplicate || !strings.Contains(r.Error, lib.UuidToString(other.RssID)) {
+		t.Errorf("итог = %+v, want duplicate с ID другого источника", r)
+	}
+	if len(sources.urls) != 0 {
+		t.Errorf("UpdateURL = %v, want без изменений", sources.urls)
+	}
+	if len(sources.health) != 1 || sources.health[0].Status != source.StatusDuplicate ||
+		sources.health[0].ConsecutiveFailures != 1 || sources.health[0].QuarantinedUntil != nil {
+		t.Errorf("здоровье = %+v, want duplicate с прежним счётчиком", sources.health)
+	}
+	if len(sources.watermarks) != 0 || len(es.sent()) != 0 {
+		t.Errorf("лента дубликата прочитана: watermarks %+v, пачки %d", sources.watermarks, len(es.sent()))
+	}
+}
+
+func TestIsQuarantined(t *testing.T) {
+	now := time.Date(2024, 5, 17, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		until pgtype.Timestamptz
+		want  bool
+	}{
+		{"не был в карантине", pgtype.Timestamptz{}, false},
+		{"карантин идёт", pgtype.Timestamptz{Time: now.Add(time.Minute), Valid: true}, true},
+		{"карантин истёк", pgtype.Timestamptz{Time: now.Add(-time.Minute), Valid: true}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := &source.RSS{QuarantinedUntil: tt.until}
+			if got := src.IsQuarantined(now); got != tt.want {
+				t.Errorf("IsQuarantined() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+// Отмена задачи посреди запроса - не ошибка ленты: здоровье и watermark не меняются
+func TestHarvestSourceCancelled(t *testing.T) {
+	sources := &fakeSources{}
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &service{retention: keepAll, sources: sources, articles: &fakeArticles{}, feeds: &fakeFeeds{
+		fetch: func(fetchCtx context.Context, _ string) (*rss.Response, error) {
+			cancel()
+			<-fetchCtx.Done()
+			return nil, fetchCtx.Err()
+		},
+	}}
+
+	r := s.harvestSource(ctx, testSource("https://example.com/rss"), false)
+	if r.Status != harvest.SourceCancelled {
+		t.Errorf("статус %s, want %s", r.Status, harvest.SourceCancelled)
+	}
+	if len(sources.health) != 0 || len(sources.watermarks) != 0 {
+		t.Errorf("записаны здоровье %+v и watermark %+v", sources.health, sources.watermarks)
+	}
+}
@@ -0,0 +1,153 @@
+package articleService
+
+import (
+	"context"
+	"errors"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/result"
+	"github.com/mmcdole/gofeed"
+	"github.com/mskKote/prospero_backend/internal/adapters/db/elastic/v8/articlesSearchRepository"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/article"
+	"testing"
+	"time"
+)
+
+func testFeed(now time.Time) *gofeed.Feed {
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+	return &gofeed.Feed{Language: "ru-RU", Items: []*gofeed.Item{
+		{GUID: "new", Title: "Новая", Link: "https://example.com/new", PublishedParsed: at(-time.Minute),
+			Categories: []string{"Политика"}},
+		{GUID: "changed", Title: "Исправленная", Link: "https://example.com/changed", PublishedParsed: at(-2 * time.Minute)},
+		{GUID: "same", Title: "Та же", Link: "https://example.com/same", PublishedParsed: at(-3 * time.Minute)},
+		{GUID: "no-date", Title: "Без даты", Link: "https://example.com/no-date"},
+		{Title: "Без GUID и ссылки", PublishedParsed: at(-time.Minute)},
+	}}
+}
+
+// ES отвечает по заголовку статьи: новая создана, исправленная обновлена, прочие без изменений
+func resultsByTitle(docs []articlesSearchRepository.BulkDocument) ([]articlesSearchRepository.BulkItemResult, error) {
+	results := make([]articlesSearchRepository.BulkItemResult, len(docs))
+	for i, d := range docs {
+		a, ok := d.Doc.(*article.EsArticleDBO)
+		switch {
+		case !ok:
+			results[i] = articlesSearchRepository.BulkItemResult{Result: result.Created}
+		case a.Name == "Новая":
+			results[i] = articlesSearchRepository.BulkItemResult{Result: result.Created}
+		case a.Name == "Исправленная":
+			results[i] = articlesSearchRepository.BulkItemResult{Result: result.Updated}
+		default:
+			results[i] = articlesSearchRepository.BulkItemResult{Result: result.Noop}
+		}
+	}
+	return results, nil
+}
+
+func TestIndexFeed(t *testing.T) {
+	now := time.Now()
+	es := &fakeElastic{bulk: resultsByTitle}
+	pg := &fakeArticles{}
+	s := &service{retention: keepAll, articles: pg, elastic: es, indexer: newBulkIndexer(context.Background(), es, 1, time.Hour)}
+
+	stats := s.indexFeed(context.Background(), "rss", testSource("").Publisher.ToDTO(), testFeed(now), nil, "")
+
+	want := article.IndexStats{Created: 1, Updated: 1, Unchanged: 1, Skipped: 2}
+	if stats != want {
+		t.Errorf("indexFeed() = %+v, want %+v", stats, want)
+	}
+	if len(pg.saved["rss"]) != 3 {
+		t.Errorf("в postgres сохранено %d статей, want 3", len(pg.saved["rss"]))
+	}
+
+	var articles, categories int
+	for _, batch := range es.sent() {
+		for _, d := range batch {
+			if a, ok := d.Doc.(*article.EsArticleDBO); ok {
+				articles++
+				if !d.Upsert || d.ID == "" || d.Index != articlesSearchRepository.ArticleIndexFor(a.DatePublished) {
+					t.Errorf("статья %+v, want upsert по стабильному ID в партицию по дате", d)
+				}
+			} else if d.Index == articlesSearchRepository.CategoryWriteIndex {
+				categories++
+			}
+		}
+	}
+	if articles != 3 || categories != 1 {
+		t.Errorf("в ES ушло статей %d и категорий %d, want 3 и 1", articles, categories)
+	}
+}
+
+// Дата публикации ушла в другую партицию: статья пишется в новую, копия из старой удаляется
+func TestIndexFeedMovedPartition(t *testing.T) {
+	now := time.Now()
+	es := &fakeElastic{bulk: func(docs []articlesSearchRepository.BulkDocument) ([]articlesSearchRepository.BulkItemResult, error) {
+		results := make([]articlesSearchRepository.BulkItemResult, len(docs))
+		for i := range results {
+			results[i] = articlesSearchRepository.BulkItemResult{Result: result.Created}
+		}
+		return results, nil
+	}}
+	pg := &fakeArticles{}
+	s := &service{retention: keepAll, articles: pg, elastic: es, indexer: newBulkIndexer(context.Background(), es, 1, time.Hour)}
+	p := testSource("").Publisher.ToDTO()
+	s.indexFeed(context.Background(), "rss", p, testFeed(now), nil, "")
+
+	feed := testFeed(now)
+	old := *feed.Items[1].PublishedParsed
+	moved := old.AddDate(-1, 0, 0)
+	feed.Items[1].PublishedParsed = &moved
+	sentBefore := len(es.sent())
+	stats := s.indexFeed(context.Background(), "rss", p, feed, nil, "")
+
+	// В новой партиции статья создана, но для ленты это исправление
+	want := article.IndexStats{Created: 2, Updated: 1, Skipped: 2}
+	if stats != want {
+		t.Errorf("indexFeed() = %+v, want %+v", stats, want)
+	}
+	var deleted []articlesSearchRepository.BulkDocument
+	for _, batch := range es.sent()[sentBefore:] {
+		for _, d := range batch {
+			if d.Delete {
+				deleted = append(deleted, d)
+			}
+		}
+	}
+	if len(deleted) != 1 || deleted[0].Index != articlesSearchRepository.ArticleIndexFor(&old) ||
+		deleted[0].Index == articlesSearchRepository.ArticleIndexFor(&moved) {
+		t.Errorf("удалены %+v, want копия из партиции %s", deleted, articlesSearchRepository.ArticleIndexFor(&old))
+	}
+}
+
+// Не сохранили в postgres - в ES ничего не пишем, все статьи с ошибкой
+func TestIndexFeedPostgresFailed(t *testing.T) {
+	es := &fakeElastic{}
+	s := &service{
+		retention: keepAll,
+		articles:  &fakeArticles{err: errors.New("deadlock detected")},
+		elastic:   es,
+		indexer:   newBulkIndexer(context.Background(), es, 1, time.Hour),
+	}
+
+	stats := s.indexFeed(context.Background(), "rss", testSource("").Publisher.ToDTO(), testFeed(time.Now()), nil, "")
+	if stats.Failed != 3 || stats.Skipped != 2 || stats.Indexed() != 0 {
+		t.Errorf("indexFeed() = %+v, want 3 ошибки и 2 пропуска", stats)
+	}
+	if len(es.sent()) != 0 {
+		t.Errorf("в ES ушло %d пачек, want 0", len(es.sent()))
+	}
+}
+
+// Ошибка записи одной статьи не теряется в итогах
+func TestIndexFeedFailed(t *testing.T) {
+	es := &fakeElastic{bulk: func(docs []articlesSearchRepository.BulkDocument) ([]articlesSearchRepository.BulkItemResult, error) {
+		return nil, errors.New("connection refused")
+	}}
+	s := &service{retention: keepAll, articles: &fakeArticles{}, elastic: es, indexer: newBulkIndexer(context.Background(), es, 1, time.Hour)}
+
+	stats := s.indexFeed(context.Background(), "rss", testSource("").Publisher.ToDTO(), testFeed(time.Now()), nil, "")
+	if stats.Failed != 3 || stats.Indexed() != 0 {
+		t.Errorf("indexFeed() = %+v, want 3 ошибки", stats)
+	}
+}
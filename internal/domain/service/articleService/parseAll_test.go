@@ -0,0 +1,129 @@
+package articleService
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/mmcdole/gofeed"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/harvest"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/source"
+	"github.com/mskKote/prospero_backend/pkg/client/rss"
+	"github.com/mskKote/prospero_backend/pkg/lib"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func withConcurrency(t *testing.T, n int) {
+	t.Helper()
+	concurrency := cfg.HarvestConcurrency
+	t.Cleanup(func() { cfg.HarvestConcurrency = concurrency })
+	cfg.HarvestConcurrency = n
+}
+
+// Источников больше страницы чтения, одновременно парсится не больше harvest_concurrency
+func TestParseAllBoundedPool(t *testing.T) {
+	withConcurrency(t, 3)
+	sources := &fakeSources{}
+	for i := 0; i < 120; i++ {
+		src := testSource(fmt.Sprintf("https://feeds%d.example.com/rss", i))
+		src.RssID = lib.StringToUUID(fmt.Sprintf("00000000-0000-0000-0000-%012d", i))
+		sources.list = append(sources.list, src)
+	}
+	// Карантин ещё идёт - ленту не запрашиваем
+	sources.list[7].QuarantinedUntil = pgtype.Timestamptz{Time: time.Now().Add(time.Hour), Valid: true}
+
+	var running, peak int32
+	var mu sync.Mutex
+	fetched := map[string]int{}
+	s := &service{retention: keepAll, busy: map[string]bool{}, sources: sources, articles: &fakeArticles{}, feeds: &fakeFeeds{fetch: func(ctx context.Context, url string) (*rss.Response, error) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		mu.Lock()
+		fetched[url]++
+		mu.Unlock()
+		time.Sleep(time.Millisecond)
+		return &rss.Response{Feed: &gofeed.Feed{}, StatusCode: http.StatusOK}, nil
+	}}}
+
+	if _, err := s.ParseAllOnce(context.Background(), false); err != nil {
+		t.Fatal(err)
+	}
+	if peak > 3 {
+		t.Errorf("одновременно парсилось %d источников, want не больше 3", peak)
+	}
+	if len(fetched) != 119 {
+		t.Errorf("запрошено %d лент, want 119", len(fetched))
+	}
+	for url, n := range fetched {
+		if n != 1 {
+			t.Errorf("%s запрошена %d раз", url, n)
+		}
+	}
+	if _, ok := fetched[sources.list[7].RssURL]; ok {
+		t.Error("источник в карантине запрошен")
+	}
+}
+
+// Источник, который парсят по запросу, общий парсинг пропускает, не запрашивая ленту
+func TestParseAllSkipsBusy(t *testing.T) {
+	busy, free := testSource("https://busy.example.com/rss"), testSource("https://free.example.com/rss")
+	free.RssID = lib.StringToUUID("00000000-0000-0000-0000-000000000002")
+	var mu sync.Mutex
+	fetched := map[string]bool{}
+	s := &service{retention: keepAll, busy: map[string]bool{}, sources: &fakeSources{list: []*source.RSS{busy, free}},
+		articles: &fakeArticles{}, feeds: &fakeFeeds{fetch: func(_ context.Context, url string) (*rss.Response, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			fetched[url] = true
+			return &rss.Response{Feed: &gofeed.Feed{}, StatusCode: http.StatusOK}, nil
+		}}}
+	s.lockSource(lib.UuidToString(busy.RssID))
+
+	statuses := map[string]string{}
+	if _, err := s.ParseAll(context.Background(), false, func(r harvest.SourceResult) {
+		statuses[r.RssURL] = r.Status
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if statuses[busy.RssURL] != harvest.SourceBusy || statuses[free.RssURL] != source.StatusOK {
+		t.Errorf("статусы %v, want busy и ok", statuses)
+	}
+	if fetched[busy.RssURL] || !fetched[free.RssURL] {
+		t.Errorf("запрошены ленты %v, want только свободная", fetched)
+	}
+	// Свободный источник после парсинга отпущен
+	if !s.lockSource(lib.UuidToString(free.RssID)) {
+		t.Error("источник остался занят после парсинга")
+	}
+}
+
+// Хост просит подождать - это не ошибка ленты, счётчик и карантин прежние
+func TestHarvestSourceRateLimited(t *testing.T) {
+	until := time.Now().Add(time.Hour)
+	sources := &fakeSources{}
+	s := &service{retention: keepAll, sources: sources, articles: &fakeArticles{}, feeds: &fakeFeeds{
+		res: &rss.Response{StatusCode: http.StatusTooManyRequests},
+		err: &rss.RateLimitedError{Host: "example.com", Until: until},
+	}}
+	src := testSource("https://example.com/rss")
+	src.ConsecutiveFailures = 2
+
+	s.harvestSource(context.Background(), src, false)
+
+	if len(sources.health) != 1 {
+		t.Fatalf("здоровье записано %d раз, want 1", len(sources.health))
+	}
+	h := sources.health[0]
+	if h.Status != source.StatusRateLimited || h.ConsecutiveFailures != 2 || h.QuarantinedUntil != nil {
+		t.Errorf("здоровье = %+v, want rate_limited с прежними 2 ошибками", h)
+	}
+}
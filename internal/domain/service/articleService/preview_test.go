@@ -0,0 +1,149 @@
+package articleService
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/mmcdole/gofeed"
+	"github.com/mskKote/prospero_backend/internal/adapters/db/postgres/sourcesRepository"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/article"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/retention"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/source"
+	"github.com/mskKote/prospero_backend/pkg/client/rss"
+	"github.com/mskKote/prospero_backend/pkg/lib"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSkipReason(t *testing.T) {
+	now := time.Date(2024, 5, 17, 12, 0, 0, 0, time.UTC)
+	withID := &article.EsArticleDBO{ID: "id"}
+	tests := []struct {
+		name     string
+		item     *gofeed.Item
+		doc      *article.EsArticleDBO
+		from     *time.Time
+		lastGUID string
+		want     string
+	}{
+		{"без даты", &gofeed.Item{GUID: "a"}, withID, nil, "", article.SkipNoDate},
+		{"без идентификатора", &gofeed.Item{PublishedParsed: &now}, &article.EsArticleDBO{}, nil, "", article.SkipNoID},
+		{"раньше watermark", &gofeed.Item{GUID: "a", PublishedParsed: &now}, withID, lib.PointerFrom(now.Add(time.Minute)), "", article.SkipBeforeWatermark},
+		// Та же секунда, что у watermark: отсекаем только уже сохранённую новость
+		{"watermark, та же новость", &gofeed.Item{GUID: "a", PublishedParsed: &now}, withID, &now, "a", article.SkipBeforeWatermark},
+		{"watermark, соседняя новость", &gofeed.Item{GUID: "b", PublishedParsed: &now}, withID, &now, "a", ""},
+		{"после watermark", &gofeed.Item{GUID: "a", PublishedParsed: lib.PointerFrom(now.Add(time.Minute))}, withID, &now, "a", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (&service{retention: keepAll}).skipReason(tt.item, tt.doc, tt.from, tt.lastGUID); got != tt.want {
+				t.Errorf("skipReason() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// Статьи старше срока хранения не сохраняем: очистка их уже удалила бы
+func TestSkipExpired(t *testing.T) {
+	s := &service{retention: &retention.Policy{
+		Action:      retention.ActionDelete,
+		DefaultDays: 30,
+		Rules:       []retention.Rule{{Country: "USA", Days: 0}},
+	}}
+	old := time.Now().AddDate(0, 0, -31)
+	item := &gofeed.Item{GUID: "a", PublishedParsed: &old}
+
+	if got := s.skipReason(item, &article.EsArticleDBO{ID: "id"}, nil, ""); got != article.SkipExpired {
+		t.Errorf("skipReason() = %q, want %q", got, article.SkipExpired)
+	}
+	usa := &article.EsArticleDBO{ID: "id", Address: article.AddressES{Country: "USA"}}
+	if got := s.skipReason(item, usa, nil, ""); got != "" {
+		t.Errorf("статья без срока хранения: skipReason() = %q, want сохранить", got)
+	}
+}
+
+// Предпросмотр показывает судьбу каждой новости и ничего не пишет
+func TestPreview(t *testing.T) {
+	es := &fakeElastic{}
+	s := &service{retention: keepAll, elastic: es, feeds: &fakeFeeds{res: &rss.Response{
+		Feed:         testFeed(time.Now()),
+		StatusCode:   http.StatusOK,
+		PermanentURL: "https://example.com/feed.xml",
+	}}}
+
+	preview, err := s.Preview(context.Background(), "https://example.com/rss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if preview.RssURL != "https://example.com/feed.xml" || preview.Items != 5 || preview.Language != "ru-RU" {
+		t.Errorf("preview = %+v, want 5 новостей по новому адресу", preview)
+	}
+	want := []string{"", "", "", article.SkipNoDate, article.SkipNoID}
+	for i, a := range preview.Articles {
+		if a.SkipReason != want[i] {
+			t.Errorf("новость %q: SkipReason = %q, want %q", a.Name, a.SkipReason, want[i])
+		}
+		if want[i] == "" && a.ID == "" {
+			t.Errorf("новость %q без ID", a.Name)
+		}
+	}
+	if len(es.sent()) != 0 {
+		t.Errorf("предпросмотр записал в ES %d пачек", len(es.sent()))
+	}
+}
+
+func TestPreviewFetchFailed(t *testing.T) {
+	s := &service{retention: keepAll, feeds: &fakeFeeds{err: gofeed.HTTPError{StatusCode: http.StatusNotFound}}}
+
+	var httpErr gofeed.HTTPError
+	if _, err := s.Preview(context.Background(), "https://example.com/rss"); !errors.As(err, &httpErr) {
+		t.Errorf("Preview() error = %v, want HTTPError", err)
+	}
+}
+
+// Админ парсит источник вручную - карантин не мешает
+func TestHarvestOne(t *testing.T) {
+	src := testSource("https://example.com/rss")
+	src.ConsecutiveFailures = 5
+	src.QuarantinedUntil = pgtype.Timestamptz{Time: time.Now().Add(time.Hour), Valid: true}
+	sources := &fakeSources{list: []*source.RSS{src}}
+	s := &service{retention: keepAll, busy: map[string]bool{}, sources: sources, articles: &fakeArticles{}, feeds: &fakeFeeds{res: &rss.Response{Feed: &gofeed.Feed{}, StatusCode: http.StatusOK}}}
+
+	r, err := s.HarvestOne(context.Background(), lib.UuidToString(src.RssID), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Status != source.StatusOK {
+		t.Errorf("Status = %s, want ok", r.Status)
+	}
+	if len(sources.health) != 1 || sources.health[0].QuarantinedUntil != nil {
+		t.Errorf("здоровье = %+v, want карантин снят", sources.health)
+	}
+
+	for _, id := range []string{"не uuid", "00000000-0000-0000-0000-000000000001"} {
+		if _, err := s.HarvestOne(context.Background(), id, false); !errors.Is(err, sourcesRepository.ErrNotFound) {
+			t.Errorf("HarvestOne(%s) error = %v, want ErrNotFound", id, err)
+		}
+	}
+}
+
+// Источник уже парсится - ручной запуск отказывает, а не парсит его второй раз
+func TestHarvestOneBusy(t *testing.T) {
+	src := testSource("https://example.com/rss")
+	feeds := &fakeFeeds{res: &rss.Response{Feed: &gofeed.Feed{}, StatusCode: http.StatusOK}}
+	s := &service{retention: keepAll, busy: map[string]bool{}, sources: &fakeSources{list: []*source.RSS{src}}, articles: &fakeArticles{}, feeds: feeds}
+	id := lib.UuidToString(src.RssID)
+
+	s.lockSource(id)
+	if _, err := s.HarvestOne(context.Background(), id, false); !errors.Is(err, ErrSourceBusy) {
+		t.Fatalf("HarvestOne() error = %v, want ErrSourceBusy", err)
+	}
+	s.unlockSource(id)
+	if _, err := s.HarvestOne(context.Background(), id, false); err != nil {
+		t.Fatalf("HarvestOne() после освобождения error = %v", err)
+	}
+	if !s.lockSource(id) {
+		t.Error("источник остался занят после HarvestOne")
+	}
+}
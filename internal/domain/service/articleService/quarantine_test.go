@@ -0,0 +1,44 @@
+package articleService
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuarantineUntil(t *testing.T) {
+	now := time.Date(2024, 5, 17, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		failures int
+		// 0 - без карантина
+		want time.Duration
+	}{
+		{"меньше порога", 2, 0},
+		{"порог", 3, 30 * time.Minute},
+		{"удвоение", 4, time.Hour},
+		{"ещё удвоение", 5, 2 * time.Hour},
+		{"упёрлись в максимум", 6, 4 * time.Hour},
+		{"дальше максимума не растёт", 20, 4 * time.Hour},
+		// Удвоение не переполняет Duration
+		{"тысяча ошибок", 1000, 4 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := quarantineUntil(tt.failures, now, 3, 30*time.Minute, 4*time.Hour)
+			switch {
+			case tt.want == 0 && got != nil:
+				t.Errorf("quarantineUntil(%d) = %s, want без карантина", tt.failures, got)
+			case tt.want != 0 && got == nil:
+				t.Errorf("quarantineUntil(%d) = nil, want +%s", tt.failures, tt.want)
+			case tt.want != 0 && !got.Equal(now.Add(tt.want)):
+				t.Errorf("quarantineUntil(%d) = +%s, want +%s", tt.failures, got.Sub(now), tt.want)
+			}
+		})
+	}
+}
+
+func TestQuarantineDisabled(t *testing.T) {
+	if got := quarantineUntil(100, time.Now(), 0, 30*time.Minute, 4*time.Hour); got != nil {
+		t.Errorf("harvest_quarantine_after: 0, quarantineUntil = %s, want nil", got)
+	}
+}
@@ -0,0 +1,80 @@
+package articleService
+
+import (
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/mmcdole/gofeed"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/source"
+	"testing"
+	"time"
+)
+
+func TestHarvestFrom(t *testing.T) {
+	catchUp := cfg.HarvestCatchUp
+	t.Cleanup(func() { cfg.HarvestCatchUp = catchUp })
+	cfg.HarvestCatchUp = 24 * time.Hour
+
+	now := time.Date(2024, 5, 17, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		lastItem pgtype.Timestamptz
+		want     time.Time
+	}{
+		{"новый источник", pgtype.Timestamptz{}, now.Add(-24 * time.Hour)},
+		{"с последней новости", pgtype.Timestamptz{Time: now.Add(-time.Hour), Valid: true}, now.Add(-time.Hour)},
+		// Источник долго лежал - не тянем всю его историю
+		{"не глубже harvest_catch_up", pgtype.Timestamptz{Time: now.AddDate(0, 0, -30), Valid: true}, now.Add(-24 * time.Hour)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := harvestFrom(&source.RSS{LastItemDate: tt.lastItem}, now)
+			if !got.Equal(tt.want) {
+				t.Errorf("harvestFrom() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWatermark(t *testing.T) {
+	now := time.Date(2024, 5, 17, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+	feed := &gofeed.Feed{Items: []*gofeed.Item{
+		{GUID: "a", PublishedParsed: at(-3 * time.Hour)},
+		{GUID: "b", PublishedParsed: at(-time.Hour)},
+		{GUID: "без даты"},
+		{GUID: "c", PublishedParsed: at(-2 * time.Hour)},
+	}}
+
+	w := watermark(&source.RSS{}, feed, now)
+	if !w.LastHarvestAt.Equal(now) {
+		t.Errorf("LastHarvestAt = %s, want %s", w.LastHarvestAt, now)
+	}
+	if w.LastItemDate == nil || !w.LastItemDate.Equal(*at(-time.Hour)) || w.LastItemGUID != "b" {
+		t.Errorf("watermark = %v %q, want самая свежая новость b", w.LastItemDate, w.LastItemGUID)
+	}
+
+	// Лента отдала только старое - watermark назад не двигаем
+	src := &source.RSS{
+		LastItemDate: pgtype.Timestamptz{Time: now.Add(-30 * time.Minute), Valid: true},
+		LastItemGUID: pgtype.Text{String: "d", Valid: true},
+	}
+	w = watermark(src, feed, now)
+	if w.LastItemDate == nil || !w.LastItemDate.Equal(src.LastItemDate.Time) {
+		t.Errorf("LastItemDate = %v, want прежний %s", w.LastItemDate, src.LastItemDate.Time)
+	}
+
+	// Дата из будущего (часы издателя спешат) - watermark не дальше начала парсинга
+	future := &gofeed.Feed{Items: append([]*gofeed.Item{{GUID: "future", PublishedParsed: at(48 * time.Hour)}}, feed.Items...)}
+	w = watermark(&source.RSS{}, future, now)
+	if w.LastItemDate == nil || !w.LastItemDate.Equal(now) || w.LastItemGUID != "future" {
+		t.Errorf("watermark = %v %q, want %s", w.LastItemDate, w.LastItemGUID, now)
+	}
+
+	// Пустая лента у нового источника
+	w = watermark(&source.RSS{}, &gofeed.Feed{}, now)
+	if w.LastItemDate != nil || w.LastItemGUID != "" {
+		t.Errorf("watermark пустой ленты = %v %q, want пусто", w.LastItemDate, w.LastItemGUID)
+	}
+}
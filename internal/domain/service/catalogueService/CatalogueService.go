@@ -0,0 +1,409 @@
+package catalogueService
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/mskKote/prospero_backend/internal/adapters/db/elastic/v8/publisherSearchRepository"
+	"github.com/mskKote/prospero_backend/internal/adapters/db/postgres/publishersRepository"
+	"github.com/mskKote/prospero_backend/internal/adapters/db/postgres/sourcesRepository"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/catalogue"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/publisher"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/source"
+	"github.com/mskKote/prospero_backend/pkg/client/postgres"
+	"github.com/mskKote/prospero_backend/pkg/lib"
+	"github.com/mskKote/prospero_backend/pkg/logging"
+	"go.uber.org/zap"
+	"sort"
+	"strings"
+)
+
+var (
+	logger = logging.GetLogger().With(zap.String("prefix", "[CATALOGUE]"))
+
+	ErrNoCatalogue = errors.New("не задан catalogue_path")
+)
+
+// service, как и importService, меняет Postgres в своей транзакции,
+// поэтому держит клиента и создаёт репозитории на нём
+type service struct {
+	client       postgres.Client
+	elastic      publishersSearchRepository.IRepository
+	path         string
+	repositories func(client postgres.Client) (sourcesRepository.IRepository, publishersRepository.IRepository)
+}
+
+// pgOp - изменение Postgres. ids - ID издателей по названию в нижнем регистре,
+// созданные издатели дописывают туда себя для своих лент
+type pgOp struct {
+	change *catalogue.Change
+	apply  func(ctx context.Context, client postgres.Client, ids map[string]pgtype.UUID) error
+}
+
+type esOp struct {
+	change *catalogue.Change
+	apply  func(ctx context.Context) error
+}
+
+type reconciliation struct {
+	plan *catalogue.Plan
+	ids  map[string]pgtype.UUID
+	pg   []pgOp
+	es   []esOp
+}
+
+func (s *service) Plan(ctx context.Context, prune bool) (*catalogue.Plan, error) {
+	r, err := s.reconcile(ctx, prune)
+	if err != nil {
+		return nil, err
+	}
+	return r.plan, nil
+}
+
+func (s *service) Apply(ctx context.Context, prune bool) (*catalogue.Plan, error) {
+	r, err := s.reconcile(ctx, prune)
+	if err != nil {
+		return nil, err
+	}
+	if r.plan.Empty() {
+		r.plan.Applied = true
+		return r.plan, nil
+	}
+
+	if len(r.pg) > 0 {
+		if err := s.applyPostgres(ctx, r); err != nil {
+			return r.plan, err
+		}
+	}
+
+	// ES не откатить вместе с Postgres: применяем всё, что получится
+	var errs []error
+	for _, op := range r.es {
+		if err := op.apply(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%s %s %s: %w",
+				op.change.Store, op.change.Action, op.change.Publisher, err))
+		}
+	}
+	r.plan.Applied = true
+	logger.Info(fmt.Sprintf("Применили каталог %s: изменений %d", r.plan.Path, len(r.plan.Changes)))
+	return r.plan, errors.Join(errs...)
+}
+
+func (s *service) applyPostgres(ctx context.Context, r *reconciliation) error {
+	tx, err := s.client.Begin(ctx)
+	if err != nil {
+		return lib.HandlePgErr(err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			logger.Error("Не откатили сверку каталога", zap.Error(err))
+		}
+	}()
+
+	for _, op := range r.pg {
+		if err := op.apply(ctx, tx, r.ids); err != nil {
+			return fmt.Errorf("%s %s %s %s: %w",
+				op.change.Action, op.change.Kind, op.change.Publisher, op.change.RssURL, err)
+		}
+		logger.Info(fmt.Sprintf("%s %s %s %s",
+			op.change.Action, op.change.Kind, op.change.Publisher, op.change.RssURL))
+	}
+	return lib.HandlePgErr(tx.Commit(ctx))
+}
+
+// reconcile читает каталог и текущее состояние баз и составляет план
+func (s *service) reconcile(ctx context.Context, prune bool) (*reconciliation, error) {
+	c, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	r := &reconciliation{
+		plan: &catalogue.Plan{Path: s.path, Prune: prune, Changes: []*catalogue.Change{}},
+		ids:  map[string]pgtype.UUID{},
+	}
+	if err := s.diffPostgres(ctx, c, r); err != nil {
+		return nil, err
+	}
+	if err := s.diffElastic(ctx, c, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (s *service) load() (*catalogue.Catalogue, error) {
+	if s.path == "" {
+		return nil, ErrNoCatalogue
+	}
+	return catalogue.Load(s.path)
+}
+
+func (s *service) diffPostgres(ctx context.Context, c *catalogue.Catalogue, r *reconciliation) error {
+	_, publishersREPO := s.repositories(s.client)
+	publishers, err := publishersREPO.FindAll(ctx)
+	if err != nil {
+		return err
+	}
+	sources, err := s.allSources(ctx)
+	if err != nil {
+		return err
+	}
+
+	byName := map[string]*publisher.PgDBO{}
+	names := map[pgtype.UUID]string{}
+	for _, p := range publishers {
+		byName[strings.ToLower(p.Name)] = p
+		names[p.PublisherID] = p.Name
+		r.ids[strings.ToLower(p.Name)] = p.PublisherID
+	}
+	byURL := map[string]*source.RSS{}
+	for _, src := range sources {
+		byURL[src.RssURL] = src
+	}
+
+	// Порядок применения: издатели, их ленты, затем удаления
+	var publisherOps, sourceOps, sourceDeletes, publisherDeletes []pgOp
+	managed := map[string]bool{}
+	feeds := map[string]bool{}
+
+	for _, cp := range c.Publishers {
+		key := strings.ToLower(cp.Name)
+		managed[key] = true
+		desired := (&publisher.DTO{
+			Name:      cp.Name,
+			Country:   cp.Country,
+			City:      cp.City,
+			Longitude: cp.Longitude,
+			Latitude:  cp.Latitude,
+		}).ToDomain()
+
+		if existing, ok := byName[key]; !ok {
+			publisherOps = append(publisherOps, pgOp{
+				change: &catalogue.Change{Kind: catalogue.KindPublisher, Action: catalogue.ActionCreate, Publisher: cp.Name},
+				apply: func(ctx context.Context, client postgres.Client, ids map[string]pgtype.UUID) error {
+					_, publishersREPO := s.repositories(client)
+					p, err := publishersREPO.Create(ctx, desired)
+					if err != nil {
+						return err
+					}
+					ids[key] = p.PublisherID
+					return nil
+				},
+			})
+		} else if fields := publisherFields(existing, desired); len(fields) > 0 {
+			desired.PublisherID = existing.PublisherID
+			publisherOps = append(publisherOps, pgOp{
+				change: &catalogue.Change{Kind: catalogue.KindPublisher, Action: catalogue.ActionUpdate, Publisher: cp.Name, Fields: fields},
+				apply: func(ctx context.Context, client postgres.Client, _ map[string]pgtype.UUID) error {
+					_, publishersREPO := s.repositories(client)
+					return publishersREPO.Update(ctx, desired)
+				},
+			})
+		}
+
+		for _, feed := range cp.Feeds {
+			feed := feed
+			feeds[feed] = true
+			existing, ok := byURL[feed]
+			if !ok {
+				sourceOps = append(sourceOps, pgOp{
+					change: &catalogue.Change{Kind: catalogue.KindSource, Action: catalogue.ActionCreate, Publisher: cp.Name, RssURL: feed},
+					apply: func(ctx context.Context, client postgres.Client, ids map[string]pgtype.UUID) error {
+						sourcesREPO, _ := s.repositories(client)
+						_, err := sourcesREPO.Create(ctx, &source.RSS{
+							RssURL:    feed,
+							Publisher: publisher.PgDBO{PublisherID: ids[key]},
+						})
+						return err
+					},
+				})
+				continue
+			}
+			// Лента есть, но у другого издателя - переносим
+			if id, ok := r.ids[key]; ok && existing.Publisher.PublisherID == id {
+				continue
+			}
+			sourceOps = append(sourceOps, pgOp{
+				change: &catalogue.Change{
+					Kind: catalogue.KindSource, Action: catalogue.ActionUpdate, Publisher: cp.Name, RssURL: feed,
+					Fields: []catalogue.FieldChange{{
+						Field: "publisher", From: names[existing.Publisher.PublisherID], To: cp.Name,
+					}},
+				},
+				apply: func(ctx context.Context, client postgres.Client, ids map[string]pgtype.UUID) error {
+					moved := *existing
+					moved.Publisher = publisher.PgDBO{PublisherID: ids[key]}
+					sourcesREPO, _ := s.repositories(client)
+					return sourcesREPO.Update(ctx, moved)
+				},
+			})
+		}
+	}
+
+	// Всё, чего нет в каталоге, удаляем только с prune
+	for _, src := range sources {
+		if feeds[src.RssURL] {
+			continue
+		}
+		id := lib.UuidToString(src.RssID)
+		op := pgOp{
+			change: &catalogue.Change{Kind: catalogue.KindSource, Action: catalogue.ActionDelete, Publisher: names[src.Publisher.PublisherID], RssURL: src.RssURL},
+			apply: func(ctx context.Context, client postgres.Client, _ map[string]pgtype.UUID) error {
+				sourcesREPO, _ := s.repositories(client)
+				return sourcesREPO.Delete(ctx, id)
+			},
+		}
+		sourceDeletes = append(sourceDeletes, op)
+	}
+	for _, p := range publishers {
+		if managed[strings.ToLower(p.Name)] {
+			continue
+		}
+		id := lib.UuidToString(p.PublisherID)
+		publisherDeletes = append(publisherDeletes, pgOp{
+			change: &catalogue.Change{Kind: catalogue.KindPublisher, Action: catalogue.ActionDelete, Publisher: p.Name},
+			apply: func(ctx context.Context, client postgres.Client, _ map[string]pgtype.UUID) error {
+				_, publishersREPO := s.repositories(client)
+				return publishersREPO.Delete(ctx, id)
+			},
+		})
+	}
+
+	for _, ops := range [][]pgOp{publisherOps, sourceOps, sourceDeletes, publisherDeletes} {
+		for _, op := range ops {
+			op.change.Store = catalogue.StorePostgres
+			if op.change.Action == catalogue.ActionDelete && !r.plan.Prune {
+				r.plan.Unmanaged = append(r.plan.Unmanaged, op.change)
+				continue
+			}
+			r.plan.Changes = append(r.plan.Changes, op.change)
+			r.pg = append(r.pg, op)
+		}
+	}
+	return nil
+}
+
+// diffElastic сверяет индекс издателей по названию: ID документов в ES свои
+func (s *service) diffElastic(ctx context.Context, c *catalogue.Catalogue, r *reconciliation) error {
+	docs, err := s.elastic.FindAll(ctx)
+	if err != nil {
+		return err
+	}
+	byName := map[string][]*publisher.EsDBO{}
+	for _, d := range docs {
+		key := strings.ToLower(d.Name)
+		byName[key] = append(byName[key], d)
+	}
+
+	// managed - изменение издателя из каталога, его удаление не ждёт prune
+	add := func(op esOp, managed bool) {
+		op.change.Store = catalogue.StoreElastic
+		op.change.Kind = catalogue.KindPublisher
+		if op.change.Action == catalogue.ActionDelete && !managed && !r.plan.Prune {
+			r.plan.Unmanaged = append(r.plan.Unmanaged, op.change)
+			return
+		}
+		r.plan.Changes = append(r.plan.Changes, op.change)
+		r.es = append(r.es, op)
+	}
+	deleteDoc := func(d *publisher.EsDBO) esOp {
+		return esOp{
+			change: &catalogue.Change{Action: catalogue.ActionDelete, Publisher: d.Name},
+			apply: func(ctx context.Context) error {
+				return s.elastic.DeletePublisher(ctx, d.PublisherID)
+			},
+		}
+	}
+
+	for _, cp := range c.Publishers {
+		key := strings.ToLower(cp.Name)
+		existing := byName[key]
+		delete(byName, key)
+
+		doc := &publisher.EsDBO{Name: cp.Name}
+		switch {
+		case len(existing) == 0:
+			add(esOp{
+				change: &catalogue.Change{Action: catalogue.ActionCreate, Publisher: cp.Name},
+				apply:  indexDoc(s.elastic, doc),
+			}, true)
+			continue
+		case existing[0].Name != cp.Name:
+			doc.PublisherID = existing[0].PublisherID
+			add(esOp{
+				change: &catalogue.Change{
+					Action: catalogue.ActionUpdate, Publisher: cp.Name,
+					Fields: []catalogue.FieldChange{{Field: "name", From: existing[0].Name, To: cp.Name}},
+				},
+				apply: indexDoc(s.elastic, doc),
+			}, true)
+		}
+		// Дубликаты издателя из каталога
+		for _, d := range existing[1:] {
+			add(deleteDoc(d), true)
+		}
+	}
+
+	rest := make([]string, 0, len(byName))
+	for key := range byName {
+		rest = append(rest, key)
+	}
+	sort.Strings(rest)
+	for _, key := range rest {
+		for _, d := range byName[key] {
+			add(deleteDoc(d), false)
+		}
+	}
+	return nil
+}
+
+func indexDoc(elastic publishersSearchRepository.IRepository, doc *publisher.EsDBO) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		if !elastic.IndexPublisher(ctx, doc) {
+			return errors.New("не записали издателя в " + publishersSearchRepository.Index)
+		}
+		return nil
+	}
+}
+
+func publisherFields(existing, desired *publisher.PgDBO) (fields []catalogue.FieldChange) {
+	compare := func(field string, from, to any) {
+		if from != to {
+			fields = append(fields, catalogue.FieldChange{Field: field, From: from, To: to})
+		}
+	}
+	compare("name", existing.Name, desired.Name)
+	compare("country", existing.Country, desired.Country)
+	compare("city", existing.City, desired.City)
+	compare("latitude", existing.Point.P.Y, desired.Point.P.Y)
+	compare("longitude", existing.Point.P.X, desired.Point.P.X)
+	return fields
+}
+
+func (s *service) allSources(ctx context.Context) (all []*source.RSS, err error) {
+	repo, _ := s.repositories(s.client)
+	const page = 100
+	for offset := 0; ; offset += page {
+		sources, err := repo.FindAll(ctx, offset, page)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, sources...)
+		if len(sources) < page {
+			return all, nil
+		}
+	}
+}
+
+func repositories(client postgres.Client) (sourcesRepository.IRepository, publishersRepository.IRepository) {
+	return sourcesRepository.New(client), publishersRepository.New(client)
+}
+
+func New(
+	client postgres.Client,
+	elastic publishersSearchRepository.IRepository,
+	path string) ICatalogueService {
+	return &service{client, elastic, path, repositories}
+}
@@ -0,0 +1,207 @@
+package catalogueService
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/catalogue"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/publisher"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testCatalogue = `publishers:
+  - name: Медуза
+    country: Латвия
+    city: Рига
+    latitude: 56.95
+    longitude: 24.1
+    feeds:
+      - https://meduza.io/rss/all
+      - https://meduza.io/rss/news
+  - name: ТАСС
+    country: Россия
+    city: Москва
+    feeds:
+      - https://tass.ru/rss/v2.xml
+`
+
+func writeCatalogue(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// changes - план строками "store kind action publisher url" для сравнения
+func changes(list []*catalogue.Change) []string {
+	res := make([]string, len(list))
+	for i, c := range list {
+		res[i] = strings.TrimSpace(fmt.Sprintf("%s %s %s %s %s", c.Store, c.Kind, c.Action, c.Publisher, c.RssURL))
+	}
+	return res
+}
+
+func equal(t *testing.T, what string, got, want []string) {
+	t.Helper()
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("%s:\n%s\nwant:\n%s", what, strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+// Пустые базы: план создаёт всё, Apply применяет, повторный план пуст
+func TestPlanApply(t *testing.T) {
+	s, st, es := newTestService(writeCatalogue(t, "catalogue.yml", testCatalogue))
+
+	plan, err := s.Plan(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	equal(t, "план", changes(plan.Changes), []string{
+		"postgres publisher create Медуза",
+		"postgres publisher create ТАСС",
+		"postgres source create Медуза https://meduza.io/rss/all",
+		"postgres source create Медуза https://meduza.io/rss/news",
+		"postgres source create ТАСС https://tass.ru/rss/v2.xml",
+		"elastic publisher create Медуза",
+		"elastic publisher create ТАСС",
+	})
+	if plan.Applied || len(st.publishers) != 0 || len(es.docs) != 0 {
+		t.Fatal("Plan() что-то изменил")
+	}
+
+	plan, err = s.Apply(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !plan.Applied || st.committed != 1 {
+		t.Errorf("Applied %t, commit %d, want применено одной транзакцией", plan.Applied, st.committed)
+	}
+	// Ленты созданы у издателей, созданных в той же транзакции
+	for url, want := range map[string]string{
+		"https://meduza.io/rss/all":  "Медуза",
+		"https://meduza.io/rss/news": "Медуза",
+		"https://tass.ru/rss/v2.xml": "ТАСС",
+	} {
+		if got := st.publisherOf(url); got != want {
+			t.Errorf("издатель %s = %q, want %q", url, got, want)
+		}
+	}
+	if len(es.docs) != 2 {
+		t.Errorf("документов в ES %d, want 2", len(es.docs))
+	}
+
+	plan, err = s.Plan(context.Background(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !plan.Empty() {
+		t.Errorf("план после Apply = %v, want пусто", changes(plan.Changes))
+	}
+}
+
+// Базы разошлись с каталогом: обновления, перенос ленты, записи вне каталога
+func TestPlanDrift(t *testing.T) {
+	s, st, es := newTestService(writeCatalogue(t, "catalogue.yml", testCatalogue))
+	st.addPublisher(publisher.PgDBO{Name: "медуза", Country: "Латвия", City: "Рига"}, "https://meduza.io/rss/all")
+	st.addPublisher(publisher.PgDBO{Name: "ТАСС", Country: "Россия", City: "Москва"}, "https://meduza.io/rss/news")
+	st.addPublisher(publisher.PgDBO{Name: "Лента"}, "https://lenta.ru/rss")
+	es.docs = []*publisher.EsDBO{
+		{PublisherID: "1", Name: "медуза"},
+		{PublisherID: "2", Name: "ТАСС"},
+		{PublisherID: "3", Name: "тасс"},
+		{PublisherID: "4", Name: "Лента"},
+	}
+
+	plan, err := s.Plan(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	equal(t, "план", changes(plan.Changes), []string{
+		"postgres publisher update Медуза",
+		"postgres source update Медуза https://meduza.io/rss/news",
+		"postgres source create ТАСС https://tass.ru/rss/v2.xml",
+		"elastic publisher update Медуза",
+		"elastic publisher delete тасс",
+	})
+	// Без prune чужое только показываем
+	equal(t, "вне каталога", changes(plan.Unmanaged), []string{
+		"postgres source delete Лента https://lenta.ru/rss",
+		"postgres publisher delete Лента",
+		"elastic publisher delete Лента",
+	})
+
+	fields := plan.Changes[0].Fields
+	if len(fields) != 3 || fields[0].Field != "name" || fields[1].Field != "latitude" || fields[2].Field != "longitude" {
+		t.Errorf("поля издателя = %+v, want name, latitude, longitude", fields)
+	}
+	moved := plan.Changes[1].Fields
+	if len(moved) != 1 || moved[0].From != "ТАСС" || moved[0].To != "Медуза" {
+		t.Errorf("перенос ленты = %+v, want от ТАСС к Медузе", moved)
+	}
+
+	if _, err := s.Apply(context.Background(), true); err != nil {
+		t.Fatal(err)
+	}
+	if got := st.publisherOf("https://meduza.io/rss/news"); got != "Медуза" {
+		t.Errorf("издатель перенесённой ленты = %q, want Медуза", got)
+	}
+	if len(st.publishers) != 2 || len(st.sources) != 3 || len(es.docs) != 2 {
+		t.Errorf("после prune издателей %d, лент %d, документов %d, want 2, 3, 2",
+			len(st.publishers), len(st.sources), len(es.docs))
+	}
+	plan, err = s.Plan(context.Background(), true)
+	if err != nil || !plan.Empty() {
+		t.Errorf("план после Apply = %v, %v, want пусто", changes(plan.Changes), err)
+	}
+}
+
+// Ошибка в Postgres откатывает всю сверку, ES не трогаем
+func TestApplyRollback(t *testing.T) {
+	s, st, es := newTestService(writeCatalogue(t, "catalogue.yml", testCatalogue))
+	st.addPublisher(publisher.PgDBO{Name: "Лента"}, "https://lenta.ru/rss")
+	st.failURL = "https://tass.ru/rss/v2.xml"
+
+	_, err := s.Apply(context.Background(), true)
+	if err == nil || !strings.Contains(err.Error(), st.failURL) {
+		t.Fatalf("Apply() error = %v, want ошибку ленты %s", err, st.failURL)
+	}
+	if st.committed != 0 || len(st.publishers) != 1 || len(st.sources) != 1 || st.publishers[0].Name != "Лента" {
+		t.Errorf("после отката издатели %d, ленты %d, want как было", len(st.publishers), len(st.sources))
+	}
+	if len(es.docs) != 0 {
+		t.Errorf("в ES %d документов, want ни одного", len(es.docs))
+	}
+}
+
+func TestLoad(t *testing.T) {
+	json := writeCatalogue(t, "catalogue.json",
+		`{"publishers":[{"name":"BBC","feeds":["https://feeds.bbci.co.uk/news/rss.xml"]}]}`)
+	s, _, _ := newTestService(json)
+	c, err := s.load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Publishers) != 1 || c.Publishers[0].Feeds[0] != "https://feeds.bbci.co.uk/news/rss.xml" {
+		t.Errorf("каталог из JSON = %+v", c.Publishers)
+	}
+
+	for name, content := range map[string]string{
+		"broken.yml":  "publishers: [",
+		"invalid.yml": "publishers:\n  - name: A\n    feeds: [ftp://a.ru]\n",
+	} {
+		s, _, _ := newTestService(writeCatalogue(t, name, content))
+		if _, err := s.Plan(context.Background(), false); err == nil || !strings.Contains(err.Error(), name) {
+			t.Errorf("Plan(%s) error = %v, want ошибку с путём каталога", name, err)
+		}
+	}
+
+	s, _, _ = newTestService("")
+	if _, err := s.Plan(context.Background(), false); !errors.Is(err, ErrNoCatalogue) {
+		t.Errorf("Plan() без пути error = %v, want ErrNoCatalogue", err)
+	}
+}
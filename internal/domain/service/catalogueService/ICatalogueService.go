@@ -0,0 +1,16 @@
+package catalogueService
+
+import (
+	"context"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/catalogue"
+)
+
+type ICatalogueService interface {
+	// Plan читает каталог и показывает, что изменит сверка, ничего не меняя.
+	// prune - удалять издателей и ленты, которых нет в каталоге
+	Plan(ctx context.Context, prune bool) (*catalogue.Plan, error)
+
+	// Apply приводит Postgres и индекс издателей в ES к каталогу.
+	// Postgres меняется одной транзакцией, ES - после её фиксации
+	Apply(ctx context.Context, prune bool) (*catalogue.Plan, error)
+}
@@ -0,0 +1,222 @@
+package catalogueService
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/mskKote/prospero_backend/internal/adapters/db/elastic/v8/publisherSearchRepository"
+	"github.com/mskKote/prospero_backend/internal/adapters/db/postgres/publishersRepository"
+	"github.com/mskKote/prospero_backend/internal/adapters/db/postgres/sourcesRepository"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/publisher"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/source"
+	"github.com/mskKote/prospero_backend/pkg/client/postgres"
+	"github.com/mskKote/prospero_backend/pkg/lib"
+)
+
+// store - Postgres в памяти. Транзакция откатывает его к снимку
+type store struct {
+	sources    []*source.RSS
+	publishers []*publisher.PgDBO
+	ids        int
+	// Адрес, на котором Create падает
+	failURL   string
+	committed int
+}
+
+func (s *store) newID() string {
+	s.ids++
+	return fmt.Sprintf("00000000-0000-0000-0000-%012d", s.ids)
+}
+
+func (s *store) addPublisher(p publisher.PgDBO, urls ...string) *publisher.PgDBO {
+	p.PublisherID = lib.StringToUUID(s.newID())
+	s.publishers = append(s.publishers, &p)
+	for _, url := range urls {
+		s.sources = append(s.sources, &source.RSS{
+			RssID:     lib.StringToUUID(s.newID()),
+			RssURL:    url,
+			Publisher: publisher.PgDBO{PublisherID: p.PublisherID},
+		})
+	}
+	return &p
+}
+
+// publisherOf - название издателя ленты
+func (s *store) publisherOf(url string) string {
+	for _, src := range s.sources {
+		if src.RssURL != url {
+			continue
+		}
+		for _, p := range s.publishers {
+			if p.PublisherID == src.Publisher.PublisherID {
+				return p.Name
+			}
+		}
+	}
+	return ""
+}
+
+type fakeSources struct {
+	sourcesRepository.IRepository
+	*store
+}
+
+func (f fakeSources) FindAll(_ context.Context, offset, limit int) ([]*source.RSS, error) {
+	if offset >= len(f.sources) {
+		return nil, nil
+	}
+	return f.sources[offset:min(offset+limit, len(f.sources))], nil
+}
+
+func (f fakeSources) Create(_ context.Context, src *source.RSS) (*source.RSS, error) {
+	if src.RssURL == f.failURL {
+		return nil, errors.New("нарушено ограничение rss_url")
+	}
+	created := *src
+	created.RssID = lib.StringToUUID(f.newID())
+	f.sources = append(f.sources, &created)
+	return &created, nil
+}
+
+func (f fakeSources) Update(_ context.Context, src source.RSS) error {
+	for i, existing := range f.sources {
+		if existing.RssID == src.RssID {
+			f.sources[i] = &src
+		}
+	}
+	return nil
+}
+
+func (f fakeSources) Delete(_ context.Context, id string) error {
+	for i, src := range f.sources {
+		if lib.UuidToString(src.RssID) == id {
+			f.store.sources = append(f.sources[:i:i], f.sources[i+1:]...)
+			return nil
+		}
+	}
+	return sourcesRepository.ErrNotFound
+}
+
+type fakePublishers struct {
+	publishersRepository.IRepository
+	*store
+}
+
+func (f fakePublishers) FindAll(context.Context) ([]*publisher.PgDBO, error) {
+	return f.publishers, nil
+}
+
+func (f fakePublishers) Create(_ context.Context, p *publisher.PgDBO) (*publisher.PgDBO, error) {
+	created := *p
+	created.PublisherID = lib.StringToUUID(f.newID())
+	f.publishers = append(f.publishers, &created)
+	return &created, nil
+}
+
+func (f fakePublishers) Update(_ context.Context, p *publisher.PgDBO) error {
+	for i, existing := range f.publishers {
+		if existing.PublisherID == p.PublisherID {
+			updated := *p
+			f.publishers[i] = &updated
+		}
+	}
+	return nil
+}
+
+func (f fakePublishers) Delete(_ context.Context, id string) error {
+	for i, p := range f.publishers {
+		if lib.UuidToString(p.PublisherID) == id {
+			f.store.publishers = append(f.publishers[:i:i], f.publishers[i+1:]...)
+			return nil
+		}
+	}
+	return publishersRepository.ErrNotFound
+}
+
+type fakeClient struct {
+	postgres.Client
+	*store
+}
+
+func (c fakeClient) Begin(context.Context) (pgx.Tx, error) {
+	return &fakeTx{
+		store:      c.store,
+		sources:    append([]*source.RSS(nil), c.sources...),
+		publishers: append([]*publisher.PgDBO(nil), c.publishers...),
+	}, nil
+}
+
+type fakeTx struct {
+	pgx.Tx
+	store      *store
+	sources    []*source.RSS
+	publishers []*publisher.PgDBO
+	closed     bool
+}
+
+func (tx *fakeTx) Commit(context.Context) error {
+	if tx.closed {
+		return pgx.ErrTxClosed
+	}
+	tx.closed = true
+	tx.store.committed++
+	return nil
+}
+
+func (tx *fakeTx) Rollback(context.Context) error {
+	if tx.closed {
+		return pgx.ErrTxClosed
+	}
+	tx.closed = true
+	tx.store.sources, tx.store.publishers = tx.sources, tx.publishers
+	return nil
+}
+
+// fakeElastic - индекс издателей в памяти
+type fakeElastic struct {
+	publishersSearchRepository.IRepository
+	docs []*publisher.EsDBO
+	ids  int
+}
+
+func (f *fakeElastic) FindAll(context.Context) ([]*publisher.EsDBO, error) {
+	return f.docs, nil
+}
+
+func (f *fakeElastic) IndexPublisher(_ context.Context, p *publisher.EsDBO) bool {
+	for i, d := range f.docs {
+		if d.PublisherID == p.PublisherID {
+			f.docs[i] = p
+			return true
+		}
+	}
+	f.ids++
+	doc := *p
+	doc.PublisherID = fmt.Sprintf("es-%d", f.ids)
+	f.docs = append(f.docs, &doc)
+	return true
+}
+
+func (f *fakeElastic) DeletePublisher(_ context.Context, id string) error {
+	for i, d := range f.docs {
+		if d.PublisherID == id {
+			f.docs = append(f.docs[:i:i], f.docs[i+1:]...)
+			return nil
+		}
+	}
+	return errors.New("нет документа " + id)
+}
+
+func newTestService(path string) (*service, *store, *fakeElastic) {
+	st := &store{}
+	es := &fakeElastic{}
+	return &service{
+		client:  fakeClient{store: st},
+		elastic: es,
+		path:    path,
+		repositories: func(postgres.Client) (sourcesRepository.IRepository, publishersRepository.IRepository) {
+			return fakeSources{store: st}, fakePublishers{store: st}
+		},
+	}, st, es
+}
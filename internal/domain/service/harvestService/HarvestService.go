@@ -0,0 +1,251 @@
+package harvestService
+
+import (
+	"context"
+	"errors"
+	"github.com/mskKote/prospero_backend/internal/adapters/db/postgres/harvestRunsRepository"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/harvest"
+	"github.com/mskKote/prospero_backend/internal/domain/service/articleService"
+	"github.com/mskKote/prospero_backend/internal/domain/service/sourcesService"
+	"github.com/mskKote/prospero_backend/pkg/lib"
+	"github.com/mskKote/prospero_backend/pkg/logging"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+var (
+	logger = logging.GetLogger()
+
+	ErrNotFound       = errors.New("задача парсинга не найдена")
+	ErrAlreadyRunning = errors.New("парсинг уже идёт")
+)
+
+// Сколько завершённых задач помним
+const keepFinished = 20
+
+type service struct {
+	sources  sourcesService.ISourceService
+	articles articleService.IArticleService
+	runs     harvestRunsRepository.IRepository
+
+	mu       sync.Mutex
+	jobs     map[string]*job
+	finished []string
+	running  *job
+}
+
+// job - задача и её подписчики
+type job struct {
+	mu     sync.Mutex
+	state  harvest.Job
+	cancel context.CancelFunc
+	subs   map[chan harvest.Job]struct{}
+}
+
+func (s *service) Start(ctx context.Context, trigger string, full bool) (*harvest.Job, error) {
+	// Место занимаем под блокировкой, а в postgres ходим уже без неё
+	j := &job{subs: map[chan harvest.Job]struct{}{}}
+	s.mu.Lock()
+	if s.running != nil {
+		s.mu.Unlock()
+		return nil, ErrAlreadyRunning
+	}
+	s.running = j
+	s.mu.Unlock()
+
+	state := harvest.Job{
+		Status:    harvest.JobRunning,
+		Trigger:   trigger,
+		Full:      full,
+		StartedAt: time.Now(),
+	}
+	if total, err := s.sources.Count(ctx); err != nil {
+		logger.ErrorContext(ctx, "Не посчитали источники", zap.Error(err))
+	} else {
+		state.SourcesTotal = total
+	}
+	// ID задачи - ID запуска в harvest_runs
+	if err := s.runs.CreateRun(ctx, &state); err != nil {
+		s.mu.Lock()
+		s.running = nil
+		s.mu.Unlock()
+		return nil, err
+	}
+
+	// Задача живёт дольше запроса, который её создал
+	jobCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	j.state = state
+	j.cancel = cancel
+	s.mu.Lock()
+	s.jobs[state.ID] = j
+	s.mu.Unlock()
+
+	go s.run(jobCtx, j)
+	return lib.PointerFrom(state), nil
+}
+
+func (s *service) run(ctx context.Context, j *job) {
+	id := j.snapshot().ID
+	logger.Info("Запустили задачу парсинга " + id)
+
+	_, err := s.articles.ParseAll(ctx, j.snapshot().Full, func(r harvest.SourceResult) {
+		// История не должна зависеть от отмены задачи
+		if err := s.runs.AddSource(context.WithoutCancel(ctx), id, &r); err != nil {
+			logger.Error("Не записали итог источника "+r.RssURL, zap.Error(err))
+		}
+		j.update(func(state *harvest.Job) { state.Add(r) })
+	})
+
+	j.update(func(state *harvest.Job) {
+		state.FinishedAt = lib.PointerFrom(time.Now())
+		switch {
+		case ctx.Err() != nil:
+			state.Status = harvest.JobCancelled
+		case err != nil:
+			state.Status = harvest.JobFailed
+			state.Error = err.Error()
+		default:
+			state.Status = harvest.JobDone
+		}
+	})
+	j.cancel()
+	final := j.snapshot()
+	logger.Info("Задача парсинга " + id + " завершена: " + final.Status)
+	if err := s.runs.FinishRun(context.WithoutCancel(ctx), &final); err != nil {
+		logger.Error("Не записали итог запуска "+id, zap.Error(err))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.running = nil
+	s.finished = append(s.finished, id)
+	if len(s.finished) > keepFinished {
+		delete(s.jobs, s.finished[0])
+		s.finished = s.finished[1:]
+	}
+}
+
+func (s *service) Get(ctx context.Context, id string) (*harvest.Job, error) {
+	if j, err := s.find(id); err == nil {
+		return lib.PointerFrom(j.snapshot()), nil
+	}
+	// Давно завершённые задачи - из истории
+	return s.findRun(ctx, id)
+}
+
+func (s *service) FindRuns(ctx context.Context, page, pageSize int) ([]*harvest.Job, error) {
+	return s.runs.FindRuns(ctx, page*pageSize, pageSize)
+}
+
+func (s *service) FindRun(ctx context.Context, id, publisher string) (*harvest.Job, []*harvest.SourceResult, error) {
+	run, err := s.findRun(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	sources, err := s.runs.FindRunSources(ctx, id, publisher)
+	if err != nil {
+		return nil, nil, err
+	}
+	return run, sources, nil
+}
+
+func (s *service) CountRuns(ctx context.Context) (int64, error) {
+	return s.runs.Count(ctx)
+}
+
+func (s *service) findRun(ctx context.Context, id string) (*harvest.Job, error) {
+	if !lib.StringToUUID(id).Valid {
+		return nil, ErrNotFound
+	}
+	run, err := s.runs.FindRun(ctx, id)
+	if errors.Is(err, harvestRunsRepository.ErrNotFound) {
+		return nil, ErrNotFound
+	}
+	return run, err
+}
+
+func (s *service) Cancel(id string) error {
+	j, err := s.find(id)
+	if err != nil {
+		return err
+	}
+	j.cancel()
+	return nil
+}
+
+func (s *service) Subscribe(id string) (<-chan harvest.Job, func(), error) {
+	j, err := s.find(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Буфер на одно состояние: медленный подписчик получит последнее
+	ch := make(chan harvest.Job, 1)
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	ch <- j.state
+	if j.state.Finished() {
+		close(ch)
+		return ch, func() {}, nil
+	}
+	j.subs[ch] = struct{}{}
+
+	unsubscribe := func() {
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		if _, ok := j.subs[ch]; ok {
+			delete(j.subs, ch)
+			close(ch)
+		}
+	}
+	return ch, unsubscribe, nil
+}
+
+func (s *service) find(id string) (*job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return j, nil
+}
+
+func (j *job) snapshot() harvest.Job {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.state
+}
+
+// update меняет состояние и рассылает его подписчикам
+func (j *job) update(fn func(state *harvest.Job)) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	fn(&j.state)
+	for ch := range j.subs {
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- j.state
+		if j.state.Finished() {
+			delete(j.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+func New(
+	sources sourcesService.ISourceService,
+	articles articleService.IArticleService,
+	runs harvestRunsRepository.IRepository) IHarvestService {
+	return &service{
+		sources:  sources,
+		articles: articles,
+		runs:     runs,
+		jobs:     map[string]*job{},
+	}
+}
@@ -0,0 +1,363 @@
+package harvestService
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/mskKote/prospero_backend/internal/adapters/db/postgres/harvestRunsRepository"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/article"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/harvest"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/source"
+	"github.com/mskKote/prospero_backend/internal/domain/service/articleService"
+	"github.com/mskKote/prospero_backend/internal/domain/service/sourcesService"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeSources struct {
+	sourcesService.ISourceService
+}
+
+func (f *fakeSources) Count(context.Context) (int64, error) {
+	return 3, nil
+}
+
+// fakeArticles отдаёт итоги источников из канала results, пока его не закроют или не отменят задачу
+type fakeArticles struct {
+	articleService.IArticleService
+	results chan harvest.SourceResult
+	err     error
+}
+
+func (f *fakeArticles) ParseAll(ctx context.Context, _ bool, onSource func(harvest.SourceResult)) (article.IndexStats, error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return article.IndexStats{}, ctx.Err()
+		case r, ok := <-f.results:
+			if !ok {
+				return article.IndexStats{}, f.err
+			}
+			onSource(r)
+		}
+	}
+}
+
+// fakeRuns - история запусков в памяти
+type fakeRuns struct {
+	harvestRunsRepository.IRepository
+	createErr error
+	// creating получает сигнал, когда CreateRun вызван, и держит его до release
+	creating chan struct{}
+	release  chan struct{}
+
+	mu       sync.Mutex
+	created  int
+	sources  map[string][]harvest.SourceResult
+	finished map[string]harvest.Job
+}
+
+func (f *fakeRuns) CreateRun(_ context.Context, run *harvest.Job) error {
+	if f.creating != nil {
+		f.creating <- struct{}{}
+		<-f.release
+	}
+	if f.createErr != nil {
+		return f.createErr
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.created++
+	run.ID = fmt.Sprintf("00000000-0000-0000-0000-%012d", f.created)
+	return nil
+}
+
+func (f *fakeRuns) AddSource(_ context.Context, runID string, r *harvest.SourceResult) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sources[runID] = append(f.sources[runID], *r)
+	return nil
+}
+
+func (f *fakeRuns) FinishRun(_ context.Context, run *harvest.Job) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.finished[run.ID] = *run
+	return nil
+}
+
+func (f *fakeRuns) FindRun(_ context.Context, id string) (*harvest.Job, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	run, ok := f.finished[id]
+	if !ok {
+		return nil, harvestRunsRepository.ErrNotFound
+	}
+	return &run, nil
+}
+
+func newTestService() (*service, *fakeArticles) {
+	s, articles, _ := newTestServiceWithRuns()
+	return s, articles
+}
+
+func newTestServiceWithRuns() (*service, *fakeArticles, *fakeRuns) {
+	articles := &fakeArticles{results: make(chan harvest.SourceResult)}
+	runs := &fakeRuns{sources: map[string][]harvest.SourceResult{}, finished: map[string]harvest.Job{}}
+	return New(&fakeSources{}, articles, runs).(*service), articles, runs
+}
+
+// waitFinished дочитывает подписку до конца и возвращает последнее состояние
+func waitFinished(t *testing.T, updates <-chan harvest.Job) harvest.Job {
+	t.Helper()
+	var last harvest.Job
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case j, ok := <-updates:
+			if !ok {
+				return last
+			}
+			last = j
+		case <-timeout:
+			t.Fatalf("задача не завершилась, последнее состояние %+v", last)
+		}
+	}
+}
+
+func TestStartOnlyOne(t *testing.T) {
+	s, articles := newTestService()
+
+	job, err := s.Start(context.Background(), harvest.TriggerManual, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != harvest.JobRunning || job.SourcesTotal != 3 {
+		t.Errorf("Start() = %+v, want running из 3 источников", job)
+	}
+	if _, err := s.Start(context.Background(), harvest.TriggerCron, true); !errors.Is(err, ErrAlreadyRunning) {
+		t.Errorf("второй Start() error = %v, want ErrAlreadyRunning", err)
+	}
+
+	updates, _, err := s.Subscribe(job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	close(articles.results)
+	if last := waitFinished(t, updates); last.Status != harvest.JobDone || last.FinishedAt == nil {
+		t.Errorf("итог = %+v, want done", last)
+	}
+
+	// После завершения можно запускать снова
+	articles.results = make(chan harvest.SourceResult)
+	deadline := time.Now().Add(time.Second)
+	for {
+		next, err := s.Start(context.Background(), harvest.TriggerManual, false)
+		if err == nil {
+			_ = s.Cancel(next.ID)
+			break
+		}
+		if !errors.Is(err, ErrAlreadyRunning) || time.Now().After(deadline) {
+			t.Fatalf("Start() после завершения error = %v", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSubscribeProgress(t *testing.T) {
+	s, articles := newTestService()
+	job, err := s.Start(context.Background(), harvest.TriggerManual, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updates, _, err := s.Subscribe(job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	articles.results <- harvest.SourceResult{Status: source.StatusOK, Articles: article.IndexStats{Created: 2}}
+	articles.results <- harvest.SourceResult{Status: source.StatusError}
+	articles.results <- harvest.SourceResult{Status: harvest.SourceQuarantined}
+	close(articles.results)
+
+	last := waitFinished(t, updates)
+	if last.SourcesDone != 3 || last.SourcesFailed != 1 || last.SourcesSkipped != 1 || last.Articles.Created != 2 {
+		t.Errorf("итог = %+v, want 3 источника: 1 с ошибкой, 1 пропущен, 2 статьи", last)
+	}
+
+	// Подписка на завершённую задачу отдаёт итог и сразу закрывается
+	updates, _, err = s.Subscribe(job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := waitFinished(t, updates); got.Status != harvest.JobDone || got.SourcesDone != 3 {
+		t.Errorf("подписка на завершённую = %+v, want итог", got)
+	}
+}
+
+func TestParseAllFailed(t *testing.T) {
+	s, articles := newTestService()
+	articles.err = errors.New("elastic недоступен")
+	job, err := s.Start(context.Background(), harvest.TriggerManual, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updates, _, _ := s.Subscribe(job.ID)
+	close(articles.results)
+
+	if last := waitFinished(t, updates); last.Status != harvest.JobFailed || last.Error != "elastic недоступен" {
+		t.Errorf("итог = %+v, want failed с текстом ошибки", last)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	s, _ := newTestService()
+	job, err := s.Start(context.Background(), harvest.TriggerManual, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updates, _, _ := s.Subscribe(job.ID)
+
+	if err := s.Cancel(job.ID); err != nil {
+		t.Fatal(err)
+	}
+	if last := waitFinished(t, updates); last.Status != harvest.JobCancelled {
+		t.Errorf("итог = %+v, want cancelled", last)
+	}
+	if err := s.Cancel("unknown"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Cancel(unknown) error = %v, want ErrNotFound", err)
+	}
+	for _, id := range []string{"unknown", "00000000-0000-0000-0000-000000000099"} {
+		if _, err := s.Get(context.Background(), id); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get(%s) error = %v, want ErrNotFound", id, err)
+		}
+	}
+}
+
+// Запрос, запустивший парсинг, закончился - задача продолжает работать
+func TestJobOutlivesRequest(t *testing.T) {
+	s, articles := newTestService()
+	ctx, cancel := context.WithCancel(context.Background())
+	job, err := s.Start(ctx, harvest.TriggerManual, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+
+	articles.results <- harvest.SourceResult{Status: source.StatusOK}
+	got, err := s.Get(context.Background(), job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != harvest.JobRunning {
+		t.Errorf("после отмены запроса Status = %s, want running", got.Status)
+	}
+	_ = s.Cancel(job.ID)
+}
+
+// Отписавшийся клиент больше не получает обновлений, а задача не блокируется
+func TestUnsubscribe(t *testing.T) {
+	s, articles := newTestService()
+	job, err := s.Start(context.Background(), harvest.TriggerManual, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updates, unsubscribe, _ := s.Subscribe(job.ID)
+	unsubscribe()
+	unsubscribe()
+
+	articles.results <- harvest.SourceResult{Status: source.StatusOK}
+	articles.results <- harvest.SourceResult{Status: source.StatusOK}
+	<-updates
+	if _, ok := <-updates; ok {
+		t.Error("канал отписавшегося не закрыт")
+	}
+	_ = s.Cancel(job.ID)
+}
+
+// Итоги источников и запуска остаются в истории, даже если задачу отменили
+func TestRunHistory(t *testing.T) {
+	s, articles, runs := newTestServiceWithRuns()
+	job, err := s.Start(context.Background(), harvest.TriggerCron, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Trigger != harvest.TriggerCron || job.ID == "" {
+		t.Errorf("Start() = %+v, want запуск по cron с ID из истории", job)
+	}
+	updates, _, _ := s.Subscribe(job.ID)
+
+	articles.results <- harvest.SourceResult{RssURL: "https://example.com/rss", Status: source.StatusOK}
+	_ = s.Cancel(job.ID)
+	waitFinished(t, updates)
+
+	runs.mu.Lock()
+	sources, final := runs.sources[job.ID], runs.finished[job.ID]
+	runs.mu.Unlock()
+	if len(sources) != 1 || sources[0].RssURL != "https://example.com/rss" {
+		t.Errorf("в истории источники %+v, want один", sources)
+	}
+	if final.Status != harvest.JobCancelled || final.SourcesDone != 1 || final.FinishedAt == nil {
+		t.Errorf("в истории запуск %+v, want cancelled после одного источника", final)
+	}
+
+	// Задача выпала из памяти - Get достаёт её из истории
+	s.mu.Lock()
+	delete(s.jobs, job.ID)
+	s.mu.Unlock()
+	got, err := s.Get(context.Background(), job.ID)
+	if err != nil || got.Status != harvest.JobCancelled {
+		t.Errorf("Get() = %+v, %v, want cancelled из истории", got, err)
+	}
+}
+
+// Запуск не записался в историю - парсинг не начинается и не блокирует следующий
+func TestStartCreateRunFailed(t *testing.T) {
+	s, _, runs := newTestServiceWithRuns()
+	runs.createErr = errors.New("postgres недоступен")
+
+	if _, err := s.Start(context.Background(), harvest.TriggerManual, false); !errors.Is(err, runs.createErr) {
+		t.Fatalf("Start() error = %v, want ошибку postgres", err)
+	}
+	runs.createErr = nil
+	job, err := s.Start(context.Background(), harvest.TriggerManual, false)
+	if err != nil {
+		t.Fatalf("повторный Start() error = %v", err)
+	}
+	_ = s.Cancel(job.ID)
+}
+
+// Пока запуск пишется в postgres, сервис не заблокирован: второй Start сразу получает отказ
+func TestStartWhileCreatingRun(t *testing.T) {
+	s, _, runs := newTestServiceWithRuns()
+	runs.creating, runs.release = make(chan struct{}), make(chan struct{})
+
+	started := make(chan *harvest.Job)
+	go func() {
+		job, _ := s.Start(context.Background(), harvest.TriggerManual, false)
+		started <- job
+	}()
+	<-runs.creating
+
+	second := make(chan error)
+	go func() {
+		_, err := s.Start(context.Background(), harvest.TriggerCron, false)
+		second <- err
+	}()
+	select {
+	case err := <-second:
+		if !errors.Is(err, ErrAlreadyRunning) {
+			t.Errorf("второй Start() error = %v, want ErrAlreadyRunning", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("второй Start() ждёт, пока первый пишет в postgres")
+	}
+
+	close(runs.release)
+	if job := <-started; job == nil {
+		t.Fatal("первый Start() не запустил задачу")
+	} else {
+		_ = s.Cancel(job.ID)
+	}
+}
@@ -0,0 +1,30 @@
+package harvestService
+
+import (
+	"context"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/harvest"
+)
+
+type IHarvestService interface {
+	// Start запускает парсинг всех источников в фоне и заводит запуск в истории.
+	// Одновременно идёт только одна задача - иначе ErrAlreadyRunning
+	Start(ctx context.Context, trigger string, full bool) (*harvest.Job, error)
+
+	// Get - текущее состояние задачи
+	Get(ctx context.Context, id string) (*harvest.Job, error)
+
+	// Cancel останавливает задачу, уже записанные статьи остаются
+	Cancel(id string) error
+
+	// Subscribe присылает состояние задачи при каждом изменении.
+	// Канал закрывается, когда задача завершилась; unsubscribe обязателен
+	Subscribe(id string) (updates <-chan harvest.Job, unsubscribe func(), err error)
+
+	// FindRuns - история запусков, свежие первыми
+	FindRuns(ctx context.Context, page, pageSize int) ([]*harvest.Job, error)
+
+	// FindRun - запуск и итоги его источников, publisher фильтрует по названию
+	FindRun(ctx context.Context, id, publisher string) (*harvest.Job, []*harvest.SourceResult, error)
+
+	CountRuns(ctx context.Context) (int64, error)
+}
@@ -0,0 +1,29 @@
+package importService
+
+import (
+	"context"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/source"
+	"io"
+)
+
+// Форматы файлов для ImportRows
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+)
+
+type IImportService interface {
+	// ImportSources добавляет источники вместе с издателями одной транзакцией.
+	// Записи проверяются заранее, уже известные rss_url пропускаются,
+	// издатели ищутся по названию. mode - source.ImportAllOrNothing или source.ImportBestEffort
+	ImportSources(ctx context.Context, entries []*source.AddSourceAndPublisherDTO, mode string) ([]*source.ImportResultDTO, error)
+
+	// ImportRows - ImportSources для CSV с заголовком или JSON lines
+	ImportRows(ctx context.Context, r io.Reader, format, mode string) ([]*source.ImportResultDTO, error)
+
+	// ImportOPML - ImportSources для лент из OPML
+	ImportOPML(ctx context.Context, r io.Reader, mode string) ([]*source.ImportResultDTO, error)
+
+	// ExportOPML выгружает источники, сгруппированные по стране и издателю
+	ExportOPML(ctx context.Context, w io.Writer) error
+}
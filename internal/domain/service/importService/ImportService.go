@@ -0,0 +1,224 @@
+package importService
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/mskKote/prospero_backend/internal/adapters/db/postgres/publishersRepository"
+	"github.com/mskKote/prospero_backend/internal/adapters/db/postgres/sourcesRepository"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/publisher"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/source"
+	"github.com/mskKote/prospero_backend/pkg/client/postgres"
+	"github.com/mskKote/prospero_backend/pkg/lib"
+	"github.com/mskKote/prospero_backend/pkg/logging"
+	"go.uber.org/zap"
+	"net/url"
+	"strings"
+)
+
+var (
+	logger = logging.GetLogger()
+
+	ErrUnknownMode = errors.New("режим импорта: all_or_nothing или best_effort")
+)
+
+// Почему запись пропущена
+const (
+	reasonExists     = "rss_url уже есть"
+	reasonRepeated   = "rss_url повторяется в файле"
+	reasonRolledBack = "импорт откатили из-за ошибок"
+)
+
+// service работает с репозиториями внутри своей транзакции,
+// поэтому держит клиента и создаёт репозитории на нём
+type service struct {
+	client       postgres.Client
+	repositories func(client postgres.Client) (sourcesRepository.IRepository, publishersRepository.IRepository)
+}
+
+func (s *service) ImportSources(
+	ctx context.Context,
+	entries []*source.AddSourceAndPublisherDTO,
+	mode string) ([]*source.ImportResultDTO, error) {
+
+	if mode != source.ImportAllOrNothing && mode != source.ImportBestEffort {
+		return nil, ErrUnknownMode
+	}
+
+	// Сначала проверяем все записи, в базу ещё не ходим
+	results := make([]*source.ImportResultDTO, len(entries))
+	seen := map[string]bool{}
+	invalid := false
+	for i, e := range entries {
+		e.Name, e.RssUrl = strings.TrimSpace(e.Name), strings.TrimSpace(e.RssUrl)
+		e.Country, e.City = strings.TrimSpace(e.Country), strings.TrimSpace(e.City)
+		res := &source.ImportResultDTO{Entry: i + 1, RssURL: e.RssUrl, Publisher: e.Name}
+		results[i] = res
+
+		if err := validateEntry(e); err != nil {
+			res.Status, res.Error = source.ImportFailed, err.Error()
+			invalid = true
+		} else if seen[e.RssUrl] {
+			res.Status, res.Reason = source.ImportSkipped, reasonRepeated
+		}
+		seen[e.RssUrl] = true
+	}
+	if invalid && mode == source.ImportAllOrNothing {
+		skipPending(results)
+		return results, nil
+	}
+
+	tx, err := s.client.Begin(ctx)
+	if err != nil {
+		return nil, lib.HandlePgErr(err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			logger.Error("Не откатили импорт", zap.Error(err))
+		}
+	}()
+
+	for i, e := range entries {
+		res := results[i]
+		if res.Status != "" {
+			continue
+		}
+
+		if mode == source.ImportAllOrNothing {
+			if err := s.importEntry(ctx, tx, e, res); err != nil {
+				res.Status, res.Error = source.ImportFailed, err.Error()
+				rollBack(results)
+				return results, nil
+			}
+			continue
+		}
+
+		// best effort: каждая запись в своей точке сохранения,
+		// иначе первая ошибка Postgres ломает всю транзакцию
+		if err := s.importSavepoint(ctx, tx, e, res); err != nil {
+			logger.Warn("Не импортировали "+res.RssURL, zap.Error(err))
+			*res = source.ImportResultDTO{
+				Entry:     res.Entry,
+				RssURL:    res.RssURL,
+				Publisher: res.Publisher,
+				Status:    source.ImportFailed,
+				Error:     err.Error(),
+			}
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, lib.HandlePgErr(err)
+	}
+	return results, nil
+}
+
+func (s *service) importSavepoint(ctx context.Context, tx pgx.Tx, e *source.AddSourceAndPublisherDTO, res *source.ImportResultDTO) error {
+	sp, err := tx.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	if err := s.importEntry(ctx, sp, e, res); err != nil {
+		if rbErr := sp.Rollback(ctx); rbErr != nil {
+			return errors.Join(err, rbErr)
+		}
+		return err
+	}
+	return sp.Commit(ctx)
+}
+
+// importEntry сохраняет одну запись через client - транзакцию или точку сохранения
+func (s *service) importEntry(ctx context.Context, client postgres.Client, e *source.AddSourceAndPublisherDTO, res *source.ImportResultDTO) error {
+	sources, publishers := s.repositories(client)
+
+	// Дедупликация по rss_url
+	existing, err := sources.FindByURL(ctx, e.RssUrl)
+	if err == nil {
+		res.Status, res.Reason = source.ImportSkipped, reasonExists
+		res.RssID = lib.UuidToString(existing.RssID)
+		return nil
+	}
+	if !errors.Is(err, sourcesRepository.ErrNotFound) {
+		return err
+	}
+
+	p, err := publishers.FindByName(ctx, e.Name)
+	if errors.Is(err, publishersRepository.ErrNotFound) {
+		dto := &publisher.DTO{
+			Name:      e.Name,
+			Country:   e.Country,
+			City:      e.City,
+			Longitude: e.Longitude,
+			Latitude:  e.Latitude,
+		}
+		if p, err = publishers.Create(ctx, dto.ToDomain()); err != nil {
+			return err
+		}
+		res.PublisherCreated = true
+	} else if err != nil {
+		return err
+	}
+
+	created, err := sources.Create(ctx, &source.RSS{
+		RssURL:    e.RssUrl,
+		Publisher: publisher.PgDBO{PublisherID: p.PublisherID},
+	})
+	if err != nil {
+		return err
+	}
+	res.Status = source.ImportCreated
+	res.RssID = lib.UuidToString(created.RssID)
+	return nil
+}
+
+func validateEntry(e *source.AddSourceAndPublisherDTO) error {
+	var errs []error
+	if e.Name == "" {
+		errs = append(errs, errors.New("нет названия издателя"))
+	}
+	if u, err := url.Parse(e.RssUrl); e.RssUrl == "" || err != nil ||
+		(u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, fmt.Errorf("rss_url должен быть http(s) адресом: %q", e.RssUrl))
+	}
+	if e.Latitude < -90 || e.Latitude > 90 {
+		errs = append(errs, fmt.Errorf("latitude вне [-90, 90]: %v", e.Latitude))
+	}
+	if e.Longitude < -180 || e.Longitude > 180 {
+		errs = append(errs, fmt.Errorf("longitude вне [-180, 180]: %v", e.Longitude))
+	}
+	return errors.Join(errs...)
+}
+
+// skipPending - из-за ошибок проверки импорт не начинали, верные записи не применены
+func skipPending(results []*source.ImportResultDTO) {
+	for _, res := range results {
+		if res.Status == "" {
+			res.Status, res.Reason = source.ImportSkipped, reasonRolledBack
+		}
+	}
+}
+
+// rollBack - транзакцию откатили, ничего из сделанного не сохранилось
+func rollBack(results []*source.ImportResultDTO) {
+	for _, res := range results {
+		if res.Status != source.ImportCreated && res.Status != "" {
+			continue
+		}
+		*res = source.ImportResultDTO{
+			Entry:     res.Entry,
+			RssURL:    res.RssURL,
+			Publisher: res.Publisher,
+			Status:    source.ImportSkipped,
+			Reason:    reasonRolledBack,
+		}
+	}
+}
+
+func repositories(client postgres.Client) (sourcesRepository.IRepository, publishersRepository.IRepository) {
+	return sourcesRepository.New(client), publishersRepository.New(client)
+}
+
+func New(client postgres.Client) IImportService {
+	return &service{client, repositories}
+}
@@ -0,0 +1,150 @@
+package importService
+
+import (
+	"context"
+	"errors"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/publisher"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/source"
+	"strings"
+	"testing"
+)
+
+func entries() []*source.AddSourceAndPublisherDTO {
+	return []*source.AddSourceAndPublisherDTO{
+		{Name: "Медуза", Country: "Россия", RssUrl: "https://meduza.io/rss/all"},
+		{Name: "ТАСС", Country: "Россия", RssUrl: "https://tass.ru/rss/v2.xml"},
+		{Name: "BBC", Country: "Великобритания", RssUrl: "https://bbc.co.uk/rss"},
+	}
+}
+
+func statuses(results []*source.ImportResultDTO) string {
+	s := make([]string, len(results))
+	for i, r := range results {
+		s[i] = r.Status
+	}
+	return strings.Join(s, ",")
+}
+
+// Ошибка проверки в all_or_nothing - в базу не ходим вовсе
+func TestImportAllOrNothingInvalid(t *testing.T) {
+	s, st := newTestService()
+	in := entries()
+	in[1].Latitude = 91
+
+	results, err := s.ImportSources(context.Background(), in, source.ImportAllOrNothing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := statuses(results); got != "skipped,failed,skipped" {
+		t.Errorf("статусы = %s, want skipped,failed,skipped", got)
+	}
+	if results[0].Reason != reasonRolledBack || !strings.Contains(results[1].Error, "latitude") {
+		t.Errorf("итоги = %+v %+v, want откат и ошибку latitude", results[0], results[1])
+	}
+	if st.begun != 0 || len(st.sources) != 0 {
+		t.Errorf("транзакций %d, источников %d, want 0 и 0", st.begun, len(st.sources))
+	}
+}
+
+// Ошибка postgres в all_or_nothing откатывает уже созданное
+func TestImportAllOrNothingRollback(t *testing.T) {
+	s, st := newTestService()
+	st.failURLs["https://tass.ru/rss/v2.xml"] = true
+
+	results, err := s.ImportSources(context.Background(), entries(), source.ImportAllOrNothing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := statuses(results); got != "skipped,failed,skipped" {
+		t.Errorf("статусы = %s, want skipped,failed,skipped", got)
+	}
+	if results[0].RssID != "" || results[0].PublisherCreated {
+		t.Errorf("откаченная запись %+v хранит rss_id и издателя", results[0])
+	}
+	if st.committed != 0 || len(st.sources) != 0 || len(st.publishers) != 0 {
+		t.Errorf("commit %d, источников %d, издателей %d, want всё откатить",
+			st.committed, len(st.sources), len(st.publishers))
+	}
+}
+
+// best_effort откатывает только запись с ошибкой, вместе с её новым издателем
+func TestImportBestEffort(t *testing.T) {
+	s, st := newTestService()
+	st.failURLs["https://tass.ru/rss/v2.xml"] = true
+	in := entries()
+	in = append(in, &source.AddSourceAndPublisherDTO{Name: "Без адреса"})
+
+	results, err := s.ImportSources(context.Background(), in, source.ImportBestEffort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := statuses(results); got != "created,failed,created,failed" {
+		t.Errorf("статусы = %s, want created,failed,created,failed", got)
+	}
+	if results[1].PublisherCreated || results[1].Error == "" {
+		t.Errorf("запись с ошибкой = %+v, want ошибку без созданного издателя", results[1])
+	}
+	if st.committed != 1 || len(st.sources) != 2 {
+		t.Errorf("commit %d, источников %d, want 1 и 2", st.committed, len(st.sources))
+	}
+	for _, p := range st.publishers {
+		if p.Name == "ТАСС" {
+			t.Error("издатель ТАСС остался после отката точки сохранения")
+		}
+	}
+}
+
+// Знакомый издатель не создаётся заново, знакомый адрес пропускается
+func TestImportExisting(t *testing.T) {
+	s, st := newTestService()
+	st.addPublisher(publisher.PgDBO{Name: "Медуза"}, "https://meduza.io/rss/all")
+	in := append(entries(), &source.AddSourceAndPublisherDTO{Name: "Медуза", RssUrl: " https://meduza.io/rss/news "})
+
+	results, err := s.ImportSources(context.Background(), in, source.ImportAllOrNothing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := statuses(results); got != "skipped,created,created,created" {
+		t.Errorf("статусы = %s, want skipped,created,created,created", got)
+	}
+	if results[0].Reason != reasonExists || results[0].RssID == "" {
+		t.Errorf("известный адрес = %+v, want пропуск с rss_id", results[0])
+	}
+	if last := results[3]; last.PublisherCreated || last.RssURL != "https://meduza.io/rss/news" {
+		t.Errorf("новая лента Медузы = %+v, want без нового издателя и пробелов", last)
+	}
+}
+
+func TestImportUnknownMode(t *testing.T) {
+	s, _ := newTestService()
+	if _, err := s.ImportSources(context.Background(), entries(), "some"); !errors.Is(err, ErrUnknownMode) {
+		t.Errorf("ImportSources() error = %v, want ErrUnknownMode", err)
+	}
+}
+
+func TestValidateEntry(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry source.AddSourceAndPublisherDTO
+		want  []string
+	}{
+		{"верная", source.AddSourceAndPublisherDTO{Name: "A", RssUrl: "https://a.ru/rss", Latitude: -90, Longitude: 180}, nil},
+		{"без названия", source.AddSourceAndPublisherDTO{RssUrl: "https://a.ru/rss"}, []string{"названия"}},
+		{"не http", source.AddSourceAndPublisherDTO{Name: "A", RssUrl: "a.ru/rss"}, []string{"rss_url"}},
+		{"всё сразу", source.AddSourceAndPublisherDTO{Latitude: 100, Longitude: -181},
+			[]string{"названия", "rss_url", "latitude", "longitude"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateEntry(&tt.entry)
+			if (err == nil) != (tt.want == nil) {
+				t.Fatalf("validateEntry() = %v, want ошибки %v", err, tt.want)
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(err.Error(), w) {
+					t.Errorf("validateEntry() = %v, want упоминание %s", err, w)
+				}
+			}
+		})
+	}
+}
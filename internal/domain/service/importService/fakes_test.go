@@ -0,0 +1,163 @@
+package importService
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/mskKote/prospero_backend/internal/adapters/db/postgres/publishersRepository"
+	"github.com/mskKote/prospero_backend/internal/adapters/db/postgres/sourcesRepository"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/publisher"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/source"
+	"github.com/mskKote/prospero_backend/pkg/client/postgres"
+	"github.com/mskKote/prospero_backend/pkg/lib"
+)
+
+// store - источники и издатели в памяти, как их видят оба репозитория.
+// Транзакции и точки сохранения откатывают его к снимку
+type store struct {
+	sources    []*source.RSS
+	publishers []*publisher.PgDBO
+	ids        int
+	// Адреса, на которых Create падает, как на ограничении в postgres
+	failURLs map[string]bool
+	// Транзакции верхнего уровня
+	begun, committed int
+}
+
+type snapshot struct {
+	sources    []*source.RSS
+	publishers []*publisher.PgDBO
+}
+
+func (s *store) snapshot() snapshot {
+	return snapshot{
+		sources:    append([]*source.RSS(nil), s.sources...),
+		publishers: append([]*publisher.PgDBO(nil), s.publishers...),
+	}
+}
+
+func (s *store) newID() string {
+	s.ids++
+	return fmt.Sprintf("00000000-0000-0000-0000-%012d", s.ids)
+}
+
+// addPublisher заводит издателя с лентами, как будто они уже в базе
+func (s *store) addPublisher(p publisher.PgDBO, urls ...string) {
+	p.PublisherID = lib.StringToUUID(s.newID())
+	s.publishers = append(s.publishers, &p)
+	for _, url := range urls {
+		s.sources = append(s.sources, &source.RSS{RssID: lib.StringToUUID(s.newID()), RssURL: url, Publisher: p})
+	}
+}
+
+type fakeSources struct {
+	sourcesRepository.IRepository
+	*store
+}
+
+func (f fakeSources) FindByURL(_ context.Context, url string) (*source.RSS, error) {
+	for _, src := range f.sources {
+		if src.RssURL == url {
+			return src, nil
+		}
+	}
+	return nil, sourcesRepository.ErrNotFound
+}
+
+func (f fakeSources) Create(_ context.Context, src *source.RSS) (*source.RSS, error) {
+	if f.failURLs[src.RssURL] {
+		return nil, errors.New("нарушено ограничение rss_url")
+	}
+	created := *src
+	created.RssID = lib.StringToUUID(f.newID())
+	for _, p := range f.publishers {
+		if p.PublisherID == src.Publisher.PublisherID {
+			created.Publisher = *p
+		}
+	}
+	f.sources = append(f.sources, &created)
+	return &created, nil
+}
+
+func (f fakeSources) FindAllWithPublishers(_ context.Context, offset, limit int) ([]*source.RSS, error) {
+	if offset >= len(f.sources) {
+		return nil, nil
+	}
+	return f.sources[offset:min(offset+limit, len(f.sources))], nil
+}
+
+type fakePublishers struct {
+	publishersRepository.IRepository
+	*store
+}
+
+func (f fakePublishers) FindByName(_ context.Context, name string) (*publisher.PgDBO, error) {
+	for _, p := range f.publishers {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+	return nil, publishersRepository.ErrNotFound
+}
+
+func (f fakePublishers) Create(_ context.Context, p *publisher.PgDBO) (*publisher.PgDBO, error) {
+	created := *p
+	created.PublisherID = lib.StringToUUID(f.newID())
+	f.publishers = append(f.publishers, &created)
+	return &created, nil
+}
+
+// fakeClient умеет только начинать транзакции, запросы идут через фейковые репозитории
+type fakeClient struct {
+	postgres.Client
+	*store
+}
+
+func (c fakeClient) Begin(context.Context) (pgx.Tx, error) {
+	c.begun++
+	return &fakeTx{store: c.store, saved: c.snapshot(), top: true}, nil
+}
+
+type fakeTx struct {
+	pgx.Tx
+	store  *store
+	saved  snapshot
+	top    bool
+	closed bool
+}
+
+// Begin внутри транзакции - точка сохранения
+func (tx *fakeTx) Begin(context.Context) (pgx.Tx, error) {
+	return &fakeTx{store: tx.store, saved: tx.store.snapshot()}, nil
+}
+
+func (tx *fakeTx) Commit(context.Context) error {
+	if tx.closed {
+		return pgx.ErrTxClosed
+	}
+	tx.closed = true
+	if tx.top {
+		tx.store.committed++
+	}
+	return nil
+}
+
+func (tx *fakeTx) Rollback(context.Context) error {
+	if tx.closed {
+		return pgx.ErrTxClosed
+	}
+	tx.closed = true
+	tx.store.sources, tx.store.publishers = tx.saved.sources, tx.saved.publishers
+	return nil
+}
+
+func newTestService() (*service, *store) {
+	st := &store{failURLs: map[string]bool{}}
+	return &service{
+		client: fakeClient{store: st},
+		repositories: func(postgres.Client) (sourcesRepository.IRepository, publishersRepository.IRepository) {
+			return fakeSources{store: st}, fakePublishers{store: st}
+		},
+	}, st
+}
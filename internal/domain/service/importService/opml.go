@@ -0,0 +1,120 @@
+package importService
+
+import (
+	"context"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/source"
+	"github.com/mskKote/prospero_backend/pkg/opml"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// Тип outline издателя в нашей выгрузке
+const outlinePublisher = "publisher"
+
+func (s *service) ImportOPML(ctx context.Context, r io.Reader, mode string) ([]*source.ImportResultDTO, error) {
+	doc, err := opml.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*source.AddSourceAndPublisherDTO
+	var walk func(outlines []*opml.Outline, parent source.AddSourceAndPublisherDTO)
+	walk = func(outlines []*opml.Outline, parent source.AddSourceAndPublisherDTO) {
+		for _, o := range outlines {
+			// Папки передают вложенным лентам страну, издатели - ещё и себя
+			inherited := parent
+			if o.Country != "" {
+				inherited.Country = o.Country
+			}
+			if o.Type == outlinePublisher {
+				inherited.Name = o.Name()
+				inherited.City = o.City
+				inherited.Latitude, _ = strconv.ParseFloat(o.Latitude, 64)
+				inherited.Longitude, _ = strconv.ParseFloat(o.Longitude, 64)
+			}
+
+			if o.IsFeed() {
+				e := inherited
+				e.RssUrl = o.XMLURL
+				// Лента из обычной читалки - сама себе издатель
+				if e.Name == "" {
+					e.Name = o.Name()
+				}
+				entries = append(entries, &e)
+			}
+			walk(o.Outlines, inherited)
+		}
+	}
+	walk(doc.Body.Outlines, source.AddSourceAndPublisherDTO{})
+
+	return s.ImportSources(ctx, entries, mode)
+}
+
+func (s *service) ExportOPML(ctx context.Context, w io.Writer) error {
+	sourcesREPO, _ := s.repositories(s.client)
+
+	// страна -> издатель -> ленты
+	countries := map[string]map[string]*opml.Outline{}
+	const page = 100
+	for offset := 0; ; offset += page {
+		sources, err := sourcesREPO.FindAllWithPublishers(ctx, offset, page)
+		if err != nil {
+			return err
+		}
+		for _, src := range sources {
+			p := src.Publisher
+			publishers, ok := countries[p.Country]
+			if !ok {
+				publishers = map[string]*opml.Outline{}
+				countries[p.Country] = publishers
+			}
+			po, ok := publishers[p.Name]
+			if !ok {
+				po = &opml.Outline{
+					Text:      p.Name,
+					Type:      outlinePublisher,
+					Country:   p.Country,
+					City:      p.City,
+					Latitude:  strconv.FormatFloat(p.Point.P.Y, 'f', -1, 64),
+					Longitude: strconv.FormatFloat(p.Point.P.X, 'f', -1, 64),
+				}
+				publishers[p.Name] = po
+			}
+
+			title := src.FeedTitle.String
+			if title == "" {
+				title = p.Name
+			}
+			po.Outlines = append(po.Outlines, &opml.Outline{
+				Text:     title,
+				Title:    title,
+				Type:     opml.TypeRSS,
+				XMLURL:   src.RssURL,
+				Language: src.FeedLanguage.String,
+			})
+		}
+		if len(sources) < page {
+			break
+		}
+	}
+
+	doc := opml.New("Prospero sources")
+	for _, country := range sortedKeys(countries) {
+		co := &opml.Outline{Text: country, Country: country}
+		for _, name := range sortedKeys(countries[country]) {
+			co.Outlines = append(co.Outlines, countries[country][name])
+		}
+		doc.Body.Outlines = append(doc.Body.Outlines, co)
+	}
+	return doc.Write(w)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,127 @@
+package importService
+
+import (
+	"bytes"
+	"context"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/publisher"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/source"
+	"github.com/mskKote/prospero_backend/pkg/opml"
+	"strings"
+	"testing"
+)
+
+const testOPML = `<?xml version="1.0" encoding="UTF-8"?>
+<opml version="2.0"><head><title>Sources</title></head><body>
+  <outline text="Россия" country="Россия">
+    <outline text="Медуза" type="publisher" city="Рига" latitude="56.95" longitude="24.1">
+      <outline text="Главное" type="rss" xmlUrl="https://meduza.io/rss/all"/>
+      <outline text="Новости" type="rss" xmlUrl="https://meduza.io/rss/news"/>
+    </outline>
+    <outline text="ТАСС" type="publisher" city="Москва" latitude="55.75" longitude="37.62">
+      <outline text="Лента" type="rss" xmlUrl=" https://tass.ru/rss/v2.xml "/>
+    </outline>
+  </outline>
+  <outline title="Blog" type="rss" xmlUrl="https://blog.example.com/feed"/>
+  <outline text="Повтор" type="rss" xmlUrl="https://tass.ru/rss/v2.xml"/>
+  <outline text="Папка без лент"/>
+</body></opml>`
+
+func TestImportOPML(t *testing.T) {
+	s, st := newTestService()
+	st.addPublisher(publisher.PgDBO{Name: "Медуза", Country: "Россия"}, "https://meduza.io/rss/all")
+
+	results, err := s.ImportOPML(context.Background(), strings.NewReader(testOPML), source.ImportBestEffort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []source.ImportResultDTO{
+		{Entry: 1, RssURL: "https://meduza.io/rss/all", Publisher: "Медуза", Status: source.ImportSkipped, Reason: reasonExists},
+		{Entry: 2, RssURL: "https://meduza.io/rss/news", Publisher: "Медуза", Status: source.ImportCreated},
+		{Entry: 3, RssURL: "https://tass.ru/rss/v2.xml", Publisher: "ТАСС", Status: source.ImportCreated, PublisherCreated: true},
+		// Лента обычной читалки - сама себе издатель
+		{Entry: 4, RssURL: "https://blog.example.com/feed", Publisher: "Blog", Status: source.ImportCreated, PublisherCreated: true},
+		// Тот же адрес в файле второй раз
+		{Entry: 5, RssURL: "https://tass.ru/rss/v2.xml", Publisher: "Повтор", Status: source.ImportSkipped, Reason: reasonRepeated},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("итогов %d, want %d: %+v", len(results), len(want), results)
+	}
+	for i, r := range results {
+		got := *r
+		got.RssID = ""
+		if got != want[i] {
+			t.Errorf("запись %d = %+v, want %+v", i+1, got, want[i])
+		}
+	}
+	if results[0].RssID == "" {
+		t.Error("у пропущенной нет rss_id существующего источника")
+	}
+
+	// Издатель из OPML получил страну папки и свои координаты
+	tass := st.publishers[len(st.publishers)-2]
+	if tass.Name != "ТАСС" || tass.Country != "Россия" || tass.City != "Москва" ||
+		tass.Point.P != (pgtype.Vec2{X: 37.62, Y: 55.75}) {
+		t.Errorf("издатель = %+v, want ТАСС, Россия, Москва с координатами", tass)
+	}
+	if len(st.sources) != 4 {
+		t.Errorf("источников %d, want 4", len(st.sources))
+	}
+}
+
+func TestImportOPMLNotOPML(t *testing.T) {
+	s, _ := newTestService()
+	if _, err := s.ImportOPML(context.Background(), strings.NewReader(`<rss version="2.0"/>`), source.ImportBestEffort); err == nil {
+		t.Error("ImportOPML(rss) без ошибки")
+	}
+}
+
+// Выгрузку можно загрузить обратно и получить тех же издателей
+func TestExportOPML(t *testing.T) {
+	s, st := newTestService()
+	meduza := publisher.PgDBO{Name: "Медуза", Country: "Россия", City: "Рига",
+		Point: pgtype.Point{P: pgtype.Vec2{X: 24.1, Y: 56.95}, Valid: true}}
+	st.addPublisher(publisher.PgDBO{Name: "BBC", Country: "Великобритания", City: "Лондон"}, "https://bbc.co.uk/rss")
+	st.addPublisher(meduza, "https://meduza.io/rss/all", "https://meduza.io/rss/news")
+	st.sources[1].FeedTitle = pgtype.Text{String: "Медуза: главное", Valid: true}
+
+	var buf bytes.Buffer
+	if err := s.ExportOPML(context.Background(), &buf); err != nil {
+		t.Fatal(err)
+	}
+	doc, err := opml.Parse(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Страны по алфавиту, в них издатели, в издателях ленты
+	countries := doc.Body.Outlines
+	if len(countries) != 2 || countries[0].Text != "Великобритания" || countries[1].Text != "Россия" {
+		t.Fatalf("страны = %+v, want Великобритания, Россия", countries)
+	}
+	p := countries[1].Outlines[0]
+	if p.Text != "Медуза" || p.Type != outlinePublisher || p.Latitude != "56.95" || p.Longitude != "24.1" {
+		t.Errorf("издатель = %+v, want Медуза с координатами", p)
+	}
+	if len(p.Outlines) != 2 || p.Outlines[0].Text != "Медуза: главное" || p.Outlines[1].Text != "Медуза" {
+		t.Errorf("ленты = %+v, want название ленты, а без него - издателя", p.Outlines)
+	}
+
+	imported, importedStore := newTestService()
+	results, err := imported.ImportOPML(context.Background(), bytes.NewReader(buf.Bytes()), source.ImportAllOrNothing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range results {
+		if r.Status != source.ImportCreated {
+			t.Errorf("повторный импорт %+v, want created", r)
+		}
+	}
+	got, err := fakePublishers{store: importedStore}.FindByName(context.Background(), "Медуза")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Country != meduza.Country || got.City != meduza.City || got.Point.P != meduza.Point.P {
+		t.Errorf("издатель после импорта = %+v, want %+v", got, meduza)
+	}
+}
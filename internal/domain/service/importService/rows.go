@@ -0,0 +1,151 @@
+package importService
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/source"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Колонки CSV, порядок любой
+var csvColumns = []string{"name", "country", "city", "longitude", "latitude", "rss_url"}
+
+// row - запись файла или ошибка её разбора
+type row struct {
+	entry *source.AddSourceAndPublisherDTO
+	err   error
+}
+
+func (s *service) ImportRows(ctx context.Context, r io.Reader, format, mode string) ([]*source.ImportResultDTO, error) {
+	var rows []row
+	var err error
+	switch format {
+	case FormatCSV:
+		rows, err = readCSV(r)
+	case FormatJSONL:
+		rows, err = readJSONL(r)
+	default:
+		return nil, fmt.Errorf("формат %q: нужен csv или jsonl", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Неразобранная строка не пройдёт проверку, как и неверные значения
+	entries := make([]*source.AddSourceAndPublisherDTO, len(rows))
+	for i, rw := range rows {
+		entries[i] = rw.entry
+		if rw.err != nil {
+			entries[i] = &source.AddSourceAndPublisherDTO{}
+		}
+	}
+	results, err := s.ImportSources(ctx, entries, mode)
+	if err != nil {
+		return nil, err
+	}
+	for i, rw := range rows {
+		if rw.err != nil {
+			results[i].Status, results[i].Reason, results[i].Error = source.ImportFailed, "", rw.err.Error()
+		}
+	}
+	return results, nil
+}
+
+func readCSV(r io.Reader) ([]row, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	// Число колонок проверяем сами, построчно
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("нет заголовка CSV: %w", err)
+	}
+	// Регистр не важен: rss_Url как в AddSourceAndPublisherDTO тоже подходит
+	index := map[string]int{}
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	for _, column := range csvColumns {
+		if _, ok := index[column]; !ok {
+			return nil, fmt.Errorf("в заголовке CSV нет колонки %s", column)
+		}
+	}
+
+	var rows []row
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				rows = append(rows, row{err: err})
+				continue
+			}
+			return nil, err
+		}
+		rows = append(rows, csvRow(record, index))
+	}
+}
+
+func csvRow(record []string, index map[string]int) row {
+	get := func(column string) string {
+		if i := index[column]; i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	float := func(column string) (float64, error) {
+		value := get(column)
+		if value == "" {
+			return 0, nil
+		}
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%s: не число %q", column, value)
+		}
+		return f, nil
+	}
+
+	longitude, lonErr := float("longitude")
+	latitude, latErr := float("latitude")
+	if err := errors.Join(lonErr, latErr); err != nil {
+		return row{err: err}
+	}
+	return row{entry: &source.AddSourceAndPublisherDTO{
+		Name:      get("name"),
+		Country:   get("country"),
+		City:      get("city"),
+		Longitude: longitude,
+		Latitude:  latitude,
+		RssUrl:    get("rss_url"),
+	}}
+}
+
+func readJSONL(r io.Reader) ([]row, error) {
+	var rows []row
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		// rss_url и rss_Url: json сравнивает имена полей без учёта регистра
+		e := &source.AddSourceAndPublisherDTO{}
+		if err := json.Unmarshal([]byte(line), e); err != nil {
+			rows = append(rows, row{err: err})
+			continue
+		}
+		rows = append(rows, row{entry: e})
+	}
+	return rows, scanner.Err()
+}
@@ -0,0 +1,103 @@
+package importService
+
+import (
+	"context"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/source"
+	"strings"
+	"testing"
+)
+
+func TestReadCSV(t *testing.T) {
+	// BOM от Excel, колонки в своём порядке, rss_Url как в DTO
+	csv := "\ufeffrss_Url, Name,country,city,latitude,longitude\n" +
+		"https://meduza.io/rss/all, Медуза ,Россия,Рига,56.95,24.1\n" +
+		"https://tass.ru/rss/v2.xml,ТАСС,Россия,Москва,,\n" +
+		"https://bbc.co.uk/rss,BBC,Великобритания,Лондон,север,\n" +
+		"https://short.ru/rss,Короткая\n" +
+		"https://q.ru/rss,\"Кавычка \"не там,Россия,,,\n"
+
+	rows, err := readCSV(strings.NewReader(csv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 5 {
+		t.Fatalf("строк %d, want 5", len(rows))
+	}
+	want := source.AddSourceAndPublisherDTO{
+		Name: "Медуза", Country: "Россия", City: "Рига", Latitude: 56.95, Longitude: 24.1, RssUrl: "https://meduza.io/rss/all",
+	}
+	if rows[0].err != nil || *rows[0].entry != want {
+		t.Errorf("строка 1 = %+v, %v, want %+v", rows[0].entry, rows[0].err, want)
+	}
+	if rows[1].err != nil || rows[1].entry.Latitude != 0 {
+		t.Errorf("строка 2 = %+v, %v, want пустые координаты как 0", rows[1].entry, rows[1].err)
+	}
+	if rows[2].err == nil || !strings.Contains(rows[2].err.Error(), "latitude") {
+		t.Errorf("строка 3 error = %v, want не число в latitude", rows[2].err)
+	}
+	// Недостающие колонки пустые - запись не пройдёт проверку, но не ломает файл
+	if rows[3].err != nil || rows[3].entry.Country != "" {
+		t.Errorf("строка 4 = %+v, %v, want пустую страну", rows[3].entry, rows[3].err)
+	}
+	if rows[4].err == nil {
+		t.Error("строка 5 с кривыми кавычками разобрана без ошибки")
+	}
+}
+
+func TestReadCSVHeader(t *testing.T) {
+	for _, csv := range []string{"", "name,country,city,longitude,latitude\n"} {
+		if _, err := readCSV(strings.NewReader(csv)); err == nil {
+			t.Errorf("readCSV(%q) без ошибки", csv)
+		}
+	}
+}
+
+func TestReadJSONL(t *testing.T) {
+	jsonl := `{"name":"Медуза","rss_url":"https://meduza.io/rss/all","latitude":56.95}
+
+{"name":"ТАСС","rss_Url":"https://tass.ru/rss/v2.xml"}
+{"name":"сломано"
+{"name":"BBC","latitude":"north"}
+`
+	rows, err := readJSONL(strings.NewReader(jsonl))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 4 {
+		t.Fatalf("строк %d, want 4: пустые пропускаются", len(rows))
+	}
+	for i, url := range []string{"https://meduza.io/rss/all", "https://tass.ru/rss/v2.xml"} {
+		if rows[i].err != nil || rows[i].entry.RssUrl != url {
+			t.Errorf("строка %d = %+v, %v, want %s", i+1, rows[i].entry, rows[i].err, url)
+		}
+	}
+	if rows[2].err == nil || rows[3].err == nil {
+		t.Errorf("ошибки строк 3 и 4 = %v, %v, want обе", rows[2].err, rows[3].err)
+	}
+}
+
+// Номера строк в итогах совпадают с файлом, ошибки разбора видны в итогах
+func TestImportRows(t *testing.T) {
+	s, st := newTestService()
+	jsonl := `{"name":"Медуза","rss_url":"https://meduza.io/rss/all"}
+{"name":"сломано"
+{"name":"ТАСС","rss_url":"https://tass.ru/rss/v2.xml"}`
+
+	results, err := s.ImportRows(context.Background(), strings.NewReader(jsonl), FormatJSONL, source.ImportBestEffort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := statuses(results); got != "created,failed,created" {
+		t.Errorf("статусы = %s, want created,failed,created", got)
+	}
+	if results[1].Entry != 2 || results[1].Error == "" {
+		t.Errorf("строка 2 = %+v, want ошибку разбора", results[1])
+	}
+	if len(st.sources) != 2 {
+		t.Errorf("источников %d, want 2", len(st.sources))
+	}
+
+	if _, err := s.ImportRows(context.Background(), strings.NewReader(""), "xlsx", source.ImportBestEffort); err == nil {
+		t.Error("ImportRows(xlsx) без ошибки")
+	}
+}
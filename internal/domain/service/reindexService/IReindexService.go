@@ -0,0 +1,13 @@
+package reindexService
+
+import (
+	"context"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/reindex"
+)
+
+type IReindexService interface {
+	// Reindex пересобирает индексы статей, категорий и людей из postgres.
+	// Продолжает прерванную переиндексацию, restart начинает заново.
+	// onProgress получает состояние после каждой пачки
+	Reindex(ctx context.Context, restart bool, onProgress func(reindex.Run)) (*reindex.Run, error)
+}
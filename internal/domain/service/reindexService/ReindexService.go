@@ -0,0 +1,194 @@
+package reindexService
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/mskKote/prospero_backend/internal/adapters/db/elastic/v8/articlesSearchRepository"
+	"github.com/mskKote/prospero_backend/internal/adapters/db/postgres/articlesRepository"
+	"github.com/mskKote/prospero_backend/internal/adapters/db/postgres/reindexRunsRepository"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/article"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/reindex"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/retention"
+	"github.com/mskKote/prospero_backend/pkg/config"
+	"github.com/mskKote/prospero_backend/pkg/logging"
+	"go.uber.org/zap"
+	"time"
+)
+
+var (
+	logger = logging.GetLogger().With(zap.String("prefix", "[REINDEX]"))
+	cfg    = config.GetConfig()
+
+	ErrBulkFailed = errors.New("пачка _bulk не записана")
+)
+
+type service struct {
+	articles articlesRepository.IRepository
+	runs     reindexRunsRepository.IRepository
+	elastic  articlesSearchRepository.IRepository
+	// Устаревшие статьи, которые оставил archive, в индексы не возвращаем
+	retention *retention.Policy
+}
+
+func New(
+	articles articlesRepository.IRepository,
+	runs reindexRunsRepository.IRepository,
+	elastic articlesSearchRepository.IRepository) IReindexService {
+	return &service{articles, runs, elastic, retention.FromConfig(cfg)}
+}
+
+func (s *service) Reindex(ctx context.Context, restart bool, onProgress func(reindex.Run)) (*reindex.Run, error) {
+	run, err := s.start(ctx, restart)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.copyAll(ctx, run, onProgress)
+	now := time.Now()
+	run.FinishedAt = &now
+	if err != nil {
+		run.Status, run.Error = reindex.RunFailed, err.Error()
+	} else {
+		run.Status, run.Error = reindex.RunDone, ""
+	}
+	// Итог пишем и после отмены ctx, иначе потеряем место, с которого продолжать
+	if saveErr := s.runs.SaveProgress(context.WithoutCancel(ctx), run); saveErr != nil {
+		logger.Error("Не записали итог переиндексации", zap.Error(saveErr))
+	}
+
+	logger.Info(fmt.Sprintf("Переиндексация %s: %s, статей %d из %d, ошибок %d",
+		run.ID, run.Status, run.Done, run.Total, run.Failed))
+	return run, err
+}
+
+// start продолжает последнюю незавершённую переиндексацию или начинает новую
+func (s *service) start(ctx context.Context, restart bool) (*reindex.Run, error) {
+	if !restart {
+		run, err := s.runs.FindUnfinished(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if run != nil {
+			// Статьи могли добавиться, пересчитываем оставшиеся
+			left, err := s.articles.CountAfter(ctx, run.LastArticleID)
+			if err != nil {
+				return nil, err
+			}
+			run.Total = run.Done + left
+			run.Status, run.Error = reindex.RunRunning, ""
+			logger.Info(fmt.Sprintf("Продолжаем переиндексацию %s после статьи %q: готово %d из %d",
+				run.ID, run.LastArticleID, run.Done, run.Total))
+			return run, s.runs.SaveProgress(ctx, run)
+		}
+	}
+
+	total, err := s.articles.CountAfter(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	run := &reindex.Run{Status: reindex.RunRunning, StartedAt: time.Now(), Total: total}
+	if err := s.runs.CreateRun(ctx, run); err != nil {
+		return nil, err
+	}
+	logger.Info(fmt.Sprintf("Начали переиндексацию %s: статей %d", run.ID, total))
+	return run, nil
+}
+
+// copyAll пишет статьи пачками по bulk_size и после каждой пачки
+// запоминает последнюю статью
+func (s *service) copyAll(ctx context.Context, run *reindex.Run, onProgress func(reindex.Run)) error {
+	size := max(cfg.BulkSize, 1)
+	for {
+		articles, err := s.articles.FindAfter(ctx, run.LastArticleID, size)
+		if err != nil {
+			return err
+		}
+		if len(articles) == 0 {
+			return nil
+		}
+
+		// Место продолжения сдвигаем, только когда пачка записана
+		done, last := int64(len(articles)), articles[len(articles)-1].ID
+		if articles = s.fresh(articles); len(articles) > 0 {
+			failed, err := s.write(ctx, articles)
+			if err != nil {
+				return err
+			}
+			run.Failed += int64(failed)
+		}
+		run.Done += done
+		run.LastArticleID = last
+		if err := s.runs.SaveProgress(ctx, run); err != nil {
+			return err
+		}
+		if onProgress != nil {
+			onProgress(*run)
+		}
+	}
+}
+
+// fresh - статьи, срок хранения которых не истёк
+func (s *service) fresh(articles []*article.EsArticleDBO) []*article.EsArticleDBO {
+	now := time.Now()
+	res := articles[:0]
+	for _, a := range articles {
+		if !s.retention.Expired(a.Address.Country, a.Publisher.Name, a.DatePublished, now) {
+			res = append(res, a)
+		}
+	}
+	return res
+}
+
+// write - одна пачка _bulk: статьи и подсказки по их категориям и людям.
+// Возвращает, сколько статей не записалось
+func (s *service) write(ctx context.Context, articles []*article.EsArticleDBO) (int, error) {
+	docs := make([]articlesSearchRepository.BulkDocument, 0, len(articles))
+	for _, a := range articles {
+		docs = append(docs, articlesSearchRepository.BulkDocument{
+			Index:  articlesSearchRepository.ArticleIndexFor(a.DatePublished),
+			ID:     a.ID,
+			Doc:    a,
+			Upsert: true,
+		})
+	}
+	categories, people := map[string]bool{}, map[string]bool{}
+	for _, a := range articles {
+		for _, category := range a.Categories {
+			if !categories[category] {
+				categories[category] = true
+				docs = append(docs, articlesSearchRepository.BulkDocument{
+					Index: articlesSearchRepository.CategoryWriteIndex,
+					ID:    article.CategoryID(category),
+					Doc:   &article.CategoryES{Name: category},
+				})
+			}
+		}
+		for _, person := range a.People {
+			if !people[person.FullName] {
+				people[person.FullName] = true
+				docs = append(docs, articlesSearchRepository.BulkDocument{
+					Index: articlesSearchRepository.PeopleWriteIndex,
+					ID:    article.PersonID(person.FullName),
+					Doc:   &article.PersonES{FullName: person.FullName},
+				})
+			}
+		}
+	}
+
+	results, err := s.elastic.Bulk(ctx, docs)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrBulkFailed, err)
+	}
+	failed := 0
+	for i, res := range results {
+		if res.Err == nil {
+			continue
+		}
+		logger.Error("Документ не записан в "+docs[i].Index, zap.String("id", docs[i].ID), zap.Error(res.Err))
+		if i < len(articles) {
+			failed++
+		}
+	}
+	return failed, nil
+}
@@ -0,0 +1,238 @@
+package reindexService
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/mskKote/prospero_backend/internal/adapters/db/elastic/v8/articlesSearchRepository"
+	"github.com/mskKote/prospero_backend/internal/adapters/db/postgres/articlesRepository"
+	"github.com/mskKote/prospero_backend/internal/adapters/db/postgres/reindexRunsRepository"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/article"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/reindex"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/retention"
+	"testing"
+	"time"
+)
+
+// fakeArticles - статьи postgres по возрастанию ID
+type fakeArticles struct {
+	articlesRepository.IRepository
+	list []*article.EsArticleDBO
+}
+
+func (f *fakeArticles) FindAfter(ctx context.Context, afterID string, limit int) ([]*article.EsArticleDBO, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var found []*article.EsArticleDBO
+	for _, a := range f.list {
+		if a.ID > afterID && len(found) < limit {
+			found = append(found, a)
+		}
+	}
+	return found, nil
+}
+
+func (f *fakeArticles) CountAfter(_ context.Context, afterID string) (int64, error) {
+	var n int64
+	for _, a := range f.list {
+		if a.ID > afterID {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// fakeRuns - reindex_runs: последняя запись и все сохранённые состояния
+type fakeRuns struct {
+	reindexRunsRepository.IRepository
+	last  *reindex.Run
+	saved []reindex.Run
+}
+
+func (f *fakeRuns) CreateRun(_ context.Context, run *reindex.Run) error {
+	run.ID = fmt.Sprintf("run-%d", len(f.saved)+1)
+	f.last = run
+	f.saved = append(f.saved, *run)
+	return nil
+}
+
+func (f *fakeRuns) SaveProgress(ctx context.Context, run *reindex.Run) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	copied := *run
+	f.last = &copied
+	f.saved = append(f.saved, copied)
+	return nil
+}
+
+func (f *fakeRuns) FindUnfinished(context.Context) (*reindex.Run, error) {
+	if f.last == nil || f.last.Finished() {
+		return nil, nil
+	}
+	copied := *f.last
+	return &copied, nil
+}
+
+// fakeElastic запоминает пачки. Пачка с номером failBatch падает целиком,
+// документы из failIDs не записываются
+type fakeElastic struct {
+	articlesSearchRepository.IRepository
+	failBatch int
+	failIDs   map[string]bool
+	batches   [][]articlesSearchRepository.BulkDocument
+}
+
+func (f *fakeElastic) Bulk(_ context.Context, docs []articlesSearchRepository.BulkDocument) ([]articlesSearchRepository.BulkItemResult, error) {
+	f.batches = append(f.batches, docs)
+	if len(f.batches) == f.failBatch {
+		return nil, errors.New("connection refused")
+	}
+	results := make([]articlesSearchRepository.BulkItemResult, len(docs))
+	for i, d := range docs {
+		if f.failIDs[d.ID] {
+			results[i].Err = errors.New("mapper_parsing_exception")
+		}
+	}
+	return results, nil
+}
+
+func testArticles(n int) []*article.EsArticleDBO {
+	published := time.Now().Add(-time.Hour)
+	list := make([]*article.EsArticleDBO, n)
+	for i := range list {
+		list[i] = &article.EsArticleDBO{
+			ID:            fmt.Sprintf("a%02d", i+1),
+			DatePublished: &published,
+			Categories:    []string{"Политика"},
+			People:        []article.PersonES{{FullName: fmt.Sprintf("Человек %d", i%2)}},
+		}
+	}
+	return list
+}
+
+// newTestService - сервис, который хранит статьи вечно, если не задана policy
+func newTestService(articles *fakeArticles, runs *fakeRuns, es *fakeElastic, policy ...*retention.Policy) IReindexService {
+	s := &service{articles, runs, es, &retention.Policy{Action: retention.ActionDelete}}
+	if len(policy) > 0 {
+		s.retention = policy[0]
+	}
+	return s
+}
+
+func withBulkSize(t *testing.T, size int) {
+	t.Helper()
+	old := cfg.BulkSize
+	t.Cleanup(func() { cfg.BulkSize = old })
+	cfg.BulkSize = size
+}
+
+func TestReindex(t *testing.T) {
+	withBulkSize(t, 2)
+	es := &fakeElastic{failIDs: map[string]bool{"a03": true}}
+	runs := &fakeRuns{}
+	s := newTestService(&fakeArticles{list: testArticles(5)}, runs, es)
+
+	var progress []int64
+	run, err := s.Reindex(context.Background(), false, func(r reindex.Run) { progress = append(progress, r.Done) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.Status != reindex.RunDone || run.Total != 5 || run.Done != 5 || run.Failed != 1 || run.LastArticleID != "a05" {
+		t.Errorf("Reindex() = %+v", run)
+	}
+	if fmt.Sprint(progress) != "[2 4 5]" {
+		t.Errorf("прогресс %v, want [2 4 5]", progress)
+	}
+
+	// Пачка: статьи в партиции по дате, затем подсказки без повторов
+	var got []string
+	for _, d := range es.batches[0] {
+		got = append(got, d.Index+" "+d.ID)
+	}
+	want := fmt.Sprint([]string{
+		articlesSearchRepository.ArticleIndexFor(es.batches[0][0].Doc.(*article.EsArticleDBO).DatePublished) + " a01",
+		articlesSearchRepository.ArticleIndexFor(es.batches[0][1].Doc.(*article.EsArticleDBO).DatePublished) + " a02",
+		articlesSearchRepository.CategoryWriteIndex + " " + article.CategoryID("Политика"),
+		articlesSearchRepository.PeopleWriteIndex + " " + article.PersonID("Человек 0"),
+		articlesSearchRepository.PeopleWriteIndex + " " + article.PersonID("Человек 1"),
+	})
+	if fmt.Sprint(got) != want {
+		t.Errorf("первая пачка %v\nwant %s", got, want)
+	}
+}
+
+// Упавшая пачка оставляет место продолжения, следующий запуск идёт с него
+func TestReindexResume(t *testing.T) {
+	withBulkSize(t, 2)
+	articles := &fakeArticles{list: testArticles(5)}
+	runs := &fakeRuns{}
+
+	run, err := newTestService(articles, runs, &fakeElastic{failBatch: 2}).Reindex(context.Background(), false, nil)
+	if !errors.Is(err, ErrBulkFailed) {
+		t.Fatalf("Reindex() error = %v, want ErrBulkFailed", err)
+	}
+	if run.Status != reindex.RunFailed || run.Done != 2 || run.LastArticleID != "a02" {
+		t.Errorf("Reindex() = %+v, want failed после a02", run)
+	}
+
+	// Пока стояли, добавилась статья
+	articles.list = append(articles.list, &article.EsArticleDBO{ID: "a06"})
+	es := &fakeElastic{}
+	run, err = newTestService(articles, runs, es).Reindex(context.Background(), false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.ID != "run-1" || run.Status != reindex.RunDone || run.Done != 6 || run.Total != 6 {
+		t.Errorf("продолжение = %+v, want run-1 done 6 из 6", run)
+	}
+	if first := es.batches[0][0].ID; first != "a03" {
+		t.Errorf("продолжили со статьи %s, want a03", first)
+	}
+
+	// restart начинает заново, даже если есть незавершённая
+	runs.last.Status = reindex.RunFailed
+	run, err = newTestService(articles, runs, &fakeElastic{}).Reindex(context.Background(), true, nil)
+	if err != nil || run.ID == "run-1" || run.Done != 6 {
+		t.Errorf("restart = %+v, %v, want новую переиндексацию", run, err)
+	}
+}
+
+// Итог записывается и после отмены ctx
+func TestReindexCancelled(t *testing.T) {
+	withBulkSize(t, 2)
+	ctx, cancel := context.WithCancel(context.Background())
+	runs := &fakeRuns{}
+	s := newTestService(&fakeArticles{list: testArticles(5)}, runs, &fakeElastic{})
+
+	_, err := s.Reindex(ctx, false, func(reindex.Run) { cancel() })
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Reindex() error = %v, want context.Canceled", err)
+	}
+	if runs.last.Status != reindex.RunFailed || runs.last.LastArticleID != "a02" {
+		t.Errorf("записано %+v, want failed после a02", runs.last)
+	}
+}
+
+// Статьи старше срока хранения считаются пройденными, но в ES не пишутся
+func TestReindexExpired(t *testing.T) {
+	withBulkSize(t, 2)
+	list := testArticles(4)
+	old := time.Now().AddDate(0, 0, -31)
+	list[0].DatePublished, list[1].DatePublished, list[3].DatePublished = &old, &old, &old
+	es := &fakeElastic{}
+	policy := &retention.Policy{Action: retention.ActionArchive, DefaultDays: 30}
+
+	run, err := newTestService(&fakeArticles{list: list}, &fakeRuns{}, es, policy).Reindex(context.Background(), false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.Done != 4 || run.LastArticleID != "a04" {
+		t.Errorf("Reindex() = %+v, want пройдены все 4", run)
+	}
+	// Первая пачка целиком устарела - в ES не ходим
+	if len(es.batches) != 1 || es.batches[0][0].ID != "a03" {
+		t.Errorf("пачки %v, want одна с a03", es.batches)
+	}
+}
@@ -0,0 +1,21 @@
+package retentionService
+
+import (
+	"context"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/retention"
+)
+
+type IRetentionService interface {
+	// Run удаляет статьи старше сроков хранения из ES и, при action=delete, из postgres,
+	// затем удаляет или архивирует партиции, целиком вышедшие за срок.
+	// Одновременно идёт только одна очистка - иначе ErrAlreadyRunning
+	Run(ctx context.Context, trigger string) (*retention.Run, error)
+
+	// Policy - сроки хранения из app.yml
+	Policy() *retention.Policy
+
+	// FindRuns - история очисток, свежие первыми
+	FindRuns(ctx context.Context, page, pageSize int) ([]*retention.Run, error)
+
+	CountRuns(ctx context.Context) (int64, error)
+}
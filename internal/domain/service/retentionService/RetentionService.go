@@ -0,0 +1,136 @@
+package retentionService
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/mskKote/prospero_backend/internal/adapters/db/elastic/v8/articlesSearchRepository"
+	"github.com/mskKote/prospero_backend/internal/adapters/db/postgres/articlesRepository"
+	"github.com/mskKote/prospero_backend/internal/adapters/db/postgres/retentionRunsRepository"
+	customMetrics "github.com/mskKote/prospero_backend/internal/adapters/metrics"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/retention"
+	"github.com/mskKote/prospero_backend/pkg/config"
+	"github.com/mskKote/prospero_backend/pkg/lib"
+	"github.com/mskKote/prospero_backend/pkg/logging"
+	"github.com/mskKote/prospero_backend/pkg/metrics"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+var (
+	logger = logging.GetLogger().With(zap.String("prefix", "[RETENTION]"))
+	cfg    = config.GetConfig()
+
+	ErrAlreadyRunning = errors.New("очистка уже идёт")
+)
+
+type service struct {
+	articles articlesRepository.IRepository
+	elastic  articlesSearchRepository.IRepository
+	runs     retentionRunsRepository.IRepository
+	policy   *retention.Policy
+
+	mu sync.Mutex
+}
+
+func New(
+	articles articlesRepository.IRepository,
+	elastic articlesSearchRepository.IRepository,
+	runs retentionRunsRepository.IRepository) IRetentionService {
+	return &service{articles: articles, elastic: elastic, runs: runs, policy: retention.FromConfig(cfg)}
+}
+
+func (s *service) Policy() *retention.Policy {
+	return s.policy
+}
+
+func (s *service) Run(ctx context.Context, trigger string) (*retention.Run, error) {
+	if err := s.policy.Validate(); err != nil {
+		return nil, err
+	}
+	if !s.mu.TryLock() {
+		return nil, ErrAlreadyRunning
+	}
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	run := &retention.Run{
+		Trigger:   trigger,
+		Status:    retention.RunRunning,
+		Action:    s.policy.Action,
+		StartedAt: now,
+		Scopes:    []retention.Purged{},
+		Indices:   []string{},
+	}
+	if err := s.runs.CreateRun(ctx, run); err != nil {
+		return nil, err
+	}
+
+	err := s.purge(ctx, run, now)
+	run.FinishedAt = lib.PointerFrom(time.Now())
+	if err != nil {
+		run.Status, run.Error = retention.RunFailed, err.Error()
+	} else {
+		run.Status = retention.RunDone
+	}
+	if err := s.runs.FinishRun(context.WithoutCancel(ctx), run); err != nil {
+		logger.Error("Не записали итог очистки "+run.ID, zap.Error(err))
+	}
+
+	metrics.AddCounterVecMetric(customMetrics.MetricRetentionPurgedName, float64(run.Documents), "documents")
+	metrics.AddCounterVecMetric(customMetrics.MetricRetentionPurgedName, float64(run.Articles), "articles")
+	metrics.AddCounterVecMetric(customMetrics.MetricRetentionPurgedName, float64(len(run.Indices)), "indices")
+	logger.Info(fmt.Sprintf("Очистка %s: %s, документов %d, статей %d, партиций %d (документов в них %d)",
+		run.ID, run.Status, run.Documents, run.Articles, len(run.Indices), run.IndexDocs))
+	return run, err
+}
+
+// purge - сначала статьи по правилам, потом партиции целиком:
+// партиция уходит, только когда в ней не осталось статей ни одного правила
+func (s *service) purge(ctx context.Context, run *retention.Run, now time.Time) error {
+	for _, scope := range s.policy.Scopes(now) {
+		purged := retention.Purged{Scope: scope.Name, Before: scope.Before}
+
+		documents, err := s.elastic.DeleteExpired(ctx, scope)
+		purged.Documents = documents
+		run.Documents += documents
+		if err != nil {
+			run.Scopes = append(run.Scopes, purged)
+			return fmt.Errorf("%s: %w", scope.Name, err)
+		}
+
+		// archive оставляет статьи в postgres - это и есть архив
+		if s.policy.Action == retention.ActionDelete {
+			articles, err := s.articles.DeleteExpired(ctx, scope)
+			purged.Articles = articles
+			run.Articles += articles
+			if err != nil {
+				run.Scopes = append(run.Scopes, purged)
+				return fmt.Errorf("%s: %w", scope.Name, err)
+			}
+		}
+		run.Scopes = append(run.Scopes, purged)
+		logger.Info(fmt.Sprintf("%s: до %s удалили документов %d, статей %d",
+			scope.Name, scope.Before.Format(time.DateOnly), purged.Documents, purged.Articles))
+	}
+
+	days := s.policy.MaxDays()
+	if days == 0 {
+		return nil
+	}
+	dropped, err := s.elastic.DropPartitions(ctx, now.AddDate(0, 0, -days), s.policy.Action == retention.ActionArchive)
+	for _, p := range dropped {
+		run.Indices = append(run.Indices, p.Index)
+		run.IndexDocs += p.Docs
+	}
+	return err
+}
+
+func (s *service) FindRuns(ctx context.Context, page, pageSize int) ([]*retention.Run, error) {
+	return s.runs.FindRuns(ctx, page*pageSize, pageSize)
+}
+
+func (s *service) CountRuns(ctx context.Context) (int64, error) {
+	return s.runs.Count(ctx)
+}
@@ -0,0 +1,206 @@
+package retentionService
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/mskKote/prospero_backend/internal/adapters/db/elastic/v8/articlesSearchRepository"
+	"github.com/mskKote/prospero_backend/internal/adapters/db/postgres/articlesRepository"
+	"github.com/mskKote/prospero_backend/internal/adapters/db/postgres/retentionRunsRepository"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/retention"
+	"github.com/mskKote/prospero_backend/pkg/client/elastic"
+	"testing"
+	"time"
+)
+
+// fakeArticles удаляет из postgres по 2 статьи на правило
+type fakeArticles struct {
+	articlesRepository.IRepository
+	scopes []string
+}
+
+func (f *fakeArticles) DeleteExpired(_ context.Context, scope retention.Scope) (int64, error) {
+	f.scopes = append(f.scopes, scope.Name)
+	return 2, nil
+}
+
+// fakeElastic удаляет по 3 документа на правило, на правиле failScope падает.
+// С block первый DeleteExpired закрывает started и ждёт закрытия block
+type fakeElastic struct {
+	articlesSearchRepository.IRepository
+	failScope string
+	block     chan struct{}
+	started   chan struct{}
+
+	scopes  []string
+	dropped *time.Time
+	archive bool
+}
+
+func (f *fakeElastic) DeleteExpired(_ context.Context, scope retention.Scope) (int64, error) {
+	if block := f.block; block != nil {
+		f.block = nil
+		close(f.started)
+		<-block
+	}
+	f.scopes = append(f.scopes, scope.Name)
+	if scope.Name == f.failScope {
+		return 1, errors.New("search_phase_execution_exception")
+	}
+	return 3, nil
+}
+
+func (f *fakeElastic) DropPartitions(_ context.Context, before time.Time, archive bool) ([]*elastic.Partition, error) {
+	f.dropped, f.archive = &before, archive
+	return []*elastic.Partition{{Index: "articles_v1_2023.01", Docs: 10}}, nil
+}
+
+// fakeRuns запоминает записанные итоги
+type fakeRuns struct {
+	retentionRunsRepository.IRepository
+	created  int
+	finished []retention.Run
+}
+
+func (f *fakeRuns) CreateRun(_ context.Context, run *retention.Run) error {
+	f.created++
+	run.ID = fmt.Sprintf("run-%d", f.created)
+	return nil
+}
+
+func (f *fakeRuns) FinishRun(_ context.Context, run *retention.Run) error {
+	f.finished = append(f.finished, *run)
+	return nil
+}
+
+func testPolicy(action string) *retention.Policy {
+	return &retention.Policy{
+		Action:      action,
+		DefaultDays: 365,
+		Rules: []retention.Rule{
+			{Country: "Russia", Days: 730},
+			{Publisher: "Vedomosti", Days: 30},
+		},
+	}
+}
+
+func newTestService(policy *retention.Policy) (*service, *fakeArticles, *fakeElastic, *fakeRuns) {
+	articles, es, runs := &fakeArticles{}, &fakeElastic{}, &fakeRuns{}
+	return &service{articles: articles, elastic: es, runs: runs, policy: policy}, articles, es, runs
+}
+
+func TestRunDelete(t *testing.T) {
+	s, articles, es, runs := newTestService(testPolicy(retention.ActionDelete))
+
+	started := time.Now()
+	run, err := s.Run(context.Background(), retention.TriggerManual)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "[country:Russia publisher:Vedomosti default]"
+	if fmt.Sprint(es.scopes) != want || fmt.Sprint(articles.scopes) != want {
+		t.Errorf("правила ES %v, postgres %v, want %s", es.scopes, articles.scopes, want)
+	}
+	if run.Status != retention.RunDone || run.Documents != 9 || run.Articles != 6 || len(run.Scopes) != 3 {
+		t.Errorf("Run() = %+v", run)
+	}
+
+	// Партиции - по самому долгому сроку
+	if es.dropped == nil || es.archive {
+		t.Fatalf("DropPartitions(%v, archive=%t), want удаление", es.dropped, es.archive)
+	}
+	if limit := started.AddDate(0, 0, -730); es.dropped.Sub(limit) < 0 || es.dropped.Sub(limit) > time.Minute {
+		t.Errorf("партиции до %s, want %s", es.dropped, limit)
+	}
+	if fmt.Sprint(run.Indices) != "[articles_v1_2023.01]" || run.IndexDocs != 10 {
+		t.Errorf("партиции %v, документов %d", run.Indices, run.IndexDocs)
+	}
+	if len(runs.finished) != 1 || runs.finished[0].Status != retention.RunDone {
+		t.Errorf("записано %+v", runs.finished)
+	}
+}
+
+// archive оставляет статьи в postgres и закрывает партиции
+func TestRunArchive(t *testing.T) {
+	s, articles, es, _ := newTestService(testPolicy(retention.ActionArchive))
+
+	run, err := s.Run(context.Background(), retention.TriggerCron)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(articles.scopes) != 0 || run.Articles != 0 {
+		t.Errorf("из postgres удалены статьи правил %v", articles.scopes)
+	}
+	if !es.archive {
+		t.Error("партиции удалены, want закрыты")
+	}
+}
+
+// Ошибка правила останавливает очистку, партиции не трогаются, итог записан
+func TestRunFailed(t *testing.T) {
+	s, articles, es, runs := newTestService(testPolicy(retention.ActionDelete))
+	es.failScope = "publisher:Vedomosti"
+
+	run, err := s.Run(context.Background(), retention.TriggerCron)
+	if err == nil {
+		t.Fatal("Run() без ошибки")
+	}
+	if run.Status != retention.RunFailed || run.Documents != 4 || fmt.Sprint(articles.scopes) != "[country:Russia]" {
+		t.Errorf("Run() = %+v, postgres %v", run, articles.scopes)
+	}
+	if es.dropped != nil {
+		t.Error("партиции удалены после ошибки")
+	}
+	if len(runs.finished) != 1 || runs.finished[0].Error == "" {
+		t.Errorf("записано %+v, want ошибку", runs.finished)
+	}
+}
+
+func TestRunKeepForever(t *testing.T) {
+	policy := testPolicy(retention.ActionDelete)
+	policy.Rules = append(policy.Rules, retention.Rule{Country: "USA", Days: 0})
+	s, _, es, _ := newTestService(policy)
+
+	if _, err := s.Run(context.Background(), retention.TriggerCron); err != nil {
+		t.Fatal(err)
+	}
+	if es.dropped != nil {
+		t.Error("партиции удалены, хотя статьи USA хранятся всегда")
+	}
+}
+
+func TestRunInvalidPolicy(t *testing.T) {
+	s, _, _, runs := newTestService(&retention.Policy{Action: "drop"})
+	if _, err := s.Run(context.Background(), retention.TriggerCron); err == nil {
+		t.Error("Run() без ошибки")
+	}
+	if runs.created != 0 {
+		t.Error("записана очистка с неверной политикой")
+	}
+}
+
+func TestRunAlreadyRunning(t *testing.T) {
+	s, _, es, _ := newTestService(testPolicy(retention.ActionDelete))
+	block, started := make(chan struct{}), make(chan struct{})
+	es.block, es.started = block, started
+
+	done := make(chan error)
+	go func() {
+		_, err := s.Run(context.Background(), retention.TriggerCron)
+		done <- err
+	}()
+	<-started
+
+	if _, err := s.Run(context.Background(), retention.TriggerManual); !errors.Is(err, ErrAlreadyRunning) {
+		t.Errorf("второй Run() error = %v, want ErrAlreadyRunning", err)
+	}
+	close(block)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	// После окончания очистку снова можно запустить
+	if _, err := s.Run(context.Background(), retention.TriggerManual); err != nil {
+		t.Errorf("Run() после окончания: %v", err)
+	}
+}
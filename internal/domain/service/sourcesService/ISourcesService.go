@@ -6,11 +6,18 @@ import (
 )
 
 type ISourceService interface {
-	AddSource(ctx context.Context, dto source.AddSourceDTO) (*source.DTO, error)
+	// AddSource добавляет источник, meta - результат CheckFeed, если адрес проверяли
+	AddSource(ctx context.Context, dto source.AddSourceDTO, meta *source.FeedMeta) (*source.DTO, error)
+	// CheckFeed скачивает ленту по адресу. Если это не лента - *source.FeedError
+	CheckFeed(ctx context.Context, rssURL string) (*source.FeedMeta, error)
+	// Discover находит ленты сайта и возвращает рабочие, лучшие первыми
+	Discover(ctx context.Context, siteURL string) ([]*source.DiscoveredFeedDTO, error)
 	FindAll(ctx context.Context, page, pageSize int) ([]*source.DTO, error)
 	FindAllWithPublisher(ctx context.Context, page, pageSize int) ([]*source.RSS, error)
 	FindByPublisherName(ctx context.Context, name string, page, pageSize int) ([]*source.DTO, error)
-	Update(ctx context.Context, source *source.DTO) (*source.DTO, error)
+	Update(ctx context.Context, source *source.DTO, meta *source.FeedMeta) (*source.DTO, error)
 	Delete(ctx context.Context, dto source.DeleteSourceDTO) error
+	// ResetHealth обнуляет ошибки источника и выводит его из карантина
+	ResetHealth(ctx context.Context, dto source.ResetSourceDTO) error
 	Count(ctx context.Context) (int64, error)
 }
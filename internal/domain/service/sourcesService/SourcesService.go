@@ -2,14 +2,31 @@ package sourcesService
 
 import (
 	"context"
+	"errors"
+	"github.com/mmcdole/gofeed"
 	"github.com/mskKote/prospero_backend/internal/adapters/db/postgres/sourcesRepository"
 	"github.com/mskKote/prospero_backend/internal/domain/entity/publisher"
 	"github.com/mskKote/prospero_backend/internal/domain/entity/source"
+	"github.com/mskKote/prospero_backend/pkg/client/rss"
+	"github.com/mskKote/prospero_backend/pkg/config"
 	"github.com/mskKote/prospero_backend/pkg/lib"
+	"github.com/mskKote/prospero_backend/pkg/logging"
+	"go.uber.org/zap"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	logger = logging.GetLogger()
+	cfg    = config.GetConfig()
 )
 
 type service struct {
 	sources sourcesRepository.IRepository
+	feeds   rss.Client
 }
 
 func (s *service) Count(ctx context.Context) (int64, error) {
@@ -37,26 +54,191 @@ func (s *service) FindByPublisherName(ctx context.Context, name string, page, pa
 	return source.ToDTOs(src), nil
 }
 
-func (s *service) Update(ctx context.Context, dto *source.DTO) (*source.DTO, error) {
-	err := s.sources.Update(ctx, dto.ToDomain())
-	return dto, err
+func (s *service) Update(ctx context.Context, dto *source.DTO, meta *source.FeedMeta) (*source.DTO, error) {
+	src := dto.ToDomain()
+	if err := s.sources.Update(ctx, src); err != nil {
+		return dto, err
+	}
+	if meta != nil {
+		if err := s.sources.UpdateFeedMeta(ctx, src.RssID, *meta); err != nil {
+			return dto, err
+		}
+		src.SetFeedMeta(*meta)
+		dto.Feed = src.ToDTO().Feed
+	}
+	return dto, nil
 }
 
 func (s *service) Delete(ctx context.Context, dto source.DeleteSourceDTO) error {
 	return s.sources.Delete(ctx, dto.RssID)
 }
 
-func (s *service) AddSource(ctx context.Context, dto source.AddSourceDTO) (*source.DTO, error) {
+func (s *service) ResetHealth(ctx context.Context, dto source.ResetSourceDTO) error {
+	return s.sources.ResetHealth(ctx, dto.RssID)
+}
+
+func (s *service) AddSource(ctx context.Context, dto source.AddSourceDTO, meta *source.FeedMeta) (*source.DTO, error) {
 	id := lib.StringToUUID(dto.PublisherID)
 	p := publisher.PgDBO{PublisherID: id}
 
-	saved, err := s.sources.Create(ctx, &source.RSS{
+	src := &source.RSS{
 		RssURL:    dto.RssURL,
 		Publisher: p,
-	})
+	}
+	if meta != nil {
+		src.SetFeedMeta(*meta)
+	}
+	saved, err := s.sources.Create(ctx, src)
 	return saved.ToDTO(), err
 }
 
-func New(sources sourcesRepository.IRepository) ISourceService {
-	return &service{sources}
+func (s *service) CheckFeed(ctx context.Context, rssURL string) (*source.FeedMeta, error) {
+	if u, err := url.Parse(rssURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, &source.FeedError{
+			URL:    rssURL,
+			Reason: source.FeedInvalidURL,
+			Detail: "нужен абсолютный http(s) адрес",
+		}
+	}
+
+	fetchCtx, cancel := context.WithTimeout(ctx, cfg.HarvestSourceTimeout)
+	defer cancel()
+	res, err := s.feeds.Fetch(fetchCtx, rssURL, rss.Validators{})
+	if err != nil {
+		return nil, feedError(rssURL, res, err)
+	}
+
+	feed := res.Feed
+	meta := &source.FeedMeta{
+		URL:          rssURL,
+		Title:        strings.TrimSpace(feed.Title),
+		Language:     feed.Language,
+		ItemCount:    len(feed.Items),
+		UpdatePeriod: updatePeriod(feed),
+		CheckedAt:    time.Now(),
+	}
+	if res.PermanentURL != "" {
+		meta.URL = res.PermanentURL
+	}
+	return meta, nil
+}
+
+// Сколько кандидатов скачиваем при поиске лент сайта
+const maxDiscoveryCandidates = 15
+
+// Надёжность способов найти ленту, меньше - лучше
+var discoveryRank = map[string]int{
+	rss.ViaLinkAlternate: 0,
+	rss.ViaAnchor:        1,
+	rss.ViaSitemap:       2,
+	rss.ViaCommonPath:    3,
+}
+
+func (s *service) Discover(ctx context.Context, siteURL string) ([]*source.DiscoveredFeedDTO, error) {
+	candidates, err := s.feeds.Discover(ctx, siteURL)
+	if err != nil {
+		return nil, err
+	}
+	if len(candidates) > maxDiscoveryCandidates {
+		candidates = candidates[:maxDiscoveryCandidates]
+	}
+
+	feeds := []*source.DiscoveredFeedDTO{}
+	seen := map[string]bool{}
+	for _, c := range candidates {
+		meta, err := s.CheckFeed(ctx, c.URL)
+		if err != nil {
+			logger.Info("Кандидат не лента: "+c.URL, zap.Error(err))
+			continue
+		}
+		// /rss и /feed часто отдают одну ленту без редиректа
+		sameFeed := meta.Title + "\x00" + strconv.Itoa(meta.ItemCount)
+		if seen[meta.URL] || (meta.Title != "" && seen[sameFeed]) {
+			continue
+		}
+		seen[meta.URL], seen[sameFeed] = true, true
+
+		title := meta.Title
+		if title == "" {
+			title = c.Title
+		}
+		feeds = append(feeds, &source.DiscoveredFeedDTO{
+			RssURL:              meta.URL,
+			Via:                 c.Via,
+			Title:               title,
+			Language:            meta.Language,
+			ItemCount:           meta.ItemCount,
+			UpdatePeriodMinutes: int(meta.UpdatePeriod.Minutes()),
+		})
+	}
+
+	// Сначала объявленные самим сайтом, потом наполненные
+	sort.SliceStable(feeds, func(i, j int) bool {
+		if ri, rj := discoveryRank[feeds[i].Via], discoveryRank[feeds[j].Via]; ri != rj {
+			return ri < rj
+		}
+		return feeds[i].ItemCount > feeds[j].ItemCount
+	})
+	return feeds, nil
+}
+
+// feedError объясняет, почему по адресу не нашлось ленты
+func feedError(rssURL string, res *rss.Response, err error) *source.FeedError {
+	e := &source.FeedError{URL: rssURL, Detail: err.Error()}
+	if res != nil {
+		e.HTTPStatus = res.StatusCode
+	}
+
+	var rateLimited *rss.RateLimitedError
+	var httpErr gofeed.HTTPError
+	switch {
+	case errors.As(err, &rateLimited):
+		e.Reason = source.FeedRateLimited
+	case errors.As(err, &httpErr):
+		e.Reason = source.FeedHTTPError
+	case res == nil:
+		// Сервер не ответил
+		e.Reason = source.FeedUnreachable
+	default:
+		// Ответил, но не RSS/Atom/JSON Feed - обычно HTML страница
+		e.Reason = source.FeedNotAFeed
+	}
+	return e
+}
+
+// Периоды sy:updatePeriod
+var syndicationPeriods = map[string]time.Duration{
+	"hourly":  time.Hour,
+	"daily":   24 * time.Hour,
+	"weekly":  7 * 24 * time.Hour,
+	"monthly": 30 * 24 * time.Hour,
+	"yearly":  365 * 24 * time.Hour,
+}
+
+// updatePeriod - как часто лента обещает обновляться:
+// sy:updatePeriod, делённый на sy:updateFrequency
+func updatePeriod(feed *gofeed.Feed) time.Duration {
+	sy, ok := feed.Extensions["sy"]
+	if !ok {
+		return 0
+	}
+	first := func(name string) string {
+		if values := sy[name]; len(values) > 0 {
+			return strings.TrimSpace(values[0].Value)
+		}
+		return ""
+	}
+
+	period, ok := syndicationPeriods[strings.ToLower(first("updatePeriod"))]
+	if !ok {
+		return 0
+	}
+	if frequency, err := strconv.Atoi(first("updateFrequency")); err == nil && frequency > 0 {
+		period /= time.Duration(frequency)
+	}
+	return period
+}
+
+func New(sources sourcesRepository.IRepository, feeds rss.Client) ISourceService {
+	return &service{sources, feeds}
 }
@@ -0,0 +1,184 @@
+package sourcesService
+
+import (
+	"context"
+	"errors"
+	"github.com/mmcdole/gofeed"
+	ext "github.com/mmcdole/gofeed/extensions"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/source"
+	"github.com/mskKote/prospero_backend/pkg/client/rss"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// fakeFeeds отвечает на любой адрес одним и тем же, с byURL - лентой по адресу
+type fakeFeeds struct {
+	res        *rss.Response
+	err        error
+	byURL      map[string]*gofeed.Feed
+	candidates []rss.Candidate
+}
+
+func (f *fakeFeeds) Fetch(_ context.Context, url string, _ rss.Validators) (*rss.Response, error) {
+	if f.byURL == nil {
+		return f.res, f.err
+	}
+	feed, ok := f.byURL[url]
+	if !ok {
+		return &rss.Response{StatusCode: http.StatusNotFound},
+			gofeed.HTTPError{StatusCode: http.StatusNotFound, Status: "404 Not Found"}
+	}
+	return &rss.Response{Feed: feed, StatusCode: http.StatusOK}, nil
+}
+
+func (f *fakeFeeds) Discover(context.Context, string) ([]rss.Candidate, error) {
+	return f.candidates, nil
+}
+
+func parseFeed(t *testing.T, body string) *gofeed.Feed {
+	t.Helper()
+	feed, err := gofeed.NewParser().ParseString(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return feed
+}
+
+func TestCheckFeed(t *testing.T) {
+	feed := parseFeed(t, `<?xml version="1.0"?>
+<rss version="2.0" xmlns:sy="http://purl.org/rss/1.0/modules/syndication/"><channel>
+<title> Тест </title><language>ru</language>
+<sy:updatePeriod>hourly</sy:updatePeriod><sy:updateFrequency>4</sy:updateFrequency>
+<item><guid>1</guid></item><item><guid>2</guid></item>
+</channel></rss>`)
+	s := &service{feeds: &fakeFeeds{res: &rss.Response{Feed: feed, StatusCode: http.StatusOK}}}
+
+	meta, err := s.CheckFeed(context.Background(), "https://example.com/rss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Title != "Тест" || meta.Language != "ru" || meta.ItemCount != 2 || meta.UpdatePeriod != 15*time.Minute {
+		t.Errorf("CheckFeed() = %+v, want Тест, ru, 2 новости, раз в 15m", meta)
+	}
+}
+
+func TestCheckFeedError(t *testing.T) {
+	tests := []struct {
+		name   string
+		url    string
+		feeds  *fakeFeeds
+		reason string
+		status int
+	}{
+		{"относительный адрес", "/rss", &fakeFeeds{}, source.FeedInvalidURL, 0},
+		{"не http", "ftp://example.com/rss", &fakeFeeds{}, source.FeedInvalidURL, 0},
+		{"сервер не ответил", "https://example.com/rss",
+			&fakeFeeds{err: errors.New("connection refused")}, source.FeedUnreachable, 0},
+		{"404", "https://example.com/rss", &fakeFeeds{
+			res: &rss.Response{StatusCode: http.StatusNotFound},
+			err: gofeed.HTTPError{StatusCode: http.StatusNotFound, Status: "404 Not Found"},
+		}, source.FeedHTTPError, http.StatusNotFound},
+		{"429", "https://example.com/rss", &fakeFeeds{
+			res: &rss.Response{StatusCode: http.StatusTooManyRequests},
+			err: &rss.RateLimitedError{Host: "example.com", Until: time.Now().Add(time.Hour)},
+		}, source.FeedRateLimited, http.StatusTooManyRequests},
+		// Вместо ленты отдали страницу сайта
+		{"html", "https://example.com/", &fakeFeeds{
+			res: &rss.Response{StatusCode: http.StatusOK},
+			err: gofeed.ErrFeedTypeNotDetected,
+		}, source.FeedNotAFeed, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &service{feeds: tt.feeds}
+			_, err := s.CheckFeed(context.Background(), tt.url)
+
+			var feedErr *source.FeedError
+			if !errors.As(err, &feedErr) {
+				t.Fatalf("CheckFeed() error = %v, want *source.FeedError", err)
+			}
+			if feedErr.Reason != tt.reason || feedErr.HTTPStatus != tt.status || feedErr.URL != tt.url {
+				t.Errorf("FeedError = %+v, want %s со статусом %d", feedErr, tt.reason, tt.status)
+			}
+		})
+	}
+}
+
+func TestUpdatePeriod(t *testing.T) {
+	sy := func(period, frequency string) *gofeed.Feed {
+		feed := &gofeed.Feed{Extensions: ext.Extensions{"sy": {}}}
+		if period != "" {
+			feed.Extensions["sy"]["updatePeriod"] = []ext.Extension{{Value: period}}
+		}
+		if frequency != "" {
+			feed.Extensions["sy"]["updateFrequency"] = []ext.Extension{{Value: frequency}}
+		}
+		return feed
+	}
+	tests := []struct {
+		name string
+		feed *gofeed.Feed
+		want time.Duration
+	}{
+		{"не заявлена", &gofeed.Feed{}, 0},
+		{"daily", sy("daily", ""), 24 * time.Hour},
+		{"регистр и пробелы", sy(" Weekly ", ""), 7 * 24 * time.Hour},
+		{"hourly дважды", sy("hourly", "2"), 30 * time.Minute},
+		{"кривая частота", sy("hourly", "0"), time.Hour},
+		{"неизвестный период", sy("sometimes", "2"), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := updatePeriod(tt.feed); got != tt.want {
+				t.Errorf("updatePeriod() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	items := func(n int) []*gofeed.Item {
+		return make([]*gofeed.Item, n)
+	}
+	feeds := &fakeFeeds{
+		// Клиент отдаёт кандидатов от надёжных к догадкам
+		candidates: []rss.Candidate{
+			{URL: "https://example.com/main.xml", Via: rss.ViaLinkAlternate},
+			{URL: "https://example.com/broken.xml", Via: rss.ViaLinkAlternate},
+			{URL: "https://example.com/sport.xml", Via: rss.ViaAnchor, Title: "Спорт"},
+			{URL: "https://example.com/big.xml", Via: rss.ViaAnchor},
+			// Та же лента, что main.xml, по другим адресам
+			{URL: "https://example.com/rss", Via: rss.ViaCommonPath},
+			{URL: "https://example.com/feed", Via: rss.ViaCommonPath},
+		},
+		byURL: map[string]*gofeed.Feed{
+			"https://example.com/rss":       {Title: "Главное", Items: items(20)},
+			"https://example.com/sport.xml": {Items: items(5)},
+			"https://example.com/main.xml":  {Title: "Главное", Items: items(20)},
+			"https://example.com/big.xml":   {Title: "Всё", Items: items(50)},
+			"https://example.com/feed":      {Title: "Главное", Items: items(20)},
+		},
+	}
+	s := &service{feeds: feeds}
+
+	found, err := s.Discover(context.Background(), "https://example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Сначала объявленная в <link>, среди ссылок - наполненные первыми,
+	// дубли главной и битая лента отброшены, без title - название из ссылки
+	want := []source.DiscoveredFeedDTO{
+		{RssURL: "https://example.com/main.xml", Via: rss.ViaLinkAlternate, Title: "Главное", ItemCount: 20},
+		{RssURL: "https://example.com/big.xml", Via: rss.ViaAnchor, Title: "Всё", ItemCount: 50},
+		{RssURL: "https://example.com/sport.xml", Via: rss.ViaAnchor, Title: "Спорт", ItemCount: 5},
+	}
+	if len(found) != len(want) {
+		t.Fatalf("найдено %d лент, want %d: %+v", len(found), len(want), found)
+	}
+	for i := range want {
+		if *found[i] != want[i] {
+			t.Errorf("лента %d = %+v, want %+v", i, *found[i], want[i])
+		}
+	}
+}
@@ -3,7 +3,9 @@ package RSS
 import (
 	"context"
 	"github.com/go-co-op/gocron"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/harvest"
 	"github.com/mskKote/prospero_backend/internal/domain/service/articleService"
+	"github.com/mskKote/prospero_backend/internal/domain/service/harvestService"
 	"github.com/mskKote/prospero_backend/internal/domain/service/sourcesService"
 	"github.com/mskKote/prospero_backend/pkg/config"
 	"github.com/mskKote/prospero_backend/pkg/logging"
@@ -20,14 +22,17 @@ var (
 type usecase struct {
 	sources  sourcesService.ISourceService
 	articles articleService.IArticleService
+	harvest  harvestService.IHarvestService
 }
 
 func New(
 	s sourcesService.ISourceService,
-	a articleService.IArticleService) IParserUsecase {
+	a articleService.IArticleService,
+	h harvestService.IHarvestService) IParserUsecase {
 	return &usecase{
 		sources:  s,
 		articles: a,
+		harvest:  h,
 	}
 }
 
@@ -52,6 +57,9 @@ func (u *usecase) Startup() {
 }
 
 func (u *usecase) ParseJob() {
+	// Через задачи, чтобы не пересечься с парсингом из админки
 	ctx := context.Background()
-	_ = u.articles.ParseAllOnce(ctx, false)
+	if _, err := u.harvest.Start(ctx, harvest.TriggerCron, false); err != nil {
+		logger.Warn("Пропускаем парсинг по расписанию", zap.Error(err))
+	}
 }
@@ -1,21 +1,36 @@
 package adminka
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"github.com/gin-gonic/gin"
+	"github.com/mskKote/prospero_backend/internal/adapters/db/postgres/sourcesRepository"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/catalogue"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/harvest"
 	"github.com/mskKote/prospero_backend/internal/domain/entity/publisher"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/retention"
 	"github.com/mskKote/prospero_backend/internal/domain/entity/source"
 	"github.com/mskKote/prospero_backend/internal/domain/service/articleService"
+	"github.com/mskKote/prospero_backend/internal/domain/service/catalogueService"
+	"github.com/mskKote/prospero_backend/internal/domain/service/harvestService"
+	"github.com/mskKote/prospero_backend/internal/domain/service/importService"
 	"github.com/mskKote/prospero_backend/internal/domain/service/publishersService"
+	"github.com/mskKote/prospero_backend/internal/domain/service/retentionService"
 	"github.com/mskKote/prospero_backend/internal/domain/service/sourcesService"
+	"github.com/mskKote/prospero_backend/pkg/config"
 	"github.com/mskKote/prospero_backend/pkg/lib"
 	"github.com/mskKote/prospero_backend/pkg/logging"
 	"go.uber.org/zap"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
 )
 
 var (
 	logger = logging.GetLogger()
+	cfg    = config.GetConfig()
 )
 
 const pageSize int = 6
@@ -25,13 +40,21 @@ type usecase struct {
 	sources    sourcesService.ISourceService
 	publishers publishersService.IPublishersService
 	articles   articleService.IArticleService
+	harvest    harvestService.IHarvestService
+	imports    importService.IImportService
+	catalogue  catalogueService.ICatalogueService
+	retention  retentionService.IRetentionService
 }
 
 func New(
 	s *sourcesService.ISourceService,
 	p *publishersService.IPublishersService,
-	a *articleService.IArticleService) IAdminkaUseCase {
-	return &usecase{*s, *p, *a}
+	a *articleService.IArticleService,
+	h *harvestService.IHarvestService,
+	i *importService.IImportService,
+	cat *catalogueService.ICatalogueService,
+	r *retentionService.IRetentionService) IAdminkaUseCase {
+	return &usecase{*s, *p, *a, *h, *i, *cat, *r}
 }
 
 // AddSourceAndPublisher godoc
@@ -42,7 +65,9 @@ func New(
 //	@Accept			json
 //	@Produce		json
 //	@Param			dto	body	source.AddSourceAndPublisherDTO	true	"Add Source and Publisher DTO"
+//	@Param			validate	query	bool	false	"Check that URL is a feed (default validate_sources)"
 //	@Success		200
+//	@Failure		422	{object}	source.FeedError
 //	@Router			/addSourceAndPublisher [post]
 func (u *usecase) AddSourceAndPublisher(c *gin.Context) {
 	sp := &source.AddSourceAndPublisherDTO{}
@@ -53,6 +78,12 @@ func (u *usecase) AddSourceAndPublisher(c *gin.Context) {
 		return
 	}
 
+	// Проверяем ленту до издателя, чтобы не оставить издателя без источника
+	meta, ok := u.checkFeed(c, sp.RssUrl)
+	if !ok {
+		return
+	}
+
 	// ADD PUBLISHER
 	p := &publisher.AddPublisherDTO{
 		Name:      sp.Name,
@@ -73,7 +104,7 @@ func (u *usecase) AddSourceAndPublisher(c *gin.Context) {
 		RssURL:      sp.RssUrl,
 		PublisherID: created.PublisherID,
 	}
-	if _, err := u.sources.AddSource(c, *s); err != nil {
+	if _, err := u.sources.AddSource(c, *s, meta); err != nil {
 		_ = c.Error(err)
 		lib.ResponseBadRequest(c, err, "Не получилось добавить источник")
 		return
@@ -92,7 +123,9 @@ func (u *usecase) AddSourceAndPublisher(c *gin.Context) {
 //	@Accept			json
 //	@Produce		json
 //	@Param			dto	body	source.AddSourceDTO	true	"Add Source DTO"
+//	@Param			validate	query	bool	false	"Check that URL is a feed (default validate_sources)"
 //	@Success		200
+//	@Failure		422	{object}	source.FeedError
 //	@Router			/RSS/addSource [post]
 func (u *usecase) CreateSourceRSS(c *gin.Context) {
 	s := source.AddSourceDTO{}
@@ -102,7 +135,12 @@ func (u *usecase) CreateSourceRSS(c *gin.Context) {
 		return
 	}
 
-	src, err := u.sources.AddSource(c, s)
+	meta, ok := u.checkFeed(c, s.RssURL)
+	if !ok {
+		return
+	}
+
+	src, err := u.sources.AddSource(c, s, meta)
 	if err != nil {
 		_ = c.Error(err)
 		lib.ResponseBadRequest(c, err, "Не получилось добавить источник")
@@ -115,6 +153,88 @@ func (u *usecase) CreateSourceRSS(c *gin.Context) {
 	})
 }
 
+// DiscoverSourcesRSS godoc
+//
+//	@Summary		Discover RSS feeds of a site
+//	@Description	Find feeds via link alternate, anchors, sitemap and common paths, parse them and rank
+//	@Tags			sources
+//	@Accept			json
+//	@Produce		json
+//	@Param			dto	body	source.DiscoverSourcesDTO	true	"Discover Sources DTO"
+//	@Success		200	{array}	source.DiscoveredFeedDTO
+//	@Router			/RSS/discoverSources [post]
+func (u *usecase) DiscoverSourcesRSS(c *gin.Context) {
+	dto := source.DiscoverSourcesDTO{}
+	if err := c.Bind(&dto); err != nil {
+		_ = c.Error(err)
+		lib.ResponseBadRequest(c, err, "Неправильное тело запроса")
+		return
+	}
+
+	feeds, err := u.sources.Discover(c, dto.SiteURL)
+	if err != nil {
+		_ = c.Error(err)
+		lib.ResponseBadRequest(c, err, "Не получилось найти ленты на "+dto.SiteURL)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "ok",
+		"data":    feeds,
+	})
+}
+
+// CreateSourcesRSS godoc
+//
+//	@Summary		Create several RSS sources
+//	@Description	Add several feeds (e.g. from discovery) to one publisher, report per URL
+//	@Tags			sources
+//	@Accept			json
+//	@Produce		json
+//	@Param			dto			body	source.AddSourcesDTO	true	"Add Sources DTO"
+//	@Param			validate	query	bool					false	"Check that URL is a feed (default validate_sources)"
+//	@Success		200			{array}	source.AddSourceResultDTO
+//	@Router			/RSS/addSources [post]
+func (u *usecase) CreateSourcesRSS(c *gin.Context) {
+	dto := source.AddSourcesDTO{}
+	if err := c.Bind(&dto); err != nil {
+		_ = c.Error(err)
+		lib.ResponseBadRequest(c, err, "Неправильное тело запроса")
+		return
+	}
+	validate, err := strconv.ParseBool(c.DefaultQuery("validate", strconv.FormatBool(cfg.ValidateSources)))
+	if err != nil {
+		_ = c.Error(err)
+		lib.ResponseBadRequest(c, err, "Неправильные параметры запроса")
+		return
+	}
+
+	results := make([]*source.AddSourceResultDTO, 0, len(dto.RssURLs))
+	for _, rssURL := range dto.RssURLs {
+		res := &source.AddSourceResultDTO{RssURL: rssURL}
+		results = append(results, res)
+
+		var meta *source.FeedMeta
+		if validate {
+			if meta, err = u.sources.CheckFeed(c, rssURL); err != nil {
+				res.Error = err.Error()
+				errors.As(err, &res.Feed)
+				continue
+			}
+		}
+		s := source.AddSourceDTO{RssURL: rssURL, PublisherID: dto.PublisherID}
+		if res.Source, err = u.sources.AddSource(c, s, meta); err != nil {
+			res.Error = err.Error()
+			res.Source = nil
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "ok",
+		"data":    results,
+	})
+}
+
 // ReadSourcesRSS godoc
 //
 //	@Summary		Read RSS sources
@@ -270,7 +390,9 @@ func (u *usecase) ReadSourcesRSSWithPublishers(c *gin.Context) {
 //	@Accept			json
 //	@Produce		json
 //	@Param			dto	body	source.DTO	true	"Update Source DTO"
+//	@Param			validate	query	bool	false	"Check that URL is a feed (default validate_sources)"
 //	@Success		200
+//	@Failure		422	{object}	source.FeedError
 //	@Router			/RSS/updateSource [put]
 func (u *usecase) UpdateSourceRSS(c *gin.Context) {
 	dto := &source.DTO{}
@@ -280,7 +402,12 @@ func (u *usecase) UpdateSourceRSS(c *gin.Context) {
 		return
 	}
 
-	data, err := u.sources.Update(c, dto)
+	meta, ok := u.checkFeed(c, dto.RssURL)
+	if !ok {
+		return
+	}
+
+	data, err := u.sources.Update(c, dto, meta)
 	if err != nil {
 		_ = c.Error(err)
 		lib.ResponseBadRequest(c, err, "Не получилось обновить RSS источник")
@@ -293,6 +420,38 @@ func (u *usecase) UpdateSourceRSS(c *gin.Context) {
 	})
 }
 
+// checkFeed проверяет, что по адресу лента, и достаёт её метаданные.
+// Проверку выключает ?validate=false, по умолчанию - validate_sources.
+// false - адрес не прошёл, ответ уже отправлен
+func (u *usecase) checkFeed(c *gin.Context, rssURL string) (*source.FeedMeta, bool) {
+	validate, err := strconv.ParseBool(c.DefaultQuery("validate", strconv.FormatBool(cfg.ValidateSources)))
+	if err != nil {
+		_ = c.Error(err)
+		lib.ResponseBadRequest(c, err, "Неправильные параметры запроса")
+		return nil, false
+	}
+	if !validate {
+		return nil, true
+	}
+
+	meta, err := u.sources.CheckFeed(c, rssURL)
+	var feedErr *source.FeedError
+	if errors.As(err, &feedErr) {
+		_ = c.Error(err)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "По адресу нет RSS ленты",
+			"error":   err.Error(),
+			"feed":    feedErr})
+		return nil, false
+	}
+	if err != nil {
+		_ = c.Error(err)
+		lib.ResponseBadRequest(c, err, "Не получилось проверить ленту "+rssURL)
+		return nil, false
+	}
+	return meta, true
+}
+
 // DeleteSourceRSS godoc
 //
 //	@Summary		Delete RSS source
@@ -320,6 +479,152 @@ func (u *usecase) DeleteSourceRSS(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "ok"})
 }
 
+// ResetSourceRSS godoc
+//
+//	@Summary		Reset RSS source health
+//	@Description	Reset failure counter and lift quarantine of RSS source
+//	@Tags			sources
+//	@Accept			json
+//	@Produce		json
+//	@Param			dto	body	source.ResetSourceDTO	true	"Reset Source DTO"
+//	@Success		200
+//	@Router			/RSS/resetSource [post]
+func (u *usecase) ResetSourceRSS(c *gin.Context) {
+	dto := source.ResetSourceDTO{}
+	if err := c.Bind(&dto); err != nil {
+		_ = c.Error(err)
+		lib.ResponseBadRequest(c, err, "Неправильное тело запроса")
+		return
+	}
+
+	if err := u.sources.ResetHealth(c, dto); err != nil {
+		_ = c.Error(err)
+		lib.ResponseBadRequest(c, err, "Не получилось сбросить RSS источник")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "ok"})
+}
+
+// ---------------------------------------------------- import / export
+
+// ImportOPML godoc
+//
+//	@Summary		Import OPML
+//	@Description	Create publishers and sources from OPML 2.0, skip known rss_url, report per entry
+//	@Tags			sources
+//	@Accept			mpfd,xml
+//	@Produce		json
+//	@Param			file	formData	file	false	"OPML file, or send it as request body"
+//	@Param			mode	query		string	false	"all_or_nothing or best_effort"	default(best_effort)
+//	@Success		200		{array}		source.ImportResultDTO
+//	@Router			/RSS/importOPML [post]
+func (u *usecase) ImportOPML(c *gin.Context) {
+	body, _, closeBody, err := uploadedFile(c)
+	if err != nil {
+		_ = c.Error(err)
+		lib.ResponseBadRequest(c, err, "Не получилось прочитать файл")
+		return
+	}
+	defer closeBody()
+
+	mode := c.DefaultQuery("mode", source.ImportBestEffort)
+	results, err := u.imports.ImportOPML(c, body, mode)
+	if err != nil {
+		_ = c.Error(err)
+		lib.ResponseBadRequest(c, err, "Неправильный OPML")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "ok",
+		"data":    results,
+	})
+}
+
+// ImportSourcesRSS godoc
+//
+//	@Summary		Bulk import publishers with sources
+//	@Description	Import CSV (header: name,country,city,longitude,latitude,rss_url) or JSON lines of AddSourceAndPublisherDTO in one transaction, report per row
+//	@Tags			sources
+//	@Accept			mpfd,plain
+//	@Produce		json
+//	@Param			file	formData	file	false	"CSV or JSONL file, or send it as request body"
+//	@Param			format	query		string	false	"csv or jsonl, by default from file extension or Content-Type"
+//	@Param			mode	query		string	false	"all_or_nothing or best_effort"	default(all_or_nothing)
+//	@Success		200		{array}		source.ImportResultDTO
+//	@Router			/RSS/importSources [post]
+func (u *usecase) ImportSourcesRSS(c *gin.Context) {
+	body, filename, closeBody, err := uploadedFile(c)
+	if err != nil {
+		_ = c.Error(err)
+		lib.ResponseBadRequest(c, err, "Не получилось прочитать файл")
+		return
+	}
+	defer closeBody()
+
+	format := c.Query("format")
+	if format == "" {
+		format = importService.FormatJSONL
+		if strings.HasSuffix(strings.ToLower(filename), ".csv") || c.ContentType() == "text/csv" {
+			format = importService.FormatCSV
+		}
+	}
+	mode := c.DefaultQuery("mode", source.ImportAllOrNothing)
+
+	results, err := u.imports.ImportRows(c, body, format, mode)
+	if err != nil {
+		_ = c.Error(err)
+		lib.ResponseBadRequest(c, err, "Не получилось импортировать источники")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "ok",
+		"data":    results,
+	})
+}
+
+// ExportOPML godoc
+//
+//	@Summary		Export OPML
+//	@Description	Export all sources as OPML 2.0 grouped by country and publisher
+//	@Tags			sources
+//	@Produce		xml
+//	@Success		200
+//	@Router			/RSS/exportOPML [get]
+func (u *usecase) ExportOPML(c *gin.Context) {
+	buf := &bytes.Buffer{}
+	if err := u.imports.ExportOPML(c, buf); err != nil {
+		_ = c.Error(err)
+		lib.ResponseBadRequest(c, err, "Не получилось выгрузить источники")
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="prospero.opml"`)
+	c.Data(http.StatusOK, "text/x-opml; charset=utf-8", buf.Bytes())
+}
+
+// uploadedFile - файл из multipart поля file или всё тело запроса
+func uploadedFile(c *gin.Context) (body io.Reader, filename string, closeBody func(), err error) {
+	if c.ContentType() != "multipart/form-data" {
+		return c.Request.Body, "", func() {}, nil
+	}
+	header, err := c.FormFile("file")
+	if err != nil {
+		return nil, "", nil, err
+	}
+	f, err := header.Open()
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return f, header.Filename, func() {
+		if err := f.Close(); err != nil {
+			logger.Error("Не закрыли файл "+header.Filename, zap.Error(err))
+		}
+	}, nil
+}
+
 // ---------------------------------------------------- publishers CRUD
 
 // CreatePublisher godoc
@@ -439,22 +744,423 @@ func (u *usecase) DeletePublisher(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "ok"})
 }
 
+// ---------------------------------------------------- catalogue
+
+// PlanCatalogue godoc
+//
+//	@Summary		Plan catalogue reconcile
+//	@Description	Show what applying catalogue_path would change in Postgres and the publisher index, without changing anything
+//	@Tags			publishers
+//	@Produce		json
+//	@Param			prune	query	bool	false	"Delete publishers and feeds missing from the catalogue"	default(false)
+//	@Success		200		{object}	catalogue.Plan
+//	@Router			/catalogue/plan [get]
+func (u *usecase) PlanCatalogue(c *gin.Context) {
+	u.reconcileCatalogue(c, u.catalogue.Plan)
+}
+
+// ApplyCatalogue godoc
+//
+//	@Summary		Apply catalogue
+//	@Description	Create, update and with prune delete publishers and feeds so that Postgres and the publisher index match catalogue_path
+//	@Tags			publishers
+//	@Produce		json
+//	@Param			prune	query	bool	false	"Delete publishers and feeds missing from the catalogue"	default(false)
+//	@Success		200		{object}	catalogue.Plan
+//	@Router			/catalogue/apply [post]
+func (u *usecase) ApplyCatalogue(c *gin.Context) {
+	u.reconcileCatalogue(c, u.catalogue.Apply)
+}
+
+func (u *usecase) reconcileCatalogue(
+	c *gin.Context,
+	reconcile func(ctx context.Context, prune bool) (*catalogue.Plan, error)) {
+
+	prune, err := strconv.ParseBool(c.DefaultQuery("prune", "false"))
+	if err != nil {
+		_ = c.Error(err)
+		lib.ResponseBadRequest(c, err, "Неправильные параметры запроса")
+		return
+	}
+
+	plan, err := reconcile(c, prune)
+	if err != nil {
+		_ = c.Error(err)
+		logger.Error("Сверка каталога", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Каталог не применён: " + err.Error(),
+			"data":    plan,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "ok",
+		"data":    plan,
+	})
+}
+
 // ---------------------------------------------------- RSS
 
 // Harvest godoc
 //
 //	@Summary		Harvest RSS
-//	@Description	Harvest RSS feeds and parse articles
+//	@Description	Start harvesting RSS feeds in background, returns job to follow
 //	@Tags			sources
 //	@Produce		json
-//	@Success		200
+//	@Param			full	query	bool	false	"Ignore source watermarks"	default(true)
+//	@Success		202
+//	@Failure		409
 //	@Router			/RSS/harvest [post]
 func (u *usecase) Harvest(c *gin.Context) {
-	if err := u.articles.ParseAllOnce(c, true); err != nil {
+	full, err := strconv.ParseBool(c.DefaultQuery("full", "true"))
+	if err != nil {
 		_ = c.Error(err)
-		lib.ResponseBadRequest(c, err, "Не получилось прочитать источники")
+		lib.ResponseBadRequest(c, err, "Неправильные параметры запроса")
+		return
+	}
+
+	job, err := u.harvest.Start(c, harvest.TriggerManual, full)
+	if errors.Is(err, harvestService.ErrAlreadyRunning) {
+		c.JSON(http.StatusConflict, gin.H{
+			"message": "Парсинг уже идёт",
+			"error":   err.Error()})
+		return
+	}
+	if err != nil {
+		_ = c.Error(err)
+		lib.ResponseBadRequest(c, err, "Не получилось запустить парсинг")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "ok",
+		"data":    job,
+	})
+}
+
+// HarvestSource godoc
+//
+//	@Summary		Harvest one RSS source
+//	@Description	Harvest single source by rss_id, even if it is quarantined
+//	@Tags			sources
+//	@Accept			json
+//	@Produce		json
+//	@Param			dto	body		source.HarvestSourceDTO	true	"Harvest Source DTO"
+//	@Success		200	{object}	harvest.SourceResult
+//	@Failure		404
+//	@Failure		409
+//	@Router			/RSS/harvestSource [post]
+func (u *usecase) HarvestSource(c *gin.Context) {
+	dto := source.HarvestSourceDTO{}
+	if err := c.Bind(&dto); err != nil {
+		_ = c.Error(err)
+		lib.ResponseBadRequest(c, err, "Неправильное тело запроса")
+		return
+	}
+
+	res, err := u.articles.HarvestOne(c, dto.RssID, dto.Full)
+	if errors.Is(err, sourcesRepository.ErrNotFound) {
+		_ = c.Error(err)
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Источник " + dto.RssID + " не найден",
+			"error":   err.Error()})
+		return
+	}
+	if errors.Is(err, articleService.ErrSourceBusy) {
+		c.JSON(http.StatusConflict, gin.H{
+			"message": "Источник уже парсится",
+			"error":   err.Error()})
+		return
+	}
+	if err != nil {
+		_ = c.Error(err)
+		lib.ResponseBadRequest(c, err, "Не получилось распарсить источник")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "ok",
+		"data":    res,
+	})
+}
+
+// PreviewSource godoc
+//
+//	@Summary		Preview RSS feed
+//	@Description	Fetch and parse any feed URL, return documents that would be indexed without writing them
+//	@Tags			sources
+//	@Accept			json
+//	@Produce		json
+//	@Param			dto	body		source.PreviewSourceDTO	true	"Preview Source DTO"
+//	@Success		200	{object}	article.Preview
+//	@Router			/RSS/previewSource [post]
+func (u *usecase) PreviewSource(c *gin.Context) {
+	dto := source.PreviewSourceDTO{}
+	if err := c.Bind(&dto); err != nil {
+		_ = c.Error(err)
+		lib.ResponseBadRequest(c, err, "Неправильное тело запроса")
+		return
+	}
+
+	preview, err := u.articles.Preview(c, dto.RssURL)
+	if err != nil {
+		_ = c.Error(err)
+		lib.ResponseBadRequest(c, err, "Не получилось прочитать ленту "+dto.RssURL)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "ok",
+		"data":    preview,
+	})
+}
+
+// ReadHarvestJob godoc
+//
+//	@Summary		Read harvest job
+//	@Description	Progress of harvest job: sources done/failed, articles indexed
+//	@Tags			sources
+//	@Produce		json
+//	@Param			id	path	string	true	"Job ID"
+//	@Success		200	{object}	harvest.Job
+//	@Failure		404
+//	@Router			/RSS/harvest/{id} [get]
+func (u *usecase) ReadHarvestJob(c *gin.Context) {
+	job, err := u.harvest.Get(c, c.Param("id"))
+	if err != nil {
+		responseJobNotFound(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "ok",
+		"data":    job,
+	})
+}
+
+// StreamHarvestJob godoc
+//
+//	@Summary		Stream harvest job
+//	@Description	Server-sent events with harvest job progress until it finishes
+//	@Tags			sources
+//	@Produce		text/event-stream
+//	@Param			id	path	string	true	"Job ID"
+//	@Success		200	{object}	harvest.Job
+//	@Failure		404
+//	@Router			/RSS/harvest/{id}/stream [get]
+func (u *usecase) StreamHarvestJob(c *gin.Context) {
+	updates, unsubscribe, err := u.harvest.Subscribe(c.Param("id"))
+	if err != nil {
+		responseJobNotFound(c, err)
+		return
+	}
+	defer unsubscribe()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case job, ok := <-updates:
+			if !ok {
+				return false
+			}
+			c.SSEvent("progress", job)
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+// CancelHarvestJob godoc
+//
+//	@Summary		Cancel harvest job
+//	@Description	Stop running harvest job, already indexed articles are kept
+//	@Tags			sources
+//	@Produce		json
+//	@Param			id	path	string	true	"Job ID"
+//	@Success		200
+//	@Failure		404
+//	@Router			/RSS/harvest/{id}/cancel [post]
+func (u *usecase) CancelHarvestJob(c *gin.Context) {
+	if err := u.harvest.Cancel(c.Param("id")); err != nil {
+		responseJobNotFound(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "ok"})
 }
+
+// ReadHarvestRuns godoc
+//
+//	@Summary		Read harvest runs
+//	@Description	History of harvest runs, newest first
+//	@Tags			sources
+//	@Produce		json
+//	@Param			page	query	int	false	"Page number"
+//	@Success		200		{array}	harvest.Job
+//	@Router			/RSS/runs [get]
+func (u *usecase) ReadHarvestRuns(c *gin.Context) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err == nil && page < 1 {
+		err = errors.New("page должен быть положительным")
+	}
+	if err != nil {
+		_ = c.Error(err)
+		lib.ResponseBadRequest(c, err, "Неправильные параметры запроса")
+		return
+	}
+
+	total, err := u.harvest.CountRuns(c)
+	if err != nil {
+		_ = c.Error(err)
+		lib.ResponseBadRequest(c, err, "Не получилось посчитать запуски")
+		return
+	}
+
+	runs, err := u.harvest.FindRuns(c, page-1, pageSize)
+	if err != nil {
+		_ = c.Error(err)
+		lib.ResponseBadRequest(c, err, "Не получилось прочитать историю парсинга")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "ok",
+		"data":    runs,
+		"pagination": gin.H{
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+		},
+	})
+}
+
+// ReadHarvestRun godoc
+//
+//	@Summary		Read harvest run
+//	@Description	Harvest run with per-source results: fetch status, items, new and duplicate articles, errors
+//	@Tags			sources
+//	@Produce		json
+//	@Param			id			path	string	true	"Run ID"
+//	@Param			publisher	query	string	false	"Publisher name filter"
+//	@Success		200
+//	@Failure		404
+//	@Router			/RSS/runs/{id} [get]
+func (u *usecase) ReadHarvestRun(c *gin.Context) {
+	run, sources, err := u.harvest.FindRun(c, c.Param("id"), c.Query("publisher"))
+	if errors.Is(err, harvestService.ErrNotFound) {
+		responseJobNotFound(c, err)
+		return
+	}
+	if err != nil {
+		_ = c.Error(err)
+		lib.ResponseBadRequest(c, err, "Не получилось прочитать запуск")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "ok",
+		"data": gin.H{
+			"run":     run,
+			"sources": sources,
+		},
+	})
+}
+
+func responseJobNotFound(c *gin.Context, err error) {
+	_ = c.Error(err)
+	c.JSON(http.StatusNotFound, gin.H{
+		"message": "Задача " + c.Param("id") + " не найдена",
+		"error":   err.Error()})
+}
+
+// ReadRetentionPolicy godoc
+//
+//	@Summary		Read retention policy
+//	@Description	How many days articles are kept per country and publisher, 0 - forever
+//	@Tags			retention
+//	@Produce		json
+//	@Success		200	{object}	retention.Policy
+//	@Router			/retention/policy [get]
+func (u *usecase) ReadRetentionPolicy(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"message": "ok",
+		"data":    u.retention.Policy(),
+	})
+}
+
+// RunRetention godoc
+//
+//	@Summary		Run retention
+//	@Description	Purge articles older than retention policy and drop or archive expired partitions
+//	@Tags			retention
+//	@Produce		json
+//	@Success		200	{object}	retention.Run
+//	@Failure		409
+//	@Router			/retention/run [post]
+func (u *usecase) RunRetention(c *gin.Context) {
+	// Клиент может не дождаться ответа: очистка, прерванная посреди удаления
+	// партиций, оставила бы их наполовину перенесёнными
+	run, err := u.retention.Run(context.WithoutCancel(c), retention.TriggerManual)
+	if errors.Is(err, retentionService.ErrAlreadyRunning) {
+		c.JSON(http.StatusConflict, gin.H{
+			"message": "Очистка уже идёт",
+			"error":   err.Error()})
+		return
+	}
+	if err != nil {
+		_ = c.Error(err)
+		lib.ResponseBadRequest(c, err, "Очистка не удалась")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "ok",
+		"data":    run,
+	})
+}
+
+// ReadRetentionRuns godoc
+//
+//	@Summary		Read retention runs
+//	@Description	History of retention runs with purged documents, articles and partitions, newest first
+//	@Tags			retention
+//	@Produce		json
+//	@Param			page	query	int	false	"Page number"
+//	@Success		200		{array}	retention.Run
+//	@Router			/retention/runs [get]
+func (u *usecase) ReadRetentionRuns(c *gin.Context) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err == nil && page < 1 {
+		err = errors.New("page должен быть положительным")
+	}
+	if err != nil {
+		_ = c.Error(err)
+		lib.ResponseBadRequest(c, err, "Неправильные параметры запроса")
+		return
+	}
+
+	total, err := u.retention.CountRuns(c)
+	if err != nil {
+		_ = c.Error(err)
+		lib.ResponseBadRequest(c, err, "Не получилось посчитать очистки")
+		return
+	}
+
+	runs, err := u.retention.FindRuns(c, page-1, pageSize)
+	if err != nil {
+		_ = c.Error(err)
+		lib.ResponseBadRequest(c, err, "Не получилось прочитать историю очисток")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "ok",
+		"data":    runs,
+		"pagination": gin.H{
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+		},
+	})
+}
@@ -0,0 +1,138 @@
+package adminka
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/harvest"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/retention"
+	"github.com/mskKote/prospero_backend/internal/domain/service/harvestService"
+	"github.com/mskKote/prospero_backend/internal/domain/service/retentionService"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeHarvest отдаёт подписчику заранее заготовленные состояния задачи
+type fakeHarvest struct {
+	harvestService.IHarvestService
+	states       []harvest.Job
+	unsubscribed bool
+}
+
+func (f *fakeHarvest) Start(context.Context, string, bool) (*harvest.Job, error) {
+	return nil, harvestService.ErrAlreadyRunning
+}
+
+func (f *fakeHarvest) Subscribe(id string) (<-chan harvest.Job, func(), error) {
+	if id != "job" {
+		return nil, nil, harvestService.ErrNotFound
+	}
+	ch := make(chan harvest.Job, len(f.states))
+	for _, s := range f.states {
+		ch <- s
+	}
+	close(ch)
+	return ch, func() { f.unsubscribed = true }, nil
+}
+
+func testServer(t *testing.T, h *fakeHarvest) *httptest.Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	u := &usecase{harvest: h}
+	r := gin.New()
+	r.POST("/RSS/harvest", u.Harvest)
+	r.GET("/RSS/harvest/:id/stream", u.StreamHarvestJob)
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestStreamHarvestJob(t *testing.T) {
+	h := &fakeHarvest{states: []harvest.Job{
+		{ID: "job", Status: harvest.JobRunning, SourcesDone: 1},
+		{ID: "job", Status: harvest.JobDone, SourcesDone: 2},
+	}}
+	srv := testServer(t, h)
+
+	res, err := http.Get(srv.URL + "/RSS/harvest/job/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, _ := io.ReadAll(res.Body)
+
+	if ct := res.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/event-stream") {
+		t.Errorf("Content-Type = %q, want text/event-stream", ct)
+	}
+	// Стрим заканчивается вместе с задачей: оба состояния и ничего после
+	if n := strings.Count(string(body), "event:progress"); n != 2 {
+		t.Errorf("событий progress %d, want 2:\n%s", n, body)
+	}
+	if !strings.Contains(string(body), `"status":"done"`) {
+		t.Errorf("нет финального состояния:\n%s", body)
+	}
+	if !h.unsubscribed {
+		t.Error("подписка не снята")
+	}
+}
+
+func TestStreamHarvestJobNotFound(t *testing.T) {
+	srv := testServer(t, &fakeHarvest{})
+
+	res, err := http.Get(srv.URL + "/RSS/harvest/unknown/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", res.StatusCode)
+	}
+}
+
+func TestHarvestAlreadyRunning(t *testing.T) {
+	srv := testServer(t, &fakeHarvest{})
+
+	res, err := http.Post(srv.URL+"/RSS/harvest?full=false", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusConflict {
+		t.Errorf("status = %d, want 409", res.StatusCode)
+	}
+}
+
+// fakeRetention запоминает, был ли контекст очистки отменён
+type fakeRetention struct {
+	retentionService.IRetentionService
+	err error
+}
+
+func (f *fakeRetention) Run(ctx context.Context, trigger string) (*retention.Run, error) {
+	f.err = ctx.Err()
+	return &retention.Run{Trigger: trigger}, nil
+}
+
+// Очистка доходит до конца, даже если клиент отключился
+func TestRunRetentionOutlivesRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	f := &fakeRetention{}
+	u := &usecase{retention: f}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w := httptest.NewRecorder()
+	c, r := gin.CreateTestContext(w)
+	r.ContextWithFallback = true
+	c.Request = httptest.NewRequest(http.MethodPost, "/retention/run", nil).WithContext(ctx)
+
+	u.RunRetention(c)
+	if f.err != nil {
+		t.Errorf("контекст очистки: %v, want не отменён", f.err)
+	}
+	if w.Code != http.StatusOK {
+		t.Errorf("status = %d, want 200", w.Code)
+	}
+}
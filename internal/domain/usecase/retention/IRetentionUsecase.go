@@ -0,0 +1,9 @@
+package retention
+
+type IRetentionUsecase interface {
+	// Startup запускает очистку по расписанию retention.cron
+	Startup()
+
+	// RetentionJob удаляет статьи старше сроков хранения
+	RetentionJob()
+}
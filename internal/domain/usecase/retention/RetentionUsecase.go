@@ -0,0 +1,47 @@
+package retention
+
+import (
+	"context"
+	"github.com/go-co-op/gocron"
+	"github.com/mskKote/prospero_backend/internal/domain/entity/retention"
+	"github.com/mskKote/prospero_backend/internal/domain/service/retentionService"
+	"github.com/mskKote/prospero_backend/pkg/config"
+	"github.com/mskKote/prospero_backend/pkg/logging"
+	"go.uber.org/zap"
+	"time"
+)
+
+var (
+	logger = logging.GetLogger()
+	cfg    = config.GetConfig()
+)
+
+type usecase struct {
+	retention retentionService.IRetentionService
+}
+
+func New(r retentionService.IRetentionService) IRetentionUsecase {
+	return &usecase{retention: r}
+}
+
+// Startup - cron job очистки, расписание в app.yml.
+// Без расписания очистка запускается только из админки
+func (u *usecase) Startup() {
+	if cfg.Retention.Cron == "" {
+		logger.Info("[RETENTION] Расписание очистки не задано")
+		return
+	}
+	s := gocron.NewScheduler(time.UTC)
+	logger.Info("[RETENTION] Чистим каждые " + cfg.Retention.Cron)
+
+	if _, err := s.Cron(cfg.Retention.Cron).Do(u.RetentionJob); err != nil {
+		logger.Fatal("[RETENTION] Не стартовали CRON очистки", zap.Error(err))
+	}
+	s.StartAsync()
+}
+
+func (u *usecase) RetentionJob() {
+	if _, err := u.retention.Run(context.Background(), retention.TriggerCron); err != nil {
+		logger.Warn("[RETENTION] Очистка по расписанию не удалась", zap.Error(err))
+	}
+}
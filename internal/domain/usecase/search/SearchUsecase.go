@@ -3,10 +3,13 @@ package search
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"github.com/gin-gonic/gin"
+	"github.com/mskKote/prospero_backend/internal/adapters/db/elastic/v8/articlesSearchRepository"
 	"github.com/mskKote/prospero_backend/internal/controller/http/v1/dto"
 	"github.com/mskKote/prospero_backend/internal/domain/service/articleService"
 	"github.com/mskKote/prospero_backend/internal/domain/service/publishersService"
+	"github.com/mskKote/prospero_backend/pkg/config"
 	"github.com/mskKote/prospero_backend/pkg/lib"
 	"github.com/mskKote/prospero_backend/pkg/logging"
 	"go.uber.org/zap"
@@ -14,7 +17,10 @@ import (
 	"strconv"
 )
 
-var logger = logging.GetLogger()
+var (
+	logger = logging.GetLogger()
+	cfg    = config.GetConfig()
+)
 
 // usecase - зависимые сервисы
 type usecase struct {
@@ -38,8 +44,16 @@ func New(p *publishersService.IPublishersService, a *articleService.IArticleServ
 //	@Param			filterCountry		body	[]dto.SearchCountry		true	"Array of search countries"
 //	@Param			filterCategories	body	[]dto.SearchCategory	true	"Array of search categories"
 //	@Param			filterLanguages		body	[]dto.SearchLanguage	true	"Array of search languages"
-//	@Param			filterTime			body	dto.SearchTime			true	"Time filter"
+//	@Param			filterTime			body	dto.SearchTime			true	"Time filter: ISO-8601 dates, now-24h or last 7 days, open-ended, with time zone"
+//	@Param			filterGeo			body	[]dto.SearchGeo			false	"Map areas around article or publisher location: distance, bounding box or polygon"
+//	@Param			sort				body	string					false	"Sort mode: date (default), relevance or hybrid"
+//	@Param			size				query	int						false	"Page size, capped by search_max_page_size"
+//	@Param			paginate			query	bool					false	"Return a cursor for the next page"
+//	@Param			cursor				query	string					false	"Cursor from previous page, same filters required"
+//	@Param			facets				query	bool					false	"Count facets: countries, publishers, categories, languages, people, dates"
 //	@Success		200
+//	@Failure		400
+//	@Failure		410
 //	@Router			/grandFilter [post]
 func (u *usecase) GrandFilter(c *gin.Context) {
 	ctx := c.Request.Context()
@@ -67,13 +81,45 @@ func (u *usecase) GrandFilter(c *gin.Context) {
 	//	return
 	//}
 
-	sizeQuery := c.Query("size")
-	size, err := strconv.Atoi(sizeQuery)
+	if err := req.Validate(); err != nil {
+		_ = c.Error(err)
+		lib.ResponseBadRequest(c, err, "Неправильные фильтры")
+		return
+	}
+
+	// Размер страницы ограничен сверху: глубже - курсором
+	size, err := strconv.Atoi(c.Query("size"))
+	if err != nil || size < 1 {
+		size = cfg.SearchPageSize
+	}
+	size = min(size, cfg.SearchMaxPageSize)
+
+	facets, err := strconv.ParseBool(c.DefaultQuery("facets", "false"))
 	if err != nil {
-		size = 150
+		_ = c.Error(err)
+		lib.ResponseBadRequest(c, err, "Неправильные параметры запроса")
+		return
+	}
+	// Курсор на следующую страницу - только по просьбе клиента
+	paginate, err := strconv.ParseBool(c.DefaultQuery("paginate", "false"))
+	if err != nil {
+		_ = c.Error(err)
+		lib.ResponseBadRequest(c, err, "Неправильные параметры запроса")
+		return
 	}
 
-	grandFilter, total, err := u.articles.FindWithGrandFilter(ctx, req, size)
+	page, err := u.articles.FindWithGrandFilter(ctx, req, size, c.Query("cursor"), paginate, facets)
+	if errors.Is(err, articlesSearchRepository.ErrCursorExpired) {
+		c.JSON(http.StatusGone, gin.H{
+			"message": "Курсор устарел",
+			"error":   err.Error()})
+		return
+	}
+	if errors.Is(err, articlesSearchRepository.ErrBadCursor) || errors.Is(err, articlesSearchRepository.ErrCursorFilter) {
+		_ = c.Error(err)
+		lib.ResponseBadRequest(c, err, "Неправильный курсор")
+		return
+	}
 	if err != nil {
 		_ = c.Error(err)
 		lib.ResponseBadRequest(c, err, "Не смогли найти статьи")
@@ -81,8 +127,50 @@ func (u *usecase) GrandFilter(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"data":    grandFilter,
-		"total":   total,
+		"data":    page.Hits,
+		"total":   page.Total,
+		"cursor":  page.Cursor,
+		"facets":  page.Facets,
+		"message": "ok",
+	})
+}
+
+// MapClusters godoc
+//
+//	@Summary		Cluster articles on map
+//	@Description	Group articles matching grand filter inside viewport into geotile clusters with centroid, count and latest headlines
+//	@Tags			search
+//	@Accept			json
+//	@Produce		json
+//	@Param			dto	body	dto.MapRequest	true	"Grand filter with viewport bounds and zoom"
+//	@Success		200
+//	@Failure		400
+//	@Router			/map [post]
+func (u *usecase) MapClusters(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	req := dto.MapRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(err)
+		lib.ResponseBadRequest(c, err, "Неправильное тело запроса")
+		return
+	}
+	if err := req.Validate(); err != nil {
+		_ = c.Error(err)
+		lib.ResponseBadRequest(c, err, "Неправильные фильтры")
+		return
+	}
+
+	clusters, err := u.articles.FindClusters(ctx, req)
+	if err != nil {
+		_ = c.Error(err)
+		lib.ResponseBadRequest(c, err, "Не смогли собрать карту")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    clusters.Clusters,
+		"total":   clusters.Total,
 		"message": "ok",
 	})
 }
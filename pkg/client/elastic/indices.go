@@ -0,0 +1,409 @@
+package elastic
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/indices/create"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/indices/updatealiases"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/conflicts"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/optype"
+	"github.com/mskKote/prospero_backend/pkg/lib"
+	"go.uber.org/zap"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// ErrVersionNotBumped - маппинг в коде изменился, а версия индекса осталась прежней
+var ErrVersionNotBumped = errors.New("маппинг изменился, нужна новая версия индекса")
+
+// Как часто спрашиваем ES о ходе _reindex
+var reindexPoll = 5 * time.Second
+
+// VersionedIndex - логический индекс. Данные лежат в физическом <Alias>_v<Version>,
+// читают его через алиас Alias, пишут через WriteAlias.
+// При смене маппинга поднимают Version, и Upgrade переносит данные в новый индекс
+type VersionedIndex struct {
+	Alias   string
+	Version int
+	// Настройки и маппинг физического индекса
+	Request *create.Request
+}
+
+func (v *VersionedIndex) Name() string {
+	return fmt.Sprintf("%s_v%d", v.Alias, v.Version)
+}
+
+func (v *VersionedIndex) WriteAlias() string {
+	return WriteAlias(v.Alias)
+}
+
+// WriteAlias - алиас записи логического индекса
+func WriteAlias(alias string) string {
+	return alias + "_write"
+}
+
+// hash - отпечаток настроек и маппинга, хранится в _meta физического индекса
+func (v *VersionedIndex) hash() (string, error) {
+	return requestHash(v.Request)
+}
+
+func requestHash(request *create.Request, extra ...string) (string, error) {
+	req := *request
+	if req.Mappings != nil {
+		mappings := *req.Mappings
+		mappings.Meta_ = nil
+		req.Mappings = &mappings
+	}
+	data, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	for _, e := range extra {
+		data = append(data, e...)
+	}
+	sum := sha1.Sum(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// IndexState - что сейчас за алиасами логического индекса
+type IndexState struct {
+	Alias string `json:"alias"`
+	// Физический индекс за алиасом чтения и за алиасом записи
+	Read  string `json:"read,omitempty"`
+	Write string `json:"write,omitempty"`
+	// Какой физический индекс нужен коду
+	Wanted string `json:"wanted"`
+	// Индекс создан до версионирования: вместо алиаса индекс с тем же именем
+	Legacy bool `json:"legacy,omitempty"`
+	// Маппинг в коде не совпадает с маппингом индекса той же версии
+	MappingChanged bool  `json:"mappingChanged,omitempty"`
+	Docs           int64 `json:"docs"`
+}
+
+// Outdated - данные надо перенести в новую версию индекса
+func (s *IndexState) Outdated() bool {
+	return s.Read != s.Wanted || s.Write != s.Wanted
+}
+
+// Indices управляет версиями индексов: создаёт, сверяет маппинг,
+// переносит данные через _reindex и переключает алиасы
+type Indices struct {
+	client *elasticsearch.TypedClient
+}
+
+func NewIndices(client *elasticsearch.TypedClient) *Indices {
+	return &Indices{client}
+}
+
+func (i *Indices) State(ctx context.Context, v *VersionedIndex) (*IndexState, error) {
+	s := &IndexState{Alias: v.Alias, Wanted: v.Name()}
+
+	// Во время переноса алиас чтения смотрит и на старый индекс, и на новый:
+	// читаем пока из старого
+	reads, err := i.aliasTargets(ctx, v.Alias)
+	if err != nil {
+		return nil, err
+	}
+	for _, index := range reads {
+		if s.Read == "" || s.Read == s.Wanted {
+			s.Read = index
+		}
+	}
+	if s.Write, err = i.aliasTarget(ctx, v.WriteAlias()); err != nil {
+		return nil, err
+	}
+	if s.Read == "" {
+		if s.Legacy, err = i.exists(ctx, v.Alias); err != nil {
+			return nil, err
+		}
+		if s.Legacy {
+			s.Read = v.Alias
+		}
+	}
+
+	if s.Read == "" {
+		return s, nil
+	}
+	if s.Read == s.Wanted {
+		wanted, err := v.hash()
+		if err != nil {
+			return nil, err
+		}
+		current, err := i.mappingHash(ctx, s.Read)
+		if err != nil {
+			return nil, err
+		}
+		s.MappingChanged = current != wanted
+	}
+	count, err := i.client.Count().Index(s.Read).Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.Docs = count.Count
+	return s, nil
+}
+
+// Ensure создаёт индекс, если его нет, и сообщает о смене маппинга.
+// С upgrade устаревший индекс сразу переносится в новую версию
+func (i *Indices) Ensure(ctx context.Context, v *VersionedIndex, upgrade bool) (*IndexState, error) {
+	s, err := i.State(ctx, v)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case s.MappingChanged:
+		logger.Error(fmt.Sprintf("Маппинг %s изменился без новой версии, поднимите версию после %s", v.Alias, s.Read))
+		return s, ErrVersionNotBumped
+	case s.Read == "":
+		if err := i.create(ctx, v); err != nil {
+			return nil, err
+		}
+		if err := i.updateAliases(ctx,
+			addAlias(v.Name(), v.Alias, false),
+			addAlias(v.Name(), v.WriteAlias(), true)); err != nil {
+			return nil, err
+		}
+		logger.Info(fmt.Sprintf("Создали индекс %s за алиасами %s и %s", v.Name(), v.Alias, v.WriteAlias()))
+		return i.State(ctx, v)
+	case !s.Outdated():
+		return s, nil
+	case !upgrade:
+		logger.Warn(fmt.Sprintf("Индекс %s устарел: %s, нужен %s", v.Alias, s.Read, s.Wanted))
+		return s, nil
+	}
+	return i.Upgrade(ctx, v)
+}
+
+// Upgrade переносит данные в новую версию индекса без простоя поиска:
+//  1. создаёт новый физический индекс;
+//  2. переключает на него алиас записи и добавляет его под алиас чтения -
+//     новые документы сразу видны, пока копируются старые;
+//  3. копирует старые документы через _reindex, не перезаписывая новые (op_type=create);
+//  4. одним запросом _aliases снимает старый индекс с алиаса чтения.
+//
+// Индекс без версии алиасом чтения не накрыть: новые документы увидят только после шага 4.
+// Прерванный перенос можно запустить заново: шаги повторяемы.
+// Старый индекс остаётся, кроме индекса без версии - его место занимает алиас
+func (i *Indices) Upgrade(ctx context.Context, v *VersionedIndex) (*IndexState, error) {
+	s, err := i.State(ctx, v)
+	if err != nil {
+		return nil, err
+	}
+	if s.MappingChanged {
+		return s, ErrVersionNotBumped
+	}
+	if !s.Outdated() {
+		return s, nil
+	}
+	from := s.Read
+
+	// 1
+	exists, err := i.exists(ctx, v.Name())
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		if err := i.create(ctx, v); err != nil {
+			return nil, err
+		}
+	}
+
+	// 2
+	if s.Write != v.Name() {
+		actions := []types.IndicesAction{addAlias(v.Name(), v.WriteAlias(), true)}
+		if s.Write != "" {
+			actions = append(actions, removeAlias(s.Write, v.WriteAlias()))
+		}
+		if !s.Legacy {
+			actions = append(actions, addAlias(v.Name(), v.Alias, false))
+		}
+		if err := i.updateAliases(ctx, actions...); err != nil {
+			return nil, err
+		}
+		logger.Info(fmt.Sprintf("Запись %s переключили на %s", v.WriteAlias(), v.Name()))
+	}
+
+	// 3
+	if from != "" && from != v.Name() {
+		if err := i.reindex(ctx, from, v.Name(), nil); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := i.client.Indices.Refresh().Index(v.Name()).Do(ctx); err != nil {
+		return nil, err
+	}
+
+	// 4
+	actions := []types.IndicesAction{addAlias(v.Name(), v.Alias, false)}
+	switch {
+	case s.Legacy:
+		actions = append(actions, types.IndicesAction{RemoveIndex: &types.RemoveIndexAction{Index: &from}})
+	case from != "" && from != v.Name():
+		actions = append(actions, removeAlias(from, v.Alias))
+	}
+	if err := i.updateAliases(ctx, actions...); err != nil {
+		return nil, err
+	}
+	logger.Info(fmt.Sprintf("Чтение %s переключили с %s на %s", v.Alias, from, v.Name()))
+
+	return i.State(ctx, v)
+}
+
+type reindexStatus struct {
+	Total            int64 `json:"total"`
+	Created          int64 `json:"created"`
+	VersionConflicts int64 `json:"version_conflicts"`
+}
+
+// reindex запускает _reindex задачей и ждёт её, сообщая о ходе в лог.
+// script может переложить документ в другой индекс, nil - без скрипта
+func (i *Indices) reindex(ctx context.Context, from, to string, script *types.InlineScript) error {
+	req := i.client.Reindex().
+		Source(&types.ReindexSource{Index: []string{from}}).
+		Dest(&types.ReindexDestination{Index: to, OpType: &optype.Create}).
+		// Документы, уже записанные в новый индекс, новее копий из старого
+		Conflicts(conflicts.Proceed).
+		WaitForCompletion(false)
+	if script != nil {
+		req.Script(script)
+	}
+	res, err := req.Do(ctx)
+	if err != nil {
+		return err
+	}
+	task := fmt.Sprint(res.Task)
+	logger.Info(fmt.Sprintf("Копируем %s в %s, задача %s", from, to, task))
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(reindexPoll):
+		}
+
+		t, err := i.client.Tasks.Get(task).Do(ctx)
+		if err != nil {
+			return err
+		}
+		var status reindexStatus
+		if len(t.Task.Status) > 0 {
+			_ = json.Unmarshal(t.Task.Status, &status)
+		}
+		logger.Info(fmt.Sprintf("Копируем %s в %s: %d из %d, уже были %d",
+			from, to, status.Created+status.VersionConflicts, status.Total, status.VersionConflicts))
+
+		if t.Completed {
+			if t.Error != nil {
+				reason := t.Error.Type
+				if t.Error.Reason != nil {
+					reason += ": " + *t.Error.Reason
+				}
+				return errors.New(reason)
+			}
+			return nil
+		}
+	}
+}
+
+func (i *Indices) create(ctx context.Context, v *VersionedIndex) error {
+	hash, err := v.hash()
+	if err != nil {
+		return err
+	}
+	req := *v.Request
+	mappings := types.TypeMapping{}
+	if req.Mappings != nil {
+		mappings = *req.Mappings
+	}
+	meta, _ := json.Marshal(hash)
+	version, _ := json.Marshal(v.Version)
+	mappings.Meta_ = types.Metadata{"hash": meta, "version": version}
+	req.Mappings = &mappings
+
+	if _, err := i.client.Indices.Create(v.Name()).Request(&req).Do(ctx); err != nil {
+		logger.Error("Не создали индекс "+v.Name(), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (i *Indices) mappingHash(ctx context.Context, index string) (string, error) {
+	res, err := i.client.Indices.GetMapping().Index(index).Do(ctx)
+	if err != nil {
+		return "", err
+	}
+	var hash string
+	if record, ok := res[index]; ok && record.Mappings.Meta_ != nil {
+		_ = json.Unmarshal(record.Mappings.Meta_["hash"], &hash)
+	}
+	return hash, nil
+}
+
+// aliasTarget - физический индекс за алиасом или пустая строка, если алиаса нет
+func (i *Indices) aliasTarget(ctx context.Context, alias string) (string, error) {
+	indices, err := i.aliasTargets(ctx, alias)
+	if err != nil || len(indices) == 0 {
+		return "", err
+	}
+	return indices[0], nil
+}
+
+// aliasTargets - индексы за алиасом по имени
+func (i *Indices) aliasTargets(ctx context.Context, alias string) ([]string, error) {
+	exists, err := i.client.Indices.ExistsAlias(alias).Do(ctx)
+	if err != nil || !exists {
+		return nil, err
+	}
+	res, err := i.client.Indices.GetAlias().Name(alias).Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	indices := make([]string, 0, len(res))
+	for index := range res {
+		indices = append(indices, index)
+	}
+	sort.Strings(indices)
+	return indices, nil
+}
+
+func (i *Indices) exists(ctx context.Context, index string) (bool, error) {
+	resp, err := i.client.Indices.Exists(index).Perform(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	return resp.StatusCode == http.StatusOK, nil
+}
+
+func (i *Indices) updateAliases(ctx context.Context, actions ...types.IndicesAction) error {
+	_, err := i.client.Indices.UpdateAliases().
+		Request(&updatealiases.Request{Actions: actions}).
+		Do(ctx)
+	return err
+}
+
+func addAlias(index, alias string, write bool) types.IndicesAction {
+	action := &types.AddAction{
+		Index: lib.PointerFrom(index),
+		Alias: lib.PointerFrom(alias),
+	}
+	if write {
+		action.IsWriteIndex = lib.PointerFrom(true)
+	}
+	return types.IndicesAction{Add: action}
+}
+
+func removeAlias(index, alias string) types.IndicesAction {
+	return types.IndicesAction{Remove: &types.RemoveAction{
+		Index: lib.PointerFrom(index),
+		Alias: lib.PointerFrom(alias),
+	}}
+}
@@ -0,0 +1,342 @@
+package elastic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/indices/create"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeES - индексы и алиасы в памяти, понимает только запросы Indices
+type fakeES struct {
+	mu sync.Mutex
+	// Индекс -> хэш маппинга из _meta и число документов
+	hashes map[string]string
+	docs   map[string]int64
+	// Алиас -> индексы по имени
+	aliases map[string][]string
+	// Журнал изменений: create, alias, reindex
+	log []string
+	// Задача _reindex падает с этой причиной
+	reindexErr string
+}
+
+func newTestIndices(t *testing.T) (*Indices, *fakeES) {
+	t.Helper()
+	poll := reindexPoll
+	t.Cleanup(func() { reindexPoll = poll })
+	reindexPoll = time.Millisecond
+
+	es := &fakeES{hashes: map[string]string{}, docs: map[string]int64{}, aliases: map[string][]string{}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		es.mu.Lock()
+		status, res := es.handle(r.Method, strings.Trim(r.URL.Path, "/"), body)
+		es.mu.Unlock()
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(res))
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := elasticsearch.NewTypedClient(elasticsearch.Config{Addresses: []string{srv.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewIndices(client), es
+}
+
+func (es *fakeES) handle(method, path string, body []byte) (int, string) {
+	parts := strings.Split(path, "/")
+	notFound := `{"error":{"type":"index_not_found_exception","reason":"no such index"},"status":404}`
+
+	switch {
+	case parts[0] == "_alias":
+		indices, ok := es.aliases[parts[1]]
+		if !ok {
+			return http.StatusNotFound, notFound
+		}
+		res := make([]string, len(indices))
+		for i, index := range indices {
+			res[i] = fmt.Sprintf(`%q:{"aliases":{%q:{}}}`, index, parts[1])
+		}
+		return http.StatusOK, "{" + strings.Join(res, ",") + "}"
+
+	case parts[0] == "_aliases":
+		var req struct {
+			Actions []map[string]map[string]interface{} `json:"actions"`
+		}
+		_ = json.Unmarshal(body, &req)
+		for _, action := range req.Actions {
+			for kind, a := range action {
+				switch kind {
+				case "add":
+					es.addAlias(a["alias"].(string), a["index"].(string))
+				case "remove":
+					es.removeAlias(a["alias"].(string), a["index"].(string))
+				case "remove_index":
+					delete(es.hashes, a["index"].(string))
+					delete(es.docs, a["index"].(string))
+				}
+				es.log = append(es.log, fmt.Sprintf("%s %v", kind, a["alias"]))
+			}
+		}
+		return http.StatusOK, `{"acknowledged":true}`
+
+	case parts[0] == "_reindex":
+		var req struct {
+			Source struct {
+				Index []string `json:"index"`
+			} `json:"source"`
+			Dest struct {
+				Index string `json:"index"`
+			} `json:"dest"`
+		}
+		_ = json.Unmarshal(body, &req)
+		from, to := req.Source.Index[0], req.Dest.Index
+		es.docs[to] += es.docs[from]
+		es.log = append(es.log, "reindex "+from+" "+to)
+		return http.StatusOK, `{"task":"node:1"}`
+
+	case parts[0] == "_tasks":
+		if es.reindexErr != "" {
+			return http.StatusOK, fmt.Sprintf(`{"completed":true,"task":{"status":{}},`+
+				`"error":{"type":"illegal_argument_exception","reason":%q}}`, es.reindexErr)
+		}
+		return http.StatusOK, `{"completed":true,"task":{"status":{"total":2,"created":2}}}`
+	}
+
+	index := es.resolve(parts[0])
+	_, exists := es.hashes[index]
+	switch {
+	case len(parts) == 1 && method == http.MethodHead:
+		if !exists {
+			return http.StatusNotFound, ""
+		}
+		return http.StatusOK, ""
+	case len(parts) == 1 && method == http.MethodPut:
+		var req struct {
+			Mappings struct {
+				Meta map[string]interface{} `json:"_meta"`
+			} `json:"mappings"`
+		}
+		_ = json.Unmarshal(body, &req)
+		hash, _ := req.Mappings.Meta["hash"].(string)
+		es.hashes[parts[0]] = hash
+		es.log = append(es.log, "create "+parts[0])
+		return http.StatusOK, fmt.Sprintf(`{"acknowledged":true,"shards_acknowledged":true,"index":%q}`, parts[0])
+	case !exists:
+		return http.StatusNotFound, notFound
+	case parts[1] == "_mapping":
+		meta := ""
+		if es.hashes[index] != "" {
+			meta = fmt.Sprintf(`"_meta":{"hash":%q}`, es.hashes[index])
+		}
+		return http.StatusOK, fmt.Sprintf(`{%q:{"mappings":{%s}}}`, index, meta)
+	case parts[1] == "_count":
+		return http.StatusOK, fmt.Sprintf(`{"count":%d,"_shards":{"total":1,"successful":1,"skipped":0,"failed":0}}`, es.docs[index])
+	case parts[1] == "_refresh":
+		return http.StatusOK, `{"_shards":{"total":1,"successful":1,"failed":0}}`
+	}
+	return http.StatusBadRequest, `{"error":{"type":"unknown","reason":"` + method + " " + path + `"},"status":400}`
+}
+
+func (es *fakeES) addAlias(alias, index string) {
+	for _, i := range es.aliases[alias] {
+		if i == index {
+			return
+		}
+	}
+	es.aliases[alias] = append(es.aliases[alias], index)
+	sort.Strings(es.aliases[alias])
+}
+
+func (es *fakeES) removeAlias(alias, index string) {
+	var rest []string
+	for _, i := range es.aliases[alias] {
+		if i != index {
+			rest = append(rest, i)
+		}
+	}
+	if len(rest) == 0 {
+		delete(es.aliases, alias)
+		return
+	}
+	es.aliases[alias] = rest
+}
+
+// alias - индексы за алиасом через запятую
+func (es *fakeES) alias(name string) string {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	return strings.Join(es.aliases[name], ",")
+}
+
+// resolve - первый физический индекс за алиасом или сам индекс
+func (es *fakeES) resolve(name string) string {
+	if indices, ok := es.aliases[name]; ok {
+		return indices[0]
+	}
+	return name
+}
+
+func testIndex(version int, field string) *VersionedIndex {
+	return &VersionedIndex{
+		Alias:   "articles",
+		Version: version,
+		Request: &create.Request{Mappings: &types.TypeMapping{
+			Properties: map[string]types.Property{field: types.NewKeywordProperty()},
+		}},
+	}
+}
+
+func TestHashIgnoresMeta(t *testing.T) {
+	v := testIndex(1, "name")
+	before, _ := v.hash()
+	v.Request.Mappings.Meta_ = types.Metadata{"hash": json.RawMessage(`"x"`)}
+	after, _ := v.hash()
+	if before != after {
+		t.Error("hash() зависит от _meta")
+	}
+	if other, _ := testIndex(1, "title").hash(); other == before {
+		t.Error("hash() не заметил смену маппинга")
+	}
+}
+
+// Пустой кластер: индекс v1 за алиасами чтения и записи, второй Ensure ничего не меняет
+func TestEnsureCreates(t *testing.T) {
+	indices, es := newTestIndices(t)
+	ctx := context.Background()
+
+	s, err := indices.Ensure(ctx, testIndex(1, "name"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Read != "articles_v1" || s.Write != "articles_v1" || s.Outdated() || s.MappingChanged {
+		t.Errorf("Ensure() = %+v", s)
+	}
+	if es.alias("articles_write") != "articles_v1" {
+		t.Errorf("articles_write -> %q", es.alias("articles_write"))
+	}
+
+	changes := len(es.log)
+	if _, err := indices.Ensure(ctx, testIndex(1, "name"), true); err != nil {
+		t.Fatal(err)
+	}
+	if len(es.log) != changes {
+		t.Errorf("повторный Ensure() изменил кластер: %v", es.log[changes:])
+	}
+}
+
+func TestEnsureVersionNotBumped(t *testing.T) {
+	indices, _ := newTestIndices(t)
+	ctx := context.Background()
+	if _, err := indices.Ensure(ctx, testIndex(1, "name"), false); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := indices.Ensure(ctx, testIndex(1, "title"), true)
+	if !errors.Is(err, ErrVersionNotBumped) || !s.MappingChanged {
+		t.Errorf("Ensure() = %+v, %v, want ErrVersionNotBumped", s, err)
+	}
+	if _, err := indices.Upgrade(ctx, testIndex(1, "title")); !errors.Is(err, ErrVersionNotBumped) {
+		t.Errorf("Upgrade() error = %v, want ErrVersionNotBumped", err)
+	}
+}
+
+// Запись переключается до _reindex, новый индекс сразу читается вместе со старым,
+// старый снимается с чтения после _reindex
+func TestUpgrade(t *testing.T) {
+	indices, es := newTestIndices(t)
+	ctx := context.Background()
+	if _, err := indices.Ensure(ctx, testIndex(1, "name"), false); err != nil {
+		t.Fatal(err)
+	}
+	es.docs["articles_v1"] = 2
+
+	// Без upgrade устаревший индекс только отмечается
+	s, err := indices.Ensure(ctx, testIndex(2, "title"), false)
+	if err != nil || !s.Outdated() || s.Read != "articles_v1" {
+		t.Fatalf("Ensure() = %+v, %v, want устаревший v1", s, err)
+	}
+
+	es.log = nil
+	s, err = indices.Ensure(ctx, testIndex(2, "title"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "[create articles_v2 add articles_write remove articles_write add articles " +
+		"reindex articles_v1 articles_v2 add articles remove articles]"
+	if fmt.Sprint(es.log) != want {
+		t.Errorf("шаги = %v\nwant %s", es.log, want)
+	}
+	if s.Read != "articles_v2" || s.Write != "articles_v2" || s.Docs != 2 || es.alias("articles") != "articles_v2" {
+		t.Errorf("Upgrade() = %+v, articles -> %s", s, es.alias("articles"))
+	}
+	if _, ok := es.hashes["articles_v1"]; !ok {
+		t.Error("старый индекс удалён")
+	}
+}
+
+// Индекс без версии заменяется алиасом
+func TestUpgradeLegacy(t *testing.T) {
+	indices, es := newTestIndices(t)
+	ctx := context.Background()
+	es.hashes["articles"] = ""
+	es.docs["articles"] = 2
+
+	s, err := indices.State(ctx, testIndex(1, "name"))
+	if err != nil || !s.Legacy || s.Read != "articles" || s.Docs != 2 {
+		t.Fatalf("State() = %+v, %v, want legacy", s, err)
+	}
+
+	s, err = indices.Upgrade(ctx, testIndex(1, "name"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Legacy || s.Read != "articles_v1" || s.Docs != 2 {
+		t.Errorf("Upgrade() = %+v", s)
+	}
+	if _, ok := es.hashes["articles"]; ok {
+		t.Error("индекс без версии остался")
+	}
+}
+
+// Упавший _reindex оставляет чтение на обоих индексах, повторный запуск доводит перенос
+func TestUpgradeReindexFailed(t *testing.T) {
+	indices, es := newTestIndices(t)
+	ctx := context.Background()
+	if _, err := indices.Ensure(ctx, testIndex(1, "name"), false); err != nil {
+		t.Fatal(err)
+	}
+
+	es.reindexErr = "disk full"
+	if _, err := indices.Upgrade(ctx, testIndex(2, "title")); err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Fatalf("Upgrade() error = %v, want disk full", err)
+	}
+	if es.alias("articles") != "articles_v1,articles_v2" || es.alias("articles_write") != "articles_v2" {
+		t.Errorf("алиасы после ошибки: %v", es.aliases)
+	}
+	// Пока перенос не закончен, состояние смотрит на старый индекс
+	if s, err := indices.State(ctx, testIndex(2, "title")); err != nil || s.Read != "articles_v1" || !s.Outdated() {
+		t.Errorf("State() = %+v, %v, want чтение из articles_v1", s, err)
+	}
+
+	es.reindexErr = ""
+	s, err := indices.Upgrade(ctx, testIndex(2, "title"))
+	if err != nil || s.Outdated() {
+		t.Errorf("повторный Upgrade() = %+v, %v", s, err)
+	}
+}
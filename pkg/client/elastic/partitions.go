@@ -0,0 +1,400 @@
+package elastic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/indices/create"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/indices/putindextemplate"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/expandwildcard"
+	"github.com/mskKote/prospero_backend/pkg/lib"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Периоды партиций
+const (
+	PeriodDaily   = "daily"
+	PeriodMonthly = "monthly"
+)
+
+var ErrUnknownPeriod = errors.New("период партиций: daily или monthly")
+
+// Партиции чужих шаблонов не перекрываем
+const templatePriority = 100
+
+// Перекладывает документ старого индекса в партицию по дате
+const partitionScript = `
+def value = ctx._source[params.field];
+if (value == null) { ctx.op = 'noop'; return; }
+ZonedDateTime t = ZonedDateTime.parse(value.toString()).withZoneSameInstant(ZoneOffset.UTC);
+ctx._index = params.prefix + t.format(DateTimeFormatter.ofPattern(params.pattern));`
+
+// PartitionedIndex - логический индекс из партиций по времени:
+// <Alias>_v<Version>_2024.05 при monthly или <Alias>_v<Version>_2024.05.17 при daily.
+// Партицию создаёт шаблон индекса при первой записи, читают все партиции через алиас Alias.
+// Смена маппинга или периода - новая Version, как у VersionedIndex
+type PartitionedIndex struct {
+	Alias   string
+	Version int
+	Period  string
+	// Поле даты документа, по нему выбирается партиция
+	TimeField string
+	// Настройки и маппинг партиций
+	Request *create.Request
+	// Painless-скрипт, который правит документы прошлых версий при переносе в партиции,
+	// и его параметры
+	Migrate       string
+	MigrateParams map[string]json.RawMessage
+}
+
+// Template - имя шаблона партиций текущей версии
+func (p *PartitionedIndex) Template() string {
+	return fmt.Sprintf("%s_v%d", p.Alias, p.Version)
+}
+
+func (p *PartitionedIndex) prefix() string {
+	return p.Template() + "_"
+}
+
+func (p *PartitionedIndex) layout() string {
+	if p.Period == PeriodDaily {
+		return "2006.01.02"
+	}
+	return "2006.01"
+}
+
+// Partition - партиция для документа с датой t
+func (p *PartitionedIndex) Partition(t time.Time) string {
+	return p.prefix() + t.UTC().Format(p.layout())
+}
+
+// Bounds - период, который хранит партиция; false, если имя не партиции этой версии
+func (p *PartitionedIndex) Bounds(index string) (start, end time.Time, ok bool) {
+	if !strings.HasPrefix(index, p.prefix()) {
+		return start, end, false
+	}
+	start, err := time.Parse(p.layout(), strings.TrimPrefix(index, p.prefix()))
+	if err != nil {
+		return start, end, false
+	}
+	if p.Period == PeriodDaily {
+		return start, start.AddDate(0, 0, 1), true
+	}
+	return start, start.AddDate(0, 1, 0), true
+}
+
+func (p *PartitionedIndex) validate() error {
+	if p.Period != PeriodDaily && p.Period != PeriodMonthly {
+		return fmt.Errorf("%s: %w", p.Alias, ErrUnknownPeriod)
+	}
+	return nil
+}
+
+// Partition - физический индекс за период
+type Partition struct {
+	Index string    `json:"index"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Docs  int64     `json:"docs"`
+	// Закрыт архивированием: данные на диске, в поиске не участвует
+	Closed bool `json:"closed,omitempty"`
+}
+
+// PartitionedState - шаблон и партиции логического индекса
+type PartitionedState struct {
+	Alias    string `json:"alias"`
+	Template string `json:"template"`
+	// Шаблон есть и добавляет партиции под алиас
+	TemplateExists bool `json:"templateExists"`
+	Active         bool `json:"active"`
+	// Маппинг в коде не совпадает с шаблоном той же версии
+	MappingChanged bool         `json:"mappingChanged,omitempty"`
+	Partitions     []*Partition `json:"partitions"`
+	// Индексы под алиасом из прошлых версий, их данные ещё не в партициях
+	Legacy []string `json:"legacy,omitempty"`
+	Docs   int64    `json:"docs"`
+}
+
+// Outdated - данные надо перенести в партиции текущей версии
+func (s *PartitionedState) Outdated() bool {
+	return !s.Active || len(s.Legacy) > 0
+}
+
+func (p *PartitionedIndex) hash() (string, error) {
+	return requestHash(p.Request, p.Period, p.TimeField)
+}
+
+func (i *Indices) PartitionedState(ctx context.Context, p *PartitionedIndex) (*PartitionedState, error) {
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	s := &PartitionedState{Alias: p.Alias, Template: p.Template(), Partitions: []*Partition{}}
+
+	exists, err := i.client.Indices.ExistsIndexTemplate(p.Template()).Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		res, err := i.client.Indices.GetIndexTemplate().Name(p.Template()).Do(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range res.IndexTemplates {
+			s.TemplateExists = true
+			var current string
+			_ = json.Unmarshal(t.IndexTemplate.Meta_["hash"], &current)
+			wanted, err := p.hash()
+			if err != nil {
+				return nil, err
+			}
+			s.MappingChanged = current != wanted
+			if t.IndexTemplate.Template != nil {
+				_, s.Active = t.IndexTemplate.Template.Aliases[p.Alias]
+			}
+		}
+	}
+
+	if s.Partitions, err = i.partitions(ctx, p); err != nil {
+		return nil, err
+	}
+	for _, part := range s.Partitions {
+		s.Docs += part.Docs
+	}
+
+	// Под алиасом всё, что не партиция этой версии, - наследство
+	aliased, err := i.client.Indices.ExistsAlias(p.Alias).Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if aliased {
+		res, err := i.client.Indices.GetAlias().Name(p.Alias).Do(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for index := range res {
+			if !strings.HasPrefix(index, p.prefix()) {
+				s.Legacy = append(s.Legacy, index)
+			}
+		}
+	} else if legacy, err := i.exists(ctx, p.Alias); err != nil {
+		return nil, err
+	} else if legacy {
+		// Индекс до версионирования с именем алиаса
+		s.Legacy = append(s.Legacy, p.Alias)
+	}
+	sort.Strings(s.Legacy)
+	return s, nil
+}
+
+// partitions - партиции текущей версии по возрастанию дат, включая закрытые
+func (i *Indices) partitions(ctx context.Context, p *PartitionedIndex) ([]*Partition, error) {
+	records, err := i.client.Cat.Indices().
+		Index(p.prefix() + "*").
+		ExpandWildcards(expandwildcard.All).
+		Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	partitions := []*Partition{}
+	for _, r := range records {
+		if r.Index == nil {
+			continue
+		}
+		start, end, ok := p.Bounds(*r.Index)
+		if !ok {
+			continue
+		}
+		docs, _ := strconv.ParseInt(r.DocsCount, 10, 64)
+		partitions = append(partitions, &Partition{
+			Index:  *r.Index,
+			Start:  start,
+			End:    end,
+			Docs:   docs,
+			Closed: r.Status != nil && *r.Status == "close",
+		})
+	}
+	sort.Slice(partitions, func(a, b int) bool { return partitions[a].Start.Before(partitions[b].Start) })
+	return partitions, nil
+}
+
+// EnsurePartitioned создаёт шаблон партиций и сообщает о смене маппинга.
+// Пока под алиасом есть индексы прошлых версий, шаблон не добавляет партиции
+// под алиас, чтобы поиск не видел статьи дважды. С upgrade данные сразу переносятся
+func (i *Indices) EnsurePartitioned(ctx context.Context, p *PartitionedIndex, upgrade bool) (*PartitionedState, error) {
+	s, err := i.PartitionedState(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case s.MappingChanged:
+		logger.Error(fmt.Sprintf("Маппинг %s изменился без новой версии, поднимите версию после %s", p.Alias, p.Template()))
+		return s, ErrVersionNotBumped
+	case !s.Outdated():
+		return s, nil
+	case len(s.Legacy) == 0:
+		// Новая установка или перенос, прерванный перед переключением алиаса
+		return i.UpgradePartitioned(ctx, p)
+	case !s.TemplateExists:
+		if err := i.putTemplate(ctx, p, false); err != nil {
+			return nil, err
+		}
+		logger.Info(fmt.Sprintf("Создали шаблон %s, партиции пока не под алиасом %s", p.Template(), p.Alias))
+	}
+	if !upgrade {
+		logger.Warn(fmt.Sprintf("Индекс %s устарел: %v, нужны партиции %s", p.Alias, s.Legacy, p.Template()))
+		return i.PartitionedState(ctx, p)
+	}
+	return i.UpgradePartitioned(ctx, p)
+}
+
+// UpgradePartitioned переносит индексы прошлых версий в партиции без простоя поиска:
+//  1. создаёт шаблон партиций без алиаса - новые статьи уже пишутся в партиции;
+//  2. копирует старые документы _reindex со скриптом, выбирающим партицию по дате;
+//  3. включает алиас в шаблоне и одним запросом _aliases ставит под алиас партиции
+//     и снимает с него старые индексы.
+//
+// Прерванный перенос можно запустить заново. Старые индексы остаются,
+// кроме индекса без версии - его место занимает алиас
+func (i *Indices) UpgradePartitioned(ctx context.Context, p *PartitionedIndex) (*PartitionedState, error) {
+	s, err := i.PartitionedState(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	if s.MappingChanged {
+		return s, ErrVersionNotBumped
+	}
+	if !s.Outdated() {
+		return s, nil
+	}
+
+	// 1
+	if !s.TemplateExists {
+		if err := i.putTemplate(ctx, p, len(s.Legacy) == 0); err != nil {
+			return nil, err
+		}
+	}
+
+	// 2
+	pattern, _ := json.Marshal(strings.NewReplacer("2006", "yyyy", "01", "MM", "02", "dd").Replace(p.layout()))
+	prefix, _ := json.Marshal(p.prefix())
+	field, _ := json.Marshal(p.TimeField)
+	params := map[string]json.RawMessage{"pattern": pattern, "prefix": prefix, "field": field}
+	for k, v := range p.MigrateParams {
+		params[k] = v
+	}
+	script := &types.InlineScript{Source: p.Migrate + partitionScript, Params: params}
+	for _, from := range s.Legacy {
+		// Индекс назначения переопределяет скрипт
+		if err := i.reindex(ctx, from, p.Partition(time.Now()), script); err != nil {
+			return nil, err
+		}
+	}
+
+	// 3
+	if err := i.putTemplate(ctx, p, true); err != nil {
+		return nil, err
+	}
+	if _, err := i.client.Indices.Refresh().Index(p.prefix() + "*").Do(ctx); err != nil {
+		return nil, err
+	}
+	partitions, err := i.partitions(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	var actions []types.IndicesAction
+	for _, part := range partitions {
+		if !part.Closed {
+			actions = append(actions, addAlias(part.Index, p.Alias, false))
+		}
+	}
+	for _, from := range s.Legacy {
+		if from == p.Alias {
+			actions = append(actions, types.IndicesAction{RemoveIndex: &types.RemoveIndexAction{Index: lib.PointerFrom(from)}})
+		} else {
+			actions = append(actions, removeAlias(from, p.Alias))
+		}
+	}
+	// Алиас записи прошлой версии больше не нужен
+	if write, err := i.aliasTarget(ctx, WriteAlias(p.Alias)); err != nil {
+		return nil, err
+	} else if write != "" {
+		actions = append(actions, removeAlias(write, WriteAlias(p.Alias)))
+	}
+	if len(actions) > 0 {
+		if err := i.updateAliases(ctx, actions...); err != nil {
+			return nil, err
+		}
+	}
+	logger.Info(fmt.Sprintf("Чтение %s переключили на партиции %s: %d", p.Alias, p.Template(), len(partitions)))
+
+	return i.PartitionedState(ctx, p)
+}
+
+// DropPartitions удаляет партиции, целиком лежащие до before.
+// С archive партиции не удаляются, а снимаются с алиаса и закрываются
+func (i *Indices) DropPartitions(ctx context.Context, p *PartitionedIndex, before time.Time, archive bool) ([]*Partition, error) {
+	partitions, err := i.partitions(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	var dropped []*Partition
+	for _, part := range partitions {
+		if part.End.After(before) {
+			break
+		}
+		if archive {
+			if part.Closed {
+				continue
+			}
+			if err := i.updateAliases(ctx, removeAlias(part.Index, p.Alias)); err != nil {
+				return dropped, err
+			}
+			if _, err := i.client.Indices.Close(part.Index).Do(ctx); err != nil {
+				return dropped, err
+			}
+			logger.Info(fmt.Sprintf("Архивировали партицию %s: документов %d", part.Index, part.Docs))
+		} else {
+			if _, err := i.client.Indices.Delete(part.Index).Do(ctx); err != nil {
+				return dropped, err
+			}
+			logger.Info(fmt.Sprintf("Удалили партицию %s: документов %d", part.Index, part.Docs))
+		}
+		dropped = append(dropped, part)
+	}
+	return dropped, nil
+}
+
+// putTemplate записывает шаблон партиций; active - партиции сразу под алиасом
+func (i *Indices) putTemplate(ctx context.Context, p *PartitionedIndex, active bool) error {
+	hash, err := p.hash()
+	if err != nil {
+		return err
+	}
+	meta, _ := json.Marshal(hash)
+	version, _ := json.Marshal(p.Version)
+
+	template := &types.IndexTemplateMapping{
+		Settings: p.Request.Settings,
+		Mappings: p.Request.Mappings,
+	}
+	if active {
+		template.Aliases = map[string]types.Alias{p.Alias: {}}
+	}
+	_, err = i.client.Indices.PutIndexTemplate(p.Template()).
+		Request(&putindextemplate.Request{
+			IndexPatterns: []string{p.prefix() + "*"},
+			Priority:      lib.PointerFrom(templatePriority),
+			Template:      template,
+			Meta_:         types.Metadata{"hash": meta, "version": version},
+		}).
+		Do(ctx)
+	return err
+}
@@ -0,0 +1,74 @@
+package elastic
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPartition(t *testing.T) {
+	moscow := time.FixedZone("MSK", 3*60*60)
+	// В Москве уже 1 июня, в UTC ещё 31 мая
+	at := time.Date(2024, 6, 1, 1, 0, 0, 0, moscow)
+
+	tests := []struct {
+		period string
+		want   string
+	}{
+		{PeriodMonthly, "articles_v3_2024.05"},
+		{PeriodDaily, "articles_v3_2024.05.31"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.period, func(t *testing.T) {
+			p := &PartitionedIndex{Alias: "articles", Version: 3, Period: tt.period}
+			if got := p.Partition(at); got != tt.want {
+				t.Errorf("Partition() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBounds(t *testing.T) {
+	monthly := &PartitionedIndex{Alias: "articles", Version: 3, Period: PeriodMonthly}
+	daily := &PartitionedIndex{Alias: "articles", Version: 3, Period: PeriodDaily}
+	day := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name       string
+		p          *PartitionedIndex
+		index      string
+		start, end time.Time
+		ok         bool
+	}{
+		{"месяц", monthly, "articles_v3_2024.12", day(2024, 12, 1), day(2025, 1, 1), true},
+		{"день", daily, "articles_v3_2024.02.29", day(2024, 2, 29), day(2024, 3, 1), true},
+		{"другая версия", monthly, "articles_v2_2024.12", time.Time{}, time.Time{}, false},
+		{"не дата", monthly, "articles_v3_backup", time.Time{}, time.Time{}, false},
+		{"день в месячных", monthly, "articles_v3_2024.02.29", time.Time{}, time.Time{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, ok := tt.p.Bounds(tt.index)
+			if ok != tt.ok || (ok && (!start.Equal(tt.start) || !end.Equal(tt.end))) {
+				t.Errorf("Bounds(%s) = %s, %s, %t, want %s, %s, %t", tt.index, start, end, ok, tt.start, tt.end, tt.ok)
+			}
+		})
+	}
+}
+
+// Смена периода - новый шаблон, как и смена маппинга
+func TestPartitionedHash(t *testing.T) {
+	v := testIndex(1, "name")
+	monthly := &PartitionedIndex{Alias: "articles", Version: 1, Period: PeriodMonthly, TimeField: "date", Request: v.Request}
+	daily := *monthly
+	daily.Period = PeriodDaily
+
+	a, _ := monthly.hash()
+	b, _ := daily.hash()
+	if a == b {
+		t.Error("hash() не заметил смену периода")
+	}
+	if err := (&PartitionedIndex{Alias: "articles", Period: "weekly"}).validate(); !errors.Is(err, ErrUnknownPeriod) {
+		t.Errorf("validate() = %v, want ErrUnknownPeriod", err)
+	}
+}
@@ -0,0 +1,205 @@
+package rss
+
+import (
+	"bufio"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Откуда взялся кандидат в ленты, от надёжного к догадкам
+const (
+	ViaLinkAlternate = "link_alternate"
+	ViaAnchor        = "anchor"
+	ViaSitemap       = "sitemap"
+	ViaCommonPath    = "common_path"
+)
+
+// Candidate - адрес, похожий на ленту сайта. Пока не скачан
+type Candidate struct {
+	URL string
+	Via string
+	// title из <link>, если есть
+	Title string
+}
+
+// Пути, где обычно лежат ленты
+var commonFeedPaths = []string{"/rss", "/feed", "/rss.xml", "/feed.xml", "/atom.xml", "/index.xml"}
+
+var feedMimeTypes = map[string]bool{
+	"application/rss+xml":   true,
+	"application/atom+xml":  true,
+	"application/feed+json": true,
+}
+
+// Сколько читаем от страниц сайта
+const maxPageSize = 5 << 20
+
+func (c *client) Discover(ctx context.Context, siteURL string) ([]Candidate, error) {
+	base, err := url.Parse(siteURL)
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return nil, fmt.Errorf("нужен абсолютный http(s) адрес сайта: %q", siteURL)
+	}
+
+	var candidates []Candidate
+	seen := map[string]bool{}
+	add := func(ref, via, title string) {
+		u, err := base.Parse(strings.TrimSpace(ref))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return
+		}
+		u.Fragment = ""
+		if seen[u.String()] {
+			return
+		}
+		seen[u.String()] = true
+		candidates = append(candidates, Candidate{URL: u.String(), Via: via, Title: strings.TrimSpace(title)})
+	}
+
+	// Главная страница: <link rel="alternate"> и ссылки со словами rss/feed/atom
+	page, err := c.get(ctx, siteURL)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := goquery.NewDocumentFromReader(io.LimitReader(page.Body, maxPageSize))
+	closeBody(page, siteURL)
+	if err != nil {
+		return nil, err
+	}
+	// Относительные ссылки - от адреса после редиректов
+	base = page.Request.URL
+
+	doc.Find(`link[rel~="alternate"][href]`).Each(func(_ int, s *goquery.Selection) {
+		if feedMimeTypes[strings.ToLower(strings.TrimSpace(s.AttrOr("type", "")))] {
+			add(s.AttrOr("href", ""), ViaLinkAlternate, s.AttrOr("title", ""))
+		}
+	})
+	doc.Find("a[href]").Each(func(_ int, s *goquery.Selection) {
+		if href := s.AttrOr("href", ""); looksLikeFeed(href) {
+			add(href, ViaAnchor, s.Text())
+		}
+	})
+
+	// Sitemap из robots.txt
+	for _, loc := range c.sitemapFeeds(ctx, base) {
+		add(loc, ViaSitemap, "")
+	}
+
+	for _, path := range commonFeedPaths {
+		add(path, ViaCommonPath, "")
+	}
+	return candidates, nil
+}
+
+// sitemapFeeds - похожие на ленты адреса из sitemap, объявленных в robots.txt.
+// Ошибки не важны: sitemap - только подсказка
+func (c *client) sitemapFeeds(ctx context.Context, base *url.URL) (feeds []string) {
+	robotsURL := base.ResolveReference(&url.URL{Path: "/robots.txt"}).String()
+	robots, err := c.get(ctx, robotsURL)
+	if err != nil {
+		logger.Info("Нет robots.txt: "+robotsURL, zap.Error(err))
+		return nil
+	}
+	var sitemaps []string
+	scanner := bufio.NewScanner(io.LimitReader(robots.Body, maxPageSize))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if name, value, ok := strings.Cut(line, ":"); ok && strings.EqualFold(name, "sitemap") {
+			sitemaps = append(sitemaps, strings.TrimSpace(value))
+		}
+	}
+	closeBody(robots, robotsURL)
+
+	// Больших сайтов с сотней sitemap не обходим целиком
+	const maxSitemaps = 3
+	for i, sitemapURL := range sitemaps {
+		if i == maxSitemaps {
+			break
+		}
+		locs, err := c.sitemapLocs(ctx, sitemapURL)
+		if err != nil {
+			logger.Info("Не прочитали sitemap "+sitemapURL, zap.Error(err))
+			continue
+		}
+		for _, loc := range locs {
+			if looksLikeFeed(loc) {
+				feeds = append(feeds, loc)
+			}
+		}
+	}
+	return feeds
+}
+
+func (c *client) sitemapLocs(ctx context.Context, sitemapURL string) ([]string, error) {
+	resp, err := c.get(ctx, sitemapURL)
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(resp, sitemapURL)
+
+	// <urlset> и <sitemapindex> одинаково хранят адреса в <loc>
+	var sitemap struct {
+		Locs []string `xml:"url>loc"`
+		Maps []string `xml:"sitemap>loc"`
+	}
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, maxPageSize)).Decode(&sitemap); err != nil {
+		return nil, err
+	}
+	return append(sitemap.Locs, sitemap.Maps...), nil
+}
+
+// looksLikeFeed - адрес по виду ведёт на ленту, а не на страницу
+func looksLikeFeed(ref string) bool {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return false
+	}
+	path := strings.ToLower(u.Path)
+	if strings.Contains(path, "sitemap") {
+		return false
+	}
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == '.' || r == '-' || r == '_' }) {
+		switch part {
+		case "rss", "feed", "feeds", "atom", "rdf":
+			return true
+		}
+	}
+	return false
+}
+
+// get - GET страницы сайта с той же вежливостью, что и для лент
+func (c *client) get(ctx context.Context, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.hosts.wait(ctx, req.URL.Host); err != nil {
+		return nil, err
+	}
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		closeBody(resp, rawURL)
+		return nil, errors.New(rawURL + ": " + resp.Status)
+	}
+	return resp, nil
+}
+
+func closeBody(resp *http.Response, rawURL string) {
+	if err := resp.Body.Close(); err != nil {
+		logger.Error("Не закрыли ответ "+rawURL, zap.Error(err))
+	}
+}
@@ -0,0 +1,117 @@
+package rss
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDiscover(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`<html><head>
+<link rel="alternate" type="application/rss+xml" title=" Главное " href="/rss.xml">
+<link rel="alternate" type="text/html" href="/en/">
+<link rel="stylesheet" href="/feed.css">
+</head><body>
+<a href="/news/rss">Лента новостей</a>
+<a href="/rss.xml#top">RSS</a>
+<a href="/about">О нас</a>
+<a href="mailto:rss@example.com">rss</a>
+</body></html>`))
+	})
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("User-agent: *\nDisallow: /admin\nSitemap: http://" + r.Host + "/sitemap.xml\n"))
+	})
+	mux.HandleFunc("/sitemap.xml", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`<?xml version="1.0"?><urlset>
+<url><loc>http://` + r.Host + `/feeds/sport.xml</loc></url>
+<url><loc>http://` + r.Host + `/news/1</loc></url>
+</urlset>`))
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	candidates, err := testClient().Discover(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Candidate{
+		{URL: srv.URL + "/rss.xml", Via: ViaLinkAlternate, Title: "Главное"},
+		{URL: srv.URL + "/news/rss", Via: ViaAnchor, Title: "Лента новостей"},
+		{URL: srv.URL + "/feeds/sport.xml", Via: ViaSitemap},
+		{URL: srv.URL + "/rss", Via: ViaCommonPath},
+		{URL: srv.URL + "/feed", Via: ViaCommonPath},
+		{URL: srv.URL + "/feed.xml", Via: ViaCommonPath},
+		{URL: srv.URL + "/atom.xml", Via: ViaCommonPath},
+		{URL: srv.URL + "/index.xml", Via: ViaCommonPath},
+	}
+	if len(candidates) != len(want) {
+		t.Fatalf("кандидатов %d, want %d: %+v", len(candidates), len(want), candidates)
+	}
+	for i := range want {
+		if candidates[i] != want[i] {
+			t.Errorf("кандидат %d = %+v, want %+v", i, candidates[i], want[i])
+		}
+	}
+}
+
+// Без robots.txt и sitemap остаются ссылки страницы и обычные пути
+func TestDiscoverWithoutRobots(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`<html><body><a href="atom.xml">Atom</a></body></html>`))
+	}))
+	t.Cleanup(srv.Close)
+
+	candidates, err := testClient().Discover(context.Background(), srv.URL+"/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// atom.xml найден ссылкой, среди обычных путей он не повторяется
+	if len(candidates) != len(commonFeedPaths) {
+		t.Fatalf("кандидатов %d, want %d: %+v", len(candidates), len(commonFeedPaths), candidates)
+	}
+	want := Candidate{URL: srv.URL + "/atom.xml", Via: ViaAnchor, Title: "Atom"}
+	if candidates[0] != want {
+		t.Errorf("первый кандидат = %+v, want %+v", candidates[0], want)
+	}
+}
+
+func TestDiscoverErrors(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(srv.Close)
+
+	for _, siteURL := range []string{"example.com", "ftp://example.com", srv.URL} {
+		if _, err := testClient().Discover(context.Background(), siteURL); err == nil {
+			t.Errorf("Discover(%s) без ошибки", siteURL)
+		}
+	}
+}
+
+func TestLooksLikeFeed(t *testing.T) {
+	tests := map[string]bool{
+		"/rss":                  true,
+		"/news/feed/":           true,
+		"/export/rss_all.xml":   true,
+		"https://x.ru/atom.xml": true,
+		"/feeds/sport":          true,
+		"/sitemap-rss.xml":      false,
+		"/rsshub":               false,
+		"/news/1":               false,
+		"/about":                false,
+	}
+	for ref, want := range tests {
+		if got := looksLikeFeed(ref); got != want {
+			t.Errorf("looksLikeFeed(%q) = %t, want %t", ref, got, want)
+		}
+	}
+}
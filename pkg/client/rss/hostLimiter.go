@@ -0,0 +1,80 @@
+package rss
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimitedError - хост попросил подождать (HTTP 429 / Retry-After)
+// или очередь к нему не успевает до дедлайна запроса
+type RateLimitedError struct {
+	Host  string
+	Until time.Time
+}
+
+func (e *RateLimitedError) Error() string {
+	return fmt.Sprintf("хост %s занят до %s", e.Host, e.Until.Format(time.RFC3339))
+}
+
+// hostLimiter - вежливость к хостам: не чаще одного запроса в interval
+// и никаких запросов, пока не истёк Retry-After
+type hostLimiter struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	next map[string]time.Time
+}
+
+func newHostLimiter(interval time.Duration) *hostLimiter {
+	return &hostLimiter{interval: interval, next: map[string]time.Time{}}
+}
+
+// wait ждёт своей очереди к хосту
+func (l *hostLimiter) wait(ctx context.Context, host string) error {
+	l.mu.Lock()
+	now := time.Now()
+	at := l.next[host]
+	if at.Before(now) {
+		at = now
+	}
+	if deadline, ok := ctx.Deadline(); ok && at.After(deadline) {
+		l.mu.Unlock()
+		return &RateLimitedError{Host: host, Until: at}
+	}
+	l.next[host] = at.Add(l.interval)
+	l.mu.Unlock()
+
+	if d := time.Until(at); d > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(d):
+		}
+	}
+	return nil
+}
+
+// backoff откладывает запросы к хосту до until
+func (l *hostLimiter) backoff(host string, until time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until.After(l.next[host]) {
+		l.next[host] = until
+	}
+}
+
+// retryAfter разбирает Retry-After: секунды или HTTP дата
+func retryAfter(h http.Header, now time.Time, fallback time.Duration) time.Time {
+	value := h.Get("Retry-After")
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return now.Add(time.Duration(seconds) * time.Second)
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return at
+	}
+	return now.Add(fallback)
+}
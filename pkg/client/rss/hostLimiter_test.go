@@ -0,0 +1,121 @@
+package rss
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 5, 17, 12, 0, 0, 0, time.UTC)
+	fallback := 10 * time.Minute
+
+	tests := []struct {
+		name  string
+		value string
+		want  time.Time
+	}{
+		{"секунды", "120", now.Add(2 * time.Minute)},
+		{"ноль", "0", now},
+		{"HTTP дата", "Fri, 17 May 2024 13:30:00 GMT", time.Date(2024, 5, 17, 13, 30, 0, 0, time.UTC)},
+		{"нет заголовка", "", now.Add(fallback)},
+		{"отрицательные секунды", "-5", now.Add(fallback)},
+		{"мусор", "soon", now.Add(fallback)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{}
+			if tt.value != "" {
+				h.Set("Retry-After", tt.value)
+			}
+			if got := retryAfter(h, now, fallback); !got.Equal(tt.want) {
+				t.Errorf("retryAfter(%q) = %s, want %s", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHostLimiterInterval(t *testing.T) {
+	const interval = 50 * time.Millisecond
+	l := newHostLimiter(interval)
+	ctx := context.Background()
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := l.wait(ctx, "example.com"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Первый сразу, два следующих - через interval каждый
+	if elapsed := time.Since(start); elapsed < 2*interval {
+		t.Errorf("3 запроса к хосту за %s, want не меньше %s", elapsed, 2*interval)
+	}
+
+	// Другой хост свою очередь не ждёт
+	start = time.Now()
+	if err := l.wait(ctx, "other.com"); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed >= interval {
+		t.Errorf("другой хост ждал %s", elapsed)
+	}
+}
+
+// Очередь к хосту дальше дедлайна запроса - не ждём впустую
+func TestHostLimiterDeadline(t *testing.T) {
+	l := newHostLimiter(0)
+	until := time.Now().Add(time.Hour)
+	l.backoff("example.com", until)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start := time.Now()
+	err := l.wait(ctx, "example.com")
+
+	var rateLimited *RateLimitedError
+	if !errors.As(err, &rateLimited) || !rateLimited.Until.Equal(until) {
+		t.Fatalf("wait() error = %v, want RateLimitedError до %s", err, until)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("wait() ждал %s, want сразу отказ", elapsed)
+	}
+
+	// Более ранний backoff очередь не укорачивает
+	l.backoff("example.com", time.Now().Add(time.Minute))
+	if err := l.wait(ctx, "example.com"); !errors.As(err, &rateLimited) || !rateLimited.Until.Equal(until) {
+		t.Errorf("после раннего backoff wait() error = %v, want до %s", err, until)
+	}
+}
+
+// 429 откладывает хост по Retry-After, следующий запрос к нему не уходит
+func TestFetchRateLimited(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	t.Cleanup(srv.Close)
+	c := testClient()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	res, err := c.Fetch(ctx, srv.URL, Validators{})
+	var rateLimited *RateLimitedError
+	if !errors.As(err, &rateLimited) || time.Until(rateLimited.Until) < 59*time.Minute {
+		t.Fatalf("Fetch() error = %v, want RateLimitedError на час", err)
+	}
+	if res == nil || res.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("Response = %+v, want статус 429", res)
+	}
+
+	if _, err := c.Fetch(ctx, srv.URL, Validators{}); !errors.As(err, &rateLimited) {
+		t.Errorf("повторный Fetch() error = %v, want RateLimitedError", err)
+	}
+	if requests != 1 {
+		t.Errorf("запросов к хосту %d, want 1", requests)
+	}
+}
@@ -0,0 +1,145 @@
+package rss
+
+import (
+	"context"
+	"errors"
+	"github.com/mmcdole/gofeed"
+	"github.com/mskKote/prospero_backend/pkg/config"
+	"github.com/mskKote/prospero_backend/pkg/logging"
+	"go.uber.org/zap"
+	"net/http"
+	"time"
+)
+
+var (
+	logger = logging.GetLogger()
+	cfg    = config.GetConfig()
+
+	// ErrNotModified - лента не изменилась с прошлого запроса (HTTP 304)
+	ErrNotModified = errors.New("лента не изменилась")
+)
+
+// Validators - HTTP валидаторы ленты с прошлого запроса
+type Validators struct {
+	ETag         string
+	LastModified string
+}
+
+// Response - распарсенная лента и её новые валидаторы
+type Response struct {
+	Feed       *gofeed.Feed
+	Validators Validators
+	// HTTP статус последнего ответа
+	StatusCode int
+	// Новый адрес ленты, если все редиректы были постоянными (301/308)
+	PermanentURL string
+}
+
+type Client interface {
+	// Fetch скачивает и парсит ленту.
+	// С непустыми валидаторами запрос условный: если лента не изменилась,
+	// вернётся ErrNotModified и тело не будет скачано.
+	// Если сервер ответил, Response заполнен и вместе с ошибкой
+	Fetch(ctx context.Context, url string, v Validators) (*Response, error)
+
+	// Discover ищет адреса лент сайта: <link rel="alternate">, ссылки,
+	// sitemap из robots.txt и обычные пути вроде /rss. Ленты не скачивает
+	Discover(ctx context.Context, siteURL string) ([]Candidate, error)
+}
+
+// Сколько ждать, если хост ответил 429 без Retry-After
+const defaultRetryAfter = time.Minute
+
+type client struct {
+	http      *http.Client
+	hosts     *hostLimiter
+	userAgent string
+}
+
+func NewClient() Client {
+	return &client{
+		http:      &http.Client{Timeout: 60 * time.Second},
+		hosts:     newHostLimiter(cfg.HarvestHostInterval),
+		userAgent: cfg.HarvestUserAgent,
+	}
+}
+
+func (c *client) Fetch(ctx context.Context, url string, v Validators) (*Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	host := req.URL.Host
+	if err := c.hosts.wait(ctx, host); err != nil {
+		return nil, err
+	}
+
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+	if v.ETag != "" {
+		req.Header.Set("If-None-Match", v.ETag)
+	}
+	if v.LastModified != "" {
+		req.Header.Set("If-Modified-Since", v.LastModified)
+	}
+
+	// Следим, были ли все редиректы постоянными
+	permanent := true
+	hc := *c.http
+	hc.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return errors.New("слишком много редиректов")
+		}
+		if req.Response == nil ||
+			(req.Response.StatusCode != http.StatusMovedPermanently &&
+				req.Response.StatusCode != http.StatusPermanentRedirect) {
+			permanent = false
+		}
+		return nil
+	}
+
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			logger.Error("Не закрыли ответ "+url, zap.Error(err))
+		}
+	}()
+
+	res := &Response{StatusCode: resp.StatusCode}
+	if final := resp.Request.URL.String(); permanent && final != url {
+		res.PermanentURL = final
+	}
+
+	if resp.StatusCode == http.StatusNotModified {
+		return res, ErrNotModified
+	}
+	if resp.StatusCode == http.StatusTooManyRequests ||
+		(resp.StatusCode == http.StatusServiceUnavailable && resp.Header.Get("Retry-After") != "") {
+		until := retryAfter(resp.Header, time.Now(), defaultRetryAfter)
+		c.hosts.backoff(host, until)
+		return res, &RateLimitedError{Host: host, Until: until}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return res, gofeed.HTTPError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+		}
+	}
+
+	// gofeed.Parser хранит состояние, на каждую ленту свой
+	feed, err := gofeed.NewParser().Parse(resp.Body)
+	if err != nil {
+		return res, err
+	}
+
+	res.Feed = feed
+	res.Validators = Validators{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+	return res, nil
+}
@@ -0,0 +1,110 @@
+package rss
+
+import (
+	"context"
+	"errors"
+	"github.com/mmcdole/gofeed"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const testFeed = `<?xml version="1.0"?>
+<rss version="2.0"><channel><title>Тест</title>
+<item><guid>1</guid><title>Новость</title><pubDate>Fri, 17 May 2024 10:00:00 GMT</pubDate></item>
+</channel></rss>`
+
+// testClient - клиент без пауз между запросами к одному хосту
+func testClient() *client {
+	return &client{http: &http.Client{}, hosts: newHostLimiter(0)}
+}
+
+// feedServer отдаёт ленту с ETag и Last-Modified и честно отвечает 304
+func feedServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` ||
+			r.Header.Get("If-Modified-Since") == "Fri, 17 May 2024 10:00:00 GMT" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", "Fri, 17 May 2024 10:00:00 GMT")
+		_, _ = w.Write([]byte(testFeed))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestFetchConditional(t *testing.T) {
+	srv := feedServer(t)
+	c := testClient()
+
+	res, err := c.Fetch(context.Background(), srv.URL, Validators{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Feed.Items) != 1 {
+		t.Errorf("в ленте %d новостей, want 1", len(res.Feed.Items))
+	}
+	want := Validators{ETag: `"v1"`, LastModified: "Fri, 17 May 2024 10:00:00 GMT"}
+	if res.Validators != want {
+		t.Errorf("Validators = %+v, want %+v", res.Validators, want)
+	}
+
+	// Любого из валидаторов достаточно
+	for _, v := range []Validators{want, {ETag: want.ETag}, {LastModified: want.LastModified}} {
+		if _, err := c.Fetch(context.Background(), srv.URL, v); !errors.Is(err, ErrNotModified) {
+			t.Errorf("Fetch(%+v) error = %v, want ErrNotModified", v, err)
+		}
+	}
+}
+
+func TestFetchHTTPError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "нет", http.StatusNotFound)
+	}))
+	t.Cleanup(srv.Close)
+
+	_, err := testClient().Fetch(context.Background(), srv.URL, Validators{})
+	var httpErr gofeed.HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusNotFound {
+		t.Errorf("Fetch() error = %v, want HTTPError 404", err)
+	}
+}
+
+func TestFetchPermanentURL(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/feed.xml", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(testFeed))
+	})
+	mux.Handle("/moved", http.RedirectHandler("/feed.xml", http.StatusMovedPermanently))
+	mux.Handle("/moved-308", http.RedirectHandler("/moved", http.StatusPermanentRedirect))
+	mux.Handle("/temporary", http.RedirectHandler("/feed.xml", http.StatusFound))
+	// Временный редирект в цепочке - адрес не меняем
+	mux.Handle("/mixed", http.RedirectHandler("/temporary", http.StatusMovedPermanently))
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/feed.xml", ""},
+		{"/moved", srv.URL + "/feed.xml"},
+		{"/moved-308", srv.URL + "/feed.xml"},
+		{"/temporary", ""},
+		{"/mixed", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			res, err := testClient().Fetch(context.Background(), srv.URL+tt.path, Validators{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.PermanentURL != tt.want {
+				t.Errorf("PermanentURL = %q, want %q", res.PermanentURL, tt.want)
+			}
+		})
+	}
+}
@@ -5,7 +5,9 @@ import (
 	"github.com/joho/godotenv"
 	"log"
 	"os"
+	"path/filepath"
 	"sync"
+	"time"
 )
 
 // AppConfig - app.yml
@@ -15,11 +17,74 @@ type appConfig struct {
 	Port              string `yaml:"port" env-default:"80"`
 	CronSourcesRSS    string `yaml:"cron_sources_rss"`
 	UseCronSourcesRSS bool   `yaml:"use_cron_sources_rss"`
-	MigratePostgres   bool   `yaml:"migrate_postgres"`
-	MigrateElastic    bool   `yaml:"migrate_elastic"`
-	Metrics           bool   `yaml:"metrics"`
-	UseTracingJaeger  bool   `yaml:"use_tracing_jaeger"`
-	Logger            struct {
+	// Проверять, что адрес источника - лента, при добавлении и изменении
+	ValidateSources bool `yaml:"validate_sources" env-default:"true"`
+	// Файл каталога издателей и лент (YAML или JSON), сверять ли его при старте
+	// и удалять ли записи, которых в каталоге нет
+	CataloguePath      string `yaml:"catalogue_path"`
+	CatalogueOnStartup bool   `yaml:"catalogue_on_startup"`
+	CataloguePrune     bool   `yaml:"catalogue_prune"`
+	// Сколько лент качаем одновременно, как часто ходим на один хост,
+	// сколько ждём одну ленту и как представляемся
+	HarvestConcurrency   int           `yaml:"harvest_concurrency" env-default:"8"`
+	HarvestHostInterval  time.Duration `yaml:"harvest_host_interval" env-default:"1s"`
+	HarvestSourceTimeout time.Duration `yaml:"harvest_source_timeout" env-default:"30s"`
+	HarvestUserAgent     string        `yaml:"harvest_user_agent"`
+	// Насколько глубоко в прошлое смотрим, если источник давно не читали
+	HarvestCatchUp time.Duration `yaml:"harvest_catch_up" env-default:"24h"`
+	// Сколько ошибок подряд до карантина источника и на сколько он уходит
+	HarvestQuarantineAfter      int           `yaml:"harvest_quarantine_after" env-default:"5"`
+	HarvestQuarantineBackoff    time.Duration `yaml:"harvest_quarantine_backoff" env-default:"30m"`
+	HarvestQuarantineMaxBackoff time.Duration `yaml:"harvest_quarantine_max_backoff" env-default:"24h"`
+	// Запись в ES пачками через _bulk
+	BulkSize          int           `yaml:"bulk_size" env-default:"500"`
+	BulkFlushInterval time.Duration `yaml:"bulk_flush_interval" env-default:"1s"`
+	BulkMaxRetries    int           `yaml:"bulk_max_retries" env-default:"3"`
+	BulkRetryDelay    time.Duration `yaml:"bulk_retry_delay" env-default:"500ms"`
+	// Применять ли при старте новые версии схемы из migrations_path
+	MigratePostgres bool   `yaml:"migrate_postgres"`
+	MigrationsPath  string `yaml:"migrations_path" env-default:"resources/migrations"`
+	MigrateElastic  bool   `yaml:"migrate_elastic"`
+	// Переносить ли при старте данные в новую версию индекса ES.
+	// Без этого устаревший индекс только попадает в лог: migrate es-upgrade
+	ElasticAutoUpgrade bool `yaml:"elastic_auto_upgrade" env-default:"true"`
+	// Размер страницы grandFilter по умолчанию и предел, сколько живёт курсор между страницами
+	SearchPageSize    int           `yaml:"search_page_size" env-default:"150"`
+	SearchMaxPageSize int           `yaml:"search_max_page_size" env-default:"500"`
+	SearchKeepAlive   time.Duration `yaml:"search_keep_alive" env-default:"1m"`
+	// Подсветка совпадений в name и description: длина фрагмента, сколько их и теги вокруг
+	SearchHighlight struct {
+		FragmentSize int    `yaml:"fragment_size" env-default:"150"`
+		Fragments    int    `yaml:"fragments" env-default:"3"`
+		PreTag       string `yaml:"pre_tag" env-default:"<mark>"`
+		PostTag      string `yaml:"post_tag" env-default:"</mark>"`
+	} `yaml:"search_highlight"`
+	// Ранжирование sort=hybrid: оценка совпадения затухает с возрастом статьи
+	// (до offset без штрафа, через scale - в decay раз) и умножается на вес издания
+	SearchRanking struct {
+		RecencyScale     time.Duration      `yaml:"recency_scale" env-default:"168h"`
+		RecencyOffset    time.Duration      `yaml:"recency_offset" env-default:"24h"`
+		RecencyDecay     float64            `yaml:"recency_decay" env-default:"0.5"`
+		PublisherWeights map[string]float64 `yaml:"publisher_weights"`
+	} `yaml:"search_ranking"`
+	// Статьи в ES лежат в партициях по дням (daily) или месяцам (monthly)
+	ArticleRollover string `yaml:"article_rollover" env-default:"monthly"`
+	// Сколько дней хранить статьи: по умолчанию, для стран и издателей, 0 - всегда.
+	// delete удаляет устаревшее, archive закрывает партиции и оставляет статьи в postgres.
+	// Пустой cron - очистка только из админки
+	Retention struct {
+		Cron        string `yaml:"cron"`
+		Action      string `yaml:"action" env-default:"delete"`
+		DefaultDays int    `yaml:"default_days"`
+		Rules       []struct {
+			Country   string `yaml:"country"`
+			Publisher string `yaml:"publisher"`
+			Days      int    `yaml:"days"`
+		} `yaml:"rules"`
+	} `yaml:"retention"`
+	Metrics          bool `yaml:"metrics"`
+	UseTracingJaeger bool `yaml:"use_tracing_jaeger"`
+	Logger           struct {
 		ToFile        bool `yaml:"to_file"`
 		ToConsole     bool `yaml:"to_console"`
 		ToELK         bool `yaml:"to_elk"`
@@ -70,14 +135,14 @@ func GetConfig() *Config {
 		instance = &Config{}
 		// app.yml
 		instanceApp := &appConfig{}
-		if err := cleanenv.ReadConfig(configPath, instanceApp); err != nil {
+		if err := cleanenv.ReadConfig(findUp(configPath), instanceApp); err != nil {
 			help, _ := cleanenv.GetDescription(instanceApp, nil)
 			log.Fatalf("cleanenv: {%s}, {%s}", err, help)
 		}
 		instance.appConfig = instanceApp
 
 		// .env
-		if err := godotenv.Load(); err != nil {
+		if err := godotenv.Load(findUp(".env")); err != nil {
 			log.Fatal("Ошибка загрузки .env файла")
 		}
 
@@ -102,6 +167,26 @@ func GetConfig() *Config {
 	return instance
 }
 
+// findUp ищет файл в рабочем каталоге и выше:
+// go test запускает пакет из его каталога, а app.yml и .env лежат в корне
+func findUp(name string) string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return name
+	}
+	for {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return name
+		}
+		dir = parent
+	}
+}
+
 func getEnvKey(key string) string {
 	if value, exists := os.LookupEnv(key); exists {
 		return value
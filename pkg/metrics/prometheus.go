@@ -17,6 +17,9 @@ var (
 )
 
 func GetMetricByName(name string) (*ginPrometheus.Metric, bool) {
+	if prometheus_ == nil {
+		return nil, false
+	}
 	for i := range prometheus_.MetricsList {
 		if prometheus_.MetricsList[i].Name == name {
 			a := prometheus_.MetricsList[i]
@@ -34,6 +37,14 @@ func IncMetric(name string) {
 	}
 }
 
+func AddCounterVecMetric(name string, value float64, labels ...string) {
+	if m, ok := GetMetricByName(name); ok {
+		m.MetricCollector.(*prometheus.CounterVec).WithLabelValues(labels...).Add(value)
+	} else {
+		logger.Error(fmt.Sprintf("Нет метрики [%s]", name))
+	}
+}
+
 //func SetGaugeMetric(name string, value float64) {
 //	if m, ok := GetMetricByName(name); ok {
 //		m.MetricCollector.(prometheus.Gauge).Set(value)
@@ -0,0 +1,136 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"strings"
+	"time"
+)
+
+// fakeDB - Postgres, который помнит schema_migrations и выполненный SQL.
+// Транзакция применяет изменения только при Commit
+type fakeDB struct {
+	applied map[int64]applied
+	// Выполненные запросы по транзакциям: первая строка - блокировка
+	txs [][]string
+	// SQL с этой подстрокой падает
+	failOn string
+	// schema_migrations создана
+	table bool
+}
+
+func newFakeDB() *fakeDB {
+	return &fakeDB{applied: map[int64]applied{}}
+}
+
+func (db *fakeDB) Exec(_ context.Context, sql string, _ ...interface{}) (pgconn.CommandTag, error) {
+	db.txs = append(db.txs, []string{strings.TrimSpace(sql)})
+	return pgconn.CommandTag{}, nil
+}
+
+func (db *fakeDB) Query(context.Context, string, ...interface{}) (pgx.Rows, error) {
+	return newRows(db.applied), nil
+}
+
+func (db *fakeDB) QueryRow(context.Context, string, ...interface{}) pgx.Row {
+	return fakeRow{db.table}
+}
+
+// fakeRow - ответ to_regclass
+type fakeRow struct{ exists bool }
+
+func (r fakeRow) Scan(dest ...any) error {
+	*dest[0].(*bool) = r.exists
+	return nil
+}
+
+func (db *fakeDB) Begin(context.Context) (pgx.Tx, error) {
+	db.txs = append(db.txs, nil)
+	pending := map[int64]applied{}
+	for v, a := range db.applied {
+		pending[v] = a
+	}
+	return &fakeTx{db: db, n: len(db.txs) - 1, pending: pending}, nil
+}
+
+type fakeTx struct {
+	pgx.Tx
+	db      *fakeDB
+	n       int
+	pending map[int64]applied
+	closed  bool
+}
+
+func (tx *fakeTx) Exec(_ context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	sql = strings.TrimSpace(sql)
+	tx.db.txs[tx.n] = append(tx.db.txs[tx.n], sql)
+	if tx.db.failOn != "" && strings.Contains(sql, tx.db.failOn) {
+		return pgconn.CommandTag{}, errors.New("syntax error")
+	}
+	switch {
+	case strings.HasPrefix(sql, "CREATE TABLE IF NOT EXISTS public.schema_migrations"):
+		tx.db.table = true
+	case strings.HasPrefix(sql, "INSERT INTO schema_migrations"):
+		tx.pending[args[0].(int64)] = applied{name: args[1].(string), checksum: args[2].(string), appliedAt: time.Now()}
+	case strings.HasPrefix(sql, "DELETE FROM schema_migrations"):
+		delete(tx.pending, args[0].(int64))
+	}
+	return pgconn.CommandTag{}, nil
+}
+
+func (tx *fakeTx) Query(context.Context, string, ...interface{}) (pgx.Rows, error) {
+	return newRows(tx.pending), nil
+}
+
+func (tx *fakeTx) Commit(context.Context) error {
+	if tx.closed {
+		return pgx.ErrTxClosed
+	}
+	tx.closed = true
+	tx.db.applied = tx.pending
+	return nil
+}
+
+func (tx *fakeTx) Rollback(context.Context) error {
+	if tx.closed {
+		return pgx.ErrTxClosed
+	}
+	tx.closed = true
+	return nil
+}
+
+// fakeRows - строки schema_migrations
+type fakeRows struct {
+	pgx.Rows
+	versions []int64
+	applied  map[int64]applied
+	i        int
+}
+
+func newRows(done map[int64]applied) *fakeRows {
+	r := &fakeRows{applied: done, i: -1}
+	for v := range done {
+		r.versions = append(r.versions, v)
+	}
+	return r
+}
+
+func (r *fakeRows) Next() bool {
+	r.i++
+	return r.i < len(r.versions)
+}
+
+func (r *fakeRows) Scan(dest ...any) error {
+	v := r.versions[r.i]
+	a := r.applied[v]
+	*dest[0].(*int64) = v
+	*dest[1].(*string) = a.name
+	*dest[2].(*string) = a.checksum
+	*dest[3].(*time.Time) = a.appliedAt
+	return nil
+}
+
+func (r *fakeRows) Close()     {}
+func (r *fakeRows) Err() error { return nil }
@@ -0,0 +1,307 @@
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/mskKote/prospero_backend/pkg/client/postgres"
+	"github.com/mskKote/prospero_backend/pkg/logging"
+	"go.uber.org/zap"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+var logger = logging.GetLogger().With(zap.String("prefix", "[MIGRATION]"))
+
+// Ключ advisory lock: одновременно мигрирует только один экземпляр
+const lockKey int64 = 20230517
+
+// Файлы миграций: 0002_articles.up.sql и 0002_articles.down.sql
+var fileName = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Migration - версия схемы из файлов каталога миграций
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// Status - версия схемы и её состояние в базе
+type Status struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+	// Файл изменили после применения
+	Changed bool `json:"changed,omitempty"`
+	// В базе применена, а файла нет
+	Missing bool `json:"missing,omitempty"`
+}
+
+type applied struct {
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+// Migrator применяет версии схемы по одной: каждая - в своей транзакции
+// под pg_advisory_xact_lock, с записью в schema_migrations
+type Migrator struct {
+	client postgres.Client
+	dir    string
+}
+
+func New(client postgres.Client, dir string) *Migrator {
+	return &Migrator{client, dir}
+}
+
+// Load читает миграции из каталога по возрастанию версий
+func (m *Migrator) Load() ([]*Migration, error) {
+	entries, err := os.ReadDir(m.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, e := range entries {
+		match := fileName.FindStringSubmatch(e.Name())
+		if e.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", e.Name(), err)
+		}
+		data, err := os.ReadFile(filepath.Join(m.dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		mg, ok := byVersion[version]
+		if !ok {
+			mg = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = mg
+		} else if mg.Name != match[2] {
+			return nil, fmt.Errorf("у версии %d два названия: %s и %s", version, mg.Name, match[2])
+		}
+		if match[3] == "up" {
+			mg.Up = string(data)
+			sum := sha256.Sum256(data)
+			mg.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			mg.Down = string(data)
+		}
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, mg := range byVersion {
+		if mg.Up == "" {
+			return nil, fmt.Errorf("у версии %d нет %d_%s.up.sql", mg.Version, mg.Version, mg.Name)
+		}
+		migrations = append(migrations, mg)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+func (m *Migrator) Status(ctx context.Context) ([]*Status, error) {
+	migrations, err := m.Load()
+	if err != nil {
+		return nil, err
+	}
+	// Status только читает: таблицу не создаём, иначе CREATE гонится с Up
+	// другого экземпляра, который держит блокировку
+	var exists bool
+	if err := m.client.QueryRow(ctx,
+		`SELECT to_regclass('public.schema_migrations') IS NOT NULL`).Scan(&exists); err != nil {
+		return nil, err
+	}
+	done := map[int64]applied{}
+	if exists {
+		if done, err = appliedVersions(ctx, m.client); err != nil {
+			return nil, err
+		}
+	}
+
+	var statuses []*Status
+	for _, mg := range migrations {
+		s := &Status{Version: mg.Version, Name: mg.Name}
+		if a, ok := done[mg.Version]; ok {
+			s.Applied, s.AppliedAt = true, &a.appliedAt
+			s.Changed = a.checksum != mg.Checksum
+			delete(done, mg.Version)
+		}
+		statuses = append(statuses, s)
+	}
+	for version, a := range done {
+		a := a
+		statuses = append(statuses, &Status{
+			Version: version, Name: a.name, Applied: true, AppliedAt: &a.appliedAt, Missing: true,
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// Up применяет все неприменённые версии по возрастанию.
+// Повторный запуск ничего не делает
+func (m *Migrator) Up(ctx context.Context) (versions []int64, err error) {
+	migrations, err := m.Load()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		mg, err := m.step(ctx, func(done map[int64]applied) (*Migration, string, error) {
+			for _, mg := range migrations {
+				a, ok := done[mg.Version]
+				if !ok {
+					return mg, mg.Up, nil
+				}
+				if a.checksum != mg.Checksum {
+					logger.Warn(fmt.Sprintf("Миграцию %d_%s изменили после применения", mg.Version, mg.Name))
+				}
+			}
+			return nil, "", nil
+		})
+		if err != nil || mg == nil {
+			return versions, err
+		}
+		versions = append(versions, mg.Version)
+	}
+}
+
+// Down откатывает steps последних применённых версий
+func (m *Migrator) Down(ctx context.Context, steps int) (versions []int64, err error) {
+	migrations, err := m.Load()
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int64]*Migration{}
+	for _, mg := range migrations {
+		byVersion[mg.Version] = mg
+	}
+
+	for i := 0; i < steps; i++ {
+		mg, err := m.step(ctx, func(done map[int64]applied) (*Migration, string, error) {
+			last := int64(-1)
+			for version := range done {
+				last = max(last, version)
+			}
+			if last < 0 {
+				return nil, "", nil
+			}
+			mg, ok := byVersion[last]
+			if !ok || mg.Down == "" {
+				return nil, "", fmt.Errorf("нет %d_%s.down.sql, версию %d не откатить", last, done[last].name, last)
+			}
+			return mg, mg.Down, nil
+		})
+		if err != nil || mg == nil {
+			return versions, err
+		}
+		versions = append(versions, mg.Version)
+	}
+	return versions, nil
+}
+
+// step берёт блокировку, выбирает по применённым версиям следующую
+// и выполняет её SQL в той же транзакции
+func (m *Migrator) step(
+	ctx context.Context,
+	next func(done map[int64]applied) (*Migration, string, error)) (*Migration, error) {
+
+	tx, err := m.client.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			logger.Error("Не откатили миграцию", zap.Error(err))
+		}
+	}()
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, lockKey); err != nil {
+		return nil, err
+	}
+	if err := m.ensureTable(ctx, tx); err != nil {
+		return nil, err
+	}
+	// Читаем после блокировки: другой экземпляр мог уже всё применить
+	done, err := appliedVersions(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	mg, sql, err := next(done)
+	if err != nil || mg == nil {
+		return nil, err
+	}
+
+	// Применённую версию откатываем, новую - применяем
+	_, isApplied := done[mg.Version]
+	up := !isApplied
+	direction := "up"
+	if !up {
+		direction = "down"
+	}
+	started := time.Now()
+	if _, err := tx.Exec(ctx, sql); err != nil {
+		return nil, fmt.Errorf("%d_%s.%s.sql: %w", mg.Version, mg.Name, direction, err)
+	}
+
+	if up {
+		_, err = tx.Exec(ctx, `
+			INSERT INTO schema_migrations(version, name, checksum)
+			VALUES ($1, $2, $3)`, mg.Version, mg.Name, mg.Checksum)
+	} else {
+		_, err = tx.Exec(ctx, `DELETE FROM schema_migrations WHERE version = $1`, mg.Version)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	logger.Info(fmt.Sprintf("%d_%s.%s.sql за %s", mg.Version, mg.Name, direction, time.Since(started)))
+	return mg, nil
+}
+
+func (m *Migrator) ensureTable(ctx context.Context, client postgres.Client) error {
+	_, err := client.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS public.schema_migrations
+		(
+			version    BIGINT PRIMARY KEY,
+			name       VARCHAR(255) NOT NULL,
+			checksum   VARCHAR(64)  NOT NULL,
+			applied_at TIMESTAMPTZ  NOT NULL DEFAULT current_timestamp
+		)`)
+	return err
+}
+
+func appliedVersions(ctx context.Context, client postgres.Client) (map[int64]applied, error) {
+	rows, err := client.Query(ctx, `SELECT version, name, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	done := map[int64]applied{}
+	for rows.Next() {
+		var version int64
+		var a applied
+		if err := rows.Scan(&version, &a.name, &a.checksum, &a.appliedAt); err != nil {
+			return nil, err
+		}
+		done[version] = a
+	}
+	return done, rows.Err()
+}
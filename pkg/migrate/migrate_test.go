@@ -0,0 +1,290 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeMigrations(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, sql := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(sql), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoad(t *testing.T) {
+	dir := writeMigrations(t, map[string]string{
+		"0010_tags.up.sql":       "CREATE TABLE tags();",
+		"0002_articles.up.sql":   "CREATE TABLE articles();",
+		"0002_articles.down.sql": "DROP TABLE articles;",
+		"0001_init.up.sql":       "CREATE TABLE admins();",
+		"0001_init.down.sql":     "DROP TABLE admins;",
+		"README.md":              "не миграция",
+		"0003_draft.sql":         "без направления",
+	})
+
+	migrations, err := New(nil, dir).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		version  int64
+		name     string
+		up, down string
+	}{
+		{1, "init", "CREATE TABLE admins();", "DROP TABLE admins;"},
+		{2, "articles", "CREATE TABLE articles();", "DROP TABLE articles;"},
+		{10, "tags", "CREATE TABLE tags();", ""},
+	}
+	if len(migrations) != len(want) {
+		t.Fatalf("Load() вернул %d миграций, want %d", len(migrations), len(want))
+	}
+	for i, w := range want {
+		mg := migrations[i]
+		if mg.Version != w.version || mg.Name != w.name || mg.Up != w.up || mg.Down != w.down {
+			t.Errorf("миграция %d = {%d %s %q %q}, want {%d %s %q %q}",
+				i, mg.Version, mg.Name, mg.Up, mg.Down, w.version, w.name, w.up, w.down)
+		}
+		if mg.Checksum == "" {
+			t.Errorf("у миграции %d_%s нет checksum", mg.Version, mg.Name)
+		}
+	}
+}
+
+func TestLoadChecksum(t *testing.T) {
+	a, err := New(nil, writeMigrations(t, map[string]string{
+		"0001_init.up.sql":   "CREATE TABLE admins();",
+		"0001_init.down.sql": "DROP TABLE admins;",
+	})).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := New(nil, writeMigrations(t, map[string]string{
+		"0001_init.up.sql":   "CREATE TABLE admins();",
+		"0001_init.down.sql": "DROP TABLE IF EXISTS admins;",
+	})).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a[0].Checksum != b[0].Checksum {
+		t.Error("checksum зависит от down.sql, want только от up.sql")
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+	}{
+		{"нет up", map[string]string{
+			"0001_init.up.sql":       "CREATE TABLE admins();",
+			"0002_articles.down.sql": "DROP TABLE articles;",
+		}},
+		{"два названия у версии", map[string]string{
+			"0002_articles.up.sql": "CREATE TABLE articles();",
+			"0002_posts.down.sql":  "DROP TABLE posts;",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(nil, writeMigrations(t, tt.files)).Load(); err == nil {
+				t.Error("Load() без ошибки, want ошибку")
+			}
+		})
+	}
+
+	if _, err := New(nil, filepath.Join(t.TempDir(), "missing")).Load(); err == nil {
+		t.Error("Load() несуществующего каталога без ошибки, want ошибку")
+	}
+}
+
+// Миграции проекта: версии по порядку, у каждой есть откат
+func TestLoadProjectMigrations(t *testing.T) {
+	migrations, err := New(nil, filepath.Join("..", "..", "resources", "migrations")).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, mg := range migrations {
+		if i > 0 && mg.Version <= migrations[i-1].Version {
+			t.Errorf("версия %d после %d", mg.Version, migrations[i-1].Version)
+		}
+		if mg.Down == "" {
+			t.Errorf("у %d_%s нет down.sql", mg.Version, mg.Name)
+		}
+	}
+}
+
+// Выпущенные миграции не меняются: базы, где они применены, получили бы Changed.
+// Исправление схемы - новой версией
+func TestProjectMigrationsUnchanged(t *testing.T) {
+	shipped := map[int64]string{
+		1: "055112c9754bee059815d21f64e7b09dfd1214c5b913c2fdfa1f5f78955a13c5",
+		2: "fb912efa184f9a5e427748728dcc1b52ef1ffe5ca21bf55551ae88d6bbd487bc",
+		3: "87c1e06f8d26551e3f4b637fa07c7cdddb92ca00ea695f3e8399b477e172dfab",
+		4: "cad9e30427dd5f304b4f92f93f2d0f2d96458f4476fc62f688ef74d933c6a1f7",
+	}
+	migrations, err := New(nil, filepath.Join("..", "..", "resources", "migrations")).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mg := range migrations {
+		if sum, ok := shipped[mg.Version]; ok && mg.Checksum != sum {
+			t.Errorf("%d_%s.up.sql изменён после выпуска", mg.Version, mg.Name)
+		}
+	}
+}
+
+func testMigrations(t *testing.T) string {
+	t.Helper()
+	return writeMigrations(t, map[string]string{
+		"0001_init.up.sql":       "CREATE TABLE admins();",
+		"0001_init.down.sql":     "DROP TABLE admins;",
+		"0002_articles.up.sql":   "CREATE TABLE articles();",
+		"0002_articles.down.sql": "DROP TABLE articles;",
+		"0003_tags.up.sql":       "CREATE TABLE tags();",
+	})
+}
+
+// Каждая версия - своя транзакция под блокировкой, повторный Up ничего не делает
+func TestUp(t *testing.T) {
+	db := newFakeDB()
+	m := New(db, testMigrations(t))
+
+	versions, err := m.Up(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(versions) != "[1 2 3]" {
+		t.Errorf("Up() = %v, want [1 2 3]", versions)
+	}
+	for i, tx := range db.txs {
+		if len(tx) == 0 || !strings.Contains(tx[0], "pg_advisory_xact_lock") {
+			t.Errorf("транзакция %d начинается не с блокировки: %q", i, tx)
+		}
+	}
+	if len(db.applied) != 3 {
+		t.Errorf("в schema_migrations %d версий, want 3", len(db.applied))
+	}
+
+	versions, err = m.Up(context.Background())
+	if err != nil || len(versions) != 0 {
+		t.Errorf("повторный Up() = %v, %v, want ничего", versions, err)
+	}
+}
+
+// Упавшая миграция не записывается, следующие не применяются
+func TestUpFailed(t *testing.T) {
+	db := newFakeDB()
+	db.failOn = "CREATE TABLE articles"
+
+	versions, err := New(db, testMigrations(t)).Up(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "2_articles.up.sql") {
+		t.Fatalf("Up() error = %v, want ошибку 2_articles.up.sql", err)
+	}
+	if fmt.Sprint(versions) != "[1]" {
+		t.Errorf("Up() = %v, want [1]", versions)
+	}
+	if _, ok := db.applied[2]; ok || len(db.applied) != 1 {
+		t.Errorf("применены %v, want только 1", db.applied)
+	}
+}
+
+func TestDown(t *testing.T) {
+	db := newFakeDB()
+	m := New(db, testMigrations(t))
+	if _, err := m.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// У 0003 нет down.sql - откатить её нельзя, и дальше не идём
+	if versions, err := m.Down(context.Background(), 2); err == nil || len(versions) != 0 {
+		t.Errorf("Down() = %v, %v, want ошибку про 0003", versions, err)
+	}
+
+	delete(db.applied, 3)
+	versions, err := m.Down(context.Background(), 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(versions) != "[2 1]" || len(db.applied) != 0 {
+		t.Errorf("Down() = %v, осталось %v, want [2 1] и пусто", versions, db.applied)
+	}
+}
+
+// 0004 только догоняет старые базы до 0001_init: откат отказывает
+// и оставляет версию применённой
+func TestDownProjectIrreversible(t *testing.T) {
+	db := newFakeDB()
+	db.failOn = "RAISE EXCEPTION"
+	m := New(db, filepath.Join("..", "..", "resources", "migrations"))
+	if _, err := m.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := db.applied[4]; !ok {
+		t.Fatalf("применены %v, want с 0004", db.applied)
+	}
+
+	versions, err := m.Down(context.Background(), 1)
+	if err == nil || !strings.Contains(err.Error(), "4_sources_rss_upgrade.down.sql") || len(versions) != 0 {
+		t.Errorf("Down() = %v, %v, want отказ 0004", versions, err)
+	}
+	if _, ok := db.applied[4]; !ok {
+		t.Error("0004 откатилась, want осталась применённой")
+	}
+}
+
+func TestStatus(t *testing.T) {
+	db := newFakeDB()
+	m := New(db, testMigrations(t))
+	if _, err := m.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// 0002 изменили после применения, 0009 применена, но файла нет
+	a := db.applied[2]
+	a.checksum = "old"
+	db.applied[2] = a
+	db.applied[9] = applied{name: "removed", checksum: "x", appliedAt: time.Now()}
+
+	statuses, err := m.Status(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, s := range statuses {
+		got = append(got, fmt.Sprintf("%d %t %t %t", s.Version, s.Applied, s.Changed, s.Missing))
+	}
+	want := "[1 true false false 2 true true false 3 true false false 9 true false true]"
+	if fmt.Sprint(got) != want {
+		t.Errorf("Status() = %v, want %s", got, want)
+	}
+}
+
+// На новой базе Status показывает всё неприменённым и ничего не создаёт
+func TestStatusWithoutTable(t *testing.T) {
+	db := newFakeDB()
+	statuses, err := New(db, testMigrations(t)).Status(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 3 {
+		t.Fatalf("Status() = %d версий, want 3", len(statuses))
+	}
+	for _, s := range statuses {
+		if s.Applied {
+			t.Errorf("%d применена, want нет", s.Version)
+		}
+	}
+	if len(db.txs) != 0 || db.table {
+		t.Errorf("выполнено %v, want без записи", db.txs)
+	}
+}
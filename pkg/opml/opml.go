@@ -0,0 +1,91 @@
+package opml
+
+import (
+	"encoding/xml"
+	"errors"
+	"golang.org/x/net/html/charset"
+	"io"
+	"time"
+)
+
+// Тип outline с лентой
+const TypeRSS = "rss"
+
+// OPML 2.0 - список лент, как его понимают читалки
+type OPML struct {
+	XMLName xml.Name `xml:"opml"`
+	Version string   `xml:"version,attr"`
+	Head    Head     `xml:"head"`
+	Body    Body     `xml:"body"`
+}
+
+type Head struct {
+	Title       string `xml:"title,omitempty"`
+	DateCreated string `xml:"dateCreated,omitempty"`
+}
+
+type Body struct {
+	Outlines []*Outline `xml:"outline"`
+}
+
+// Outline - папка или лента (если есть xmlUrl).
+// country, city, latitude, longitude - наши атрибуты для издателей
+type Outline struct {
+	Text      string     `xml:"text,attr"`
+	Title     string     `xml:"title,attr,omitempty"`
+	Type      string     `xml:"type,attr,omitempty"`
+	XMLURL    string     `xml:"xmlUrl,attr,omitempty"`
+	HTMLURL   string     `xml:"htmlUrl,attr,omitempty"`
+	Language  string     `xml:"language,attr,omitempty"`
+	Country   string     `xml:"country,attr,omitempty"`
+	City      string     `xml:"city,attr,omitempty"`
+	Latitude  string     `xml:"latitude,attr,omitempty"`
+	Longitude string     `xml:"longitude,attr,omitempty"`
+	Outlines  []*Outline `xml:"outline"`
+}
+
+// IsFeed - outline указывает на ленту
+func (o *Outline) IsFeed() bool {
+	return o.XMLURL != ""
+}
+
+// Name - подпись outline: text, а если его нет - title
+func (o *Outline) Name() string {
+	if o.Text != "" {
+		return o.Text
+	}
+	return o.Title
+}
+
+func New(title string) *OPML {
+	return &OPML{
+		Version: "2.0",
+		Head: Head{
+			Title:       title,
+			DateCreated: time.Now().UTC().Format(time.RFC1123Z),
+		},
+	}
+}
+
+func Parse(r io.Reader) (*OPML, error) {
+	doc := &OPML{}
+	dec := xml.NewDecoder(r)
+	// Старые читалки выгружают в windows-1251 и latin-1
+	dec.CharsetReader = charset.NewReaderLabel
+	if err := dec.Decode(doc); err != nil {
+		return nil, err
+	}
+	if doc.XMLName.Local != "opml" {
+		return nil, errors.New("это не OPML")
+	}
+	return doc, nil
+}
+
+func (o *OPML) Write(w io.Writer) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(o)
+}
@@ -0,0 +1,75 @@
+package opml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	// Выгрузка старой читалки в windows-1251: «Новости» = cd ee e2 ee f1 f2 e8
+	doc := "<?xml version=\"1.0\" encoding=\"windows-1251\"?>\n" +
+		"<opml version=\"1.0\"><head><title>Feeds</title></head><body>" +
+		"<outline title=\"\xcd\xee\xe2\xee\xf1\xf2\xe8\">" +
+		"<outline text=\"Example\" type=\"rss\" xmlUrl=\"https://example.com/rss\"/>" +
+		"</outline></body></opml>"
+
+	o, err := Parse(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(o.Body.Outlines) != 1 {
+		t.Fatalf("outlines = %d, want 1", len(o.Body.Outlines))
+	}
+	folder := o.Body.Outlines[0]
+	if folder.Name() != "Новости" || folder.IsFeed() {
+		t.Errorf("папка %q, IsFeed %t, want Новости без ленты", folder.Name(), folder.IsFeed())
+	}
+	if feed := folder.Outlines[0]; !feed.IsFeed() || feed.Name() != "Example" {
+		t.Errorf("лента = %+v, want Example с xmlUrl", feed)
+	}
+}
+
+func TestParseNotOPML(t *testing.T) {
+	for _, doc := range []string{
+		`<?xml version="1.0"?><rss version="2.0"><channel></channel></rss>`,
+		`<html><body>не xml`,
+		``,
+	} {
+		if _, err := Parse(strings.NewReader(doc)); err == nil {
+			t.Errorf("Parse(%q) без ошибки", doc)
+		}
+	}
+}
+
+func TestWriteParse(t *testing.T) {
+	doc := New("Prospero sources")
+	doc.Body.Outlines = []*Outline{{
+		Text:    "Россия",
+		Country: "Россия",
+		Outlines: []*Outline{{
+			Text: "Медуза", Type: "publisher", City: "Рига", Latitude: "56.95", Longitude: "24.1",
+			Outlines: []*Outline{{Text: "Главное", Type: TypeRSS, XMLURL: "https://meduza.io/rss/all?a=1&b=2"}},
+		}},
+	}}
+
+	var buf bytes.Buffer
+	if err := doc.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "<?xml") || !strings.Contains(buf.String(), `version="2.0"`) {
+		t.Errorf("нет заголовка или версии:\n%s", buf.String())
+	}
+
+	parsed, err := Parse(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := parsed.Body.Outlines[0].Outlines[0]
+	if p.City != "Рига" || p.Latitude != "56.95" || p.Longitude != "24.1" {
+		t.Errorf("издатель = %+v, want атрибуты сохранены", p)
+	}
+	if feed := p.Outlines[0]; feed.XMLURL != "https://meduza.io/rss/all?a=1&b=2" || feed.Type != TypeRSS {
+		t.Errorf("лента = %+v, want адрес с & без искажений", feed)
+	}
+}
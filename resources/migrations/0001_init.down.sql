@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS public.harvest_run_sources;
+DROP TABLE IF EXISTS public.harvest_runs;
+DROP TABLE IF EXISTS public.sources_rss;
+DROP TABLE IF EXISTS public.publishers;
+DROP TABLE IF EXISTS public.admins;
@@ -0,0 +1,116 @@
+-- Схема на момент перехода на версионные миграции.
+-- IF NOT EXISTS - базы, созданные старым migration_20230517_1.sql, принимают её как есть.
+-- Издатели и ленты - в resources/catalogue.yml, их применяет сверка каталога
+
+DO
+$$
+    BEGIN
+        IF NOT EXISTS (SELECT FROM pg_roles WHERE rolname = 'postgres') THEN
+            CREATE USER postgres SUPERUSER;
+        END IF;
+    END
+$$;
+
+-- adminka users
+CREATE TABLE IF NOT EXISTS public.admins
+(
+    user_id  UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name     VARCHAR(100) NOT NULL UNIQUE,
+    password VARCHAR(100) NOT NULL
+);
+
+-- publishers {1:N} SourcesRSS
+CREATE TABLE IF NOT EXISTS public.publishers
+(
+    publisher_id UUID PRIMARY KEY      DEFAULT gen_random_uuid(),
+    add_date     TIMESTAMPTZ  NOT NULL DEFAULT current_timestamp,
+    name         VARCHAR(100) NOT NULL UNIQUE,
+    country      VARCHAR(100) NOT NULL,
+    city         VARCHAR(100) NOT NULL,
+    point        point        NOT NULL
+);
+-- RSS links and their publishers
+CREATE TABLE IF NOT EXISTS public.sources_rss
+(
+    rss_id       UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    rss_url      VARCHAR(2048) UNIQUE NOT NULL,
+    publisher_id UUID                 NOT NULL,
+    add_date     TIMESTAMPTZ  NOT NULL DEFAULT current_timestamp,
+    -- watermark: докуда источник уже прочитан
+    last_harvest_at TIMESTAMPTZ,
+    last_item_date  TIMESTAMPTZ,
+    last_item_guid  VARCHAR(2048),
+    -- HTTP валидаторы для условного запроса ленты
+    etag            VARCHAR(1024),
+    last_modified   VARCHAR(100),
+    -- здоровье ленты и карантин
+    last_status          VARCHAR(20),
+    consecutive_failures INT NOT NULL DEFAULT 0,
+    last_error           TEXT,
+    last_http_status     INT,
+    last_success_at      TIMESTAMPTZ,
+    quarantined_until    TIMESTAMPTZ,
+    -- что лента сообщила о себе при проверке адреса
+    feed_title          VARCHAR(1024),
+    feed_language       VARCHAR(35),
+    feed_item_count     INT,
+    feed_update_minutes INT,
+    feed_checked_at     TIMESTAMPTZ,
+
+    CONSTRAINT fk_publisher
+        FOREIGN KEY (publisher_id)
+            REFERENCES public.publishers (publisher_id)
+);
+
+-- история парсинга: запуск {1:N} итоги источников
+CREATE TABLE IF NOT EXISTS public.harvest_runs
+(
+    run_id             UUID PRIMARY KEY     DEFAULT gen_random_uuid(),
+    trigger            VARCHAR(20) NOT NULL,
+    full_harvest       BOOLEAN     NOT NULL DEFAULT false,
+    status             VARCHAR(20) NOT NULL,
+    started_at         TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+    finished_at        TIMESTAMPTZ,
+    error              TEXT,
+    sources_total      BIGINT      NOT NULL DEFAULT 0,
+    sources_done       INT         NOT NULL DEFAULT 0,
+    sources_failed     INT         NOT NULL DEFAULT 0,
+    sources_skipped    INT         NOT NULL DEFAULT 0,
+    articles_created   INT         NOT NULL DEFAULT 0,
+    articles_updated   INT         NOT NULL DEFAULT 0,
+    articles_unchanged INT         NOT NULL DEFAULT 0,
+    articles_skipped   INT         NOT NULL DEFAULT 0,
+    articles_failed    INT         NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS harvest_runs_started_at_idx ON public.harvest_runs (started_at DESC);
+
+CREATE TABLE IF NOT EXISTS public.harvest_run_sources
+(
+    run_id             UUID          NOT NULL,
+    -- источник могут удалить, адрес и издатель остаются в истории
+    rss_id             UUID,
+    rss_url            VARCHAR(2048) NOT NULL,
+    publisher_name     VARCHAR(100),
+    status             VARCHAR(20)   NOT NULL,
+    http_status        INT,
+    error              TEXT,
+    items              INT           NOT NULL DEFAULT 0,
+    articles_created   INT           NOT NULL DEFAULT 0,
+    articles_updated   INT           NOT NULL DEFAULT 0,
+    articles_unchanged INT           NOT NULL DEFAULT 0,
+    articles_skipped   INT           NOT NULL DEFAULT 0,
+    articles_failed    INT           NOT NULL DEFAULT 0,
+    started_at         TIMESTAMPTZ   NOT NULL,
+    finished_at        TIMESTAMPTZ   NOT NULL,
+
+    CONSTRAINT fk_run
+        FOREIGN KEY (run_id)
+            REFERENCES public.harvest_runs (run_id)
+            ON DELETE CASCADE,
+    CONSTRAINT fk_source
+        FOREIGN KEY (rss_id)
+            REFERENCES public.sources_rss (rss_id)
+            ON DELETE SET NULL
+);
+CREATE INDEX IF NOT EXISTS harvest_run_sources_run_id_idx ON public.harvest_run_sources (run_id);
+CREATE INDEX IF NOT EXISTS harvest_run_sources_rss_id_idx ON public.harvest_run_sources (rss_id);
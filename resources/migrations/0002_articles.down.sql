@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS public.reindex_runs;
+DROP TABLE IF EXISTS public.article_links;
+DROP TABLE IF EXISTS public.article_people;
+DROP TABLE IF EXISTS public.people;
+DROP TABLE IF EXISTS public.article_categories;
+DROP TABLE IF EXISTS public.categories;
+DROP TABLE IF EXISTS public.articles;
@@ -0,0 +1,83 @@
+-- Статьи - источник истины, индексы ES собираются из них заново (migrate reindex).
+-- Издателя и ленту могут удалить, статья остаётся со снимком адреса и названия
+CREATE TABLE IF NOT EXISTS public.articles
+(
+    article_id     VARCHAR(40) PRIMARY KEY,
+    rss_id         UUID,
+    publisher_id   UUID,
+    publisher_name VARCHAR(100)  NOT NULL,
+    name           TEXT          NOT NULL,
+    description    TEXT          NOT NULL,
+    url            VARCHAR(2048) NOT NULL,
+    country        VARCHAR(100)  NOT NULL,
+    city           VARCHAR(100)  NOT NULL,
+    point          point         NOT NULL,
+    date_published TIMESTAMPTZ,
+    language       VARCHAR(35)   NOT NULL,
+    add_date       TIMESTAMPTZ   NOT NULL DEFAULT current_timestamp,
+    updated_at     TIMESTAMPTZ   NOT NULL DEFAULT current_timestamp,
+
+    CONSTRAINT fk_source
+        FOREIGN KEY (rss_id)
+            REFERENCES public.sources_rss (rss_id)
+            ON DELETE SET NULL,
+    CONSTRAINT fk_publisher
+        FOREIGN KEY (publisher_id)
+            REFERENCES public.publishers (publisher_id)
+            ON DELETE SET NULL
+);
+CREATE INDEX IF NOT EXISTS articles_date_published_idx ON public.articles (date_published DESC);
+CREATE INDEX IF NOT EXISTS articles_rss_id_idx ON public.articles (rss_id);
+
+-- categories {N:M} articles, порядок - как в ленте
+CREATE TABLE IF NOT EXISTS public.categories
+(
+    name TEXT PRIMARY KEY
+);
+CREATE TABLE IF NOT EXISTS public.article_categories
+(
+    article_id VARCHAR(40) NOT NULL REFERENCES public.articles (article_id) ON DELETE CASCADE,
+    name       TEXT        NOT NULL REFERENCES public.categories (name),
+    position   INT         NOT NULL,
+    PRIMARY KEY (article_id, name)
+);
+CREATE INDEX IF NOT EXISTS article_categories_name_idx ON public.article_categories (name);
+
+-- people {N:M} articles
+CREATE TABLE IF NOT EXISTS public.people
+(
+    full_name TEXT PRIMARY KEY
+);
+CREATE TABLE IF NOT EXISTS public.article_people
+(
+    article_id VARCHAR(40) NOT NULL REFERENCES public.articles (article_id) ON DELETE CASCADE,
+    full_name  TEXT        NOT NULL REFERENCES public.people (full_name),
+    position   INT         NOT NULL,
+    PRIMARY KEY (article_id, full_name)
+);
+CREATE INDEX IF NOT EXISTS article_people_full_name_idx ON public.article_people (full_name);
+
+-- ссылки статьи {1:N}
+CREATE TABLE IF NOT EXISTS public.article_links
+(
+    article_id VARCHAR(40) NOT NULL REFERENCES public.articles (article_id) ON DELETE CASCADE,
+    position   INT         NOT NULL,
+    url        TEXT        NOT NULL,
+    PRIMARY KEY (article_id, position)
+);
+
+-- переиндексация ES из postgres: прогресс и место, с которого продолжить
+CREATE TABLE IF NOT EXISTS public.reindex_runs
+(
+    run_id          UUID PRIMARY KEY     DEFAULT gen_random_uuid(),
+    status          VARCHAR(20) NOT NULL,
+    started_at      TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+    finished_at     TIMESTAMPTZ,
+    error           TEXT,
+    articles_total  BIGINT      NOT NULL DEFAULT 0,
+    articles_done   BIGINT      NOT NULL DEFAULT 0,
+    articles_failed BIGINT      NOT NULL DEFAULT 0,
+    last_article_id VARCHAR(40) NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS reindex_runs_started_at_idx ON public.reindex_runs (started_at DESC);
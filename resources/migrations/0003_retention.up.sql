@@ -0,0 +1,19 @@
+-- история очистки устаревших статей: что удалено по каждому правилу хранения
+CREATE TABLE IF NOT EXISTS public.retention_runs
+(
+    run_id      UUID PRIMARY KEY     DEFAULT gen_random_uuid(),
+    trigger     VARCHAR(20) NOT NULL,
+    status      VARCHAR(20) NOT NULL,
+    action      VARCHAR(20) NOT NULL,
+    started_at  TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+    finished_at TIMESTAMPTZ,
+    error       TEXT,
+    -- [{scope, before, documents, articles}]
+    scopes      JSONB       NOT NULL DEFAULT '[]',
+    -- удалённые или закрытые партиции ES
+    indices     TEXT[]      NOT NULL DEFAULT '{}',
+    index_docs  BIGINT      NOT NULL DEFAULT 0,
+    documents   BIGINT      NOT NULL DEFAULT 0,
+    articles    BIGINT      NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS retention_runs_started_at_idx ON public.retention_runs (started_at DESC);
@@ -0,0 +1,7 @@
+-- Колонки принадлежат и 0001_init: на базах, созданных ею, удаление сломало бы
+-- схему, а отличить такие базы от старых нельзя. Откатывать нечего - отказываем
+DO $$
+BEGIN
+    RAISE EXCEPTION '0004_sources_rss_upgrade не откатывается: колонки sources_rss нужны 0001_init';
+END
+$$;
@@ -0,0 +1,24 @@
+-- Базы, созданные старым migration_20230517_1.sql: sources_rss там без колонок
+-- парсинга, а CREATE TABLE IF NOT EXISTS в 0001_init её не трогает.
+-- Отдельной версией - чтобы дошло и до баз, где 0001_init уже записана применённой
+ALTER TABLE public.sources_rss
+    -- watermark: докуда источник уже прочитан
+    ADD COLUMN IF NOT EXISTS last_harvest_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS last_item_date  TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS last_item_guid  VARCHAR(2048),
+    -- HTTP валидаторы для условного запроса ленты
+    ADD COLUMN IF NOT EXISTS etag            VARCHAR(1024),
+    ADD COLUMN IF NOT EXISTS last_modified   VARCHAR(100),
+    -- здоровье ленты и карантин
+    ADD COLUMN IF NOT EXISTS last_status          VARCHAR(20),
+    ADD COLUMN IF NOT EXISTS consecutive_failures INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS last_error           TEXT,
+    ADD COLUMN IF NOT EXISTS last_http_status     INT,
+    ADD COLUMN IF NOT EXISTS last_success_at      TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS quarantined_until    TIMESTAMPTZ,
+    -- что лента сообщила о себе при проверке адреса
+    ADD COLUMN IF NOT EXISTS feed_title          VARCHAR(1024),
+    ADD COLUMN IF NOT EXISTS feed_language       VARCHAR(35),
+    ADD COLUMN IF NOT EXISTS feed_item_count     INT,
+    ADD COLUMN IF NOT EXISTS feed_update_minutes INT,
+    ADD COLUMN IF NOT EXISTS feed_checked_at     TIMESTAMPTZ;